@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/application"
+	"github.com/orlan/go-password-manager/internal/breach"
+	"github.com/orlan/go-password-manager/internal/crypto"
+	httptransport "github.com/orlan/go-password-manager/internal/transport/http"
+	"github.com/orlan/go-password-manager/internal/vault"
+)
+
+const (
+	defaultPort    = "8080"
+	defaultAddr    = "0.0.0.0"
+	defaultWebDir  = "./web"
+	shutdownPeriod = 15 * time.Second
+)
+
+// Config holds the HTTP server configuration
+type Config struct {
+	Addr        string
+	Port        string
+	VaultDir    string
+	WebDir      string
+	EnableTLS   bool
+	TLSCertFile string
+	TLSKeyFile  string
+	// Automatic vault locking; zero disables a limit
+	SessionIdleTimeout time.Duration
+	SessionMaxLifetime time.Duration
+	// Passwords unchanged for longer are reported by the vault audit; zero disables
+	PasswordMaxAge time.Duration
+	// Local Pwned Passwords hash file or range directory; empty disables breach checks
+	BreachFile string
+	// Failed unlocks in a row before a vault or client is locked out; zero disables
+	UnlockMaxAttempts     int
+	UnlockLockoutDuration time.Duration
+}
+
+func main() {
+	config, err := loadConfig()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, config); err != nil {
+		log.Fatalf("Server error: %v", err)
+	}
+}
+
+// loadConfig reads the server configuration from environment variables
+func loadConfig() (*Config, error) {
+	config := &Config{
+		Addr:                  getEnv("SERVER_ADDR", defaultAddr),
+		Port:                  getEnv("SERVER_PORT", getEnv("PORT", defaultPort)),
+		VaultDir:              getEnv("VAULT_DIR", vault.DefaultVaultDir),
+		WebDir:                getEnv("WEB_DIR", defaultWebDir),
+		TLSCertFile:           os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:            os.Getenv("TLS_KEY_FILE"),
+		BreachFile:            os.Getenv("BREACH_FILE"),
+		SessionIdleTimeout:    application.DefaultIdleTimeout,
+		SessionMaxLifetime:    application.DefaultMaxSessionLifetime,
+		PasswordMaxAge:        application.DefaultMaxPasswordAge,
+		UnlockMaxAttempts:     application.DefaultLockoutThreshold,
+		UnlockLockoutDuration: application.DefaultLockoutDuration,
+	}
+
+	if _, err := strconv.ParseUint(config.Port, 10, 16); err != nil {
+		return nil, fmt.Errorf("invalid SERVER_PORT %q: must be a number between 0 and 65535", config.Port)
+	}
+
+	if value := os.Getenv("ENABLE_TLS"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ENABLE_TLS %q: must be true or false", value)
+		}
+		config.EnableTLS = enabled
+	}
+
+	if (config.TLSCertFile == "") != (config.TLSKeyFile == "") {
+		return nil, fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	var err error
+	if config.SessionIdleTimeout, err = getDuration("SESSION_IDLE_TIMEOUT", config.SessionIdleTimeout); err != nil {
+		return nil, err
+	}
+	if config.SessionMaxLifetime, err = getDuration("SESSION_MAX_LIFETIME", config.SessionMaxLifetime); err != nil {
+		return nil, err
+	}
+	if config.PasswordMaxAge, err = getDuration("PASSWORD_MAX_AGE", config.PasswordMaxAge); err != nil {
+		return nil, err
+	}
+	if config.UnlockLockoutDuration, err = getDuration("UNLOCK_LOCKOUT_DURATION", config.UnlockLockoutDuration); err != nil {
+		return nil, err
+	}
+	if value := os.Getenv("UNLOCK_MAX_ATTEMPTS"); value != "" {
+		attempts, err := strconv.Atoi(value)
+		if err != nil || attempts < 0 {
+			return nil, fmt.Errorf("invalid UNLOCK_MAX_ATTEMPTS %q: must be a non-negative number", value)
+		}
+		config.UnlockMaxAttempts = attempts
+	}
+
+	return config, nil
+}
+
+// run wires the application together and serves HTTP until ctx is cancelled
+func run(ctx context.Context, config *Config) error {
+	repo, err := vault.NewFileRepository(config.VaultDir)
+	if err != nil {
+		return fmt.Errorf("failed to create vault repository: %w", err)
+	}
+
+	vaultService := application.NewVaultService(repo, crypto.NewService())
+	defer vaultService.Stop()
+	vaultService.SetSessionPolicy(application.SessionPolicy{
+		IdleTimeout: config.SessionIdleTimeout,
+		MaxLifetime: config.SessionMaxLifetime,
+	})
+	vaultService.SetAuditPolicy(application.AuditPolicy{
+		MaxPasswordAge: config.PasswordMaxAge,
+		MinScore:       application.DefaultMinScore,
+	})
+
+	// Failed unlock counters live next to the vaults and survive restarts
+	unlockPolicy := application.DefaultUnlockPolicy()
+	unlockPolicy.LockoutThreshold = config.UnlockMaxAttempts
+	unlockPolicy.LockoutDuration = config.UnlockLockoutDuration
+	vaultService.SetUnlockPolicy(unlockPolicy)
+	vaultService.SetAttemptStore(repo)
+	vaultService.SetTwoFactorStepStore(repo)
+	vaultService.SetAuditLogStore(repo)
+
+	if config.BreachFile != "" {
+		store, err := breach.Open(config.BreachFile)
+		if err != nil {
+			return fmt.Errorf("failed to open breach list: %w", err)
+		}
+		defer store.Close()
+		vaultService.SetBreachChecker(store)
+		log.Printf("Checking passwords against breach list %s", config.BreachFile)
+	}
+	vaultService.OnSessionLocked(func(event application.SessionEvent) {
+		if event.Reason != application.LockReasonManual {
+			log.Printf("Vault %q locked automatically (%s)", event.VaultName, event.Reason)
+		}
+	})
+
+	handler := httptransport.NewHandler(vaultService)
+
+	mux := http.NewServeMux()
+	handler.RegisterRoutes(mux)
+
+	// Serve the static web frontend when it is available
+	if info, err := os.Stat(config.WebDir); err == nil && info.IsDir() {
+		mux.Handle("/", http.FileServer(http.Dir(config.WebDir)))
+		log.Printf("Serving web frontend from %s", config.WebDir)
+	}
+
+	server := &http.Server{
+		Addr:              net.JoinHostPort(config.Addr, config.Port),
+		Handler:           handler.GetCSRFMiddleware()(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+		IdleTimeout:       120 * time.Second,
+	}
+
+	if config.EnableTLS {
+		tlsConfig, err := buildTLSConfig(config)
+		if err != nil {
+			return err
+		}
+		server.TLSConfig = tlsConfig
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		var err error
+		if config.EnableTLS {
+			log.Printf("Password manager listening on https://%s", server.Addr)
+			// Certificates are already loaded into TLSConfig
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			log.Printf("Password manager listening on http://%s", server.Addr)
+			err = server.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+		close(serverErr)
+	}()
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down server...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownPeriod)
+	defer cancel()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("graceful shutdown failed: %w", err)
+	}
+
+	log.Println("Server stopped")
+	return nil
+}
+
+// buildTLSConfig loads the configured certificate or generates a self-signed one
+func buildTLSConfig(config *Config) (*tls.Config, error) {
+	var (
+		cert tls.Certificate
+		err  error
+	)
+
+	if config.TLSCertFile != "" {
+		cert, err = tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+	} else {
+		log.Println("WARNING: no TLS certificate configured, generating a self-signed certificate")
+		cert, err = generateSelfSignedCert(config.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate self-signed certificate: %w", err)
+		}
+	}
+
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}, nil
+}
+
+// getEnv returns the value of an environment variable or a fallback
+func getEnv(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// getDuration parses a non-negative duration from an environment variable.
+// "0" disables the corresponding limit.
+func getDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration < 0 {
+		return 0, fmt.Errorf("invalid %s %q: expected a duration such as 15m or 8h", key, value)
+	}
+	return duration, nil
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/application"
+	"github.com/orlan/go-password-manager/internal/vault"
+)
+
+// configVariables are the environment variables loadConfig reads
+var configVariables = []string{
+	"SERVER_ADDR", "SERVER_PORT", "PORT", "VAULT_DIR", "WEB_DIR", "ENABLE_TLS",
+	"TLS_CERT_FILE", "TLS_KEY_FILE", "BREACH_FILE", "SESSION_IDLE_TIMEOUT",
+	"SESSION_MAX_LIFETIME", "PASSWORD_MAX_AGE", "UNLOCK_MAX_ATTEMPTS",
+	"UNLOCK_LOCKOUT_DURATION",
+}
+
+// setEnv clears the configuration variables and sets values for the test
+func setEnv(t *testing.T, values map[string]string) {
+	t.Helper()
+
+	for _, key := range configVariables {
+		t.Setenv(key, "")
+	}
+	for key, value := range values {
+		t.Setenv(key, value)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Run("applies defaults", func(t *testing.T) {
+		setEnv(t, nil)
+		config, err := loadConfig()
+		if err != nil {
+			t.Fatalf("loadConfig() failed: %v", err)
+		}
+
+		if config.Addr != defaultAddr || config.Port != defaultPort || config.WebDir != defaultWebDir {
+			t.Errorf("unexpected listen settings: %s:%s, web dir %s", config.Addr, config.Port, config.WebDir)
+		}
+		if config.VaultDir != vault.DefaultVaultDir {
+			t.Errorf("expected VaultDir %s, got %s", vault.DefaultVaultDir, config.VaultDir)
+		}
+		if config.EnableTLS {
+			t.Error("expected TLS to be off by default")
+		}
+		if config.SessionIdleTimeout != application.DefaultIdleTimeout || config.SessionMaxLifetime != application.DefaultMaxSessionLifetime {
+			t.Errorf("unexpected session limits: %v, %v", config.SessionIdleTimeout, config.SessionMaxLifetime)
+		}
+		if config.UnlockMaxAttempts != application.DefaultLockoutThreshold || config.UnlockLockoutDuration != application.DefaultLockoutDuration {
+			t.Errorf("unexpected unlock limits: %d, %v", config.UnlockMaxAttempts, config.UnlockLockoutDuration)
+		}
+	})
+
+	t.Run("parses all variables", func(t *testing.T) {
+		setEnv(t, map[string]string{
+			"SERVER_ADDR":             "127.0.0.1",
+			"PORT":                    "9000",
+			"VAULT_DIR":               "/var/lib/vaults",
+			"ENABLE_TLS":              "true",
+			"TLS_CERT_FILE":           "cert.pem",
+			"TLS_KEY_FILE":            "key.pem",
+			"SESSION_IDLE_TIMEOUT":    "5m",
+			"SESSION_MAX_LIFETIME":    "0",
+			"PASSWORD_MAX_AGE":        "2160h",
+			"UNLOCK_MAX_ATTEMPTS":     "3",
+			"UNLOCK_LOCKOUT_DURATION": "1h",
+		})
+		config, err := loadConfig()
+		if err != nil {
+			t.Fatalf("loadConfig() failed: %v", err)
+		}
+
+		if config.Addr != "127.0.0.1" || config.Port != "9000" || config.VaultDir != "/var/lib/vaults" {
+			t.Errorf("unexpected settings %+v", config)
+		}
+		if !config.EnableTLS || config.TLSCertFile != "cert.pem" || config.TLSKeyFile != "key.pem" {
+			t.Errorf("unexpected TLS settings: %v, %q, %q", config.EnableTLS, config.TLSCertFile, config.TLSKeyFile)
+		}
+		if config.SessionIdleTimeout != 5*time.Minute || config.SessionMaxLifetime != 0 || config.PasswordMaxAge != 2160*time.Hour {
+			t.Errorf("unexpected durations: %v, %v, %v", config.SessionIdleTimeout, config.SessionMaxLifetime, config.PasswordMaxAge)
+		}
+		if config.UnlockMaxAttempts != 3 || config.UnlockLockoutDuration != time.Hour {
+			t.Errorf("unexpected unlock limits: %d, %v", config.UnlockMaxAttempts, config.UnlockLockoutDuration)
+		}
+	})
+
+	t.Run("prefers SERVER_PORT over PORT", func(t *testing.T) {
+		setEnv(t, map[string]string{"SERVER_PORT": "8443", "PORT": "9000"})
+		config, err := loadConfig()
+		if err != nil {
+			t.Fatalf("loadConfig() failed: %v", err)
+		}
+		if config.Port != "8443" {
+			t.Errorf("expected port 8443, got %s", config.Port)
+		}
+	})
+
+	t.Run("reports invalid values", func(t *testing.T) {
+		tests := []struct {
+			key, value string
+			// name is the variable the error names, if not key
+			name string
+		}{
+			{"SERVER_PORT", "http", ""},
+			{"SERVER_PORT", "65536", ""},
+			{"PORT", "-1", "SERVER_PORT"},
+			{"ENABLE_TLS", "maybe", ""},
+			{"TLS_CERT_FILE", "cert.pem", "TLS_KEY_FILE"},
+			{"TLS_KEY_FILE", "key.pem", ""},
+			{"SESSION_IDLE_TIMEOUT", "five minutes", ""},
+			{"SESSION_MAX_LIFETIME", "-1h", ""},
+			{"PASSWORD_MAX_AGE", "90", ""},
+			{"UNLOCK_LOCKOUT_DURATION", "soon", ""},
+			{"UNLOCK_MAX_ATTEMPTS", "-3", ""},
+			{"UNLOCK_MAX_ATTEMPTS", "many", ""},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+				setEnv(t, map[string]string{tt.key: tt.value})
+				_, err := loadConfig()
+				if err == nil {
+					t.Fatalf("expected error for %s=%q", tt.key, tt.value)
+				}
+				name := tt.name
+				if name == "" {
+					name = tt.key
+				}
+				if !strings.Contains(err.Error(), name) {
+					t.Errorf("error for %s should name %s, got %q", tt.key, name, err)
+				}
+			})
+		}
+	})
+}
+
+// writeKeyPair writes cert and its key as PEM files and returns their paths
+func writeKeyPair(t *testing.T, cert tls.Certificate) (certFile, keyFile string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+
+	keyDER, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatalf("failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func TestBuildTLSConfig(t *testing.T) {
+	cert, err := generateSelfSignedCert("vault.example.com")
+	if err != nil {
+		t.Fatalf("generateSelfSignedCert() failed: %v", err)
+	}
+	other, err := generateSelfSignedCert("")
+	if err != nil {
+		t.Fatalf("generateSelfSignedCert() failed: %v", err)
+	}
+
+	certFile, keyFile := writeKeyPair(t, cert)
+	_, otherKeyFile := writeKeyPair(t, other)
+	garbageFile := filepath.Join(t.TempDir(), "garbage.pem")
+	os.WriteFile(garbageFile, []byte("not a certificate"), 0o600)
+
+	tests := []struct {
+		name     string
+		config   Config
+		wantCert []byte
+		wantErr  bool
+	}{
+		{"certificate files", Config{TLSCertFile: certFile, TLSKeyFile: keyFile}, cert.Certificate[0], false},
+		{"self-signed without files", Config{Addr: "127.0.0.1"}, nil, false},
+		{"missing certificate file", Config{TLSCertFile: filepath.Join(t.TempDir(), "missing.pem"), TLSKeyFile: keyFile}, nil, true},
+		{"key of another certificate", Config{TLSCertFile: certFile, TLSKeyFile: otherKeyFile}, nil, true},
+		{"invalid certificate file", Config{TLSCertFile: garbageFile, TLSKeyFile: keyFile}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tlsConfig, err := buildTLSConfig(&tt.config)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				if !strings.Contains(err.Error(), "TLS certificate") {
+					t.Errorf("unexpected error %q", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("buildTLSConfig() failed: %v", err)
+			}
+
+			if tlsConfig.MinVersion != tls.VersionTLS12 {
+				t.Errorf("expected minimum version TLS 1.2, got %#x", tlsConfig.MinVersion)
+			}
+			if len(tlsConfig.Certificates) != 1 {
+				t.Fatalf("expected one certificate, got %d", len(tlsConfig.Certificates))
+			}
+			if tt.wantCert != nil && string(tlsConfig.Certificates[0].Certificate[0]) != string(tt.wantCert) {
+				t.Error("the configured certificate was not loaded")
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"crypto/x509"
+	"strings"
+	"testing"
+)
+
+func TestGenerateSelfSignedCert(t *testing.T) {
+	tests := []struct {
+		addr    string
+		wantDNS []string
+		wantIPs int
+	}{
+		{"", []string{"localhost"}, 2},
+		{"0.0.0.0", []string{"localhost"}, 2},
+		{"192.0.2.10", []string{"localhost"}, 3},
+		{"vault.example.com", []string{"localhost", "vault.example.com"}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			cert, err := generateSelfSignedCert(tt.addr)
+			if err != nil {
+				t.Fatalf("generateSelfSignedCert() failed: %v", err)
+			}
+			parsed, err := x509.ParseCertificate(cert.Certificate[0])
+			if err != nil {
+				t.Fatalf("failed to parse certificate: %v", err)
+			}
+
+			if strings.Join(parsed.DNSNames, ",") != strings.Join(tt.wantDNS, ",") {
+				t.Errorf("expected DNS names %v, got %v", tt.wantDNS, parsed.DNSNames)
+			}
+			if len(parsed.IPAddresses) != tt.wantIPs {
+				t.Errorf("expected %d IP addresses, got %v", tt.wantIPs, parsed.IPAddresses)
+			}
+			if validity := parsed.NotAfter.Sub(parsed.NotBefore); validity < selfSignedValidity {
+				t.Errorf("certificate valid for only %v", validity)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/telegram"
+)
+
+// Default bot settings, kept in sync with docker-compose.yml and .env.example
+const (
+	defaultSessionTTL          = 5 * time.Minute
+	defaultEphemeralMessageTTL = 60 * time.Second
+	defaultRateLimitRequests   = 10
+	defaultRateLimitWindow     = 1 * time.Minute
+	defaultPasswordRetrieval   = 5
+	defaultPasswordWindow      = 1 * time.Minute
+)
+
+// parseConfig builds the bot configuration from an environment lookup function
+func parseConfig(getenv func(string) string) (*telegram.Config, error) {
+	config := &telegram.Config{
+		BotToken: strings.TrimSpace(getenv("TELEGRAM_BOT_TOKEN")),
+	}
+	if config.BotToken == "" {
+		return nil, fmt.Errorf("TELEGRAM_BOT_TOKEN is required")
+	}
+
+	var err error
+	if config.SessionTTL, err = parseDuration(getenv, "SESSION_TTL", defaultSessionTTL); err != nil {
+		return nil, err
+	}
+	if config.EphemeralMessageTTL, err = parseDuration(getenv, "EPHEMERAL_MESSAGE_TTL", defaultEphemeralMessageTTL); err != nil {
+		return nil, err
+	}
+	if config.RateLimitRequests, err = parsePositiveInt(getenv, "RATE_LIMIT_REQUESTS", defaultRateLimitRequests); err != nil {
+		return nil, err
+	}
+	if config.RateLimitWindow, err = parseDuration(getenv, "RATE_LIMIT_WINDOW", defaultRateLimitWindow); err != nil {
+		return nil, err
+	}
+	if config.PasswordRetrievalMax, err = parsePositiveInt(getenv, "PASSWORD_RETRIEVAL_MAX", defaultPasswordRetrieval); err != nil {
+		return nil, err
+	}
+	if config.PasswordRetrievalWin, err = parseDuration(getenv, "PASSWORD_RETRIEVAL_WINDOW", defaultPasswordWindow); err != nil {
+		return nil, err
+	}
+	if config.AllowedUserIDs, err = parseUserIDs(getenv("ALLOWED_USER_IDS")); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// parseDuration reads a positive duration such as "5m" or "60s"
+func parseDuration(getenv func(string) string, key string, fallback time.Duration) (time.Duration, error) {
+	value := strings.TrimSpace(getenv(key))
+	if value == "" {
+		return fallback, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q: expected a duration such as 30s, 5m or 1h", key, value)
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: duration must be positive", key, value)
+	}
+
+	return duration, nil
+}
+
+// parsePositiveInt reads a strictly positive integer
+func parsePositiveInt(getenv func(string) string, key string, fallback int) (int, error) {
+	value := strings.TrimSpace(getenv(key))
+	if value == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid %s %q: expected a positive integer", key, value)
+	}
+
+	return n, nil
+}
+
+// parseUserIDs parses a comma-separated list of Telegram user IDs
+func parseUserIDs(value string) ([]int64, error) {
+	var ids []int64
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		id, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid ALLOWED_USER_IDS entry %q: expected a numeric Telegram user ID", part)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
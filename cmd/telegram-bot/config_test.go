@@ -0,0 +1,104 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func envFrom(values map[string]string) func(string) string {
+	return func(key string) string {
+		return values[key]
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	t.Run("applies defaults", func(t *testing.T) {
+		config, err := parseConfig(envFrom(map[string]string{
+			"TELEGRAM_BOT_TOKEN": "token",
+		}))
+		if err != nil {
+			t.Fatalf("parseConfig() failed: %v", err)
+		}
+
+		if config.SessionTTL != defaultSessionTTL {
+			t.Errorf("expected SessionTTL %v, got %v", defaultSessionTTL, config.SessionTTL)
+		}
+		if config.EphemeralMessageTTL != defaultEphemeralMessageTTL {
+			t.Errorf("expected EphemeralMessageTTL %v, got %v", defaultEphemeralMessageTTL, config.EphemeralMessageTTL)
+		}
+		if config.RateLimitRequests != defaultRateLimitRequests {
+			t.Errorf("expected RateLimitRequests %d, got %d", defaultRateLimitRequests, config.RateLimitRequests)
+		}
+		if len(config.AllowedUserIDs) != 0 {
+			t.Errorf("expected no allowed user IDs, got %v", config.AllowedUserIDs)
+		}
+	})
+
+	t.Run("parses all variables", func(t *testing.T) {
+		config, err := parseConfig(envFrom(map[string]string{
+			"TELEGRAM_BOT_TOKEN":        "token",
+			"SESSION_TTL":               "10m",
+			"EPHEMERAL_MESSAGE_TTL":     "30s",
+			"RATE_LIMIT_REQUESTS":       "20",
+			"RATE_LIMIT_WINDOW":         "2m",
+			"PASSWORD_RETRIEVAL_MAX":    "3",
+			"PASSWORD_RETRIEVAL_WINDOW": "5m",
+			"ALLOWED_USER_IDS":          "123456789, 987654321,",
+		}))
+		if err != nil {
+			t.Fatalf("parseConfig() failed: %v", err)
+		}
+
+		if config.SessionTTL != 10*time.Minute {
+			t.Errorf("expected SessionTTL 10m, got %v", config.SessionTTL)
+		}
+		if config.EphemeralMessageTTL != 30*time.Second {
+			t.Errorf("expected EphemeralMessageTTL 30s, got %v", config.EphemeralMessageTTL)
+		}
+		if config.RateLimitRequests != 20 || config.RateLimitWindow != 2*time.Minute {
+			t.Errorf("unexpected rate limit: %d per %v", config.RateLimitRequests, config.RateLimitWindow)
+		}
+		if config.PasswordRetrievalMax != 3 || config.PasswordRetrievalWin != 5*time.Minute {
+			t.Errorf("unexpected password retrieval limit: %d per %v", config.PasswordRetrievalMax, config.PasswordRetrievalWin)
+		}
+		if len(config.AllowedUserIDs) != 2 || config.AllowedUserIDs[0] != 123456789 || config.AllowedUserIDs[1] != 987654321 {
+			t.Errorf("unexpected allowed user IDs: %v", config.AllowedUserIDs)
+		}
+	})
+
+	t.Run("reports invalid values", func(t *testing.T) {
+		tests := []struct {
+			key, value string
+		}{
+			{"SESSION_TTL", "five minutes"},
+			{"EPHEMERAL_MESSAGE_TTL", "-1s"},
+			{"RATE_LIMIT_REQUESTS", "0"},
+			{"RATE_LIMIT_WINDOW", "1"},
+			{"PASSWORD_RETRIEVAL_MAX", "many"},
+			{"PASSWORD_RETRIEVAL_WINDOW", "0s"},
+			{"ALLOWED_USER_IDS", "123,@someone"},
+		}
+
+		for _, tt := range tests {
+			_, err := parseConfig(envFrom(map[string]string{
+				"TELEGRAM_BOT_TOKEN": "token",
+				tt.key:               tt.value,
+			}))
+			if err == nil {
+				t.Errorf("expected error for %s=%q", tt.key, tt.value)
+				continue
+			}
+			if !strings.Contains(err.Error(), tt.key) {
+				t.Errorf("error for %s should name the variable, got %q", tt.key, err)
+			}
+		}
+	})
+
+	t.Run("requires bot token", func(t *testing.T) {
+		_, err := parseConfig(envFrom(map[string]string{}))
+		if err == nil {
+			t.Error("expected error when TELEGRAM_BOT_TOKEN is missing")
+		}
+	})
+}
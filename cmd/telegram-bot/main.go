@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/orlan/go-password-manager/internal/application"
+	"github.com/orlan/go-password-manager/internal/breach"
+	"github.com/orlan/go-password-manager/internal/crypto"
+	"github.com/orlan/go-password-manager/internal/telegram"
+	"github.com/orlan/go-password-manager/internal/vault"
+)
+
+func main() {
+	config, err := parseConfig(os.Getenv)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, config); err != nil {
+		log.Fatalf("Telegram bot error: %v", err)
+	}
+
+	log.Println("Telegram bot stopped")
+}
+
+// run wires the application together and serves the bot until ctx is
+// cancelled
+func run(ctx context.Context, config *telegram.Config) error {
+	vaultDir := os.Getenv("VAULT_DIR")
+	if vaultDir == "" {
+		vaultDir = vault.DefaultVaultDir
+	}
+
+	repo, err := vault.NewFileRepository(vaultDir)
+	if err != nil {
+		return fmt.Errorf("failed to create vault repository: %w", err)
+	}
+
+	vaultService := application.NewVaultService(repo, crypto.NewService())
+	defer vaultService.Stop()
+	// Share failed unlock counters, used one-time codes and audit logs with
+	// the HTTP server using the same vaults
+	vaultService.SetAttemptStore(repo)
+	vaultService.SetTwoFactorStepStore(repo)
+	vaultService.SetAuditLogStore(repo)
+
+	if breachFile := os.Getenv("BREACH_FILE"); breachFile != "" {
+		store, err := breach.Open(breachFile)
+		if err != nil {
+			return fmt.Errorf("failed to open breach list: %w", err)
+		}
+		defer store.Close()
+		vaultService.SetBreachChecker(store)
+	}
+
+	bot, err := telegram.NewBot(config, vaultService)
+	if err != nil {
+		return fmt.Errorf("failed to start Telegram bot: %w", err)
+	}
+
+	if len(config.AllowedUserIDs) == 0 {
+		log.Println("WARNING: ALLOWED_USER_IDS is empty, any Telegram user can talk to this bot")
+	}
+
+	return bot.Start(ctx)
+}
@@ -1,10 +1,24 @@
 import axios from 'axios';
 import type {
   PasswordRecord,
+  PasswordHistoryEntry,
   CreateVaultRequest,
+  CreateVaultResponse,
   UnlockVaultRequest,
+  TwoFactorSetup,
   AddRecordRequest,
   UpdateRecordRequest,
+  GenerateRequest,
+  GenerateResponse,
+  AuditReport,
+  TOTPResponse,
+  ExportVaultRequest,
+  ImportVaultRequest,
+  ImportResponse,
+  MembersResponse,
+  AddMemberRequest,
+  MemberRole,
+  AuditLogEntry,
 } from '@/app/types';
 
 const API_BASE = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080';
@@ -102,8 +116,9 @@ export const vaultAPI = {
     return response.data.vaults || [];
   },
 
-  create: async (data: CreateVaultRequest): Promise<void> => {
-    await api.post('/vaults/create', data);
+  create: async (data: CreateVaultRequest): Promise<CreateVaultResponse> => {
+    const response = await api.post('/vaults/create', data);
+    return response.data;
   },
 
   unlock: async (data: UnlockVaultRequest): Promise<void> => {
@@ -113,6 +128,65 @@ export const vaultAPI = {
   lock: async (name: string): Promise<void> => {
     await api.post('/vaults/lock', { name });
   },
+
+  audit: async (vaultName: string): Promise<AuditReport> => {
+    const response = await api.get(`/vaults/audit?vault_name=${encodeURIComponent(vaultName)}`);
+    return response.data;
+  },
+
+  twoFactorSetup: async (vaultName: string): Promise<TwoFactorSetup> => {
+    const response = await api.get(`/vaults/two-factor/setup?vault_name=${encodeURIComponent(vaultName)}`);
+    return response.data;
+  },
+
+  enableTwoFactor: async (vaultName: string, secret: string, code: string): Promise<void> => {
+    await api.post('/vaults/two-factor/enable', { vault_name: vaultName, secret, code });
+  },
+
+  disableTwoFactor: async (vaultName: string, code: string): Promise<void> => {
+    await api.post('/vaults/two-factor/disable', { vault_name: vaultName, code });
+  },
+
+  exportVault: async (data: ExportVaultRequest): Promise<Blob> => {
+    const response = await api.post('/vaults/export', data, { responseType: 'blob' });
+    return response.data;
+  },
+
+  importVault: async (data: ImportVaultRequest): Promise<ImportResponse> => {
+    const form = new FormData();
+    form.append('vault_name', data.vault_name);
+    form.append('format', data.format);
+    if (data.password) form.append('password', data.password);
+    if (data.duplicates) form.append('duplicates', data.duplicates);
+    form.append('file', data.file);
+
+    const response = await api.post('/vaults/import', form, {
+      headers: { 'Content-Type': 'multipart/form-data' },
+    });
+    return response.data;
+  },
+
+  members: async (vaultName: string): Promise<MembersResponse> => {
+    const response = await api.get(`/vaults/members?vault_name=${encodeURIComponent(vaultName)}`);
+    return response.data;
+  },
+
+  addMember: async (data: AddMemberRequest): Promise<void> => {
+    await api.post('/vaults/members/add', data);
+  },
+
+  removeMember: async (vaultName: string, member: string): Promise<void> => {
+    await api.post('/vaults/members/remove', { vault_name: vaultName, member });
+  },
+
+  setMemberRole: async (vaultName: string, member: string, role: MemberRole): Promise<void> => {
+    await api.post('/vaults/members/role', { vault_name: vaultName, member, role });
+  },
+
+  auditLog: async (vaultName: string): Promise<AuditLogEntry[]> => {
+    const response = await api.get(`/vaults/audit-log?vault_name=${encodeURIComponent(vaultName)}`);
+    return response.data.entries || [];
+  },
 };
 
 export const recordAPI = {
@@ -141,4 +215,29 @@ export const recordAPI = {
       data: { vault_name: vaultName, name },
     });
   },
+
+  history: async (vaultName: string, name: string): Promise<PasswordHistoryEntry[]> => {
+    const response = await api.get(
+      `/records/history?vault_name=${encodeURIComponent(vaultName)}&name=${encodeURIComponent(name)}`
+    );
+    return response.data.history || [];
+  },
+
+  restore: async (vaultName: string, name: string, version: number): Promise<void> => {
+    await api.post('/records/restore', { vault_name: vaultName, name, version });
+  },
+
+  totp: async (vaultName: string, name: string): Promise<TOTPResponse> => {
+    const response = await api.get(
+      `/records/totp?vault_name=${encodeURIComponent(vaultName)}&name=${encodeURIComponent(name)}`
+    );
+    return response.data;
+  },
+};
+
+export const generatorAPI = {
+  generate: async (data: GenerateRequest = {}): Promise<GenerateResponse> => {
+    const response = await api.post('/generate', data);
+    return response.data;
+  },
 };
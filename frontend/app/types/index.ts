@@ -1,12 +1,60 @@
+export type CustomFieldType = 'text' | 'hidden' | 'totp';
+
+export interface CustomField {
+  name: string;
+  value: string;
+  type: CustomFieldType;
+}
+
+export type ItemType = 'login' | 'secure_note' | 'card' | 'ssh_key' | 'api_credential';
+
+export interface CardData {
+  cardholder?: string;
+  brand?: string;
+  number: string;
+  exp_month?: number;
+  exp_year?: number;
+  cvv?: string;
+}
+
+export interface SSHKeyData {
+  private_key: string;
+  public_key?: string;
+  fingerprint?: string;
+  passphrase?: string;
+}
+
+export interface APICredentialData {
+  key_id?: string;
+  secret: string;
+  endpoint?: string;
+}
+
 export interface PasswordRecord {
   id: string;
   name: string;
   username: string;
   password: string;
+  totp?: string;
+  type?: ItemType;
+  card?: CardData;
+  ssh_key?: SSHKeyData;
+  api_credential?: APICredentialData;
+  urls?: string[];
+  notes?: string;
+  folder?: string;
+  tags?: string[];
+  custom_fields?: CustomField[];
   created_at: string;
   updated_at: string;
 }
 
+export interface PasswordHistoryEntry {
+  version: number;
+  password: string;
+  changed_at: string;
+}
+
 export interface Vault {
   name: string;
   isUnlocked: boolean;
@@ -15,18 +63,181 @@ export interface Vault {
 export interface CreateVaultRequest {
   name: string;
   master_password: string;
+  two_factor?: boolean;
+}
+
+export interface TwoFactorSetup {
+  secret: string;
+  uri: string;
+}
+
+export interface CreateVaultResponse {
+  message: string;
+  two_factor?: TwoFactorSetup;
 }
 
 export interface UnlockVaultRequest {
   name: string;
   master_password: string;
+  totp_code?: string;
+  member?: string;
 }
 
 export interface AddRecordRequest {
   vault_name: string;
+  type?: ItemType;
   name: string;
   username: string;
   password: string;
+  totp?: string;
+  urls?: string[];
+  notes?: string;
+  folder?: string;
+  tags?: string[];
+  custom_fields?: CustomField[];
+  card?: CardData;
+  ssh_key?: SSHKeyData;
+  api_credential?: APICredentialData;
+  generate?: PasswordPolicy;
+}
+
+export interface PasswordPolicy {
+  length?: number;
+  lowercase?: boolean;
+  uppercase?: boolean;
+  digits?: boolean;
+  symbols?: boolean;
+  exclude_ambiguous?: boolean;
+  min_lowercase?: number;
+  min_uppercase?: number;
+  min_digits?: number;
+  min_symbols?: number;
+}
+
+export interface GenerateRequest extends PasswordPolicy {
+  kind?: 'password' | 'passphrase';
+  words?: number;
+  separator?: string;
+  capitalize?: boolean;
+  include_number?: boolean;
+}
+
+export interface GenerateResponse {
+  value: string;
+  entropy_bits: number;
+}
+
+export interface TOTPResponse {
+  code: string;
+  period: number;
+  remaining_seconds: number;
+  expires_at: string;
+}
+
+export type AuditIssue = 'weak' | 'reused' | 'old' | 'breached';
+
+export interface RecordAudit {
+  name: string;
+  score: number;
+  strength: string;
+  warning?: string;
+  reused_with?: string[];
+  breach_count?: number;
+  updated_at: string;
+  issues: AuditIssue[];
+}
+
+export interface AuditReport {
+  vault_name: string;
+  checked_at: string;
+  checked: number;
+  weak: number;
+  reused: number;
+  old: number;
+  breached: number;
+  breach_check: boolean;
+  records: RecordAudit[];
+}
+
+export type ExportFormat = 'encrypted' | 'csv';
+
+export type ImportFormat = 'encrypted' | 'csv' | 'bitwarden' | '1password' | 'keepass';
+
+export type DuplicatePolicy = 'skip' | 'replace' | 'rename';
+
+export interface ExportVaultRequest {
+  vault_name: string;
+  format?: ExportFormat;
+  password?: string;
+  confirm_plaintext?: boolean;
+}
+
+export interface ImportVaultRequest {
+  vault_name: string;
+  format: ImportFormat;
+  file: File;
+  password?: string;
+  duplicates?: DuplicatePolicy;
+}
+
+export interface ImportResponse {
+  message: string;
+  added: number;
+  replaced: number;
+  renamed?: string[];
+  skipped?: string[];
+  problems?: string[];
+}
+
+export type MemberRole = 'owner' | 'editor' | 'viewer';
+
+export interface VaultMember {
+  name: string;
+  role: MemberRole;
+  added_at: string;
+  current: boolean;
+}
+
+export interface MembersResponse {
+  vault_name: string;
+  shared: boolean;
+  members: VaultMember[];
+}
+
+export interface AddMemberRequest {
+  vault_name: string;
+  member: string;
+  password: string;
+  kdf_profile?: string;
+  role?: MemberRole;
+}
+
+export type AuditLogEvent =
+  | 'unlock'
+  | 'unlock_failed'
+  | 'lock'
+  | 'list'
+  | 'get'
+  | 'add'
+  | 'update'
+  | 'delete'
+  | 'import'
+  | 'export';
+
+export interface AuditLogEntry {
+  sequence: number;
+  time: string;
+  event: AuditLogEvent;
+  actor?: string;
+  member?: string;
+  record?: string;
+  detail?: string;
+  verified: boolean;
+}
+
+export interface AuditLogResponse {
+  vault_name: string;
+  entries: AuditLogEntry[];
 }
 
 export interface UpdateRecordRequest {
@@ -34,6 +245,15 @@ export interface UpdateRecordRequest {
   name: string;
   username?: string;
   password?: string;
+  totp?: string;
+  urls?: string[];
+  notes?: string;
+  folder?: string;
+  tags?: string[];
+  custom_fields?: CustomField[];
+  card?: CardData;
+  ssh_key?: SSHKeyData;
+  api_credential?: APICredentialData;
 }
 
 export interface APIResponse<T = any> {
@@ -0,0 +1,258 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// Default brute-force protection: five free attempts, then a delay that
+// doubles with every failure, and a lockout after ten failures in a row
+const (
+	DefaultFreeAttempts     = 5
+	DefaultAttemptDelay     = time.Second
+	DefaultMaxAttemptDelay  = 5 * time.Minute
+	DefaultLockoutThreshold = 10
+	DefaultLockoutDuration  = 15 * time.Minute
+	DefaultAttemptsReset    = 24 * time.Hour
+)
+
+// UnlockPolicy controls how failed unlocks slow down further attempts.
+// Failures are counted per vault and per client, and both have to wait.
+// Checking a master password in ChangeMasterPassword counts as an unlock.
+type UnlockPolicy struct {
+	// FreeAttempts failures in a row are allowed without waiting
+	FreeAttempts int
+	// Delay is the wait after the first failure beyond FreeAttempts; it
+	// doubles with every further failure up to MaxDelay. Zero disables it.
+	Delay    time.Duration
+	MaxDelay time.Duration
+	// LockoutThreshold failures in a row lock out further attempts for
+	// LockoutDuration. Zero disables the lockout.
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+	// ResetAfter forgets failures once the last one is this old
+	ResetAfter time.Duration
+}
+
+// DefaultUnlockPolicy returns the brute-force protection used by new services
+func DefaultUnlockPolicy() UnlockPolicy {
+	return UnlockPolicy{
+		FreeAttempts:     DefaultFreeAttempts,
+		Delay:            DefaultAttemptDelay,
+		MaxDelay:         DefaultMaxAttemptDelay,
+		LockoutThreshold: DefaultLockoutThreshold,
+		LockoutDuration:  DefaultLockoutDuration,
+		ResetAfter:       DefaultAttemptsReset,
+	}
+}
+
+// wait returns how long after now the next attempt has to wait
+func (p UnlockPolicy) wait(attempts domain.UnlockAttempts, now time.Time) time.Duration {
+	var delay time.Duration
+	switch {
+	case p.LockoutThreshold > 0 && attempts.Failures >= p.LockoutThreshold:
+		delay = p.LockoutDuration
+	case p.Delay > 0 && attempts.Failures >= p.FreeAttempts:
+		// Cap the exponent; the delay is far beyond any sane maximum anyway
+		doublings := min(attempts.Failures-p.FreeAttempts, 20)
+		delay = p.Delay << doublings
+		if p.MaxDelay > 0 {
+			delay = min(delay, p.MaxDelay)
+		}
+	}
+	return max(attempts.LastFailure.Add(delay).Sub(now), 0)
+}
+
+// expired reports whether the failures are old enough to be forgotten
+func (p UnlockPolicy) expired(attempts domain.UnlockAttempts, now time.Time) bool {
+	return p.ResetAfter > 0 && now.Sub(attempts.LastFailure) >= p.ResetAfter
+}
+
+// AttemptsError is returned while unlocking has to wait after failed
+// attempts. It wraps domain.ErrTooManyAttempts.
+type AttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *AttemptsError) Error() string {
+	return fmt.Sprintf("%v, try again in %d seconds", domain.ErrTooManyAttempts, e.RetrySeconds())
+}
+
+func (e *AttemptsError) Unwrap() error {
+	return domain.ErrTooManyAttempts
+}
+
+// RetrySeconds is RetryAfter rounded up to whole seconds
+func (e *AttemptsError) RetrySeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+type clientContextKey struct{}
+
+// WithClient returns a context that attributes unlock attempts to client,
+// such as a remote address or a chat user. Attempts without a client are
+// only counted against the vault.
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientContextKey{}, client)
+}
+
+// clientFromContext returns the client set by WithClient
+func clientFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	client, _ := ctx.Value(clientContextKey{}).(string)
+	return client
+}
+
+// SetUnlockPolicy changes the brute-force protection of future unlocks
+func (s *VaultService) SetUnlockPolicy(policy UnlockPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.unlockPolicy = policy
+}
+
+// SetAttemptStore makes failed unlock counters persistent. By default they
+// are kept in memory and reset when the service restarts.
+func (s *VaultService) SetAttemptStore(store domain.AttemptStore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attempts = store
+}
+
+// unlockAttempt is an unlock that has been counted as a failure up front
+type unlockAttempt struct {
+	store domain.AttemptStore
+	keys  []string
+	// previous holds the counters before the attempt, to release it again
+	previous map[string]domain.UnlockAttempts
+	at       time.Time
+}
+
+// beginUnlockAttempt counts an attempt to unlock vaultName as failed before
+// the master password is checked, so that concurrent guesses cannot slip
+// past the limit. It returns an *AttemptsError if the vault or the client
+// has to wait first. The attempt has to be finished with its result.
+// Callers must not hold s.mu.
+func (s *VaultService) beginUnlockAttempt(ctx context.Context, vaultName string) (unlockAttempt, error) {
+	s.mu.RLock()
+	policy := s.unlockPolicy
+	store := s.attempts
+	now := s.now()
+	s.mu.RUnlock()
+
+	attempt := unlockAttempt{
+		store:    store,
+		keys:     []string{"vault:" + vaultName},
+		previous: make(map[string]domain.UnlockAttempts),
+		at:       now,
+	}
+	if client := clientFromContext(ctx); client != "" {
+		attempt.keys = append(attempt.keys, "client:"+client)
+	}
+
+	err := store.UpdateAttempts(ctx, func(attempts map[string]domain.UnlockAttempts) error {
+		for key, counter := range attempts {
+			if policy.expired(counter, now) {
+				delete(attempts, key)
+			}
+		}
+
+		var wait time.Duration
+		for _, key := range attempt.keys {
+			wait = max(wait, policy.wait(attempts[key], now))
+		}
+		if wait > 0 {
+			return &AttemptsError{RetryAfter: wait}
+		}
+
+		for _, key := range attempt.keys {
+			counter := attempts[key]
+			attempt.previous[key] = counter
+			counter.Failures++
+			counter.LastFailure = now
+			attempts[key] = counter
+		}
+		return nil
+	})
+	if err != nil {
+		return unlockAttempt{}, err
+	}
+	return attempt, nil
+}
+
+// finished settles the attempt with the result of the unlock. Success
+// clears the failures of the vault and client, and a wrong password or
+// code stays counted. Any other error says nothing about the password, so
+// the attempt is taken back. The result is already decided, so an error
+// here only leaves the counters too high.
+func (a unlockAttempt) finished(ctx context.Context, err error) {
+	switch {
+	case err == nil:
+		a.store.UpdateAttempts(ctx, func(attempts map[string]domain.UnlockAttempts) error {
+			for _, key := range a.keys {
+				delete(attempts, key)
+			}
+			return nil
+		})
+	case !isCredentialError(err):
+		a.store.UpdateAttempts(ctx, func(attempts map[string]domain.UnlockAttempts) error {
+			for _, key := range a.keys {
+				counter, ok := attempts[key]
+				if !ok {
+					continue
+				}
+				counter.Failures--
+				// Failures counted since then keep their time
+				if counter.LastFailure.Equal(a.at) {
+					counter.LastFailure = a.previous[key].LastFailure
+				}
+				if counter.Failures <= 0 {
+					delete(attempts, key)
+				} else {
+					attempts[key] = counter
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// isCredentialError reports whether err rejects a password or one-time
+// code, which is what unlock attempts are limited by
+func isCredentialError(err error) bool {
+	return err == domain.ErrInvalidMasterPassword || err == domain.ErrInvalidCredentials || err == domain.ErrInvalidTwoFactorCode
+}
+
+// memoryAttemptStore keeps unlock counters for the lifetime of the process
+type memoryAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]domain.UnlockAttempts
+}
+
+func newMemoryAttemptStore() *memoryAttemptStore {
+	return &memoryAttemptStore{attempts: make(map[string]domain.UnlockAttempts)}
+}
+
+// UpdateAttempts implements domain.AttemptStore. fn works on a copy, so a
+// failed update leaves the counters unchanged.
+func (m *memoryAttemptStore) UpdateAttempts(ctx context.Context, fn func(attempts map[string]domain.UnlockAttempts) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempts := maps.Clone(m.attempts)
+	if err := fn(attempts); err != nil {
+		return err
+	}
+
+	m.attempts = attempts
+	return nil
+}
@@ -0,0 +1,220 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/crypto"
+	"github.com/orlan/go-password-manager/internal/domain"
+	"github.com/orlan/go-password-manager/internal/vault"
+)
+
+// testUnlockPolicy allows two free attempts and locks out after four
+var testUnlockPolicy = UnlockPolicy{
+	FreeAttempts:     2,
+	Delay:            time.Second,
+	MaxDelay:         time.Minute,
+	LockoutThreshold: 4,
+	LockoutDuration:  time.Hour,
+	ResetAfter:       24 * time.Hour,
+}
+
+func setupAttemptsTest(t *testing.T, vaults ...string) (*VaultService, time.Time) {
+	t.Helper()
+
+	service, _ := setupTestService(t)
+	service.SetUnlockPolicy(testUnlockPolicy)
+	now := time.Unix(1700000000, 0)
+	setClock(service, now)
+
+	for _, name := range vaults {
+		if err := service.CreateVault(context.Background(), name, "my-password", "interactive"); err != nil {
+			t.Fatalf("CreateVault() failed: %v", err)
+		}
+	}
+	return service, now
+}
+
+// retryAfter returns the wait of an *AttemptsError, failing the test for
+// any other error
+func retryAfter(t *testing.T, err error) time.Duration {
+	t.Helper()
+
+	var attemptsErr *AttemptsError
+	if !errors.As(err, &attemptsErr) || !errors.Is(err, domain.ErrTooManyAttempts) {
+		t.Fatalf("expected an AttemptsError, got %v", err)
+	}
+	return attemptsErr.RetryAfter
+}
+
+func TestUnlockBackoff(t *testing.T) {
+	service, now := setupAttemptsTest(t, "test-vault")
+	ctx := context.Background()
+
+	for range 2 {
+		if _, err := service.UnlockVault(ctx, "test-vault", "wrong"); err != domain.ErrInvalidMasterPassword {
+			t.Fatalf("expected ErrInvalidMasterPassword, got %v", err)
+		}
+	}
+
+	// Even the right password has to wait now
+	_, err := service.UnlockVault(ctx, "test-vault", "my-password")
+	if wait := retryAfter(t, err); wait != time.Second {
+		t.Errorf("expected to wait 1s, got %v", wait)
+	}
+
+	// The delay doubles with every failure
+	now = now.Add(time.Second)
+	setClock(service, now)
+	service.UnlockVault(ctx, "test-vault", "wrong")
+	_, err = service.UnlockVault(ctx, "test-vault", "wrong")
+	if wait := retryAfter(t, err); wait != 2*time.Second {
+		t.Errorf("expected to wait 2s, got %v", wait)
+	}
+
+	// A successful unlock clears the counter
+	now = now.Add(2 * time.Second)
+	setClock(service, now)
+	if _, err := service.UnlockVault(ctx, "test-vault", "my-password"); err != nil {
+		t.Fatalf("UnlockVault() failed: %v", err)
+	}
+	for range 2 {
+		if _, err := service.UnlockVault(ctx, "test-vault", "wrong"); err != domain.ErrInvalidMasterPassword {
+			t.Errorf("expected free attempts after success, got %v", err)
+		}
+	}
+}
+
+func TestUnlockLockout(t *testing.T) {
+	service, now := setupAttemptsTest(t, "test-vault")
+	ctx := context.Background()
+
+	for range testUnlockPolicy.LockoutThreshold {
+		service.UnlockVault(ctx, "test-vault", "wrong")
+		now = now.Add(time.Minute)
+		setClock(service, now)
+	}
+
+	_, err := service.UnlockVault(ctx, "test-vault", "my-password")
+	if wait := retryAfter(t, err); wait != time.Hour-time.Minute {
+		t.Errorf("expected to wait 59m, got %v", wait)
+	}
+
+	// Password changes are locked out as well
+	err = service.ChangeMasterPassword(ctx, "test-vault", "my-password", "new-password", "")
+	retryAfter(t, err)
+
+	// Unknown vaults are not counted
+	setClock(service, now.Add(time.Hour))
+	if _, err := service.UnlockVault(ctx, "missing", "wrong"); err != domain.ErrVaultNotFound {
+		t.Errorf("expected ErrVaultNotFound, got %v", err)
+	}
+	if _, err := service.UnlockVault(ctx, "test-vault", "my-password"); err != nil {
+		t.Errorf("UnlockVault() after lockout failed: %v", err)
+	}
+}
+
+func TestUnlockAttemptsOnlyCountCredentials(t *testing.T) {
+	service, _ := setupAttemptsTest(t, "test-vault")
+	ctx := context.Background()
+
+	service.UnlockVault(ctx, "test-vault", "wrong")
+
+	// Errors before the password is checked are not guesses
+	for range testUnlockPolicy.LockoutThreshold {
+		if err := service.ChangeMasterPassword(ctx, "test-vault", "my-password", "new-password", "unknown"); err == nil {
+			t.Fatal("expected an error for an unknown KDF profile")
+		}
+	}
+
+	// The earlier failure is still counted
+	if _, err := service.UnlockVault(ctx, "test-vault", "wrong"); err != domain.ErrInvalidMasterPassword {
+		t.Fatalf("expected ErrInvalidMasterPassword, got %v", err)
+	}
+	_, err := service.UnlockVault(ctx, "test-vault", "my-password")
+	if wait := retryAfter(t, err); wait != time.Second {
+		t.Errorf("expected to wait 1s, got %v", wait)
+	}
+}
+
+func TestUnlockAttemptsPerClient(t *testing.T) {
+	service, _ := setupAttemptsTest(t, "first", "second")
+	attacker := WithClient(context.Background(), "203.0.113.7")
+	owner := WithClient(context.Background(), "198.51.100.1")
+
+	service.UnlockVault(attacker, "first", "wrong")
+	service.UnlockVault(attacker, "second", "wrong")
+
+	// The attacker used up the free attempts across both vaults
+	_, err := service.UnlockVault(attacker, "second", "my-password")
+	retryAfter(t, err)
+
+	// Each vault only saw one failure, so other clients are not affected
+	if _, err := service.UnlockVault(owner, "first", "my-password"); err != nil {
+		t.Errorf("owner UnlockVault() failed: %v", err)
+	}
+	_, err = service.UnlockVault(attacker, "first", "my-password")
+	retryAfter(t, err)
+}
+
+func TestUnlockAttemptsPersist(t *testing.T) {
+	repo, err := vault.NewFileRepository(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create repository: %v", err)
+	}
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+
+	newService := func() *VaultService {
+		service := NewVaultService(repo, crypto.NewService())
+		t.Cleanup(service.Stop)
+		service.SetUnlockPolicy(testUnlockPolicy)
+		service.SetAttemptStore(repo)
+		setClock(service, now)
+		return service
+	}
+
+	service := newService()
+	service.CreateVault(ctx, "test-vault", "my-password", "interactive")
+	service.UnlockVault(ctx, "test-vault", "wrong")
+	service.UnlockVault(ctx, "test-vault", "wrong")
+
+	// A restarted service still knows about the failures
+	restarted := newService()
+	_, err = restarted.UnlockVault(ctx, "test-vault", "my-password")
+	retryAfter(t, err)
+
+	// Old failures are forgotten
+	setClock(restarted, now.Add(testUnlockPolicy.ResetAfter))
+	if _, err := restarted.UnlockVault(ctx, "test-vault", "my-password"); err != nil {
+		t.Errorf("UnlockVault() failed: %v", err)
+	}
+}
+
+func TestUnlockPolicyWait(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 0},
+		{2, time.Second},
+		{3, 2 * time.Second},
+		{4, time.Hour},
+	}
+	for _, tt := range tests {
+		got := testUnlockPolicy.wait(domain.UnlockAttempts{Failures: tt.failures, LastFailure: now}, now)
+		if got != tt.want {
+			t.Errorf("wait(%d failures) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+
+	capped := UnlockPolicy{FreeAttempts: 0, Delay: time.Second, MaxDelay: 10 * time.Second}
+	if got := capped.wait(domain.UnlockAttempts{Failures: 50, LastFailure: now}, now); got != 10*time.Second {
+		t.Errorf("expected delay capped at 10s, got %v", got)
+	}
+}
@@ -0,0 +1,195 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+	"github.com/orlan/go-password-manager/internal/strength"
+)
+
+// Default audit thresholds
+const (
+	DefaultMaxPasswordAge = 365 * 24 * time.Hour
+	DefaultMinScore       = 3
+)
+
+// AuditPolicy controls which passwords AuditVault flags
+type AuditPolicy struct {
+	// MaxPasswordAge flags passwords unchanged for longer; zero disables the check
+	MaxPasswordAge time.Duration
+	// MinScore flags passwords whose strength score is lower
+	MinScore int
+}
+
+// DefaultAuditPolicy returns the audit thresholds used by NewVaultService
+func DefaultAuditPolicy() AuditPolicy {
+	return AuditPolicy{
+		MaxPasswordAge: DefaultMaxPasswordAge,
+		MinScore:       DefaultMinScore,
+	}
+}
+
+// AuditIssue names a problem found with a record's password
+type AuditIssue string
+
+const (
+	// AuditIssueWeak means the password is easy to guess
+	AuditIssueWeak AuditIssue = "weak"
+	// AuditIssueReused means another record has the same password
+	AuditIssueReused AuditIssue = "reused"
+	// AuditIssueOld means the password has not been changed for a long time
+	AuditIssueOld AuditIssue = "old"
+	// AuditIssueBreached means the password appears in known data breaches
+	AuditIssueBreached AuditIssue = "breached"
+)
+
+// RecordAudit describes the password of a single record. It never
+// contains the password itself. BreachCount is how often the password
+// appears in known data breaches.
+type RecordAudit struct {
+	Name        string       `json:"name"`
+	Score       int          `json:"score"`
+	Strength    string       `json:"strength"`
+	Warning     string       `json:"warning,omitempty"`
+	ReusedWith  []string     `json:"reused_with,omitempty"`
+	BreachCount int          `json:"breach_count,omitempty"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	Issues      []AuditIssue `json:"issues"`
+}
+
+// AuditReport is the health report of a vault. Records lists every
+// audited record, those with issues first. BreachCheck is false when no
+// breach list is configured.
+type AuditReport struct {
+	VaultName   string        `json:"vault_name"`
+	CheckedAt   time.Time     `json:"checked_at"`
+	Checked     int           `json:"checked"`
+	Weak        int           `json:"weak"`
+	Reused      int           `json:"reused"`
+	Old         int           `json:"old"`
+	Breached    int           `json:"breached"`
+	BreachCheck bool          `json:"breach_check"`
+	Records     []RecordAudit `json:"records"`
+}
+
+// SetAuditPolicy changes the thresholds used by AuditVault
+func (s *VaultService) SetAuditPolicy(policy AuditPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.auditPolicy = policy
+}
+
+// AuditVault checks the strength, reuse and age of every login password in
+// the vault, and whether it appears in the breach list when one is set.
+// Items without a password, such as notes and cards, are skipped.
+func (s *VaultService) AuditVault(ctx context.Context, token, vaultName string) (AuditReport, error) {
+	s.mu.Lock()
+	defer s.unlock()
+
+	sess, err := s.getSession(token, vaultName)
+	if err != nil {
+		return AuditReport{}, err
+	}
+
+	// Pick up changes saved by other processes
+	if err := s.refreshVault(ctx, sess); err != nil {
+		return AuditReport{}, err
+	}
+
+	// The report tells which passwords are weak or shared
+	if err := s.logEvent(ctx, sess, domain.AuditLogEntry{Event: domain.AuditLogList, Detail: "password audit"}); err != nil {
+		return AuditReport{}, fmt.Errorf("failed to write audit log: %w", err)
+	}
+
+	return auditRecords(vaultName, sess.vault.Records, s.auditPolicy, s.breaches, s.now())
+}
+
+// auditRecords builds the report for records as of now. breaches may be
+// nil to skip the breach check.
+func auditRecords(vaultName string, records []domain.PasswordRecord, policy AuditPolicy, breaches domain.BreachChecker, now time.Time) (AuditReport, error) {
+	report := AuditReport{
+		VaultName:   vaultName,
+		CheckedAt:   now,
+		BreachCheck: breaches != nil,
+		Records:     []RecordAudit{},
+	}
+
+	byPassword := make(map[string][]string)
+	for _, record := range records {
+		if record.Kind() == domain.ItemTypeLogin && record.Password != "" {
+			byPassword[record.Password] = append(byPassword[record.Password], record.Name)
+		}
+	}
+
+	// Look up each distinct password once
+	breachCounts := make(map[string]int)
+	if breaches != nil {
+		for password := range byPassword {
+			count, err := breaches.Count(password)
+			if err != nil {
+				return AuditReport{}, err
+			}
+			breachCounts[password] = count
+		}
+	}
+
+	for _, record := range records {
+		if record.Kind() != domain.ItemTypeLogin || record.Password == "" {
+			continue
+		}
+
+		result := strength.Estimate(record.Password, record.Name, record.Username)
+		audit := RecordAudit{
+			Name:      record.Name,
+			Score:     result.Score,
+			Strength:  result.Label(),
+			Warning:   result.Warning,
+			UpdatedAt: record.UpdatedAt,
+			Issues:    []AuditIssue{},
+		}
+
+		if result.Score < policy.MinScore {
+			audit.Issues = append(audit.Issues, AuditIssueWeak)
+			report.Weak++
+		}
+		for _, name := range byPassword[record.Password] {
+			if name != record.Name {
+				audit.ReusedWith = append(audit.ReusedWith, name)
+			}
+		}
+		if len(audit.ReusedWith) > 0 {
+			audit.Issues = append(audit.Issues, AuditIssueReused)
+			report.Reused++
+		}
+		if policy.MaxPasswordAge > 0 && now.Sub(record.UpdatedAt) > policy.MaxPasswordAge {
+			audit.Issues = append(audit.Issues, AuditIssueOld)
+			report.Old++
+		}
+		if count := breachCounts[record.Password]; count > 0 {
+			audit.BreachCount = count
+			audit.Issues = append(audit.Issues, AuditIssueBreached)
+			report.Breached++
+		}
+
+		report.Records = append(report.Records, audit)
+		report.Checked++
+	}
+
+	// Most issues first, then weakest, then by name
+	slices.SortStableFunc(report.Records, func(a, b RecordAudit) int {
+		if len(a.Issues) != len(b.Issues) {
+			return len(b.Issues) - len(a.Issues)
+		}
+		if a.Score != b.Score {
+			return a.Score - b.Score
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return report, nil
+}
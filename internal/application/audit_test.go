@@ -0,0 +1,124 @@
+package application
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+func TestAuditVault(t *testing.T) {
+	t.Run("flags weak and reused passwords", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		strong := "hJ4&kq9!Xz2@Lw"
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: strong})
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "gitlab", Username: "user", Password: strong})
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "bank", Username: "user", Password: "password1"})
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "email", Username: "user", Password: "vT8#pL2!qR6$wZ"})
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Type: domain.ItemTypeSecureNote, Name: "wifi", Notes: "password"})
+
+		report, err := service.AuditVault(ctx, token, "test-vault")
+		if err != nil {
+			t.Fatalf("AuditVault() failed: %v", err)
+		}
+
+		if report.VaultName != "test-vault" || report.CheckedAt.IsZero() {
+			t.Errorf("unexpected report header %+v", report)
+		}
+		if report.Checked != 4 {
+			t.Errorf("expected 4 checked records, got %d", report.Checked)
+		}
+		if report.Weak != 1 || report.Reused != 2 || report.Old != 0 {
+			t.Errorf("unexpected counts weak=%d reused=%d old=%d", report.Weak, report.Reused, report.Old)
+		}
+
+		byName := make(map[string]RecordAudit)
+		for _, audit := range report.Records {
+			byName[audit.Name] = audit
+		}
+		if !slices.Equal(byName["bank"].Issues, []AuditIssue{AuditIssueWeak}) {
+			t.Errorf("unexpected issues for bank: %v", byName["bank"].Issues)
+		}
+		if byName["bank"].Warning == "" {
+			t.Error("weak password should have a warning")
+		}
+		if !slices.Equal(byName["github"].ReusedWith, []string{"gitlab"}) {
+			t.Errorf("unexpected reuse for github: %v", byName["github"].ReusedWith)
+		}
+		if len(byName["email"].Issues) != 0 {
+			t.Errorf("unexpected issues for email: %v", byName["email"].Issues)
+		}
+		if _, ok := byName["wifi"]; ok {
+			t.Error("items without a password should not be audited")
+		}
+	})
+
+	t.Run("flags old passwords", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "hJ4&kq9!Xz2@Lw"})
+		service.SetAuditPolicy(AuditPolicy{MaxPasswordAge: time.Nanosecond, MinScore: DefaultMinScore})
+		time.Sleep(time.Millisecond)
+
+		report, err := service.AuditVault(ctx, token, "test-vault")
+		if err != nil {
+			t.Fatalf("AuditVault() failed: %v", err)
+		}
+		if report.Old != 1 || !slices.Equal(report.Records[0].Issues, []AuditIssue{AuditIssueOld}) {
+			t.Errorf("expected the password to be flagged as old, got %+v", report)
+		}
+	})
+
+	t.Run("never includes passwords", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "Sup3rSecretValue"})
+
+		report, _ := service.AuditVault(ctx, token, "test-vault")
+		for _, audit := range report.Records {
+			for _, text := range append([]string{audit.Name, audit.Warning}, audit.ReusedWith...) {
+				if strings.Contains(text, "Sup3rSecretValue") {
+					t.Fatalf("report contains the password: %+v", audit)
+				}
+			}
+		}
+	})
+
+	t.Run("requires a session", func(t *testing.T) {
+		service, _ := setupRecordTest(t)
+
+		_, err := service.AuditVault(context.Background(), "invalid-token", "test-vault")
+		if err != domain.ErrInvalidSession {
+			t.Errorf("expected ErrInvalidSession, got %v", err)
+		}
+	})
+}
+
+func TestAuditRecordsOrder(t *testing.T) {
+	now := time.Now()
+	records := []domain.PasswordRecord{
+		{Name: "b-strong", Password: "hJ4&kq9!Xz2@Lw", UpdatedAt: now},
+		{Name: "a-weak", Password: "123456", UpdatedAt: now},
+		{Name: "c-weak-old", Password: "qwerty", UpdatedAt: now.Add(-2 * DefaultMaxPasswordAge)},
+	}
+
+	report, err := auditRecords("test-vault", records, DefaultAuditPolicy(), nil, now)
+	if err != nil {
+		t.Fatalf("auditRecords() failed: %v", err)
+	}
+
+	var names []string
+	for _, audit := range report.Records {
+		names = append(names, audit.Name)
+	}
+	if !slices.Equal(names, []string{"c-weak-old", "a-weak", "b-strong"}) {
+		t.Errorf("unexpected order %v", names)
+	}
+}
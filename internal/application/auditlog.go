@@ -0,0 +1,368 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// AuditLogEntry is an entry of a vault's audit log
+type AuditLogEntry struct {
+	domain.AuditLogEntry
+	// Verified is set when the entry is covered by a MAC written with the
+	// vault's audit log key. Entries after the last such MAC, such as failed
+	// unlocks, could have been appended by anyone able to write the log.
+	Verified bool `json:"verified"`
+}
+
+// SetAuditLogStore makes audit logs persistent. By default they are kept
+// in memory and lost when the service restarts.
+func (s *VaultService) SetAuditLogStore(store domain.AuditLogStore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.auditLogs = store
+}
+
+// AuditLog returns the audit log of a vault, oldest entry first. The hash
+// chain and MACs of the log are checked, as are its head and the last
+// record the vault knows of, and domain.ErrAuditLogTampered is returned if
+// any of them does not match. In a shared vault only owners can read the
+// log.
+func (s *VaultService) AuditLog(ctx context.Context, token, vaultName string) ([]AuditLogEntry, error) {
+	s.mu.Lock()
+	defer s.unlock()
+
+	sess, err := s.getSession(token, vaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.authorize(ctx, sess, domain.RoleOwner); err != nil {
+		return nil, err
+	}
+	if sess.vault.AuditLog == nil {
+		return nil, errNoAuditLogKey
+	}
+
+	records, err := s.auditLogs.LoadAuditLog(ctx, vaultName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load audit log: %w", err)
+	}
+	head, err := s.auditLogs.LoadAuditLogHead(ctx, vaultName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load audit log: %w", err)
+	}
+	return s.openAuditLog(records, head, sess.vault.AuditLog)
+}
+
+// errNoAuditLogKey means a session's vault has no audit log key, although
+// vaults in the current format always have one
+var errNoAuditLogKey = errors.New("vault has no audit log key")
+
+// openAuditLog checks the hash chain and MACs of records and decrypts them.
+// The records must reach head, the last record written with the vault
+// unlocked, and the last record the vault knows of in key. Records cut off
+// the end of the log, or a deleted log, fail the check.
+//
+// Each record counts as verified only with the key of its segment. A MAC
+// written after the key was replaced with one of the keys before it comes
+// from a process that had not seen the change yet, or from a removed
+// member, and does not verify the records it covers.
+func (s *VaultService) openAuditLog(records []domain.AuditLogRecord, head *domain.AuditLogRecord, key *domain.AuditLogKey) ([]AuditLogEntry, error) {
+	keys := append(slices.Clone(key.Previous), *key)
+	if head != nil {
+		if macKey(s.crypto, keys, *head) < 0 || !reachesRecord(records, head.Sequence, head.Hash) {
+			return nil, domain.ErrAuditLogTampered
+		}
+	} else if slices.ContainsFunc(records, func(record domain.AuditLogRecord) bool { return record.MAC != nil }) {
+		// Every record with a MAC is written along with the head
+		return nil, domain.ErrAuditLogTampered
+	}
+	if key.Sequence > 0 && !reachesRecord(records, key.Sequence, key.Hash) {
+		return nil, domain.ErrAuditLogTampered
+	}
+
+	entries := make([]AuditLogEntry, len(records))
+	verified := 0
+	var previous []byte
+	for i, record := range records {
+		if record.Sequence != uint64(i+1) || !bytes.Equal(record.Hash, chainHash(previous, record.Sequence, record.Entry)) {
+			return nil, domain.ErrAuditLogTampered
+		}
+		if record.MAC != nil {
+			k := macKey(s.crypto, keys, record)
+			if k < 0 {
+				return nil, domain.ErrAuditLogTampered
+			}
+			if k == segmentKey(keys, record.Sequence) {
+				// The MAC covers the hash, and the hash every record before it
+				verified = i + 1
+			}
+		}
+
+		data, err := openAuditLogEntry(s.crypto, keys, record)
+		if err != nil {
+			return nil, domain.ErrAuditLogTampered
+		}
+		if err := json.Unmarshal(data, &entries[i].AuditLogEntry); err != nil || entries[i].Sequence != record.Sequence {
+			return nil, domain.ErrAuditLogTampered
+		}
+		previous = record.Hash
+	}
+
+	for i := range verified {
+		entries[i].Verified = true
+	}
+	return entries, nil
+}
+
+// segmentKey returns the index in keys of the key records with sequence
+// are written with
+func segmentKey(keys []domain.AuditLogKey, sequence uint64) int {
+	for i := len(keys) - 1; i > 0; i-- {
+		if keys[i].Start <= sequence {
+			return i
+		}
+	}
+	return 0
+}
+
+// macKey returns the index in keys of the key record's MAC was written
+// with, or -1 if none of them wrote it
+func macKey(c domain.CryptoService, keys []domain.AuditLogKey, record domain.AuditLogRecord) int {
+	for i := len(keys) - 1; i >= 0; i-- {
+		if c.VerifyMAC(record.Hash, record.MAC, keys[i].PrivateKey) {
+			return i
+		}
+	}
+	return -1
+}
+
+// openAuditLogEntry decrypts the entry of record, trying the key of its
+// segment first
+func openAuditLogEntry(c domain.CryptoService, keys []domain.AuditLogKey, record domain.AuditLogRecord) ([]byte, error) {
+	i := segmentKey(keys, record.Sequence)
+	data, err := c.OpenKey(record.Entry, keys[i].PrivateKey)
+	if err == nil {
+		return data, nil
+	}
+	for j := len(keys) - 1; j >= 0; j-- {
+		if j == i {
+			continue
+		}
+		if data, err = c.OpenKey(record.Entry, keys[j].PrivateKey); err == nil {
+			return data, nil
+		}
+	}
+	return nil, err
+}
+
+// reachesRecord reports whether records include the record with sequence
+// and hash
+func reachesRecord(records []domain.AuditLogRecord, sequence uint64, hash []byte) bool {
+	return sequence > 0 && sequence <= uint64(len(records)) && bytes.Equal(records[sequence-1].Hash, hash)
+}
+
+// chainHash links an audit log record to the hash of the record before it
+func chainHash(previous []byte, sequence uint64, entry []byte) []byte {
+	h := sha256.New()
+	h.Write(previous)
+	binary.Write(h, binary.BigEndian, sequence)
+	h.Write(entry)
+	return h.Sum(nil)
+}
+
+// logEvent appends entry to the audit log of the session's vault, with the
+// time, the member and the client filled in. Callers must hold s.mu.
+func (s *VaultService) logEvent(ctx context.Context, sess *session, entry domain.AuditLogEntry) error {
+	key := sess.vault.AuditLog
+	if key == nil {
+		return errNoAuditLogKey
+	}
+
+	entry.Time = s.now()
+	entry.Member = sess.member
+	entry.Actor = clientFromContext(ctx)
+	if entry.Actor == "" {
+		entry.Actor = sess.client
+	}
+	_, err := appendAuditLog(ctx, s.auditLogs, s.crypto, sess.vaultName, entry, key.PublicKey, key.PrivateKey)
+	return err
+}
+
+// recordAuditLogHead sets the head of the vault's log as the last record
+// the vault knows of. It is called on saves, so the vault is not written
+// just for this; a log deleted together with its head is noticed once the
+// vault has been saved after the entries. An error leaves the vault as it
+// was.
+func (s *VaultService) recordAuditLogHead(ctx context.Context, vaultName string, vault *domain.Vault) {
+	if vault.AuditLog == nil {
+		return
+	}
+	head, err := s.auditLogs.LoadAuditLogHead(ctx, vaultName)
+	if err != nil || head == nil || head.Sequence <= vault.AuditLog.Sequence {
+		return
+	}
+
+	key := *vault.AuditLog
+	key.Sequence = head.Sequence
+	key.Hash = head.Hash
+	vault.AuditLog = &key
+}
+
+// logFailedUnlock records a failed attempt to unlock a vault. Without the
+// vault key the entry can only be sealed to the public key in metadata and
+// carries no MAC. Vaults without an audit log key are not logged, and an
+// error only loses the entry. Callers must not hold s.mu.
+func (s *VaultService) logFailedUnlock(ctx context.Context, vaultName string, metadata *domain.VaultMetadata, member, detail string) {
+	if metadata.AuditLogKey == nil {
+		return
+	}
+
+	s.mu.RLock()
+	store := s.auditLogs
+	now := s.now()
+	s.mu.RUnlock()
+
+	entry := domain.AuditLogEntry{
+		Time:   now,
+		Event:  domain.AuditLogUnlockFailed,
+		Actor:  clientFromContext(ctx),
+		Member: member,
+		Detail: detail,
+	}
+	appendAuditLog(ctx, store, s.crypto, vaultName, entry, metadata.AuditLogKey, nil)
+}
+
+// appendAuditLog seals entry to publicKey and appends it to the vault's
+// log. The new hash is authenticated with privateKey unless it is nil.
+// It returns the appended record.
+func appendAuditLog(ctx context.Context, store domain.AuditLogStore, c domain.CryptoService, vaultName string, entry domain.AuditLogEntry, publicKey, privateKey []byte) (domain.AuditLogRecord, error) {
+	var appended domain.AuditLogRecord
+	err := store.AppendAuditLog(ctx, vaultName, func(last *domain.AuditLogRecord) (domain.AuditLogRecord, error) {
+		record := domain.AuditLogRecord{Sequence: 1}
+		var previous []byte
+		if last != nil {
+			record.Sequence = last.Sequence + 1
+			previous = last.Hash
+		}
+
+		entry.Sequence = record.Sequence
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return domain.AuditLogRecord{}, fmt.Errorf("failed to marshal audit log entry: %w", err)
+		}
+		if record.Entry, err = c.SealKey(data, publicKey); err != nil {
+			return domain.AuditLogRecord{}, domain.ErrEncryptionFailed
+		}
+
+		record.Hash = chainHash(previous, record.Sequence, record.Entry)
+		if privateKey != nil {
+			if record.MAC, err = c.MAC(record.Hash, privateKey); err != nil {
+				return domain.AuditLogRecord{}, fmt.Errorf("failed to authenticate audit log entry: %w", err)
+			}
+		}
+		appended = record
+		return record, nil
+	})
+	return appended, err
+}
+
+// newAuditLogKey creates the key pair of a new audit log
+func (s *VaultService) newAuditLogKey() (*domain.AuditLogKey, error) {
+	publicKey, privateKey, err := s.crypto.GenerateKeyPair()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate audit log key: %w", err)
+	}
+	return &domain.AuditLogKey{PublicKey: publicKey, PrivateKey: privateKey}, nil
+}
+
+// replaceAuditLogKey creates the key that replaces current for the records
+// appended to the vault's log from now on. current is kept to read and
+// verify the records before them.
+func (s *VaultService) replaceAuditLogKey(ctx context.Context, vaultName string, current *domain.AuditLogKey) (*domain.AuditLogKey, error) {
+	if current == nil {
+		return nil, errNoAuditLogKey
+	}
+	records, err := s.auditLogs.LoadAuditLog(ctx, vaultName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load audit log: %w", err)
+	}
+
+	key, err := s.newAuditLogKey()
+	if err != nil {
+		return nil, err
+	}
+	key.Sequence = current.Sequence
+	key.Hash = current.Hash
+	key.Start = uint64(len(records)) + 1
+
+	old := *current
+	old.Sequence, old.Hash, old.Previous = 0, nil, nil
+	key.Previous = append(slices.Clone(current.Previous), old)
+	return key, nil
+}
+
+// memoryAuditLogStore keeps audit logs for the lifetime of the process
+type memoryAuditLogStore struct {
+	mu    sync.Mutex
+	logs  map[string][]domain.AuditLogRecord
+	heads map[string]domain.AuditLogRecord
+}
+
+func newMemoryAuditLogStore() *memoryAuditLogStore {
+	return &memoryAuditLogStore{
+		logs:  make(map[string][]domain.AuditLogRecord),
+		heads: make(map[string]domain.AuditLogRecord),
+	}
+}
+
+// AppendAuditLog implements domain.AuditLogStore
+func (m *memoryAuditLogStore) AppendAuditLog(ctx context.Context, vaultName string, fn func(last *domain.AuditLogRecord) (domain.AuditLogRecord, error)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var last *domain.AuditLogRecord
+	if records := m.logs[vaultName]; len(records) > 0 {
+		last = &records[len(records)-1]
+	}
+	record, err := fn(last)
+	if err != nil {
+		return err
+	}
+
+	m.logs[vaultName] = append(m.logs[vaultName], record)
+	if record.MAC != nil {
+		m.heads[vaultName] = record
+	}
+	return nil
+}
+
+// LoadAuditLog implements domain.AuditLogStore
+func (m *memoryAuditLogStore) LoadAuditLog(ctx context.Context, vaultName string) ([]domain.AuditLogRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return slices.Clone(m.logs[vaultName]), nil
+}
+
+// LoadAuditLogHead implements domain.AuditLogStore
+func (m *memoryAuditLogStore) LoadAuditLogHead(ctx context.Context, vaultName string) (*domain.AuditLogRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	head, ok := m.heads[vaultName]
+	if !ok {
+		return nil, nil
+	}
+	return &head, nil
+}
@@ -0,0 +1,331 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/orlan/go-password-manager/internal/crypto"
+	"github.com/orlan/go-password-manager/internal/domain"
+	"github.com/orlan/go-password-manager/internal/transfer"
+	"github.com/orlan/go-password-manager/internal/vault"
+)
+
+// auditEvents returns the events of the test vault's audit log
+func auditEvents(t *testing.T, service *VaultService, token string) []AuditLogEntry {
+	t.Helper()
+
+	entries, err := service.AuditLog(context.Background(), token, "test-vault")
+	if err != nil {
+		t.Fatalf("AuditLog() failed: %v", err)
+	}
+	return entries
+}
+
+func TestAuditLogRecordsOperations(t *testing.T) {
+	service, _ := setupTestService(t)
+	ctx := WithClient(context.Background(), "192.0.2.1")
+	service.CreateVault(ctx, "test-vault", "my-password", "interactive")
+	service.SetUnlockPolicy(UnlockPolicy{})
+
+	token, err := service.UnlockVault(ctx, "test-vault", "my-password")
+	if err != nil {
+		t.Fatalf("UnlockVault() failed: %v", err)
+	}
+	service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Password: "secret", TOTP: "gezd gnbv gy3t qojq gezd gnbv gy3t qojq"})
+	service.GetPasswordRecord(ctx, token, "test-vault", "github")
+	service.ListPasswordRecords(ctx, token, "test-vault")
+	password := "changed"
+	service.UpdatePasswordRecord(ctx, token, "test-vault", "github", RecordUpdate{Password: &password})
+	service.GetRecordHistory(ctx, token, "test-vault", "github")
+	service.GetTOTPCode(ctx, token, "test-vault", "github")
+	service.ExportVault(ctx, token, "test-vault", ExportOptions{Format: transfer.FormatEncrypted, Password: "export-password-123"})
+	service.DeletePasswordRecord(ctx, token, "test-vault", "github")
+	if _, err := service.UnlockVault(WithClient(context.Background(), "telegram:42"), "test-vault", "wrong-password"); err != domain.ErrInvalidMasterPassword {
+		t.Fatalf("expected ErrInvalidMasterPassword, got %v", err)
+	}
+
+	// Operations without a client are attributed to the one that unlocked
+	service.ListPasswordRecords(context.Background(), token, "test-vault")
+	otherToken, _ := service.UnlockVault(context.Background(), "test-vault", "my-password")
+	service.LockVault(context.Background(), otherToken)
+
+	want := []struct {
+		event  domain.AuditLogEvent
+		actor  string
+		record string
+	}{
+		{domain.AuditLogUnlock, "192.0.2.1", ""},
+		{domain.AuditLogAdd, "192.0.2.1", "github"},
+		{domain.AuditLogGet, "192.0.2.1", "github"},
+		{domain.AuditLogList, "192.0.2.1", ""},
+		{domain.AuditLogUpdate, "192.0.2.1", "github"},
+		{domain.AuditLogGet, "192.0.2.1", "github"},
+		{domain.AuditLogGet, "192.0.2.1", "github"},
+		{domain.AuditLogExport, "192.0.2.1", ""},
+		{domain.AuditLogDelete, "192.0.2.1", "github"},
+		{domain.AuditLogUnlockFailed, "telegram:42", ""},
+		{domain.AuditLogList, "192.0.2.1", ""},
+		{domain.AuditLogUnlock, "", ""},
+		{domain.AuditLogLock, "", ""},
+	}
+	entries := auditEvents(t, service, token)
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d entries, got %d: %+v", len(want), len(entries), entries)
+	}
+	for i, entry := range entries {
+		if entry.Sequence != uint64(i+1) || entry.Event != want[i].event || entry.Actor != want[i].actor || entry.Record != want[i].record {
+			t.Errorf("entry %d: expected %+v, got %+v", i, want[i], entry.AuditLogEntry)
+		}
+		if !entry.Verified {
+			t.Errorf("entry %d is not verified", i)
+		}
+	}
+	if entries[5].Detail != "password history" || entries[6].Detail != "one-time code" {
+		t.Errorf("unexpected read details %q and %q", entries[5].Detail, entries[6].Detail)
+	}
+	if entries[7].Detail != string(transfer.FormatEncrypted) || entries[12].Detail != string(LockReasonManual) {
+		t.Errorf("unexpected details %q and %q", entries[7].Detail, entries[12].Detail)
+	}
+}
+
+func TestAuditLogFailedUnlockIsUnverified(t *testing.T) {
+	service, token := setupRecordTest(t)
+	service.SetUnlockPolicy(UnlockPolicy{})
+	service.UnlockVault(context.Background(), "test-vault", "wrong-password")
+
+	// Nothing written with the vault key vouches for the failed unlock yet
+	entries := auditEvents(t, service, token)
+	last := entries[len(entries)-1]
+	if last.Event != domain.AuditLogUnlockFailed || last.Verified || !entries[0].Verified {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+
+	service.ListPasswordRecords(context.Background(), token, "test-vault")
+	for _, entry := range auditEvents(t, service, token)[:len(entries)] {
+		if !entry.Verified {
+			t.Errorf("entry %d is not verified after a later entry", entry.Sequence)
+		}
+	}
+}
+
+func TestAuditLogTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(records []domain.AuditLogRecord) []domain.AuditLogRecord
+	}{
+		{"changed entry", func(records []domain.AuditLogRecord) []domain.AuditLogRecord {
+			records[1].Entry[40] ^= 1
+			return records
+		}},
+		{"removed entry", func(records []domain.AuditLogRecord) []domain.AuditLogRecord {
+			return append(records[:1], records[2:]...)
+		}},
+		{"swapped entries", func(records []domain.AuditLogRecord) []domain.AuditLogRecord {
+			records[1], records[2] = records[2], records[1]
+			return records
+		}},
+		{"rewritten chain", func(records []domain.AuditLogRecord) []domain.AuditLogRecord {
+			// Recomputing the hashes does not help without the MAC key
+			records = records[1:]
+			var previous []byte
+			for i := range records {
+				records[i].Sequence = uint64(i + 1)
+				records[i].Hash = chainHash(previous, records[i].Sequence, records[i].Entry)
+				previous = records[i].Hash
+			}
+			return records
+		}},
+		{"cut off end", func(records []domain.AuditLogRecord) []domain.AuditLogRecord {
+			return records[:len(records)-1]
+		}},
+		{"deleted log", func(records []domain.AuditLogRecord) []domain.AuditLogRecord {
+			return nil
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, token := setupRecordTest(t)
+			ctx := context.Background()
+			service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Password: "secret"})
+			service.GetPasswordRecord(ctx, token, "test-vault", "github")
+
+			store := service.auditLogs.(*memoryAuditLogStore)
+			store.logs["test-vault"] = tt.tamper(store.logs["test-vault"])
+
+			if _, err := service.AuditLog(ctx, token, "test-vault"); err != domain.ErrAuditLogTampered {
+				t.Errorf("expected ErrAuditLogTampered, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAuditLogHead(t *testing.T) {
+	service, token := setupRecordTest(t)
+	ctx := context.Background()
+	store := service.auditLogs.(*memoryAuditLogStore)
+
+	// Reads are logged without writing the vault
+	before, _ := service.repo.Load(ctx, "test-vault")
+	service.ListPasswordRecords(ctx, token, "test-vault")
+	if after, _ := service.repo.Load(ctx, "test-vault"); after.Revision != before.Revision {
+		t.Errorf("a read saved the vault: revision %d -> %d", before.Revision, after.Revision)
+	}
+
+	t.Run("removed head", func(t *testing.T) {
+		head := store.heads["test-vault"]
+		delete(store.heads, "test-vault")
+		if _, err := service.AuditLog(ctx, token, "test-vault"); err != domain.ErrAuditLogTampered {
+			t.Errorf("expected ErrAuditLogTampered, got %v", err)
+		}
+		store.heads["test-vault"] = head
+	})
+
+	t.Run("log deleted with its head after a save", func(t *testing.T) {
+		// The save records the head in the vault
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Password: "secret"})
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "gitlab", Password: "secret"})
+		if _, err := service.AuditLog(ctx, token, "test-vault"); err != nil {
+			t.Fatalf("AuditLog() failed: %v", err)
+		}
+
+		delete(store.logs, "test-vault")
+		delete(store.heads, "test-vault")
+		if _, err := service.AuditLog(ctx, token, "test-vault"); err != domain.ErrAuditLogTampered {
+			t.Errorf("expected ErrAuditLogTampered, got %v", err)
+		}
+	})
+}
+
+func TestAuditLogSharedVault(t *testing.T) {
+	service, ownerToken := setupRolesTest(t)
+	ctx := context.Background()
+	editorToken := unlockMember(t, service, "erin")
+	service.GetPasswordRecord(ctx, editorToken, "test-vault", "github")
+
+	if _, err := service.AuditLog(ctx, editorToken, "test-vault"); err != domain.ErrPermissionDenied {
+		t.Errorf("expected ErrPermissionDenied for an editor, got %v", err)
+	}
+
+	// Sharing the vault re-encrypts it, but the log stays readable
+	entries := auditEvents(t, service, ownerToken)
+	last := entries[len(entries)-1]
+	if last.Event != domain.AuditLogGet || last.Member != "erin" || last.Record != "github" {
+		t.Errorf("unexpected last entry %+v", last.AuditLogEntry)
+	}
+	if entries[0].Event != domain.AuditLogUnlock || entries[0].Member != "" {
+		t.Errorf("unexpected first entry %+v", entries[0].AuditLogEntry)
+	}
+}
+
+func TestAuditLogRemovedMember(t *testing.T) {
+	service, token := setupRecordTest(t)
+	ctx := context.Background()
+	store := service.auditLogs.(*memoryAuditLogStore)
+	service.AddMember(ctx, token, "test-vault", MemberInput{Name: "alice", Password: "alice-password", KDFProfile: "interactive"})
+	aliceToken, err := service.UnlockVaultAsMember(ctx, "test-vault", "alice", "alice-password", "")
+	if err != nil {
+		t.Fatalf("UnlockVaultAsMember() failed: %v", err)
+	}
+	oldKey := *service.sessions[aliceToken].vault.AuditLog
+
+	if err := service.RemoveMember(ctx, token, "test-vault", "alice"); err != nil {
+		t.Fatalf("RemoveMember() failed: %v", err)
+	}
+
+	t.Run("the key is replaced", func(t *testing.T) {
+		key := service.sessions[token].vault.AuditLog
+		if bytes.Equal(key.PrivateKey, oldKey.PrivateKey) || len(key.Previous) != 1 || !bytes.Equal(key.Previous[0].PrivateKey, oldKey.PrivateKey) {
+			t.Fatalf("unexpected audit log key after the removal: start %d, %d previous", key.Start, len(key.Previous))
+		}
+		metadata, _ := service.repo.Load(ctx, "test-vault")
+		if !bytes.Equal(metadata.AuditLogKey, key.PublicKey) {
+			t.Error("the vault header has the old public key")
+		}
+	})
+
+	t.Run("the lock of the removed member is logged", func(t *testing.T) {
+		entries := auditEvents(t, service, token)
+		last := entries[len(entries)-1]
+		if last.Event != domain.AuditLogLock || last.Member != "alice" || last.Detail != string(LockReasonAccessRevoked) || !last.Verified {
+			t.Errorf("unexpected last entry %+v", last)
+		}
+		for _, entry := range entries {
+			if !entry.Verified {
+				t.Errorf("entry %d is not verified", entry.Sequence)
+			}
+		}
+	})
+
+	t.Run("the old key does not verify new entries", func(t *testing.T) {
+		entry := domain.AuditLogEntry{Event: domain.AuditLogDelete, Member: OwnerMemberName, Record: "github"}
+		if _, err := appendAuditLog(ctx, store, crypto.NewService(), "test-vault", entry, oldKey.PublicKey, oldKey.PrivateKey); err != nil {
+			t.Fatalf("appendAuditLog() failed: %v", err)
+		}
+		entries := auditEvents(t, service, token)
+		if last := entries[len(entries)-1]; last.Event != domain.AuditLogDelete || last.Verified {
+			t.Errorf("unexpected last entry %+v", last)
+		}
+	})
+}
+
+func TestAuditLogPersists(t *testing.T) {
+	service1, vaultDir := setupTestService(t)
+	ctx := context.Background()
+	repo1, _ := vault.NewFileRepository(vaultDir)
+	service1.SetAuditLogStore(repo1)
+	service1.CreateVault(ctx, "test-vault", "my-password", "interactive")
+	token1, _ := service1.UnlockVault(ctx, "test-vault", "my-password")
+	service1.AddPasswordRecord(ctx, token1, "test-vault", RecordInput{Name: "github", Password: "secret"})
+
+	repo2, _ := vault.NewFileRepository(vaultDir)
+	service2 := NewVaultService(repo2, crypto.NewService())
+	t.Cleanup(service2.Stop)
+	service2.SetAuditLogStore(repo2)
+	token2, err := service2.UnlockVault(ctx, "test-vault", "my-password")
+	if err != nil {
+		t.Fatalf("UnlockVault() failed: %v", err)
+	}
+
+	entries := auditEvents(t, service2, token2)
+	if len(entries) != 3 || entries[1].Event != domain.AuditLogAdd || entries[2].Event != domain.AuditLogUnlock {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+}
+
+func TestAuditLogKeyForOlderVaults(t *testing.T) {
+	service, _ := setupTestService(t)
+	ctx := context.Background()
+	service.CreateVault(ctx, "test-vault", "my-password", "interactive")
+
+	// Remove the audit log key, as in vaults created before the log existed
+	metadata, _ := service.repo.Load(ctx, "test-vault")
+	c := crypto.NewService()
+	key, _ := c.DeriveKey("my-password", metadata.Salt, *metadata.KDF)
+	data, _ := c.Decrypt(metadata.Nonce, metadata.Encrypted, key, vaultAssociatedData("test-vault", metadata))
+	var v domain.Vault
+	json.Unmarshal(data, &v)
+	v.AuditLog = nil
+	data, _ = json.Marshal(v)
+	metadata.AuditLogKey = nil
+	metadata.Version = "1.0"
+	metadata.Nonce, metadata.Encrypted, _ = c.Encrypt(data, key, nil)
+	service.repo.Save(ctx, "test-vault", metadata)
+
+	// Failed unlocks cannot be logged yet
+	service.SetUnlockPolicy(UnlockPolicy{})
+	service.UnlockVault(ctx, "test-vault", "wrong-password")
+
+	token, err := service.UnlockVault(ctx, "test-vault", "my-password")
+	if err != nil {
+		t.Fatalf("UnlockVault() failed: %v", err)
+	}
+	if metadata, _ := service.repo.Load(ctx, "test-vault"); metadata.AuditLogKey == nil {
+		t.Error("expected the public key of the audit log in the metadata")
+	}
+	if entries := auditEvents(t, service, token); len(entries) != 1 || entries[0].Event != domain.AuditLogUnlock {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+}
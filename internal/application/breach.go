@@ -0,0 +1,35 @@
+package application
+
+import (
+	"fmt"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// SetBreachChecker enables checking passwords against a list of breached
+// passwords. Added and changed passwords that appear in it get a warning,
+// and AuditVault reports them. A nil checker disables the check.
+func (s *VaultService) SetBreachChecker(checker domain.BreachChecker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.breaches = checker
+}
+
+// breachWarnings checks password against the breach list. A failed check
+// is reported as a warning rather than an error, because it should not
+// stop the record from being saved.
+func (s *VaultService) breachWarnings(password string) []string {
+	if s.breaches == nil || password == "" {
+		return nil
+	}
+
+	count, err := s.breaches.Count(password)
+	if err != nil {
+		return []string{fmt.Sprintf("could not check the password against the breach list: %v", err)}
+	}
+	if count > 0 {
+		return []string{fmt.Sprintf("this password has appeared %d times in known data breaches; choose a different one", count)}
+	}
+	return nil
+}
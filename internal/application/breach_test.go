@@ -0,0 +1,151 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/orlan/go-password-manager/internal/breach"
+)
+
+// failingChecker is a breach list that cannot be read
+type failingChecker struct{}
+
+func (failingChecker) Count(string) (int, error) {
+	return 0, errors.New("disk error")
+}
+
+func setupBreachTest(t *testing.T) (*VaultService, string) {
+	t.Helper()
+
+	service, token := setupRecordTest(t)
+	store, err := breach.Open("../breach/testdata/pwned-passwords-sample.txt")
+	if err != nil {
+		t.Fatalf("breach.Open() failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	service.SetBreachChecker(store)
+
+	return service, token
+}
+
+func TestBreachWarnings(t *testing.T) {
+	t.Run("warns when adding a breached password", func(t *testing.T) {
+		service, token := setupBreachTest(t)
+		ctx := context.Background()
+
+		result, err := service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "P@ssw0rd"})
+		if err != nil {
+			t.Fatalf("AddPasswordRecord() failed: %v", err)
+		}
+		if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "120341 times") {
+			t.Errorf("unexpected warnings %v", result.Warnings)
+		}
+
+		// The record is saved regardless
+		if _, err := service.GetPasswordRecord(ctx, token, "test-vault", "github"); err != nil {
+			t.Errorf("GetPasswordRecord() failed: %v", err)
+		}
+	})
+
+	t.Run("does not warn for other passwords", func(t *testing.T) {
+		service, token := setupBreachTest(t)
+		ctx := context.Background()
+
+		result, _ := service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "hJ4&kq9!Xz2@Lw"})
+		if len(result.Warnings) != 0 {
+			t.Errorf("unexpected warnings %v", result.Warnings)
+		}
+	})
+
+	t.Run("warns when changing to a breached password", func(t *testing.T) {
+		service, token := setupBreachTest(t)
+		ctx := context.Background()
+
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "hJ4&kq9!Xz2@Lw"})
+
+		result, err := service.UpdatePasswordRecord(ctx, token, "test-vault", "github", RecordUpdate{Password: stringPtr("qwerty")})
+		if err != nil {
+			t.Fatalf("UpdatePasswordRecord() failed: %v", err)
+		}
+		if len(result.Warnings) != 1 {
+			t.Errorf("expected one warning, got %v", result.Warnings)
+		}
+
+		result, _ = service.UpdatePasswordRecord(ctx, token, "test-vault", "github", RecordUpdate{Username: stringPtr("other")})
+		if len(result.Warnings) != 0 {
+			t.Errorf("expected no warnings when the password is unchanged, got %v", result.Warnings)
+		}
+	})
+
+	t.Run("reports a failed check as a warning", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		service.SetBreachChecker(failingChecker{})
+
+		result, err := service.AddPasswordRecord(context.Background(), token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass"})
+		if err != nil {
+			t.Fatalf("AddPasswordRecord() failed: %v", err)
+		}
+		if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "disk error") {
+			t.Errorf("unexpected warnings %v", result.Warnings)
+		}
+	})
+
+	t.Run("is off without a breach list", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+
+		result, _ := service.AddPasswordRecord(context.Background(), token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "password"})
+		if len(result.Warnings) != 0 {
+			t.Errorf("unexpected warnings %v", result.Warnings)
+		}
+	})
+}
+
+func TestAuditVaultBreaches(t *testing.T) {
+	t.Run("flags breached passwords", func(t *testing.T) {
+		service, token := setupBreachTest(t)
+		ctx := context.Background()
+
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "abc123"})
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "email", Username: "user", Password: "hJ4&kq9!Xz2@Lw"})
+
+		report, err := service.AuditVault(ctx, token, "test-vault")
+		if err != nil {
+			t.Fatalf("AuditVault() failed: %v", err)
+		}
+		if !report.BreachCheck || report.Breached != 1 {
+			t.Fatalf("unexpected report %+v", report)
+		}
+
+		github := report.Records[0]
+		if github.Name != "github" || github.BreachCount != 4772282 || !slices.Contains(github.Issues, AuditIssueBreached) {
+			t.Errorf("unexpected audit for github %+v", github)
+		}
+	})
+
+	t.Run("fails when the breach list cannot be read", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass"})
+		service.SetBreachChecker(failingChecker{})
+
+		if _, err := service.AuditVault(ctx, token, "test-vault"); err == nil {
+			t.Error("expected an error")
+		}
+	})
+
+	t.Run("is skipped without a breach list", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "password"})
+
+		report, _ := service.AuditVault(ctx, token, "test-vault")
+		if report.BreachCheck || report.Breached != 0 {
+			t.Errorf("unexpected report %+v", report)
+		}
+	})
+}
@@ -0,0 +1,115 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// MaxPasswordHistory is the number of previous passwords kept per record.
+// Older entries are dropped when a record is changed again.
+const MaxPasswordHistory = 10
+
+// GetRecordHistory returns the previous passwords of a record, newest first
+func (s *VaultService) GetRecordHistory(ctx context.Context, token, vaultName, recordName string) ([]domain.PasswordHistoryEntry, error) {
+	s.mu.Lock()
+	defer s.unlock()
+
+	sess, err := s.getSession(token, vaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pick up changes saved by other processes
+	if err := s.refreshVault(ctx, sess); err != nil {
+		return nil, err
+	}
+
+	for _, record := range sess.vault.Records {
+		if record.Name == recordName {
+			// Old passwords may still be in use elsewhere, so this is a read
+			if err := s.logEvent(ctx, sess, domain.AuditLogEntry{Event: domain.AuditLogGet, Record: recordName, Detail: "password history"}); err != nil {
+				return nil, fmt.Errorf("failed to write audit log: %w", err)
+			}
+			return slices.Clone(record.History), nil
+		}
+	}
+
+	return nil, domain.ErrRecordNotFound
+}
+
+// RestoreRecordVersion makes a previous password current again. The
+// password it replaces is added to the history, so a restore can itself
+// be undone.
+func (s *VaultService) RestoreRecordVersion(ctx context.Context, token, vaultName, recordName string, version int) error {
+	s.mu.Lock()
+	defer s.unlock()
+
+	sess, err := s.getSession(token, vaultName)
+	if err != nil {
+		return err
+	}
+
+	if err := s.authorize(ctx, sess, domain.RoleEditor); err != nil {
+		return err
+	}
+
+	err = s.updateVault(ctx, sess, func(vault *domain.Vault) error {
+		for i := range vault.Records {
+			record := &vault.Records[i]
+			if record.Name != recordName {
+				continue
+			}
+
+			for _, entry := range record.History {
+				if entry.Version == version {
+					now := s.now()
+					recordPasswordChange(record, record.Password, entry.Password, now)
+					record.Password = entry.Password
+					record.UpdatedAt = now
+					return nil
+				}
+			}
+			return domain.ErrHistoryVersionNotFound
+		}
+
+		return domain.ErrRecordNotFound
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logEvent(ctx, sess, domain.AuditLogEntry{
+		Event:  domain.AuditLogUpdate,
+		Record: recordName,
+		Detail: fmt.Sprintf("restored version %d", version),
+	})
+	return nil
+}
+
+// recordPasswordChange adds previous to the history of record when the
+// password changes to current. The history is rebuilt rather than appended
+// to, because records are shallow copies that share it with the last saved
+// vault.
+func recordPasswordChange(record *domain.PasswordRecord, previous, current string, now time.Time) {
+	if previous == "" || previous == current {
+		return
+	}
+
+	version := 1
+	if len(record.History) > 0 {
+		version = record.History[0].Version + 1
+	}
+
+	history := make([]domain.PasswordHistoryEntry, 0, min(len(record.History)+1, MaxPasswordHistory))
+	history = append(history, domain.PasswordHistoryEntry{
+		Version:   version,
+		Password:  previous,
+		ChangedAt: now,
+	})
+	history = append(history, record.History[:min(len(record.History), MaxPasswordHistory-1)]...)
+	record.History = history
+}
@@ -0,0 +1,207 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+func TestPasswordHistory(t *testing.T) {
+	t.Run("keeps previous passwords newest first", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass1"})
+		service.UpdatePasswordRecord(ctx, token, "test-vault", "github", RecordUpdate{Password: stringPtr("pass2")})
+		service.UpdatePasswordRecord(ctx, token, "test-vault", "github", RecordUpdate{Password: stringPtr("pass3")})
+
+		history, err := service.GetRecordHistory(ctx, token, "test-vault", "github")
+		if err != nil {
+			t.Fatalf("GetRecordHistory() failed: %v", err)
+		}
+		if len(history) != 2 {
+			t.Fatalf("expected 2 history entries, got %d", len(history))
+		}
+		if history[0].Password != "pass2" || history[0].Version != 2 {
+			t.Errorf("unexpected newest entry %+v", history[0])
+		}
+		if history[1].Password != "pass1" || history[1].Version != 1 {
+			t.Errorf("unexpected oldest entry %+v", history[1])
+		}
+		if history[0].ChangedAt.IsZero() {
+			t.Error("ChangedAt should be set")
+		}
+	})
+
+	t.Run("uses the service clock", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+		now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+		setClock(service, now)
+
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass1"})
+		service.UpdatePasswordRecord(ctx, token, "test-vault", "github", RecordUpdate{Password: stringPtr("pass2")})
+
+		record, _ := service.GetPasswordRecord(ctx, token, "test-vault", "github")
+		history, _ := service.GetRecordHistory(ctx, token, "test-vault", "github")
+		if !record.CreatedAt.Equal(now) || !record.UpdatedAt.Equal(now) || len(history) != 1 || !history[0].ChangedAt.Equal(now) {
+			t.Errorf("unexpected times: created %v, updated %v, history %+v", record.CreatedAt, record.UpdatedAt, history)
+		}
+	})
+
+	t.Run("is not changed by a failed update", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass1", Tags: []string{"work"}})
+		service.UpdatePasswordRecord(ctx, token, "test-vault", "github", RecordUpdate{Password: stringPtr("pass2")})
+
+		sess := service.sessions[token]
+		service.mu.Lock()
+		err := service.updateVault(ctx, sess, func(vault *domain.Vault) error {
+			vault.Records[0].History[0].Password = "changed"
+			vault.Records[0].Tags[0] = "changed"
+			return domain.ErrRecordNotFound
+		})
+		service.mu.Unlock()
+		if err != domain.ErrRecordNotFound {
+			t.Fatalf("expected the change's error, got %v", err)
+		}
+
+		record := sess.vault.Records[0]
+		if record.History[0].Password != "pass1" || record.Tags[0] != "work" {
+			t.Errorf("the failed change reached the vault in memory: %+v", record)
+		}
+	})
+
+	t.Run("ignores updates that keep the password", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass"})
+		service.UpdatePasswordRecord(ctx, token, "test-vault", "github", RecordUpdate{Username: stringPtr("other")})
+		service.UpdatePasswordRecord(ctx, token, "test-vault", "github", RecordUpdate{Password: stringPtr("pass")})
+
+		history, _ := service.GetRecordHistory(ctx, token, "test-vault", "github")
+		if len(history) != 0 {
+			t.Errorf("expected no history, got %+v", history)
+		}
+	})
+
+	t.Run("is bounded", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass0"})
+		for i := 1; i <= MaxPasswordHistory+5; i++ {
+			_, err := service.UpdatePasswordRecord(ctx, token, "test-vault", "github", RecordUpdate{Password: stringPtr(fmt.Sprintf("pass%d", i))})
+			if err != nil {
+				t.Fatalf("UpdatePasswordRecord() failed: %v", err)
+			}
+		}
+
+		history, _ := service.GetRecordHistory(ctx, token, "test-vault", "github")
+		if len(history) != MaxPasswordHistory {
+			t.Fatalf("expected %d entries, got %d", MaxPasswordHistory, len(history))
+		}
+		last := fmt.Sprintf("pass%d", MaxPasswordHistory+4)
+		if history[0].Password != last || history[0].Version != MaxPasswordHistory+5 {
+			t.Errorf("unexpected newest entry %+v", history[0])
+		}
+	})
+
+	t.Run("is not returned with the record", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass1"})
+		service.UpdatePasswordRecord(ctx, token, "test-vault", "github", RecordUpdate{Password: stringPtr("pass2")})
+
+		record, _ := service.GetPasswordRecord(ctx, token, "test-vault", "github")
+		records, _ := service.ListPasswordRecords(ctx, token, "test-vault")
+		if record.History != nil || records[0].History != nil {
+			t.Error("history should only be returned by GetRecordHistory")
+		}
+	})
+
+	t.Run("survives lock and unlock", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass1"})
+		service.UpdatePasswordRecord(ctx, token, "test-vault", "github", RecordUpdate{Password: stringPtr("pass2")})
+		service.LockVault(ctx, token)
+
+		token, err := service.UnlockVault(ctx, "test-vault", "my-password")
+		if err != nil {
+			t.Fatalf("UnlockVault() failed: %v", err)
+		}
+		history, _ := service.GetRecordHistory(ctx, token, "test-vault", "github")
+		if len(history) != 1 || history[0].Password != "pass1" {
+			t.Errorf("history was not persisted: %+v", history)
+		}
+	})
+
+	t.Run("returns error for unknown record", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+
+		_, err := service.GetRecordHistory(context.Background(), token, "test-vault", "missing")
+		if err != domain.ErrRecordNotFound {
+			t.Errorf("expected ErrRecordNotFound, got %v", err)
+		}
+	})
+}
+
+func TestRestoreRecordVersion(t *testing.T) {
+	t.Run("restores a previous password", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass1"})
+		service.UpdatePasswordRecord(ctx, token, "test-vault", "github", RecordUpdate{Password: stringPtr("mistake")})
+
+		if err := service.RestoreRecordVersion(ctx, token, "test-vault", "github", 1); err != nil {
+			t.Fatalf("RestoreRecordVersion() failed: %v", err)
+		}
+
+		record, _ := service.GetPasswordRecord(ctx, token, "test-vault", "github")
+		if record.Password != "pass1" {
+			t.Errorf("expected restored password %q, got %q", "pass1", record.Password)
+		}
+
+		// The replaced password can be restored in turn
+		history, _ := service.GetRecordHistory(ctx, token, "test-vault", "github")
+		if len(history) != 2 || history[0].Password != "mistake" || history[0].Version != 2 {
+			t.Errorf("unexpected history after restore: %+v", history)
+		}
+	})
+
+	t.Run("returns error for unknown version", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass1"})
+
+		err := service.RestoreRecordVersion(ctx, token, "test-vault", "github", 7)
+		if err != domain.ErrHistoryVersionNotFound {
+			t.Errorf("expected ErrHistoryVersionNotFound, got %v", err)
+		}
+
+		err = service.RestoreRecordVersion(ctx, token, "test-vault", "missing", 1)
+		if err != domain.ErrRecordNotFound {
+			t.Errorf("expected ErrRecordNotFound, got %v", err)
+		}
+	})
+
+	t.Run("returns error for invalid session", func(t *testing.T) {
+		service, _ := setupRecordTest(t)
+
+		err := service.RestoreRecordVersion(context.Background(), "", "test-vault", "github", 1)
+		if err != domain.ErrInvalidSession {
+			t.Errorf("expected ErrInvalidSession, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,779 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/crypto"
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// OwnerMemberName is the member name given to the master password of a
+// single-user vault when it is shared for the first time
+const OwnerMemberName = "owner"
+
+// MemberInfo describes a member of a shared vault
+type MemberInfo struct {
+	Name    string            `json:"name"`
+	Role    domain.MemberRole `json:"role"`
+	AddedAt time.Time         `json:"added_at"`
+	// Current is set for the member who unlocked the session
+	Current bool `json:"current"`
+}
+
+// ListMembers returns the members of a shared vault in the order they were
+// added. Single-user vaults have no members.
+func (s *VaultService) ListMembers(ctx context.Context, token, vaultName string) ([]MemberInfo, error) {
+	s.mu.Lock()
+	defer s.unlock()
+
+	sess, err := s.getSession(token, vaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fails if the data key was replaced by another process
+	if err := s.refreshVault(ctx, sess); err != nil {
+		return nil, err
+	}
+	metadata, err := s.loadMetadata(ctx, vaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]MemberInfo, len(metadata.Members))
+	for i, member := range metadata.Members {
+		members[i] = MemberInfo{
+			Name:    member.Name,
+			Role:    sess.vault.roles[member.Name],
+			AddedAt: member.AddedAt,
+			Current: member.Name == sess.member,
+		}
+	}
+	return members, nil
+}
+
+// MemberInput describes a member to add to a vault
+type MemberInput struct {
+	Name     string
+	Password string
+	// KDFProfile selects how the member's key is derived; empty uses the
+	// default
+	KDFProfile string
+	// Role is what the member may do; empty means domain.RoleViewer
+	Role domain.MemberRole
+}
+
+// AddMember lets another person open the vault with their own password.
+// Adding the first member turns a single-user vault into a shared one: its
+// records are re-encrypted with a random data key, and the master password
+// becomes the member OwnerMemberName with the owner role. Only owners can
+// add members.
+func (s *VaultService) AddMember(ctx context.Context, token, vaultName string, input MemberInput) error {
+	memberName := input.Name
+	if err := domain.ValidateMemberName(memberName); err != nil {
+		return err
+	}
+	role, err := domain.ParseMemberRole(string(input.Role))
+	if err != nil {
+		return err
+	}
+	kdf, err := s.crypto.KDFProfile(input.KDFProfile)
+	if err != nil {
+		return err
+	}
+
+	// Derive the member's key without holding s.mu
+	salt, err := s.crypto.GenerateSalt()
+	if err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	passwordKey, err := s.crypto.DeriveKey(input.Password, salt, kdf)
+	if err != nil {
+		return fmt.Errorf("failed to derive key: %w", err)
+	}
+	defer crypto.Wipe(passwordKey)
+
+	s.mu.Lock()
+	defer s.unlock()
+
+	sess, err := s.getSession(token, vaultName)
+	if err != nil {
+		return err
+	}
+	if err := s.authorize(ctx, sess, domain.RoleOwner); err != nil {
+		return err
+	}
+	metadata, err := s.currentMetadata(ctx, sess)
+	if err != nil {
+		return err
+	}
+	if metadata.Member(memberName) != nil || (!metadata.Shared() && memberName == OwnerMemberName) {
+		return domain.ErrMemberAlreadyExists
+	}
+
+	var dataKey, rosterKey []byte
+	if metadata.Shared() {
+		if rosterKey, err = sessionRosterKey(sess); err != nil {
+			return err
+		}
+		dataKey = slices.Clone(sess.key.Bytes())
+	} else if dataKey, rosterKey, err = s.shareVault(vaultName, metadata, sess.key.Bytes()); err != nil {
+		return err
+	}
+	defer crypto.Wipe(dataKey)
+	defer crypto.Wipe(rosterKey)
+
+	member, err := s.newMember(memberName, role, salt, kdf, passwordKey, memberKeyAssociatedData(metadata))
+	if err != nil {
+		return err
+	}
+	if member.DataKey, err = s.crypto.SealKey(dataKey, member.PublicKey); err != nil {
+		return domain.ErrEncryptionFailed
+	}
+	member.AddedAt = s.now()
+	metadata.Members = append(metadata.Members, member)
+	if err := s.signMembers(metadata, rosterKey); err != nil {
+		return err
+	}
+
+	return s.saveMembers(ctx, sess, metadata, dataKey, rosterKey, nil, "")
+}
+
+// RemoveMember takes away a member's access to a shared vault. The vault is
+// re-encrypted with a new data key sealed to the remaining members only, so
+// the removed member cannot read later changes even with a copy of the old
+// key. Sessions of the removed member are locked; sessions of the others in
+// this process switch to the new key, those in other processes have to
+// unlock again. Removing an owner also replaces the key that signs the
+// members. The audit log key is replaced too, so entries the removed member
+// writes with the old one are not verified. The TOTP key of two-factor
+// unlock stays the same; owners should turn two-factor off and on again to
+// replace it. Only owners can remove members, and the last owner cannot be
+// removed.
+func (s *VaultService) RemoveMember(ctx context.Context, token, vaultName, memberName string) error {
+	s.mu.Lock()
+	defer s.unlock()
+
+	sess, err := s.getSession(token, vaultName)
+	if err != nil {
+		return err
+	}
+	if err := s.authorize(ctx, sess, domain.RoleOwner); err != nil {
+		return err
+	}
+	metadata, err := s.currentMetadata(ctx, sess)
+	if err != nil {
+		return err
+	}
+	member := metadata.Member(memberName)
+	if member == nil {
+		return domain.ErrMemberNotFound
+	}
+	if len(metadata.Members) == 1 {
+		return domain.ErrLastMember
+	}
+	if member.Role == domain.RoleOwner && countOwners(metadata) == 1 {
+		return domain.ErrLastOwner
+	}
+
+	dataKey, err := s.crypto.GenerateDataKey()
+	if err != nil {
+		return err
+	}
+	defer crypto.Wipe(dataKey)
+
+	previous := vaultAssociatedData(vaultName, metadata)
+	var rosterKey []byte
+	if member.Role == domain.RoleOwner {
+		rosterKey, err = s.replaceRosterKey(sess, metadata)
+	} else {
+		rosterKey, err = sessionRosterKey(sess)
+	}
+	if err != nil {
+		return err
+	}
+	defer crypto.Wipe(rosterKey)
+
+	// The removed member knows the audit log key as well, so entries from
+	// now on are sealed to a new one
+	auditLog, err := s.replaceAuditLogKey(ctx, vaultName, sess.vault.AuditLog)
+	if err != nil {
+		return err
+	}
+	metadata.AuditLogKey = auditLog.PublicKey
+
+	var vault *domain.Vault
+	if err := s.reencryptVault(vaultName, metadata, previous, sess.key.Bytes(), dataKey, func(v *domain.Vault) {
+		v.AuditLog = auditLog
+		vault = v
+	}); err != nil {
+		return err
+	}
+	metadata.Members = slices.DeleteFunc(slices.Clone(metadata.Members), func(member domain.VaultMember) bool {
+		return member.Name == memberName
+	})
+	for i := range metadata.Members {
+		if metadata.Members[i].DataKey, err = s.crypto.SealKey(dataKey, metadata.Members[i].PublicKey); err != nil {
+			return domain.ErrEncryptionFailed
+		}
+	}
+	if err := s.signMembers(metadata, rosterKey); err != nil {
+		return err
+	}
+
+	return s.saveMembers(ctx, sess, metadata, dataKey, rosterKey, vault, memberName)
+}
+
+// SetMemberRole changes what a member of a shared vault may do. Only owners
+// can change roles, and the last owner cannot give up the role. Demoting an
+// owner replaces the key that signs the members. Sessions of the member
+// pick up the new role with their next operation.
+func (s *VaultService) SetMemberRole(ctx context.Context, token, vaultName, memberName string, role domain.MemberRole) error {
+	role, err := domain.ParseMemberRole(string(role))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.unlock()
+
+	sess, err := s.getSession(token, vaultName)
+	if err != nil {
+		return err
+	}
+	if err := s.authorize(ctx, sess, domain.RoleOwner); err != nil {
+		return err
+	}
+	metadata, err := s.currentMetadata(ctx, sess)
+	if err != nil {
+		return err
+	}
+	member := metadata.Member(memberName)
+	if member == nil {
+		return domain.ErrMemberNotFound
+	}
+	if member.Role == role {
+		return nil
+	}
+	if member.Role == domain.RoleOwner && countOwners(metadata) == 1 {
+		return domain.ErrLastOwner
+	}
+
+	dataKey := slices.Clone(sess.key.Bytes())
+	defer crypto.Wipe(dataKey)
+
+	var rosterKey []byte
+	if member.Role == domain.RoleOwner {
+		// The roster key is part of the header, so the records are
+		// encrypted again
+		previous := vaultAssociatedData(vaultName, metadata)
+		if rosterKey, err = s.replaceRosterKey(sess, metadata); err != nil {
+			return err
+		}
+		if err := s.reencryptVault(vaultName, metadata, previous, dataKey, dataKey, nil); err != nil {
+			crypto.Wipe(rosterKey)
+			return err
+		}
+	} else if rosterKey, err = sessionRosterKey(sess); err != nil {
+		return err
+	}
+	defer crypto.Wipe(rosterKey)
+
+	member.Role = role
+	if err := s.signMembers(metadata, rosterKey); err != nil {
+		return err
+	}
+	return s.saveMembers(ctx, sess, metadata, dataKey, rosterKey, nil, "")
+}
+
+// currentMetadata loads the vault metadata, provided the session is up to
+// date with it. Callers must hold s.mu.
+func (s *VaultService) currentMetadata(ctx context.Context, sess *session) (*domain.VaultMetadata, error) {
+	// Also fails if the vault was re-encrypted under another key
+	if err := s.refreshVault(ctx, sess); err != nil {
+		return nil, err
+	}
+
+	metadata, err := s.loadMetadata(ctx, sess.vaultName)
+	if err != nil {
+		return nil, err
+	}
+	if metadata.Revision != sess.vault.revision {
+		return nil, domain.ErrVaultModified
+	}
+	// Never sign members changed behind our back
+	if _, err := s.memberRoles(metadata, sess.vault.firstRosterKey); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// saveMembers saves metadata with a changed member list and switches the
+// sessions on the vault to dataKey. Sessions of owners get rosterKey, the
+// key that signs the members; those of the member called removed are
+// locked. vault, if not nil, is the decrypted vault saved with metadata,
+// with the same records but other keys. Callers must hold s.mu.
+func (s *VaultService) saveMembers(ctx context.Context, sess *session, metadata *domain.VaultMetadata, dataKey, rosterKey []byte, vault *domain.Vault, removed string) error {
+	oldRevision := sess.vault.revision
+	if err := s.repo.SaveIfUnchanged(ctx, sess.vaultName, metadata, oldRevision); err != nil {
+		if err == domain.ErrVaultModified {
+			return err
+		}
+		return fmt.Errorf("failed to save vault: %w", err)
+	}
+	// Before the sessions of the removed member are locked, so their last
+	// entries are written with the new audit log key
+	if vault != nil {
+		clear(sess.vault.Records)
+		sess.vault.Vault = vault
+	}
+
+	for token, other := range s.sessions {
+		if other.vaultName != sess.vaultName {
+			continue
+		}
+		if other.member == "" {
+			// Unlocked with the master password before the vault was shared
+			other.member = OwnerMemberName
+		}
+		if removed != "" && other.member == removed {
+			s.removeSession(token, other, LockReasonAccessRevoked)
+			continue
+		}
+		other.key.Destroy()
+		other.key = crypto.CopySecureBuffer(dataKey)
+		other.rosterKey.Destroy()
+		if member := metadata.Member(other.member); member != nil && member.Role == domain.RoleOwner {
+			other.rosterKey = crypto.CopySecureBuffer(rosterKey)
+		} else {
+			other.rosterKey = crypto.NewSecureBuffer(nil)
+		}
+	}
+
+	// The records did not change, only the key they are encrypted with and
+	// the members who hold it
+	if sess.vault.revision == oldRevision {
+		sess.vault.revision = metadata.Revision
+	}
+	sess.vault.roles = rolesOf(metadata)
+	sess.vault.firstRosterKey = firstRosterKey(metadata)
+
+	if removed != "" {
+		// The backup still holds the old data key sealed to the removed member
+		return s.repo.RemoveBackup(ctx, sess.vaultName)
+	}
+	return nil
+}
+
+// rosterEntry is the part of a member that the members signature covers
+type rosterEntry struct {
+	Name      string            `json:"name"`
+	Role      domain.MemberRole `json:"role"`
+	PublicKey []byte            `json:"public_key"`
+}
+
+// roster encodes the names, roles and public keys of the members of
+// metadata for the members signature
+func roster(metadata *domain.VaultMetadata) ([]byte, error) {
+	entries := make([]rosterEntry, len(metadata.Members))
+	for i, member := range metadata.Members {
+		entries[i] = rosterEntry{Name: member.Name, Role: member.Role, PublicKey: member.PublicKey}
+	}
+	return json.Marshal(entries)
+}
+
+// newRosterKey adds a key pair that signs the members of metadata and
+// returns its private key. previous, the private key it replaces, signs the
+// new public key; nil starts the first key of a newly shared vault. The
+// public key is part of the vault header, so the records have to be
+// encrypted again.
+func (s *VaultService) newRosterKey(metadata *domain.VaultMetadata, previous []byte) ([]byte, error) {
+	publicKey, privateKey, err := s.crypto.GenerateSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	key := domain.RosterKey{PublicKey: publicKey}
+	if previous != nil {
+		if key.Signature, err = s.crypto.Sign(publicKey, previous); err != nil {
+			crypto.Wipe(privateKey)
+			return nil, fmt.Errorf("failed to sign roster key: %w", err)
+		}
+	}
+	metadata.RosterKeys = append(slices.Clone(metadata.RosterKeys), key)
+	return privateKey, nil
+}
+
+// replaceRosterKey replaces the key that signs the members when an owner
+// leaves, since every owner knows it, and returns the new private key. The
+// session's key signs the new one. Callers must hold s.mu.
+func (s *VaultService) replaceRosterKey(sess *session, metadata *domain.VaultMetadata) ([]byte, error) {
+	previous, err := sessionRosterKey(sess)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.Wipe(previous)
+	return s.newRosterKey(metadata, previous)
+}
+
+// sessionRosterKey returns a copy of the key that signs the members, which
+// only sessions of owners hold. Callers must hold s.mu.
+func sessionRosterKey(sess *session) ([]byte, error) {
+	if sess.rosterKey.Len() == 0 {
+		// Made an owner by another process; unlocking again opens the key
+		return nil, domain.ErrVaultModified
+	}
+	return slices.Clone(sess.rosterKey.Bytes()), nil
+}
+
+// firstRosterKey returns the public key the members of a shared vault were
+// first signed with, or nil
+func firstRosterKey(metadata *domain.VaultMetadata) []byte {
+	if len(metadata.RosterKeys) == 0 {
+		return nil
+	}
+	return metadata.RosterKeys[0].PublicKey
+}
+
+// memberKeyAssociatedData returns the additional data the private keys of
+// the members of metadata are encrypted with: the first key that signed the
+// members. Every later key is signed by the one before, so a member's
+// password only opens their private key while the members are signed by
+// keys descending from the one they joined with.
+func memberKeyAssociatedData(metadata *domain.VaultMetadata) []byte {
+	if first := firstRosterKey(metadata); first != nil {
+		return slices.Concat([]byte("roster-key:"), first)
+	}
+	return nil
+}
+
+// signMembers seals rosterKey, the private key matching the last of
+// metadata.RosterKeys, to the owners of metadata and signs its members with
+// it. Call it after every change to the members.
+func (s *VaultService) signMembers(metadata *domain.VaultMetadata, rosterKey []byte) error {
+	for i := range metadata.Members {
+		member := &metadata.Members[i]
+		member.RosterKey = nil
+		if member.Role != domain.RoleOwner {
+			continue
+		}
+		sealed, err := s.crypto.SealKey(rosterKey, member.PublicKey)
+		if err != nil {
+			return domain.ErrEncryptionFailed
+		}
+		member.RosterKey = sealed
+	}
+
+	message, err := roster(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode members: %w", err)
+	}
+	if metadata.MembersSignature, err = s.crypto.Sign(message, rosterKey); err != nil {
+		return fmt.Errorf("failed to sign members: %w", err)
+	}
+	metadata.MembersMAC = nil
+
+	// The key held by the session was replaced by another process
+	if !s.verifyMembers(metadata, message) {
+		return domain.ErrVaultModified
+	}
+	return nil
+}
+
+// verifyMembers reports whether message, the roster of metadata, is signed
+// by the last of its roster keys, and each of those keys by the one before
+func (s *VaultService) verifyMembers(metadata *domain.VaultMetadata, message []byte) bool {
+	keys := metadata.RosterKeys
+	if len(keys) == 0 {
+		return false
+	}
+	for i := 1; i < len(keys); i++ {
+		if !s.crypto.VerifySignature(keys[i].PublicKey, keys[i].Signature, keys[i-1].PublicKey) {
+			return false
+		}
+	}
+	return s.crypto.VerifySignature(message, metadata.MembersSignature, keys[len(keys)-1].PublicKey)
+}
+
+// memberRoles checks the members signature of metadata and returns the
+// role of each member, or nil for single-user vaults. Only owners hold the
+// key that signs the members, so other members cannot change roles, even
+// in the vault file. first is the first roster key a session already
+// trusts; nil when unlocking, where the member's private key checks it.
+func (s *VaultService) memberRoles(metadata *domain.VaultMetadata, first []byte) (map[string]domain.MemberRole, error) {
+	if !metadata.Shared() {
+		return nil, nil
+	}
+
+	message, err := roster(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode members: %w", err)
+	}
+	if !s.verifyMembers(metadata, message) {
+		return nil, domain.ErrVaultTampered
+	}
+	if first != nil && !bytes.Equal(firstRosterKey(metadata), first) {
+		return nil, domain.ErrVaultTampered
+	}
+	return rolesOf(metadata), nil
+}
+
+// openRosterKey opens the key that signs the members with the private key
+// of the named member. Members who are not owners get an empty buffer.
+func (s *VaultService) openRosterKey(metadata *domain.VaultMetadata, memberName string, privateKey []byte) (*crypto.SecureBuffer, error) {
+	member := metadata.Member(memberName)
+	if member == nil || member.Role != domain.RoleOwner {
+		return crypto.NewSecureBuffer(nil), nil
+	}
+	rosterKey, err := s.crypto.OpenKey(member.RosterKey, privateKey)
+	if err != nil {
+		return nil, domain.ErrVaultTampered
+	}
+	return crypto.NewSecureBuffer(rosterKey), nil
+}
+
+// rolesOf returns the role of each member of metadata, or nil for
+// single-user vaults
+func rolesOf(metadata *domain.VaultMetadata) map[string]domain.MemberRole {
+	if !metadata.Shared() {
+		return nil
+	}
+	roles := make(map[string]domain.MemberRole, len(metadata.Members))
+	for _, member := range metadata.Members {
+		roles[member.Name] = member.Role
+	}
+	return roles
+}
+
+// countOwners returns the number of members with the owner role
+func countOwners(metadata *domain.VaultMetadata) int {
+	owners := 0
+	for _, member := range metadata.Members {
+		if member.Role == domain.RoleOwner {
+			owners++
+		}
+	}
+	return owners
+}
+
+// shareVault turns a single-user vault into a shared one. The records are
+// re-encrypted with a new data key, and the master password becomes the
+// member OwnerMemberName, keeping its salt and KDF parameters. vaultKey is
+// the key derived from the master password. It returns the data key and the
+// key that signs the members, which the caller seals to the owner with
+// signMembers.
+func (s *VaultService) shareVault(name string, metadata *domain.VaultMetadata, vaultKey []byte) (dataKey, rosterKey []byte, err error) {
+	previous := vaultAssociatedData(name, metadata)
+	if rosterKey, err = s.newRosterKey(metadata, nil); err != nil {
+		return nil, nil, err
+	}
+
+	owner, err := s.newMember(OwnerMemberName, domain.RoleOwner, metadata.Salt, vaultKDF(metadata), vaultKey, memberKeyAssociatedData(metadata))
+	if err != nil {
+		crypto.Wipe(rosterKey)
+		return nil, nil, err
+	}
+	owner.AddedAt = s.now()
+
+	if dataKey, err = s.crypto.GenerateDataKey(); err != nil {
+		crypto.Wipe(rosterKey)
+		return nil, nil, err
+	}
+	if owner.DataKey, err = s.crypto.SealKey(dataKey, owner.PublicKey); err != nil {
+		crypto.Wipe(dataKey)
+		crypto.Wipe(rosterKey)
+		return nil, nil, domain.ErrEncryptionFailed
+	}
+
+	metadata.Salt = nil
+	metadata.KDF = nil
+	metadata.Members = []domain.VaultMember{owner}
+	if err := s.reencryptVault(name, metadata, previous, vaultKey, dataKey, nil); err != nil {
+		crypto.Wipe(dataKey)
+		crypto.Wipe(rosterKey)
+		return nil, nil, err
+	}
+	return dataKey, rosterKey, nil
+}
+
+// reencryptVault moves the records and the two-factor key of metadata from
+// oldKey to newKey. The records were encrypted with previous as additional
+// data and are encrypted with that of metadata as it is now. change, if not
+// nil, is applied to the decrypted vault in between.
+func (s *VaultService) reencryptVault(name string, metadata *domain.VaultMetadata, previous, oldKey, newKey []byte, change func(vault *domain.Vault)) error {
+	vaultData, err := s.crypto.Decrypt(metadata.Nonce, metadata.Encrypted, oldKey, previous)
+	if err != nil {
+		return domain.ErrVaultModified
+	}
+	defer func() { crypto.Wipe(vaultData) }()
+
+	if change != nil {
+		var vault domain.Vault
+		if err := json.Unmarshal(vaultData, &vault); err != nil {
+			return fmt.Errorf("failed to unmarshal vault: %w", err)
+		}
+		change(&vault)
+		crypto.Wipe(vaultData)
+		if vaultData, err = json.Marshal(&vault); err != nil {
+			return fmt.Errorf("failed to marshal vault: %w", err)
+		}
+	}
+
+	// The sealed two-factor key is part of the header, so it comes first
+	if metadata.TwoFactor != nil {
+		additionalData := twoFactorAssociatedData(name, metadata)
+		twoFactor, err := s.openTwoFactor(metadata.TwoFactor, oldKey, additionalData)
+		if err != nil {
+			return err
+		}
+		if metadata.TwoFactor, err = s.sealTwoFactor(twoFactor, newKey, additionalData); err != nil {
+			return err
+		}
+	}
+
+	if metadata.Nonce, metadata.Encrypted, err = s.crypto.Encrypt(vaultData, newKey, vaultAssociatedData(name, metadata)); err != nil {
+		return domain.ErrEncryptionFailed
+	}
+	return nil
+}
+
+// newMember creates a member with a new key pair. The private key is
+// encrypted with passwordKey, derived from the member's password with salt
+// and kdf, and additionalData from memberKeyAssociatedData. The data key
+// and the members signature are left for the caller.
+func (s *VaultService) newMember(name string, role domain.MemberRole, salt []byte, kdf domain.KDFParams, passwordKey, additionalData []byte) (domain.VaultMember, error) {
+	publicKey, privateKey, err := s.crypto.GenerateKeyPair()
+	if err != nil {
+		return domain.VaultMember{}, err
+	}
+	defer crypto.Wipe(privateKey)
+
+	nonce, ciphertext, err := s.crypto.Encrypt(privateKey, passwordKey, additionalData)
+	if err != nil {
+		return domain.VaultMember{}, domain.ErrEncryptionFailed
+	}
+
+	return domain.VaultMember{
+		Name:            name,
+		Role:            role,
+		Salt:            salt,
+		KDF:             kdf,
+		PublicKey:       publicKey,
+		PrivateKeyNonce: nonce,
+		PrivateKey:      ciphertext,
+	}, nil
+}
+
+// vaultKey returns the key the vault is encrypted with: derived from the
+// master password of a single-user vault, or the data key of a shared vault
+// opened with the password of the named member. It also returns the name of
+// the member and their private key, which the caller must wipe; both are
+// empty for single-user vaults.
+func (s *VaultService) vaultKey(metadata *domain.VaultMetadata, memberName, password string) (key []byte, name string, privateKey []byte, err error) {
+	if !metadata.Shared() {
+		if memberName != "" {
+			return nil, "", nil, domain.ErrInvalidMasterPassword
+		}
+		if key, err = s.crypto.DeriveKey(password, metadata.Salt, vaultKDF(metadata)); err != nil {
+			return nil, "", nil, fmt.Errorf("failed to derive key: %w", err)
+		}
+		return key, "", nil, nil
+	}
+
+	member, err := findMember(metadata, memberName)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	if privateKey, err = s.openPrivateKey(metadata, member, password); err != nil {
+		return nil, "", nil, err
+	}
+
+	dataKey, err := s.crypto.OpenKey(member.DataKey, privateKey)
+	if err != nil {
+		crypto.Wipe(privateKey)
+		return nil, "", nil, domain.ErrInvalidMasterPassword
+	}
+	return dataKey, member.Name, privateKey, nil
+}
+
+// changeMemberPassword re-encrypts a member's private key in metadata under
+// a new password. The caller saves metadata.
+func (s *VaultService) changeMemberPassword(metadata *domain.VaultMetadata, memberName, oldPassword, newPassword, kdfProfile string) error {
+	member, err := findMember(metadata, memberName)
+	if err != nil {
+		return err
+	}
+
+	newKDF := member.KDF
+	if kdfProfile != "" {
+		if newKDF, err = s.crypto.KDFProfile(kdfProfile); err != nil {
+			return err
+		}
+	}
+
+	privateKey, err := s.openPrivateKey(metadata, member, oldPassword)
+	if err != nil {
+		return err
+	}
+	defer crypto.Wipe(privateKey)
+
+	salt, err := s.crypto.GenerateSalt()
+	if err != nil {
+		return fmt.Errorf("failed to generate salt: %w", err)
+	}
+	newKey, err := s.crypto.DeriveKey(newPassword, salt, newKDF)
+	if err != nil {
+		return fmt.Errorf("failed to derive key: %w", err)
+	}
+	defer crypto.Wipe(newKey)
+
+	// Also gives members of vaults shared before version 1.3 the roster key
+	nonce, ciphertext, err := s.crypto.Encrypt(privateKey, newKey, memberKeyAssociatedData(metadata))
+	if err != nil {
+		return domain.ErrEncryptionFailed
+	}
+
+	member.Salt = salt
+	member.KDF = newKDF
+	member.PrivateKeyNonce = nonce
+	member.PrivateKey = ciphertext
+	return nil
+}
+
+// findMember returns the member called name, or the first member when name
+// is empty. An unknown member fails like a wrong password, so that member
+// names cannot be probed.
+func findMember(metadata *domain.VaultMetadata, name string) (*domain.VaultMember, error) {
+	if name == "" {
+		return &metadata.Members[0], nil
+	}
+	if member := metadata.Member(name); member != nil {
+		return member, nil
+	}
+	return nil, domain.ErrInvalidMasterPassword
+}
+
+// openPrivateKey decrypts a member's private key with the key derived from
+// their password. A key encrypted before the vault had roster keys opens
+// without them; such members trust the roster keys they find until they
+// change their password.
+func (s *VaultService) openPrivateKey(metadata *domain.VaultMetadata, member *domain.VaultMember, password string) ([]byte, error) {
+	key, err := s.crypto.DeriveKey(password, member.Salt, member.KDF)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	defer crypto.Wipe(key)
+
+	privateKey, err := s.crypto.Decrypt(member.PrivateKeyNonce, member.PrivateKey, key, memberKeyAssociatedData(metadata))
+	if err != nil && metadata.RosterKeys != nil {
+		privateKey, err = s.crypto.Decrypt(member.PrivateKeyNonce, member.PrivateKey, key, nil)
+	}
+	if err != nil {
+		return nil, domain.ErrInvalidMasterPassword
+	}
+	return privateKey, nil
+}
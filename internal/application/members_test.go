@@ -0,0 +1,368 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/crypto"
+	"github.com/orlan/go-password-manager/internal/domain"
+	"github.com/orlan/go-password-manager/internal/vault"
+)
+
+// memberNames returns the names of the vault members
+func memberNames(t *testing.T, service *VaultService, token string) []string {
+	t.Helper()
+
+	members, err := service.ListMembers(context.Background(), token, "test-vault")
+	if err != nil {
+		t.Fatalf("ListMembers() failed: %v", err)
+	}
+	names := make([]string, len(members))
+	for i, member := range members {
+		names[i] = member.Name
+	}
+	return names
+}
+
+func TestAddMember(t *testing.T) {
+	service, token := setupRecordTest(t)
+	ctx := context.Background()
+	service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Password: "secret"})
+
+	if names := memberNames(t, service, token); len(names) != 0 {
+		t.Fatalf("expected a single-user vault without members, got %v", names)
+	}
+
+	if err := service.AddMember(ctx, token, "test-vault", MemberInput{Name: "alice", Password: "alice-password", KDFProfile: "interactive"}); err != nil {
+		t.Fatalf("AddMember() failed: %v", err)
+	}
+
+	t.Run("the vault becomes shared", func(t *testing.T) {
+		metadata, _ := service.repo.Load(ctx, "test-vault")
+		if !metadata.Shared() || metadata.Salt != nil || metadata.KDF != nil {
+			t.Errorf("expected member keys only, got salt %x and kdf %v", metadata.Salt, metadata.KDF)
+		}
+
+		members, _ := service.ListMembers(ctx, token, "test-vault")
+		if len(members) != 2 || members[0].Name != OwnerMemberName || !members[0].Current || members[1].Name != "alice" || members[1].Current {
+			t.Errorf("unexpected members: %+v", members)
+		}
+	})
+
+	t.Run("existing sessions stay unlocked", func(t *testing.T) {
+		record, err := service.GetPasswordRecord(ctx, token, "test-vault", "github")
+		if err != nil || record.Password != "secret" {
+			t.Errorf("expected the record, got %+v, %v", record, err)
+		}
+	})
+
+	t.Run("every member unlocks with their own password", func(t *testing.T) {
+		for _, tt := range []struct{ member, password string }{
+			{"", "my-password"},
+			{OwnerMemberName, "my-password"},
+			{"alice", "alice-password"},
+		} {
+			memberToken, err := service.UnlockVaultAsMember(ctx, "test-vault", tt.member, tt.password, "")
+			if err != nil {
+				t.Fatalf("UnlockVaultAsMember(%q) failed: %v", tt.member, err)
+			}
+			if _, err := service.GetPasswordRecord(ctx, memberToken, "test-vault", "github"); err != nil {
+				t.Errorf("member %q cannot read the vault: %v", tt.member, err)
+			}
+		}
+	})
+
+	t.Run("wrong passwords and unknown members fail", func(t *testing.T) {
+		service.SetUnlockPolicy(UnlockPolicy{})
+		for _, tt := range []struct{ member, password string }{
+			{"alice", "my-password"},
+			{"", "alice-password"},
+			{"bob", "alice-password"},
+		} {
+			if _, err := service.UnlockVaultAsMember(ctx, "test-vault", tt.member, tt.password, ""); err != domain.ErrInvalidMasterPassword {
+				t.Errorf("UnlockVaultAsMember(%q, %q): expected ErrInvalidMasterPassword, got %v", tt.member, tt.password, err)
+			}
+		}
+	})
+
+	t.Run("names are unique and validated", func(t *testing.T) {
+		if err := service.AddMember(ctx, token, "test-vault", MemberInput{Name: "alice", Password: "other-password", KDFProfile: "interactive"}); err != domain.ErrMemberAlreadyExists {
+			t.Errorf("expected ErrMemberAlreadyExists, got %v", err)
+		}
+		if err := service.AddMember(ctx, token, "test-vault", MemberInput{Name: "bad name", Password: "other-password", KDFProfile: "interactive"}); err != domain.ErrInvalidMemberName {
+			t.Errorf("expected ErrInvalidMemberName, got %v", err)
+		}
+		if err := service.AddMember(ctx, "bad-token", "test-vault", MemberInput{Name: "bob", Password: "bob-password", KDFProfile: "interactive"}); err != domain.ErrInvalidSession {
+			t.Errorf("expected ErrInvalidSession, got %v", err)
+		}
+	})
+}
+
+func TestAddMemberOwnerName(t *testing.T) {
+	service, token := setupRecordTest(t)
+	err := service.AddMember(context.Background(), token, "test-vault", MemberInput{Name: OwnerMemberName, Password: "other-password", KDFProfile: "interactive"})
+	if err != domain.ErrMemberAlreadyExists {
+		t.Errorf("expected ErrMemberAlreadyExists, got %v", err)
+	}
+}
+
+func TestSingleUserVaultRejectsMember(t *testing.T) {
+	service, _ := setupRecordTest(t)
+	if _, err := service.UnlockVaultAsMember(context.Background(), "test-vault", "alice", "my-password", ""); err != domain.ErrInvalidMasterPassword {
+		t.Errorf("expected ErrInvalidMasterPassword, got %v", err)
+	}
+}
+
+func TestRemoveMember(t *testing.T) {
+	service, token := setupRecordTest(t)
+	ctx := context.Background()
+	service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Password: "secret"})
+	service.AddMember(ctx, token, "test-vault", MemberInput{Name: "alice", Password: "alice-password", KDFProfile: "interactive"})
+	service.AddMember(ctx, token, "test-vault", MemberInput{Name: "bob", Password: "bob-password", KDFProfile: "interactive", Role: domain.RoleOwner})
+
+	aliceToken, err := service.UnlockVaultAsMember(ctx, "test-vault", "alice", "alice-password", "")
+	if err != nil {
+		t.Fatalf("UnlockVaultAsMember() failed: %v", err)
+	}
+	bobToken, _ := service.UnlockVaultAsMember(ctx, "test-vault", "bob", "bob-password", "")
+
+	var events []SessionEvent
+	service.OnSessionLocked(func(event SessionEvent) {
+		events = append(events, event)
+	})
+
+	before, _ := service.repo.Load(ctx, "test-vault")
+	alice := *before.Member("alice")
+	oldKey, err := memberDataKey(before, alice, "alice-password")
+	if err != nil {
+		t.Fatalf("failed to open alice's data key: %v", err)
+	}
+
+	if err := service.RemoveMember(ctx, token, "test-vault", "alice"); err != nil {
+		t.Fatalf("RemoveMember() failed: %v", err)
+	}
+
+	t.Run("the data key is rotated", func(t *testing.T) {
+		after, _ := service.repo.Load(ctx, "test-vault")
+		if after.Member("alice") != nil {
+			t.Fatal("alice is still a member")
+		}
+		newKey, err := memberDataKey(after, *after.Member("bob"), "bob-password")
+		if err != nil {
+			t.Fatalf("failed to open bob's data key: %v", err)
+		}
+		if bytes.Equal(oldKey, newKey) {
+			t.Fatal("the data key was not replaced")
+		}
+		if _, err := crypto.NewService().Decrypt(after.Nonce, after.Encrypted, oldKey, vaultAssociatedData("test-vault", after)); err == nil {
+			t.Error("the old data key still decrypts the vault")
+		}
+	})
+
+	t.Run("the removed member is locked out", func(t *testing.T) {
+		if _, err := service.GetPasswordRecord(ctx, aliceToken, "test-vault", "github"); err != domain.ErrInvalidSession {
+			t.Errorf("expected ErrInvalidSession, got %v", err)
+		}
+		if len(events) != 1 || events[0].Token != aliceToken || events[0].Reason != LockReasonAccessRevoked {
+			t.Errorf("unexpected lock events: %+v", events)
+		}
+		if _, err := service.UnlockVaultAsMember(ctx, "test-vault", "alice", "alice-password", ""); err != domain.ErrInvalidMasterPassword {
+			t.Errorf("expected ErrInvalidMasterPassword, got %v", err)
+		}
+	})
+
+	t.Run("other members keep working", func(t *testing.T) {
+		notes := "changed"
+		for _, memberToken := range []string{token, bobToken} {
+			if _, err := service.UpdatePasswordRecord(ctx, memberToken, "test-vault", "github", RecordUpdate{Notes: &notes}); err != nil {
+				t.Errorf("UpdatePasswordRecord() failed: %v", err)
+			}
+		}
+		if _, err := service.UnlockVaultAsMember(ctx, "test-vault", "bob", "bob-password", ""); err != nil {
+			t.Errorf("bob cannot unlock again: %v", err)
+		}
+	})
+
+	t.Run("unknown and last members", func(t *testing.T) {
+		if err := service.RemoveMember(ctx, token, "test-vault", "alice"); err != domain.ErrMemberNotFound {
+			t.Errorf("expected ErrMemberNotFound, got %v", err)
+		}
+		if err := service.RemoveMember(ctx, token, "test-vault", OwnerMemberName); err != nil {
+			t.Fatalf("RemoveMember() of yourself failed: %v", err)
+		}
+		if err := service.RemoveMember(ctx, bobToken, "test-vault", "bob"); err != domain.ErrLastMember {
+			t.Errorf("expected ErrLastMember, got %v", err)
+		}
+	})
+}
+
+// memberDataKey opens the data key sealed to member
+func memberDataKey(metadata *domain.VaultMetadata, member domain.VaultMember, password string) ([]byte, error) {
+	c := crypto.NewService()
+	key, err := c.DeriveKey(password, member.Salt, member.KDF)
+	if err != nil {
+		return nil, err
+	}
+	privateKey, err := c.Decrypt(member.PrivateKeyNonce, member.PrivateKey, key, memberKeyAssociatedData(metadata))
+	if err != nil {
+		return nil, err
+	}
+	return c.OpenKey(member.DataKey, privateKey)
+}
+
+func TestRemoveMemberRemovesBackup(t *testing.T) {
+	service, vaultDir := setupTestService(t)
+	ctx := context.Background()
+	service.CreateVault(ctx, "test-vault", "my-password", "interactive")
+	token, _ := service.UnlockVault(ctx, "test-vault", "my-password")
+	service.AddMember(ctx, token, "test-vault", MemberInput{Name: "alice", Password: "alice-password", KDFProfile: "interactive"})
+
+	// The backup holds the data key sealed to alice
+	backupPath := filepath.Join(vaultDir, "test-vault"+vault.VaultExtension+vault.BackupExtension)
+	service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Password: "secret"})
+	if _, err := os.Stat(backupPath); err != nil {
+		t.Fatalf("expected a backup after saving: %v", err)
+	}
+
+	if err := service.RemoveMember(ctx, token, "test-vault", "alice"); err != nil {
+		t.Fatalf("RemoveMember() failed: %v", err)
+	}
+	if _, err := os.Stat(backupPath); !os.IsNotExist(err) {
+		t.Errorf("expected the backup to be removed, got %v", err)
+	}
+}
+
+func TestRemoveMemberInOtherProcess(t *testing.T) {
+	service1, vaultDir := setupTestService(t)
+	ctx := context.Background()
+	service1.CreateVault(ctx, "test-vault", "my-password", "interactive")
+	token1, _ := service1.UnlockVault(ctx, "test-vault", "my-password")
+	service1.AddMember(ctx, token1, "test-vault", MemberInput{Name: "alice", Password: "alice-password", KDFProfile: "interactive"})
+
+	repo2, _ := vault.NewFileRepository(vaultDir)
+	service2 := NewVaultService(repo2, crypto.NewService())
+	t.Cleanup(service2.Stop)
+	token2, err := service2.UnlockVaultAsMember(ctx, "test-vault", "alice", "alice-password", "")
+	if err != nil {
+		t.Fatalf("UnlockVaultAsMember() failed: %v", err)
+	}
+
+	if err := service1.RemoveMember(ctx, token1, "test-vault", "alice"); err != nil {
+		t.Fatalf("RemoveMember() failed: %v", err)
+	}
+	if _, err := service2.ListPasswordRecords(ctx, token2, "test-vault"); err != domain.ErrVaultModified {
+		t.Errorf("expected ErrVaultModified for a session holding the old key, got %v", err)
+	}
+}
+
+func TestChangeMemberPassword(t *testing.T) {
+	service, token := setupRecordTest(t)
+	ctx := context.Background()
+	service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Password: "secret"})
+	service.AddMember(ctx, token, "test-vault", MemberInput{Name: "alice", Password: "alice-password", KDFProfile: "interactive"})
+	service.SetUnlockPolicy(UnlockPolicy{})
+
+	if err := service.ChangeMemberPassword(ctx, "test-vault", "alice", "wrong-password", "new-password", "", ""); err != domain.ErrInvalidMasterPassword {
+		t.Errorf("expected ErrInvalidMasterPassword, got %v", err)
+	}
+	if err := service.ChangeMemberPassword(ctx, "test-vault", "alice", "alice-password", "new-password", "", ""); err != nil {
+		t.Fatalf("ChangeMemberPassword() failed: %v", err)
+	}
+
+	if _, err := service.UnlockVaultAsMember(ctx, "test-vault", "alice", "alice-password", ""); err != domain.ErrInvalidMasterPassword {
+		t.Errorf("expected the old password to fail, got %v", err)
+	}
+	aliceToken, err := service.UnlockVaultAsMember(ctx, "test-vault", "alice", "new-password", "")
+	if err != nil {
+		t.Fatalf("UnlockVaultAsMember() with the new password failed: %v", err)
+	}
+
+	// The data key is unchanged, so the owner is not affected
+	for _, memberToken := range []string{token, aliceToken} {
+		if _, err := service.GetPasswordRecord(ctx, memberToken, "test-vault", "github"); err != nil {
+			t.Errorf("GetPasswordRecord() failed: %v", err)
+		}
+	}
+	if err := service.ChangeMasterPassword(ctx, "test-vault", "my-password", "owner-password", ""); err != nil {
+		t.Errorf("ChangeMasterPassword() of the first member failed: %v", err)
+	}
+	if _, err := service.UnlockVaultAsMember(ctx, "test-vault", OwnerMemberName, "owner-password", ""); err != nil {
+		t.Errorf("UnlockVaultAsMember() with the changed owner password failed: %v", err)
+	}
+}
+
+func TestSharedVaultTwoFactor(t *testing.T) {
+	service, _ := setupTestService(t)
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	setClock(service, now)
+
+	setup, err := service.CreateVaultWithTwoFactor(ctx, "test-vault", "my-password", "interactive")
+	if err != nil {
+		t.Fatalf("CreateVaultWithTwoFactor() failed: %v", err)
+	}
+	token, err := service.UnlockVaultWithCode(ctx, "test-vault", "my-password", twoFactorCode(t, setup, now))
+	if err != nil {
+		t.Fatalf("UnlockVaultWithCode() failed: %v", err)
+	}
+	if err := service.AddMember(ctx, token, "test-vault", MemberInput{Name: "alice", Password: "alice-password", KDFProfile: "interactive"}); err != nil {
+		t.Fatalf("AddMember() failed: %v", err)
+	}
+
+	// The two-factor key moves to the data key with the records
+	now = now.Add(time.Minute)
+	setClock(service, now)
+	if _, err := service.UnlockVaultAsMember(ctx, "test-vault", "alice", "alice-password", twoFactorCode(t, setup, now)); err != nil {
+		t.Errorf("UnlockVaultAsMember() failed: %v", err)
+	}
+}
+
+func TestRemoveMemberKeepsTwoFactor(t *testing.T) {
+	service, _ := setupTestService(t)
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	setClock(service, now)
+
+	setup, _ := service.CreateVaultWithTwoFactor(ctx, "test-vault", "my-password", "interactive")
+	token, err := service.UnlockVaultWithCode(ctx, "test-vault", "my-password", twoFactorCode(t, setup, now))
+	if err != nil {
+		t.Fatalf("UnlockVaultWithCode() failed: %v", err)
+	}
+	service.AddMember(ctx, token, "test-vault", MemberInput{Name: "alice", Password: "alice-password", KDFProfile: "interactive"})
+	if err := service.RemoveMember(ctx, token, "test-vault", "alice"); err != nil {
+		t.Fatalf("RemoveMember() failed: %v", err)
+	}
+
+	// The TOTP key alice enrolled with still works until an owner replaces it
+	now = now.Add(time.Minute)
+	setClock(service, now)
+	if _, err := service.UnlockVaultAsMember(ctx, "test-vault", OwnerMemberName, "my-password", twoFactorCode(t, setup, now)); err != nil {
+		t.Fatalf("UnlockVaultAsMember() with the old key failed: %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	setClock(service, now)
+	if err := service.DisableTwoFactor(ctx, token, "test-vault", twoFactorCode(t, setup, now)); err != nil {
+		t.Fatalf("DisableTwoFactor() failed: %v", err)
+	}
+	newSetup, err := NewTwoFactorSetup("test-vault")
+	if err != nil {
+		t.Fatalf("NewTwoFactorSetup() failed: %v", err)
+	}
+	if err := service.EnableTwoFactor(ctx, token, "test-vault", newSetup.URI, twoFactorCode(t, newSetup, now)); err != nil {
+		t.Fatalf("EnableTwoFactor() failed: %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	setClock(service, now)
+	if _, err := service.UnlockVaultAsMember(ctx, "test-vault", OwnerMemberName, "my-password", twoFactorCode(t, setup, now)); err != domain.ErrInvalidCredentials {
+		t.Errorf("expected ErrInvalidCredentials for the old key, got %v", err)
+	}
+	if _, err := service.UnlockVaultAsMember(ctx, "test-vault", OwnerMemberName, "my-password", twoFactorCode(t, newSetup, now)); err != nil {
+		t.Errorf("UnlockVaultAsMember() with the new key failed: %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/orlan/go-password-manager/internal/crypto"
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// vaultMigration upgrades a vault from one format version to the next
+type vaultMigration struct {
+	from, to string
+	// migrate changes metadata and the decrypted vault of vault name in
+	// place; metadata.Version is already set to the new version. key opens
+	// the vault: the key derived from the master password, or the data key
+	// of a shared vault.
+	migrate func(s *VaultService, name string, metadata *domain.VaultMetadata, vault *domain.Vault, key []byte) error
+}
+
+// vaultMigrations lists every change to the vault format, oldest first.
+// Each migration starts from the version the one before it produces, and
+// the last one produces domain.VaultFormatVersion. When the format changes,
+// bump the version, add a migration here and keep a vault in the old format
+// in testdata.
+var vaultMigrations = []vaultMigration{
+	{from: "1.0", to: "1.1", migrate: (*VaultService).migrateOptionalFields},
+	{from: "1.1", to: "1.2", migrate: (*VaultService).migrateAssociatedData},
+	{from: "1.2", to: "1.3", migrate: (*VaultService).migrateHeaderKeys},
+}
+
+// checkVaultVersion returns domain.ErrUnsupportedVaultVersion unless the
+// vault is in the current format or can be migrated to it
+func checkVaultVersion(metadata *domain.VaultMetadata) error {
+	if metadata.Version == domain.VaultFormatVersion || slices.ContainsFunc(vaultMigrations, func(m vaultMigration) bool {
+		return m.from == metadata.Version
+	}) {
+		return nil
+	}
+	return domain.ErrUnsupportedVaultVersion
+}
+
+// loadMetadata loads the metadata of a vault, refusing formats this program
+// does not know
+func (s *VaultService) loadMetadata(ctx context.Context, name string) (*domain.VaultMetadata, error) {
+	metadata, err := s.repo.Load(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVaultVersion(metadata); err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// migrateVault upgrades metadata and the decrypted vault to the current
+// format one version at a time. It reports whether any migration ran.
+func (s *VaultService) migrateVault(name string, metadata *domain.VaultMetadata, vault *domain.Vault, key []byte) (bool, error) {
+	migrated := false
+	for _, m := range vaultMigrations {
+		if metadata.Version != m.from {
+			continue
+		}
+		metadata.Version = m.to
+		if err := m.migrate(s, name, metadata, vault, key); err != nil {
+			return false, fmt.Errorf("failed to migrate vault from version %s to %s: %w", m.from, m.to, err)
+		}
+		migrated = true
+	}
+
+	if metadata.Version != domain.VaultFormatVersion {
+		return false, domain.ErrUnsupportedVaultVersion
+	}
+	return migrated, nil
+}
+
+// saveMigratedVault encrypts a migrated vault with key and saves it in the
+// current format, provided nobody saved the vault since it was loaded.
+// The version before the migration stays in the backup file.
+func (s *VaultService) saveMigratedVault(ctx context.Context, name string, metadata *domain.VaultMetadata, vault *domain.Vault, key []byte) error {
+	vaultData, err := json.Marshal(vault)
+	if err != nil {
+		return fmt.Errorf("failed to marshal vault: %w", err)
+	}
+	defer crypto.Wipe(vaultData)
+
+	if metadata.Nonce, metadata.Encrypted, err = s.crypto.Encrypt(vaultData, key, vaultAssociatedData(name, metadata)); err != nil {
+		return domain.ErrEncryptionFailed
+	}
+	return s.repo.SaveIfUnchanged(ctx, name, metadata, metadata.Revision)
+}
+
+// migrateOptionalFields upgrades version 1.0, whose fields were added over
+// time and may be missing, to 1.1, where they are always set: the KDF
+// parameters of single-user vaults, the roles and members MAC of shared
+// vaults, the audit log key and the type of every record.
+func (s *VaultService) migrateOptionalFields(name string, metadata *domain.VaultMetadata, vault *domain.Vault, key []byte) error {
+	if !metadata.Shared() && metadata.KDF == nil {
+		kdf := domain.LegacyKDFParams
+		metadata.KDF = &kdf
+	}
+
+	// Members of a vault shared before roles existed start with the least
+	// access; only the first member, who shared the vault, is an owner and
+	// grants the others their roles again. Anyone who can open the vault
+	// can also strip a newer one down to this format, so keeping the full
+	// access the members once had would let a viewer make itself an owner.
+	// A vault with roles but no MAC is left for the MAC check.
+	if metadata.Shared() && metadata.MembersMAC == nil && !slices.ContainsFunc(metadata.Members, func(member domain.VaultMember) bool {
+		return member.Role != ""
+	}) {
+		for i := range metadata.Members {
+			metadata.Members[i].Role = domain.RoleViewer
+		}
+		metadata.Members[0].Role = domain.RoleOwner
+		if err := s.macMembers(metadata, key); err != nil {
+			return err
+		}
+	}
+
+	if vault.AuditLog == nil {
+		auditLog, err := s.newAuditLogKey()
+		if err != nil {
+			return err
+		}
+		vault.AuditLog = auditLog
+	}
+	metadata.AuditLogKey = vault.AuditLog.PublicKey
+
+	migrateRecords(vault)
+	return nil
+}
+
+// migrateAssociatedData upgrades version 1.1 to 1.2, where the records are
+// encrypted with the vault header as additional data and the two-factor key
+// with the vault name. Saving the migrated vault encrypts the records that
+// way; the two-factor key is sealed again here.
+func (s *VaultService) migrateAssociatedData(name string, metadata *domain.VaultMetadata, vault *domain.Vault, key []byte) error {
+	if metadata.TwoFactor == nil {
+		return nil
+	}
+
+	// Sealed without additional data before 1.2
+	twoFactor, err := s.openTwoFactor(metadata.TwoFactor, key, nil)
+	if err != nil {
+		return err
+	}
+	metadata.TwoFactor, err = s.sealTwoFactor(twoFactor, key, twoFactorAssociatedData(name, metadata))
+	return err
+}
+
+// migrateHeaderKeys upgrades version 1.2 to 1.3, where the sealed two-factor
+// key and the audit log key are part of the header the records are
+// encrypted with, and the members of shared vaults are signed with a key
+// only owners hold instead of a MAC keyed from the data key. The audit log
+// key in the header was not authenticated before, so it is taken from the
+// records again; saving the migrated vault encrypts the records with the
+// new header.
+func (s *VaultService) migrateHeaderKeys(name string, metadata *domain.VaultMetadata, vault *domain.Vault, key []byte) error {
+	if vault.AuditLog != nil {
+		metadata.AuditLogKey = vault.AuditLog.PublicKey
+	}
+	if !metadata.Shared() {
+		return nil
+	}
+
+	// Any member could compute the MAC, so the roles are only as
+	// trustworthy as the members were until now
+	message, err := roster(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode members: %w", err)
+	}
+	if !s.crypto.VerifyMAC(message, metadata.MembersMAC, key) {
+		return domain.ErrVaultTampered
+	}
+	metadata.RosterKeys = nil
+	rosterKey, err := s.newRosterKey(metadata, nil)
+	if err != nil {
+		return err
+	}
+	defer crypto.Wipe(rosterKey)
+	return s.signMembers(metadata, rosterKey)
+}
+
+// macMembers sets the members MAC of a vault before version 1.3, keyed from
+// its data key
+func (s *VaultService) macMembers(metadata *domain.VaultMetadata, dataKey []byte) error {
+	message, err := roster(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode members: %w", err)
+	}
+	if metadata.MembersMAC, err = s.crypto.MAC(message, dataKey); err != nil {
+		return fmt.Errorf("failed to authenticate members: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,342 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/crypto"
+	"github.com/orlan/go-password-manager/internal/domain"
+	"github.com/orlan/go-password-manager/internal/vault"
+)
+
+// setupGoldenVault copies a vault from testdata into a new directory as
+// "golden" and returns a service on that directory
+func setupGoldenVault(t *testing.T, file string) (*VaultService, string) {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", file))
+	if err != nil {
+		t.Fatalf("failed to read golden vault: %v", err)
+	}
+	vaultDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(vaultDir, "golden"+vault.VaultExtension), data, 0600); err != nil {
+		t.Fatalf("failed to write golden vault: %v", err)
+	}
+
+	repo, _ := vault.NewFileRepository(vaultDir)
+	service := NewVaultService(repo, crypto.NewService())
+	t.Cleanup(service.Stop)
+	return service, vaultDir
+}
+
+// rewriteOldVault saves the test vault in an older format version, after
+// change has edited its header. key opens the vault.
+func rewriteOldVault(t *testing.T, service *VaultService, key []byte, version string, change func(metadata *domain.VaultMetadata)) {
+	t.Helper()
+
+	ctx := context.Background()
+	metadata, _ := service.repo.Load(ctx, "test-vault")
+	c := crypto.NewService()
+	data, err := c.Decrypt(metadata.Nonce, metadata.Encrypted, key, vaultAssociatedData("test-vault", metadata))
+	if err != nil {
+		t.Fatalf("Decrypt() failed: %v", err)
+	}
+	var twoFactor []byte
+	if metadata.TwoFactor != nil {
+		if twoFactor, err = c.Decrypt(metadata.TwoFactor.Nonce, metadata.TwoFactor.Secret, key, twoFactorAssociatedData("test-vault", metadata)); err != nil {
+			t.Fatalf("Decrypt() of the two-factor key failed: %v", err)
+		}
+	}
+
+	change(metadata)
+	metadata.Version = version
+	if metadata.Nonce, metadata.Encrypted, err = c.Encrypt(data, key, vaultAssociatedData("test-vault", metadata)); err != nil {
+		t.Fatalf("Encrypt() failed: %v", err)
+	}
+	if metadata.TwoFactor != nil {
+		metadata.TwoFactor = &domain.TwoFactorConfig{}
+		if metadata.TwoFactor.Nonce, metadata.TwoFactor.Secret, err = c.Encrypt(twoFactor, key, twoFactorAssociatedData("test-vault", metadata)); err != nil {
+			t.Fatalf("Encrypt() of the two-factor key failed: %v", err)
+		}
+	}
+	if err := service.repo.Save(ctx, "test-vault", metadata); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+}
+
+func TestVaultMigrationChain(t *testing.T) {
+	if vaultMigrations[0].from != "1.0" {
+		t.Errorf("the first migration should start at 1.0, got %s", vaultMigrations[0].from)
+	}
+	for i := 1; i < len(vaultMigrations); i++ {
+		if vaultMigrations[i].from != vaultMigrations[i-1].to {
+			t.Errorf("migration %d starts at %s, but the one before produces %s", i, vaultMigrations[i].from, vaultMigrations[i-1].to)
+		}
+	}
+	if last := vaultMigrations[len(vaultMigrations)-1]; last.to != domain.VaultFormatVersion {
+		t.Errorf("the last migration produces %s, not the current version %s", last.to, domain.VaultFormatVersion)
+	}
+
+	// Every version ever written has a golden vault
+	versions := []string{domain.VaultFormatVersion}
+	for _, m := range vaultMigrations {
+		versions = append(versions, m.from)
+	}
+	for _, version := range versions {
+		if files, _ := filepath.Glob(filepath.Join("testdata", "vault-"+version+"*.vault")); len(files) == 0 {
+			t.Errorf("no golden vault for version %s in testdata", version)
+		}
+	}
+}
+
+func TestGoldenVaults(t *testing.T) {
+	tests := []struct {
+		file     string
+		member   string
+		password string
+		migrated bool
+		records  map[string]domain.ItemType
+		check    func(t *testing.T, records []domain.PasswordRecord)
+	}{
+		{
+			// As first released: no KDF parameters, revision or item types
+			file:     "vault-1.0.vault",
+			password: "golden-password",
+			migrated: true,
+			records:  map[string]domain.ItemType{"github": domain.ItemTypeLogin, "email": domain.ItemTypeLogin},
+			check: func(t *testing.T, records []domain.PasswordRecord) {
+				if records[0].Username != "octocat" || records[0].Password != "correct-horse-battery" {
+					t.Errorf("unexpected record %+v", records[0])
+				}
+			},
+		},
+		{
+			// Shared before members had roles
+			file:     "vault-1.0-shared.vault",
+			member:   "alice",
+			password: "alice-password",
+			migrated: true,
+			records:  map[string]domain.ItemType{"github": domain.ItemTypeLogin, "wifi": domain.ItemTypeSecureNote},
+			check: func(t *testing.T, records []domain.PasswordRecord) {
+				if records[0].Password != "correct-horse-battery-staple" || records[1].Notes != "guest network: hunter22" {
+					t.Errorf("unexpected records %+v", records)
+				}
+			},
+		},
+		{
+			// Before the header was authenticated
+			file:     "vault-1.1.vault",
+			password: "golden-password",
+			migrated: true,
+			records:  map[string]domain.ItemType{"github": domain.ItemTypeLogin, "visa": domain.ItemTypeCard},
+			check: func(t *testing.T, records []domain.PasswordRecord) {
+				if records[1].Card == nil || records[1].Card.Number != "4111111111111111" {
+					t.Errorf("unexpected record %+v", records[1])
+				}
+			},
+		},
+		{
+			// Before the two-factor key and audit log key were authenticated
+			file:     "vault-1.2.vault",
+			password: "golden-password",
+			migrated: true,
+			records:  map[string]domain.ItemType{"github": domain.ItemTypeLogin, "visa": domain.ItemTypeCard},
+			check: func(t *testing.T, records []domain.PasswordRecord) {
+				if records[1].Card == nil || records[1].Card.Number != "4111111111111111" {
+					t.Errorf("unexpected record %+v", records[1])
+				}
+			},
+		},
+		{
+			file:     "vault-1.3.vault",
+			password: "golden-password",
+			records:  map[string]domain.ItemType{"github": domain.ItemTypeLogin, "visa": domain.ItemTypeCard},
+			check: func(t *testing.T, records []domain.PasswordRecord) {
+				if records[1].Card == nil || records[1].Card.Number != "4111111111111111" {
+					t.Errorf("unexpected record %+v", records[1])
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			service, vaultDir := setupGoldenVault(t, tt.file)
+			ctx := context.Background()
+			before, _ := service.repo.Load(ctx, "golden")
+
+			token, err := service.UnlockVaultAsMember(ctx, "golden", tt.member, tt.password, "")
+			if err != nil {
+				t.Fatalf("UnlockVaultAsMember() failed: %v", err)
+			}
+			records, err := service.ListPasswordRecords(ctx, token, "golden")
+			if err != nil {
+				t.Fatalf("ListPasswordRecords() failed: %v", err)
+			}
+			if len(records) != len(tt.records) {
+				t.Fatalf("expected %d records, got %d", len(tt.records), len(records))
+			}
+			for _, record := range records {
+				if record.Type != tt.records[record.Name] {
+					t.Errorf("record %q: expected type %q, got %q", record.Name, tt.records[record.Name], record.Type)
+				}
+			}
+			tt.check(t, records)
+
+			// The vault is saved in the current format with every field set
+			metadata, _ := service.repo.Load(ctx, "golden")
+			if metadata.Version != domain.VaultFormatVersion || metadata.AuditLogKey == nil {
+				t.Errorf("vault not in the current format: %+v", metadata)
+			}
+			if metadata.Shared() {
+				if metadata.MembersSignature == nil || metadata.MembersMAC != nil || metadata.Member("alice").Role == "" {
+					t.Errorf("members not signed: %+v", metadata.Members)
+				}
+				// Only the owner can sign the members
+				if metadata.Member(OwnerMemberName).RosterKey == nil || metadata.Member("alice").RosterKey != nil {
+					t.Errorf("roster key not sealed to the owner only: %+v", metadata.Members)
+				}
+			} else if metadata.KDF == nil {
+				t.Error("expected KDF parameters")
+			}
+			if migrated := metadata.Revision != before.Revision; migrated != tt.migrated {
+				t.Errorf("expected migrated %v, got %v", tt.migrated, migrated)
+			}
+			if _, err := os.Stat(filepath.Join(vaultDir, "golden"+vault.VaultExtension+vault.BackupExtension)); tt.migrated && err != nil {
+				t.Errorf("expected a backup of the old format: %v", err)
+			}
+
+			if entries, err := service.AuditLog(ctx, token, "golden"); err != nil && err != domain.ErrPermissionDenied {
+				t.Errorf("AuditLog() failed: %v", err)
+			} else if err == nil && entries[0].Event != domain.AuditLogUnlock {
+				t.Errorf("unexpected audit log %+v", entries)
+			}
+
+			// Another process opens the migrated vault without migrating again
+			repo, _ := vault.NewFileRepository(vaultDir)
+			other := NewVaultService(repo, crypto.NewService())
+			t.Cleanup(other.Stop)
+			if _, err := other.UnlockVaultAsMember(ctx, "golden", tt.member, tt.password, ""); err != nil {
+				t.Fatalf("unlocking the migrated vault failed: %v", err)
+			}
+			if after, _ := repo.Load(ctx, "golden"); after.Revision != metadata.Revision {
+				t.Error("the migrated vault was saved again")
+			}
+		})
+	}
+}
+
+func TestTwoFactorVaultMigration(t *testing.T) {
+	service, _ := setupTestService(t)
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	setClock(service, now)
+	setup, _ := service.CreateVaultWithTwoFactor(ctx, "test-vault", "my-password", "interactive")
+
+	metadata, _ := service.repo.Load(ctx, "test-vault")
+	key, _ := crypto.NewService().DeriveKey("my-password", metadata.Salt, *metadata.KDF)
+	rewriteOldVault(t, service, key, "1.1", func(metadata *domain.VaultMetadata) {})
+
+	if _, err := service.UnlockVaultWithCode(ctx, "test-vault", "my-password", twoFactorCode(t, setup, now)); err != nil {
+		t.Fatalf("UnlockVaultWithCode() failed: %v", err)
+	}
+
+	// The two-factor key is now bound to the vault name
+	metadata, _ = service.repo.Load(ctx, "test-vault")
+	if metadata.Version != domain.VaultFormatVersion {
+		t.Fatalf("expected version %s, got %s", domain.VaultFormatVersion, metadata.Version)
+	}
+	if _, err := service.openTwoFactor(metadata.TwoFactor, key, nil); err == nil {
+		t.Error("the two-factor key still opens without additional data")
+	}
+	if _, err := service.openTwoFactor(metadata.TwoFactor, key, twoFactorAssociatedData("test-vault", metadata)); err != nil {
+		t.Errorf("openTwoFactor() failed: %v", err)
+	}
+}
+
+func TestHeaderKeysMigration(t *testing.T) {
+	service, _ := setupTestService(t)
+	ctx := context.Background()
+	service.CreateVault(ctx, "test-vault", "my-password", "interactive")
+	service.CreateVault(ctx, "other-vault", "my-password", "interactive")
+	other, _ := service.repo.Load(ctx, "other-vault")
+
+	// 1.2 did not authenticate the audit log key in the header
+	metadata, _ := service.repo.Load(ctx, "test-vault")
+	auditLogKey := metadata.AuditLogKey
+	key, _ := crypto.NewService().DeriveKey("my-password", metadata.Salt, *metadata.KDF)
+	rewriteOldVault(t, service, key, "1.2", func(metadata *domain.VaultMetadata) {
+		metadata.AuditLogKey = other.AuditLogKey
+	})
+
+	if _, err := service.UnlockVault(ctx, "test-vault", "my-password"); err != nil {
+		t.Fatalf("UnlockVault() failed: %v", err)
+	}
+
+	metadata, _ = service.repo.Load(ctx, "test-vault")
+	if metadata.Version != domain.VaultFormatVersion {
+		t.Fatalf("expected version %s, got %s", domain.VaultFormatVersion, metadata.Version)
+	}
+	if !bytes.Equal(metadata.AuditLogKey, auditLogKey) {
+		t.Error("the audit log key in the header was not taken from the records")
+	}
+	if _, err := crypto.NewService().Decrypt(metadata.Nonce, metadata.Encrypted, key, vaultAssociatedData("test-vault", metadata)); err != nil {
+		t.Errorf("the records are not encrypted with the new header: %v", err)
+	}
+}
+
+func TestRestoredOldVault(t *testing.T) {
+	service, vaultDir := setupGoldenVault(t, "vault-1.0.vault")
+	ctx := context.Background()
+	token, err := service.UnlockVault(ctx, "golden", "golden-password")
+	if err != nil {
+		t.Fatalf("UnlockVault() failed: %v", err)
+	}
+
+	// Put the backup in the old format back while the session is open
+	path := filepath.Join(vaultDir, "golden"+vault.VaultExtension)
+	data, _ := os.ReadFile(path + vault.BackupExtension)
+	os.WriteFile(path, data, 0600)
+
+	if _, err := service.ListPasswordRecords(ctx, token, "golden"); err != domain.ErrVaultModified {
+		t.Errorf("expected ErrVaultModified, got %v", err)
+	}
+	if _, err := service.UnlockVault(ctx, "golden", "golden-password"); err != nil {
+		t.Errorf("unlocking the restored vault failed: %v", err)
+	}
+}
+
+func TestNewerVaultVersion(t *testing.T) {
+	service, _ := setupGoldenVault(t, "vault-1.3.vault")
+	ctx := context.Background()
+	token, err := service.UnlockVault(ctx, "golden", "golden-password")
+	if err != nil {
+		t.Fatalf("UnlockVault() failed: %v", err)
+	}
+
+	// A newer version of the program upgrades the vault
+	metadata, _ := service.repo.Load(ctx, "golden")
+	metadata.Version = "9.0"
+	service.repo.SaveIfUnchanged(ctx, "golden", metadata, metadata.Revision)
+
+	if _, err := service.ListPasswordRecords(ctx, token, "golden"); err != domain.ErrUnsupportedVaultVersion {
+		t.Errorf("open session: expected ErrUnsupportedVaultVersion, got %v", err)
+	}
+	if _, err := service.AddPasswordRecord(ctx, token, "golden", RecordInput{Name: "gitlab", Password: "secret"}); err != domain.ErrUnsupportedVaultVersion {
+		t.Errorf("save: expected ErrUnsupportedVaultVersion, got %v", err)
+	}
+	if _, err := service.UnlockVault(ctx, "golden", "golden-password"); err != domain.ErrUnsupportedVaultVersion {
+		t.Errorf("unlock: expected ErrUnsupportedVaultVersion, got %v", err)
+	}
+	if err := service.ChangeMasterPassword(ctx, "golden", "golden-password", "new-password", ""); err != domain.ErrUnsupportedVaultVersion {
+		t.Errorf("change password: expected ErrUnsupportedVaultVersion, got %v", err)
+	}
+
+	if metadata, _ := service.repo.Load(ctx, "golden"); metadata.Version != "9.0" {
+		t.Errorf("the newer vault was overwritten with version %s", metadata.Version)
+	}
+}
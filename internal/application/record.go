@@ -0,0 +1,244 @@
+package application
+
+import (
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+	"github.com/orlan/go-password-manager/internal/generator"
+)
+
+// RecordInput holds the fields of a new vault item. An empty Type creates
+// a login; other types carry their data in the matching field.
+type RecordInput struct {
+	Type          domain.ItemType
+	Name          string
+	Username      string
+	Password      string
+	TOTP          string
+	URLs          []string
+	Notes         string
+	Folder        string
+	Tags          []string
+	CustomFields  []domain.CustomField
+	Card          *domain.CardData
+	SSHKey        *domain.SSHKeyData
+	APICredential *domain.APICredentialData
+
+	// Generate, when set and Password is empty, generates the password
+	// with this policy
+	Generate *generator.PasswordPolicy
+}
+
+// RecordResult describes the outcome of adding or updating a record
+type RecordResult struct {
+	// GeneratedPassword is set when the password was generated
+	GeneratedPassword string
+	// Warnings describe problems that did not stop the record from being
+	// saved, such as a password found in a data breach
+	Warnings []string
+}
+
+// RecordUpdate lists the fields to change on an existing record.
+// A nil field is left unchanged; a pointer to an empty value clears it.
+// Type data replaces the record's data as a whole. The type of an item
+// cannot change.
+type RecordUpdate struct {
+	Username      *string
+	Password      *string
+	TOTP          *string
+	URLs          *[]string
+	Notes         *string
+	Folder        *string
+	Tags          *[]string
+	CustomFields  *[]domain.CustomField
+	Card          *domain.CardData
+	SSHKey        *domain.SSHKeyData
+	APICredential *domain.APICredentialData
+}
+
+// IsEmpty reports whether the update would change nothing
+func (u RecordUpdate) IsEmpty() bool {
+	return u.Username == nil && u.Password == nil && u.TOTP == nil && u.URLs == nil &&
+		u.Notes == nil && u.Folder == nil && u.Tags == nil && u.CustomFields == nil &&
+		u.Card == nil && u.SSHKey == nil && u.APICredential == nil
+}
+
+// apply copies the set fields of the update onto record
+func (u RecordUpdate) apply(record *domain.PasswordRecord) {
+	if u.Username != nil {
+		record.Username = *u.Username
+	}
+	if u.Password != nil {
+		record.Password = *u.Password
+	}
+	if u.TOTP != nil {
+		record.TOTP = *u.TOTP
+	}
+	if u.URLs != nil {
+		record.URLs = normalizeList(*u.URLs)
+	}
+	if u.Notes != nil {
+		record.Notes = *u.Notes
+	}
+	if u.Folder != nil {
+		record.Folder = strings.TrimSpace(*u.Folder)
+	}
+	if u.Tags != nil {
+		record.Tags = normalizeList(*u.Tags)
+	}
+	if u.CustomFields != nil {
+		record.CustomFields = normalizeCustomFields(*u.CustomFields)
+	}
+	if u.Card != nil {
+		card := *u.Card
+		record.Card = &card
+	}
+	if u.SSHKey != nil {
+		key := *u.SSHKey
+		record.SSHKey = &key
+	}
+	if u.APICredential != nil {
+		credential := *u.APICredential
+		record.APICredential = &credential
+	}
+}
+
+// newRecord builds a validated record from input. The ID and timestamps
+// are left to the caller.
+func newRecord(input RecordInput) (domain.PasswordRecord, error) {
+	itemType, err := domain.ParseItemType(string(input.Type))
+	if err != nil {
+		return domain.PasswordRecord{}, err
+	}
+
+	record := domain.PasswordRecord{
+		Type:         itemType,
+		Name:         input.Name,
+		Username:     input.Username,
+		Password:     input.Password,
+		TOTP:         input.TOTP,
+		URLs:         normalizeList(input.URLs),
+		Notes:        input.Notes,
+		Folder:       strings.TrimSpace(input.Folder),
+		Tags:         normalizeList(input.Tags),
+		CustomFields: normalizeCustomFields(input.CustomFields),
+	}
+	if input.Card != nil {
+		card := *input.Card
+		record.Card = &card
+	}
+	if input.SSHKey != nil {
+		key := *input.SSHKey
+		record.SSHKey = &key
+	}
+	if input.APICredential != nil {
+		credential := *input.APICredential
+		record.APICredential = &credential
+	}
+
+	if err := validateRecord(&record); err != nil {
+		return domain.PasswordRecord{}, err
+	}
+	return record, nil
+}
+
+// validateRecord normalizes the type data of record and checks it against
+// the schema of its type. The card number is stored without grouping, the
+// fingerprint of an SSH public key is computed rather than trusted, and a
+// TOTP seed must parse.
+func validateRecord(record *domain.PasswordRecord) error {
+	if record.TOTP = strings.TrimSpace(record.TOTP); record.TOTP != "" {
+		if _, err := parseTOTP(record.TOTP); err != nil {
+			return err
+		}
+	}
+
+	if record.Card != nil {
+		record.Card.Number = domain.CardDigits(strings.TrimSpace(record.Card.Number))
+	}
+
+	if record.SSHKey != nil {
+		record.SSHKey.Fingerprint = ""
+		if publicKey := strings.TrimSpace(record.SSHKey.PublicKey); publicKey != "" {
+			parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(publicKey))
+			if err != nil {
+				return domain.ErrInvalidItem
+			}
+			record.SSHKey.PublicKey = publicKey
+			record.SSHKey.Fingerprint = ssh.FingerprintSHA256(parsed)
+		}
+	}
+
+	return record.Validate()
+}
+
+// migrateRecords gives records written before items were typed an explicit
+// type
+func migrateRecords(vault *domain.Vault) {
+	for i := range vault.Records {
+		if vault.Records[i].Type == "" {
+			vault.Records[i].Type = domain.ItemTypeLogin
+		}
+	}
+}
+
+// normalizeList trims entries and drops empty and duplicate ones
+func normalizeList(values []string) []string {
+	var result []string
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value != "" && !slices.Contains(result, value) {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// normalizeCustomFields copies fields, defaulting an empty type to text
+func normalizeCustomFields(fields []domain.CustomField) []domain.CustomField {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	result := make([]domain.CustomField, len(fields))
+	for i, field := range fields {
+		field.Name = strings.TrimSpace(field.Name)
+		if field.Type == "" {
+			field.Type = domain.CustomFieldText
+		}
+		result[i] = field
+	}
+	return result
+}
+
+// cloneRecord returns a deep copy of record so callers cannot modify
+// the in-memory vault through shared slices. The password history is left
+// out; it is only returned by GetRecordHistory.
+func cloneRecord(record domain.PasswordRecord) domain.PasswordRecord {
+	record.History = nil
+	return copyRecord(record)
+}
+
+// copyRecord returns a deep copy of record, password history included
+func copyRecord(record domain.PasswordRecord) domain.PasswordRecord {
+	record.History = slices.Clone(record.History)
+	record.URLs = slices.Clone(record.URLs)
+	record.Tags = slices.Clone(record.Tags)
+	record.CustomFields = slices.Clone(record.CustomFields)
+	if record.Card != nil {
+		card := *record.Card
+		record.Card = &card
+	}
+	if record.SSHKey != nil {
+		key := *record.SSHKey
+		record.SSHKey = &key
+	}
+	if record.APICredential != nil {
+		credential := *record.APICredential
+		record.APICredential = &credential
+	}
+	return record
+}
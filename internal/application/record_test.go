@@ -0,0 +1,522 @@
+package application
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"slices"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+	"github.com/orlan/go-password-manager/internal/generator"
+)
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+// setupRecordTest returns a service with an unlocked, empty vault
+func setupRecordTest(t *testing.T) (*VaultService, string) {
+	t.Helper()
+
+	service, _ := setupTestService(t)
+	ctx := context.Background()
+
+	if err := service.CreateVault(ctx, "test-vault", "my-password", "interactive"); err != nil {
+		t.Fatalf("CreateVault() failed: %v", err)
+	}
+	token, err := service.UnlockVault(ctx, "test-vault", "my-password")
+	if err != nil {
+		t.Fatalf("UnlockVault() failed: %v", err)
+	}
+
+	return service, token
+}
+
+func TestRecordFields(t *testing.T) {
+	t.Run("stores and normalizes optional fields", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		_, err := service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{
+			Name:     "github",
+			Username: "user",
+			Password: "pass",
+			URLs:     []string{" https://github.com ", "", "https://github.com"},
+			Notes:    "recovery codes in the safe",
+			Folder:   " Work ",
+			Tags:     []string{"dev", " dev", "code"},
+			CustomFields: []domain.CustomField{
+				{Name: "PIN", Value: "1234", Type: domain.CustomFieldHidden},
+				{Name: "Account", Value: "42"},
+			},
+		})
+		if err != nil {
+			t.Fatalf("AddPasswordRecord() failed: %v", err)
+		}
+
+		record, err := service.GetPasswordRecord(ctx, token, "test-vault", "github")
+		if err != nil {
+			t.Fatalf("GetPasswordRecord() failed: %v", err)
+		}
+
+		if !slices.Equal(record.URLs, []string{"https://github.com"}) {
+			t.Errorf("unexpected URLs %q", record.URLs)
+		}
+		if record.Notes != "recovery codes in the safe" {
+			t.Errorf("unexpected notes %q", record.Notes)
+		}
+		if record.Folder != "Work" {
+			t.Errorf("expected folder %q, got %q", "Work", record.Folder)
+		}
+		if !slices.Equal(record.Tags, []string{"dev", "code"}) {
+			t.Errorf("unexpected tags %q", record.Tags)
+		}
+		if len(record.CustomFields) != 2 {
+			t.Fatalf("expected 2 custom fields, got %d", len(record.CustomFields))
+		}
+		if record.CustomFields[1].Type != domain.CustomFieldText {
+			t.Errorf("empty field type should default to text, got %q", record.CustomFields[1].Type)
+		}
+		if !record.CustomFields[0].IsSecret() || record.CustomFields[1].IsSecret() {
+			t.Error("only the hidden field should be secret")
+		}
+	})
+
+	t.Run("fields survive lock and unlock", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		_, err := service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{
+			Name:         "github",
+			Tags:         []string{"dev"},
+			CustomFields: []domain.CustomField{{Name: "PIN", Value: "1234", Type: domain.CustomFieldHidden}},
+		})
+		if err != nil {
+			t.Fatalf("AddPasswordRecord() failed: %v", err)
+		}
+
+		service.LockVault(ctx, token)
+		token, err = service.UnlockVault(ctx, "test-vault", "my-password")
+		if err != nil {
+			t.Fatalf("UnlockVault() failed: %v", err)
+		}
+
+		record, err := service.GetPasswordRecord(ctx, token, "test-vault", "github")
+		if err != nil {
+			t.Fatalf("GetPasswordRecord() failed: %v", err)
+		}
+		if !slices.Equal(record.Tags, []string{"dev"}) || len(record.CustomFields) != 1 || record.CustomFields[0].Value != "1234" {
+			t.Errorf("fields were not persisted: %+v", record)
+		}
+	})
+
+	t.Run("rejects invalid custom fields", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		invalid := [][]domain.CustomField{
+			{{Name: "", Value: "x"}},
+			{{Name: "  ", Value: "x"}},
+			{{Name: "PIN", Value: "1234", Type: "secret"}},
+		}
+		for _, fields := range invalid {
+			_, err := service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", CustomFields: fields})
+			if err != domain.ErrInvalidCustomField {
+				t.Errorf("AddPasswordRecord(%+v): expected ErrInvalidCustomField, got %v", fields, err)
+			}
+		}
+
+		if _, err := service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github"}); err != nil {
+			t.Fatalf("AddPasswordRecord() failed: %v", err)
+		}
+		_, err := service.UpdatePasswordRecord(ctx, token, "test-vault", "github", RecordUpdate{
+			CustomFields: &invalid[2],
+		})
+		if err != domain.ErrInvalidCustomField {
+			t.Errorf("UpdatePasswordRecord(): expected ErrInvalidCustomField, got %v", err)
+		}
+	})
+
+	t.Run("returned records do not share slices with the vault", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		_, err := service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Tags: []string{"dev"}})
+		if err != nil {
+			t.Fatalf("AddPasswordRecord() failed: %v", err)
+		}
+
+		record, _ := service.GetPasswordRecord(ctx, token, "test-vault", "github")
+		record.Tags[0] = "changed"
+		records, _ := service.ListPasswordRecords(ctx, token, "test-vault")
+		records[0].Tags[0] = "changed"
+
+		record, _ = service.GetPasswordRecord(ctx, token, "test-vault", "github")
+		if record.Tags[0] != "dev" {
+			t.Errorf("vault record was modified through a returned copy: %q", record.Tags)
+		}
+	})
+}
+
+func TestGeneratedPassword(t *testing.T) {
+	t.Run("generates a password when none is given", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		policy := generator.PasswordPolicy{Length: 32, Lowercase: true, Digits: true}
+		result, err := service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{
+			Name:     "github",
+			Username: "user",
+			Generate: &policy,
+		})
+		if err != nil {
+			t.Fatalf("AddPasswordRecord() failed: %v", err)
+		}
+		if len(result.GeneratedPassword) != 32 {
+			t.Fatalf("expected a 32 character password, got %q", result.GeneratedPassword)
+		}
+
+		record, _ := service.GetPasswordRecord(ctx, token, "test-vault", "github")
+		if record.Password != result.GeneratedPassword {
+			t.Error("stored password should be the generated one")
+		}
+	})
+
+	t.Run("keeps a given password", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+
+		policy := generator.DefaultPasswordPolicy()
+		result, err := service.AddPasswordRecord(context.Background(), token, "test-vault", RecordInput{
+			Name:     "github",
+			Username: "user",
+			Password: "mine",
+			Generate: &policy,
+		})
+		if err != nil {
+			t.Fatalf("AddPasswordRecord() failed: %v", err)
+		}
+		if result.GeneratedPassword != "" {
+			t.Error("no password should be generated when one is given")
+		}
+	})
+
+	t.Run("rejects invalid policy", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+
+		policy := generator.PasswordPolicy{Length: 2, Lowercase: true}
+		_, err := service.AddPasswordRecord(context.Background(), token, "test-vault", RecordInput{
+			Name:     "github",
+			Generate: &policy,
+		})
+		if !errors.Is(err, generator.ErrInvalidPolicy) {
+			t.Errorf("expected ErrInvalidPolicy, got %v", err)
+		}
+	})
+}
+
+func TestRecordUpdate(t *testing.T) {
+	t.Run("changes only the fields that are set", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		_, err := service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{
+			Name:     "github",
+			Username: "user",
+			Password: "pass",
+			Notes:    "old notes",
+			Folder:   "Work",
+			Tags:     []string{"dev"},
+		})
+		if err != nil {
+			t.Fatalf("AddPasswordRecord() failed: %v", err)
+		}
+
+		tags := []string{"code", "oss"}
+		_, err = service.UpdatePasswordRecord(ctx, token, "test-vault", "github", RecordUpdate{
+			Notes: stringPtr("new notes"),
+			Tags:  &tags,
+		})
+		if err != nil {
+			t.Fatalf("UpdatePasswordRecord() failed: %v", err)
+		}
+
+		record, _ := service.GetPasswordRecord(ctx, token, "test-vault", "github")
+		if record.Notes != "new notes" || !slices.Equal(record.Tags, tags) {
+			t.Errorf("fields were not updated: %+v", record)
+		}
+		if record.Username != "user" || record.Password != "pass" || record.Folder != "Work" {
+			t.Errorf("unset fields should be unchanged: %+v", record)
+		}
+	})
+
+	t.Run("empty values clear fields", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		_, err := service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{
+			Name:         "github",
+			Folder:       "Work",
+			URLs:         []string{"https://github.com"},
+			CustomFields: []domain.CustomField{{Name: "PIN", Value: "1234"}},
+		})
+		if err != nil {
+			t.Fatalf("AddPasswordRecord() failed: %v", err)
+		}
+
+		_, err = service.UpdatePasswordRecord(ctx, token, "test-vault", "github", RecordUpdate{
+			Folder:       stringPtr(""),
+			URLs:         &[]string{},
+			CustomFields: &[]domain.CustomField{},
+		})
+		if err != nil {
+			t.Fatalf("UpdatePasswordRecord() failed: %v", err)
+		}
+
+		record, _ := service.GetPasswordRecord(ctx, token, "test-vault", "github")
+		if record.Folder != "" || len(record.URLs) != 0 || len(record.CustomFields) != 0 {
+			t.Errorf("fields were not cleared: %+v", record)
+		}
+	})
+
+	t.Run("IsEmpty", func(t *testing.T) {
+		if !(RecordUpdate{}).IsEmpty() {
+			t.Error("zero update should be empty")
+		}
+		if (RecordUpdate{Notes: stringPtr("")}).IsEmpty() {
+			t.Error("update clearing notes should not be empty")
+		}
+	})
+}
+
+func TestLegacyRecordDecoding(t *testing.T) {
+	// Records written before the optional fields existed
+	data := []byte(`{"name":"test-vault","records":[{"id":"1","name":"gmail","username":"user","password":"pass",` +
+		`"created_at":"2024-01-01T00:00:00Z","updated_at":"2024-01-01T00:00:00Z"}]}`)
+
+	var vault domain.Vault
+	if err := json.Unmarshal(data, &vault); err != nil {
+		t.Fatalf("failed to decode legacy vault: %v", err)
+	}
+
+	record := vault.Records[0]
+	if record.Kind() != domain.ItemTypeLogin {
+		t.Errorf("legacy record should be a login, got %q", record.Kind())
+	}
+	if record.Username != "user" || record.Password != "pass" {
+		t.Errorf("unexpected record %+v", record)
+	}
+	if record.URLs != nil || record.Tags != nil || record.CustomFields != nil || record.Notes != "" || record.Folder != "" {
+		t.Errorf("optional fields should be empty: %+v", record)
+	}
+
+	// Empty optional fields are omitted so unchanged records keep their shape
+	encoded, err := json.Marshal(record)
+	if err != nil {
+		t.Fatalf("failed to encode record: %v", err)
+	}
+	for _, key := range []string{"urls", "notes", "folder", "tags", "custom_fields"} {
+		if containsKey(encoded, key) {
+			t.Errorf("encoded record should omit %q: %s", key, encoded)
+		}
+	}
+}
+
+func TestMigrateRecords(t *testing.T) {
+	vault := &domain.Vault{Records: []domain.PasswordRecord{
+		{Name: "legacy"},
+		{Name: "note", Type: domain.ItemTypeSecureNote, Notes: "text"},
+	}}
+
+	migrateRecords(vault)
+
+	if vault.Records[0].Type != domain.ItemTypeLogin {
+		t.Errorf("untyped record should become a login, got %q", vault.Records[0].Type)
+	}
+	if vault.Records[1].Type != domain.ItemTypeSecureNote {
+		t.Errorf("typed record should keep its type, got %q", vault.Records[1].Type)
+	}
+}
+
+// testSSHKey returns a PEM private key and its authorized_keys public key
+func testSSHKey(t *testing.T) (string, string) {
+	t.Helper()
+
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(privateKey, "")
+	if err != nil {
+		t.Fatalf("failed to marshal private key: %v", err)
+	}
+	sshPublicKey, err := ssh.NewPublicKey(publicKey)
+	if err != nil {
+		t.Fatalf("failed to convert public key: %v", err)
+	}
+
+	return string(pem.EncodeToMemory(block)), string(ssh.MarshalAuthorizedKey(sshPublicKey))
+}
+
+func TestTypedItems(t *testing.T) {
+	privateKey, publicKey := testSSHKey(t)
+
+	valid := []RecordInput{
+		{Name: "login", Username: "user", Password: "pass"},
+		{Type: domain.ItemTypeLogin, Name: "typed-login", Username: "user", Password: "pass"},
+		{Type: domain.ItemTypeSecureNote, Name: "note", Notes: "wifi password is on the router"},
+		{Type: domain.ItemTypeCard, Name: "visa", Card: &domain.CardData{
+			Cardholder: "Jane Doe", Number: "4111 1111 1111 1111", ExpMonth: 12, ExpYear: 2030, CVV: "123",
+		}},
+		{Type: domain.ItemTypeSSHKey, Name: "server", Username: "deploy", SSHKey: &domain.SSHKeyData{
+			PrivateKey: privateKey, PublicKey: publicKey,
+		}},
+		{Type: domain.ItemTypeAPICredential, Name: "stripe", APICredential: &domain.APICredentialData{
+			KeyID: "pk_live", Secret: "sk_live_123", Endpoint: "https://api.stripe.com",
+		}},
+	}
+
+	t.Run("adds valid items of every type", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		for _, input := range valid {
+			if _, err := service.AddPasswordRecord(ctx, token, "test-vault", input); err != nil {
+				t.Errorf("AddPasswordRecord(%s) failed: %v", input.Name, err)
+			}
+		}
+
+		record, err := service.GetPasswordRecord(ctx, token, "test-vault", "login")
+		if err != nil {
+			t.Fatalf("GetPasswordRecord() failed: %v", err)
+		}
+		if record.Type != domain.ItemTypeLogin {
+			t.Errorf("item without type should be stored as a login, got %q", record.Type)
+		}
+
+		card, _ := service.GetPasswordRecord(ctx, token, "test-vault", "visa")
+		if card.Card.Number != "4111111111111111" {
+			t.Errorf("card number should be stored without grouping, got %q", card.Card.Number)
+		}
+
+		key, _ := service.GetPasswordRecord(ctx, token, "test-vault", "server")
+		parsed, _, _, _, _ := ssh.ParseAuthorizedKey([]byte(publicKey))
+		if key.SSHKey.Fingerprint != ssh.FingerprintSHA256(parsed) {
+			t.Errorf("unexpected fingerprint %q", key.SSHKey.Fingerprint)
+		}
+	})
+
+	t.Run("rejects items that do not match their schema", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		invalid := map[string]RecordInput{
+			"unknown type":          {Type: "wallet", Name: "x"},
+			"login with card data":  {Name: "x", Username: "u", Password: "p", Card: &domain.CardData{Number: "4111111111111111"}},
+			"empty note":            {Type: domain.ItemTypeSecureNote, Name: "x", Notes: "  "},
+			"note with password":    {Type: domain.ItemTypeSecureNote, Name: "x", Notes: "text", Password: "p"},
+			"card without data":     {Type: domain.ItemTypeCard, Name: "x"},
+			"card failing luhn":     {Type: domain.ItemTypeCard, Name: "x", Card: &domain.CardData{Number: "4111111111111112"}},
+			"card with bad month":   {Type: domain.ItemTypeCard, Name: "x", Card: &domain.CardData{Number: "4111111111111111", ExpMonth: 13}},
+			"card with bad cvv":     {Type: domain.ItemTypeCard, Name: "x", Card: &domain.CardData{Number: "4111111111111111", CVV: "12a"}},
+			"ssh key without pem":   {Type: domain.ItemTypeSSHKey, Name: "x", SSHKey: &domain.SSHKeyData{PrivateKey: "not a key"}},
+			"ssh key bad public":    {Type: domain.ItemTypeSSHKey, Name: "x", SSHKey: &domain.SSHKeyData{PrivateKey: privateKey, PublicKey: "ssh-ed25519 garbage"}},
+			"api without secret":    {Type: domain.ItemTypeAPICredential, Name: "x", APICredential: &domain.APICredentialData{KeyID: "id"}},
+			"api with two payloads": {Type: domain.ItemTypeAPICredential, Name: "x", APICredential: &domain.APICredentialData{Secret: "s"}, Card: &domain.CardData{Number: "4111111111111111"}},
+		}
+
+		for name, input := range invalid {
+			_, err := service.AddPasswordRecord(ctx, token, "test-vault", input)
+			if err != domain.ErrInvalidItem && err != domain.ErrUnknownItemType {
+				t.Errorf("%s: expected ErrInvalidItem or ErrUnknownItemType, got %v", name, err)
+			}
+		}
+
+		records, _ := service.ListPasswordRecords(ctx, token, "test-vault")
+		if len(records) != 0 {
+			t.Errorf("invalid items should not be stored, got %d records", len(records))
+		}
+	})
+
+	t.Run("updates are validated against the item type", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		for _, input := range valid {
+			if _, err := service.AddPasswordRecord(ctx, token, "test-vault", input); err != nil {
+				t.Fatalf("AddPasswordRecord(%s) failed: %v", input.Name, err)
+			}
+		}
+
+		_, err := service.UpdatePasswordRecord(ctx, token, "test-vault", "visa", RecordUpdate{
+			Card: &domain.CardData{Number: "5500 0000 0000 0004", ExpMonth: 1, ExpYear: 2031},
+		})
+		if err != nil {
+			t.Fatalf("UpdatePasswordRecord() failed: %v", err)
+		}
+		card, _ := service.GetPasswordRecord(ctx, token, "test-vault", "visa")
+		if card.Card.Number != "5500000000000004" || card.Card.Cardholder != "" {
+			t.Errorf("card data should be replaced as a whole: %+v", card.Card)
+		}
+
+		updates := map[string]RecordUpdate{
+			"visa":   {Password: stringPtr("secret")},
+			"note":   {Notes: stringPtr("")},
+			"login":  {APICredential: &domain.APICredentialData{Secret: "s"}},
+			"stripe": {APICredential: &domain.APICredentialData{KeyID: "id"}},
+		}
+		for name, update := range updates {
+			_, err := service.UpdatePasswordRecord(ctx, token, "test-vault", name, update)
+			if err != domain.ErrInvalidItem {
+				t.Errorf("update of %s: expected ErrInvalidItem, got %v", name, err)
+			}
+		}
+
+		note, _ := service.GetPasswordRecord(ctx, token, "test-vault", "note")
+		if note.Notes == "" {
+			t.Error("rejected update should leave the record unchanged")
+		}
+	})
+
+	t.Run("lists items by type", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+
+		for _, input := range valid {
+			if _, err := service.AddPasswordRecord(ctx, token, "test-vault", input); err != nil {
+				t.Fatalf("AddPasswordRecord(%s) failed: %v", input.Name, err)
+			}
+		}
+
+		logins, err := service.ListPasswordRecordsByType(ctx, token, "test-vault", domain.ItemTypeLogin)
+		if err != nil {
+			t.Fatalf("ListPasswordRecordsByType() failed: %v", err)
+		}
+		if len(logins) != 2 {
+			t.Errorf("expected 2 logins, got %d", len(logins))
+		}
+
+		cards, _ := service.ListPasswordRecordsByType(ctx, token, "test-vault", domain.ItemTypeCard)
+		if len(cards) != 1 || cards[0].Name != "visa" {
+			t.Errorf("unexpected cards %+v", cards)
+		}
+
+		if _, err := service.ListPasswordRecordsByType(ctx, token, "test-vault", "wallet"); err != domain.ErrUnknownItemType {
+			t.Errorf("expected ErrUnknownItemType, got %v", err)
+		}
+	})
+}
+
+func containsKey(data []byte, key string) bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return false
+	}
+	_, ok := fields[key]
+	return ok
+}
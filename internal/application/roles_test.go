@@ -0,0 +1,300 @@
+package application
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/orlan/go-password-manager/internal/crypto"
+	"github.com/orlan/go-password-manager/internal/domain"
+	"github.com/orlan/go-password-manager/internal/transfer"
+)
+
+// setupRolesTest shares the test vault with an editor and a viewer and
+// returns the owner's session
+func setupRolesTest(t *testing.T) (*VaultService, string) {
+	t.Helper()
+
+	service, token := setupRecordTest(t)
+	ctx := context.Background()
+	service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Password: "secret"})
+	for _, input := range []MemberInput{
+		{Name: "erin", Password: "erin-password", KDFProfile: "interactive", Role: domain.RoleEditor},
+		{Name: "victor", Password: "victor-password", KDFProfile: "interactive"},
+	} {
+		if err := service.AddMember(ctx, token, "test-vault", input); err != nil {
+			t.Fatalf("AddMember(%q) failed: %v", input.Name, err)
+		}
+	}
+	return service, token
+}
+
+// unlockMember unlocks the test vault as a member whose password is the
+// member name followed by "-password"
+func unlockMember(t *testing.T, service *VaultService, member string) string {
+	t.Helper()
+
+	token, err := service.UnlockVaultAsMember(context.Background(), "test-vault", member, member+"-password", "")
+	if err != nil {
+		t.Fatalf("UnlockVaultAsMember(%q) failed: %v", member, err)
+	}
+	return token
+}
+
+func TestMemberRoles(t *testing.T) {
+	service, ownerToken := setupRolesTest(t)
+	ctx := context.Background()
+	editorToken := unlockMember(t, service, "erin")
+	viewerToken := unlockMember(t, service, "victor")
+
+	t.Run("roles are listed", func(t *testing.T) {
+		members, err := service.ListMembers(ctx, viewerToken, "test-vault")
+		if err != nil {
+			t.Fatalf("ListMembers() failed: %v", err)
+		}
+		want := []domain.MemberRole{domain.RoleOwner, domain.RoleEditor, domain.RoleViewer}
+		for i, member := range members {
+			if member.Role != want[i] {
+				t.Errorf("member %q: expected role %q, got %q", member.Name, want[i], member.Role)
+			}
+		}
+	})
+
+	t.Run("viewers only read", func(t *testing.T) {
+		if _, err := service.GetPasswordRecord(ctx, viewerToken, "test-vault", "github"); err != nil {
+			t.Errorf("GetPasswordRecord() failed: %v", err)
+		}
+		if _, err := service.ListPasswordRecords(ctx, viewerToken, "test-vault"); err != nil {
+			t.Errorf("ListPasswordRecords() failed: %v", err)
+		}
+
+		notes := "changed"
+		if _, err := service.AddPasswordRecord(ctx, viewerToken, "test-vault", RecordInput{Name: "gitlab", Password: "secret"}); err != domain.ErrPermissionDenied {
+			t.Errorf("AddPasswordRecord(): expected ErrPermissionDenied, got %v", err)
+		}
+		if _, err := service.UpdatePasswordRecord(ctx, viewerToken, "test-vault", "github", RecordUpdate{Notes: &notes}); err != domain.ErrPermissionDenied {
+			t.Errorf("UpdatePasswordRecord(): expected ErrPermissionDenied, got %v", err)
+		}
+		if err := service.DeletePasswordRecord(ctx, viewerToken, "test-vault", "github"); err != domain.ErrPermissionDenied {
+			t.Errorf("DeletePasswordRecord(): expected ErrPermissionDenied, got %v", err)
+		}
+		if err := service.RestoreRecordVersion(ctx, viewerToken, "test-vault", "github", 1); err != domain.ErrPermissionDenied {
+			t.Errorf("RestoreRecordVersion(): expected ErrPermissionDenied, got %v", err)
+		}
+		if _, err := service.ImportRecords(ctx, viewerToken, "test-vault", []byte("name,url,username,password\n"), ImportOptions{Format: "csv"}); err != domain.ErrPermissionDenied {
+			t.Errorf("ImportRecords(): expected ErrPermissionDenied, got %v", err)
+		}
+	})
+
+	t.Run("editors change records but not the vault", func(t *testing.T) {
+		if _, err := service.AddPasswordRecord(ctx, editorToken, "test-vault", RecordInput{Name: "gitlab", Password: "secret"}); err != nil {
+			t.Errorf("AddPasswordRecord() failed: %v", err)
+		}
+		if err := service.DeletePasswordRecord(ctx, editorToken, "test-vault", "gitlab"); err != nil {
+			t.Errorf("DeletePasswordRecord() failed: %v", err)
+		}
+
+		if err := service.AddMember(ctx, editorToken, "test-vault", MemberInput{Name: "mallory", Password: "mallory-password", KDFProfile: "interactive"}); err != domain.ErrPermissionDenied {
+			t.Errorf("AddMember(): expected ErrPermissionDenied, got %v", err)
+		}
+		if err := service.RemoveMember(ctx, editorToken, "test-vault", "victor"); err != domain.ErrPermissionDenied {
+			t.Errorf("RemoveMember(): expected ErrPermissionDenied, got %v", err)
+		}
+		if err := service.SetMemberRole(ctx, editorToken, "test-vault", "erin", domain.RoleOwner); err != domain.ErrPermissionDenied {
+			t.Errorf("SetMemberRole(): expected ErrPermissionDenied, got %v", err)
+		}
+		if _, err := service.ExportVault(ctx, editorToken, "test-vault", ExportOptions{Format: transfer.FormatEncrypted, Password: "export-password-123"}); err != domain.ErrPermissionDenied {
+			t.Errorf("ExportVault(): expected ErrPermissionDenied, got %v", err)
+		}
+		if err := service.DisableTwoFactor(ctx, editorToken, "test-vault", "123456"); err != domain.ErrPermissionDenied {
+			t.Errorf("DisableTwoFactor(): expected ErrPermissionDenied, got %v", err)
+		}
+	})
+
+	t.Run("members change their own password", func(t *testing.T) {
+		if err := service.ChangeMemberPassword(ctx, "test-vault", "victor", "victor-password", "victor-password", "", ""); err != nil {
+			t.Errorf("ChangeMemberPassword() failed: %v", err)
+		}
+	})
+
+	t.Run("owners change roles", func(t *testing.T) {
+		if err := service.SetMemberRole(ctx, ownerToken, "test-vault", "victor", domain.RoleEditor); err != nil {
+			t.Fatalf("SetMemberRole() failed: %v", err)
+		}
+		// The open session picks up the new role
+		if _, err := service.AddPasswordRecord(ctx, viewerToken, "test-vault", RecordInput{Name: "gitlab", Password: "secret"}); err != nil {
+			t.Errorf("AddPasswordRecord() after promotion failed: %v", err)
+		}
+
+		if err := service.SetMemberRole(ctx, ownerToken, "test-vault", "victor", "admin"); err != domain.ErrUnknownRole {
+			t.Errorf("expected ErrUnknownRole, got %v", err)
+		}
+		if err := service.SetMemberRole(ctx, ownerToken, "test-vault", "nobody", domain.RoleEditor); err != domain.ErrMemberNotFound {
+			t.Errorf("expected ErrMemberNotFound, got %v", err)
+		}
+	})
+
+	t.Run("a vault keeps an owner", func(t *testing.T) {
+		if err := service.SetMemberRole(ctx, ownerToken, "test-vault", OwnerMemberName, domain.RoleEditor); err != domain.ErrLastOwner {
+			t.Errorf("SetMemberRole(): expected ErrLastOwner, got %v", err)
+		}
+		if err := service.RemoveMember(ctx, ownerToken, "test-vault", OwnerMemberName); err != domain.ErrLastOwner {
+			t.Errorf("RemoveMember(): expected ErrLastOwner, got %v", err)
+		}
+
+		if err := service.SetMemberRole(ctx, ownerToken, "test-vault", "erin", domain.RoleOwner); err != nil {
+			t.Fatalf("SetMemberRole() failed: %v", err)
+		}
+		if err := service.SetMemberRole(ctx, ownerToken, "test-vault", OwnerMemberName, domain.RoleViewer); err != nil {
+			t.Errorf("SetMemberRole() with another owner failed: %v", err)
+		}
+		if err := service.AddMember(ctx, ownerToken, "test-vault", MemberInput{Name: "mallory", Password: "mallory-password", KDFProfile: "interactive"}); err != domain.ErrPermissionDenied {
+			t.Errorf("AddMember() after demotion: expected ErrPermissionDenied, got %v", err)
+		}
+	})
+}
+
+func TestSingleUserVaultOwnsEverything(t *testing.T) {
+	service, token := setupRecordTest(t)
+	ctx := context.Background()
+
+	if _, err := service.ExportVault(ctx, token, "test-vault", ExportOptions{Format: transfer.FormatEncrypted, Password: "export-password-123"}); err != nil {
+		t.Errorf("ExportVault() failed: %v", err)
+	}
+	if err := service.SetMemberRole(ctx, token, "test-vault", OwnerMemberName, domain.RoleEditor); err != domain.ErrMemberNotFound {
+		t.Errorf("expected ErrMemberNotFound, got %v", err)
+	}
+}
+
+func TestTamperedRoles(t *testing.T) {
+	service, ownerToken := setupRolesTest(t)
+	ctx := context.Background()
+	viewerToken := unlockMember(t, service, "victor")
+
+	// The viewer promotes themselves in the file, but cannot sign the
+	// members again without an owner's key
+	metadata, _ := service.repo.Load(ctx, "test-vault")
+	metadata.Member("victor").Role = domain.RoleOwner
+	if err := service.repo.Save(ctx, "test-vault", metadata); err != nil {
+		t.Fatalf("Save() failed: %v", err)
+	}
+
+	// Re-signing the members would hide the change
+	if err := service.SetMemberRole(ctx, ownerToken, "test-vault", "erin", domain.RoleViewer); err != domain.ErrVaultTampered {
+		t.Errorf("owner session: expected ErrVaultTampered, got %v", err)
+	}
+
+	// Bumping the revision makes open sessions reload the members
+	if err := service.repo.SaveIfUnchanged(ctx, "test-vault", metadata, metadata.Revision); err != nil {
+		t.Fatalf("SaveIfUnchanged() failed: %v", err)
+	}
+
+	if _, err := service.ListPasswordRecords(ctx, viewerToken, "test-vault"); err != domain.ErrVaultTampered {
+		t.Errorf("open session: expected ErrVaultTampered, got %v", err)
+	}
+
+	service.SetUnlockPolicy(UnlockPolicy{})
+	if _, err := service.UnlockVaultAsMember(ctx, "test-vault", "victor", "victor-password", ""); err != domain.ErrVaultTampered {
+		t.Errorf("unlock: expected ErrVaultTampered, got %v", err)
+	}
+}
+
+func TestRosterKeyReplacedByViewer(t *testing.T) {
+	service, ownerToken := setupRolesTest(t)
+	ctx := context.Background()
+	editorToken := unlockMember(t, service, "erin")
+	dataKey := slices.Clone(service.sessions[ownerToken].key.Bytes())
+
+	// The viewer holds the data key, so they can sign the members with a
+	// key of their own and encrypt the records for the changed header
+	metadata, _ := service.repo.Load(ctx, "test-vault")
+	previous := vaultAssociatedData("test-vault", metadata)
+	metadata.Member("victor").Role = domain.RoleOwner
+	metadata.RosterKeys = nil
+	rosterKey, _ := service.newRosterKey(metadata, nil)
+	if err := service.signMembers(metadata, rosterKey); err != nil {
+		t.Fatalf("signMembers() failed: %v", err)
+	}
+	if err := service.reencryptVault("test-vault", metadata, previous, dataKey, dataKey, nil); err != nil {
+		t.Fatalf("reencryptVault() failed: %v", err)
+	}
+	if err := service.repo.SaveIfUnchanged(ctx, "test-vault", metadata, metadata.Revision); err != nil {
+		t.Fatalf("SaveIfUnchanged() failed: %v", err)
+	}
+
+	if _, err := service.ListPasswordRecords(ctx, editorToken, "test-vault"); err != domain.ErrVaultTampered {
+		t.Errorf("open session: expected ErrVaultTampered, got %v", err)
+	}
+	// The other members' private keys are bound to the first roster key
+	service.SetUnlockPolicy(UnlockPolicy{})
+	if _, err := service.UnlockVaultAsMember(ctx, "test-vault", "erin", "erin-password", ""); err == nil {
+		t.Error("unlocking with a replaced roster key succeeded")
+	}
+}
+
+func TestRosterKeyReplacedWhenOwnerLeaves(t *testing.T) {
+	service, ownerToken := setupRolesTest(t)
+	ctx := context.Background()
+	if err := service.SetMemberRole(ctx, ownerToken, "test-vault", "erin", domain.RoleOwner); err != nil {
+		t.Fatalf("SetMemberRole() failed: %v", err)
+	}
+	erinToken := unlockMember(t, service, "erin")
+	oldKey := slices.Clone(service.sessions[erinToken].rosterKey.Bytes())
+
+	if err := service.SetMemberRole(ctx, ownerToken, "test-vault", "erin", domain.RoleViewer); err != nil {
+		t.Fatalf("SetMemberRole() failed: %v", err)
+	}
+	metadata, _ := service.repo.Load(ctx, "test-vault")
+	if len(metadata.RosterKeys) != 2 || metadata.Member("erin").RosterKey != nil {
+		t.Fatalf("expected a new roster key that erin does not have, got %+v", metadata.RosterKeys)
+	}
+	if service.sessions[erinToken].rosterKey.Len() != 0 {
+		t.Error("the session of the former owner kept the roster key")
+	}
+
+	// The former owner still knows the old key, which no longer signs
+	metadata.Member("erin").Role = domain.RoleOwner
+	message, _ := roster(metadata)
+	metadata.MembersSignature, _ = crypto.NewService().Sign(message, oldKey)
+	if err := service.repo.SaveIfUnchanged(ctx, "test-vault", metadata, metadata.Revision); err != nil {
+		t.Fatalf("SaveIfUnchanged() failed: %v", err)
+	}
+	service.SetUnlockPolicy(UnlockPolicy{})
+	if _, err := service.UnlockVaultAsMember(ctx, "test-vault", "erin", "erin-password", ""); err != domain.ErrVaultTampered {
+		t.Errorf("expected ErrVaultTampered, got %v", err)
+	}
+}
+
+func TestVaultSharedBeforeRoles(t *testing.T) {
+	// Shared before members had roles, with neither roles nor a MAC
+	service, _ := setupGoldenVault(t, "vault-1.0-shared.vault")
+	ctx := context.Background()
+
+	// Unlocking migrates the vault, leaving only the member who shared it
+	// an owner
+	aliceToken, err := service.UnlockVaultAsMember(ctx, "golden", "alice", "alice-password", "")
+	if err != nil {
+		t.Fatalf("UnlockVaultAsMember() failed: %v", err)
+	}
+	if _, err := service.AddPasswordRecord(ctx, aliceToken, "golden", RecordInput{Name: "gitlab", Password: "secret"}); err != domain.ErrPermissionDenied {
+		t.Errorf("expected ErrPermissionDenied before the role is granted again, got %v", err)
+	}
+
+	token, err := service.UnlockVaultAsMember(ctx, "golden", OwnerMemberName, "golden-password", "")
+	if err != nil {
+		t.Fatalf("UnlockVaultAsMember() failed: %v", err)
+	}
+	if err := service.SetMemberRole(ctx, token, "golden", "alice", domain.RoleEditor); err != nil {
+		t.Fatalf("SetMemberRole() failed: %v", err)
+	}
+	if _, err := service.AddPasswordRecord(ctx, aliceToken, "golden", RecordInput{Name: "gitlab", Password: "secret"}); err != nil {
+		t.Errorf("AddPasswordRecord() after granting the role failed: %v", err)
+	}
+	members, _ := service.ListMembers(ctx, token, "golden")
+	want := []domain.MemberRole{domain.RoleOwner, domain.RoleEditor}
+	for i, member := range members {
+		if member.Role != want[i] {
+			t.Errorf("member %q: expected role %q, got %q", member.Name, want[i], member.Role)
+		}
+	}
+}
@@ -2,13 +2,19 @@ package application
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/orlan/go-password-manager/internal/crypto"
 	"github.com/orlan/go-password-manager/internal/domain"
+	"github.com/orlan/go-password-manager/internal/generator"
+	"github.com/orlan/go-password-manager/internal/otp"
 )
 
 // VaultService handles vault operations and session management
@@ -16,29 +22,94 @@ type VaultService struct {
 	repo   domain.VaultRepository
 	crypto domain.CryptoService
 
-	// Session management
+	// Session management, keyed by opaque session token
 	sessions map[string]*session
+	policy   SessionPolicy
 	mu       sync.RWMutex
-}
 
-// session holds the decrypted vault and encryption key in memory
-type session struct {
-	vault *domain.Vault
-	key   []byte
+	// Thresholds used by AuditVault
+	auditPolicy AuditPolicy
+
+	// Known breached passwords; nil when no list is configured
+	breaches domain.BreachChecker
+
+	// Brute-force protection of UnlockVault and ChangeMasterPassword
+	unlockPolicy UnlockPolicy
+	attempts     domain.AttemptStore
+
+	// Where vault operations are logged
+	auditLogs domain.AuditLogStore
+
+	// Last accepted two-factor time step per vault, so a code cannot be
+	// used twice
+	twoFactorSteps domain.TwoFactorStepStore
+
+	// Lock events queued while s.mu is held, delivered by unlock
+	pendingEvents []SessionEvent
+
+	// Lock event listeners, guarded by listenersMu
+	listeners   []func(SessionEvent)
+	listenersMu sync.RWMutex
+
+	// now returns the current time; replaced in tests
+	now func() time.Time
+
+	sweepTicker *time.Ticker
+	done        chan struct{}
+	stopOnce    sync.Once
 }
 
-// NewVaultService creates a new vault service instance
+const (
+	// SessionTokenLength is the number of random bytes in a session token
+	SessionTokenLength = 32
+
+	// maxSaveAttempts bounds how often a change is re-applied when another
+	// process keeps saving the same vault
+	maxSaveAttempts = 3
+)
+
+// NewVaultService creates a new vault service instance.
+// Sessions lock automatically according to DefaultSessionPolicy; call Stop
+// to end the background expiry loop.
 func NewVaultService(repo domain.VaultRepository, crypto domain.CryptoService) *VaultService {
-	return &VaultService{
-		repo:     repo,
-		crypto:   crypto,
-		sessions: make(map[string]*session),
-	}
+	s := &VaultService{
+		repo:           repo,
+		crypto:         crypto,
+		sessions:       make(map[string]*session),
+		policy:         DefaultSessionPolicy(),
+		auditPolicy:    DefaultAuditPolicy(),
+		unlockPolicy:   DefaultUnlockPolicy(),
+		attempts:       newMemoryAttemptStore(),
+		auditLogs:      newMemoryAuditLogStore(),
+		twoFactorSteps: newMemoryTwoFactorStepStore(),
+		now:            time.Now,
+		sweepTicker:    time.NewTicker(sessionSweepInterval),
+		done:           make(chan struct{}),
+	}
+
+	go s.expireSessions()
+	return s
 }
 
-// CreateVault creates a new encrypted vault
-func (s *VaultService) CreateVault(ctx context.Context, name, masterPassword string) error {
-	// Check if vault already exists
+// CreateVault creates a new encrypted vault.
+// kdfProfile selects the key derivation profile; empty uses the default.
+func (s *VaultService) CreateVault(ctx context.Context, name, masterPassword, kdfProfile string) error {
+	return s.createVault(ctx, name, masterPassword, kdfProfile, nil)
+}
+
+// createVault creates a new encrypted vault, guarded by twoFactor unless
+// it is nil
+func (s *VaultService) createVault(ctx context.Context, name, masterPassword, kdfProfile string, twoFactor *otp.Key) error {
+	if err := domain.ValidateVaultName(name); err != nil {
+		return err
+	}
+
+	kdf, err := s.crypto.KDFProfile(kdfProfile)
+	if err != nil {
+		return err
+	}
+
+	// Fail early, before deriving the key. Create checks again.
 	exists, err := s.repo.Exists(ctx, name)
 	if err != nil {
 		return fmt.Errorf("failed to check vault existence: %w", err)
@@ -54,15 +125,22 @@ func (s *VaultService) CreateVault(ctx context.Context, name, masterPassword str
 	}
 
 	// Derive encryption key
-	key, err := s.crypto.DeriveKey(masterPassword, salt)
+	key, err := s.crypto.DeriveKey(masterPassword, salt, kdf)
 	if err != nil {
 		return fmt.Errorf("failed to derive key: %w", err)
 	}
+	defer crypto.Wipe(key)
+
+	auditLog, err := s.newAuditLogKey()
+	if err != nil {
+		return err
+	}
 
 	// Create empty vault
 	vault := &domain.Vault{
-		Name:    name,
-		Records: []domain.PasswordRecord{},
+		Name:     name,
+		Records:  []domain.PasswordRecord{},
+		AuditLog: auditLog,
 	}
 
 	// Serialize vault
@@ -70,131 +148,440 @@ func (s *VaultService) CreateVault(ctx context.Context, name, masterPassword str
 	if err != nil {
 		return fmt.Errorf("failed to marshal vault: %w", err)
 	}
-
-	// Encrypt vault
-	nonce, ciphertext, err := s.crypto.Encrypt(vaultData, key)
-	if err != nil {
-		return domain.ErrEncryptionFailed
-	}
+	defer crypto.Wipe(vaultData)
 
 	// Create metadata
 	metadata := &domain.VaultMetadata{
-		Version:   "1.0",
-		Salt:      salt,
-		Nonce:     nonce,
-		Encrypted: ciphertext,
+		Version:     domain.VaultFormatVersion,
+		Salt:        salt,
+		KDF:         &kdf,
+		AuditLogKey: auditLog.PublicKey,
 	}
 
-	// Save to disk
-	if err := s.repo.Save(ctx, name, metadata); err != nil {
+	if twoFactor != nil {
+		if metadata.TwoFactor, err = s.sealTwoFactor(twoFactor, key, twoFactorAssociatedData(name, metadata)); err != nil {
+			return err
+		}
+	}
+
+	// Encrypt vault
+	if metadata.Nonce, metadata.Encrypted, err = s.crypto.Encrypt(vaultData, key, vaultAssociatedData(name, metadata)); err != nil {
+		return domain.ErrEncryptionFailed
+	}
+
+	// Save to disk, unless another process created the vault meanwhile
+	if err := s.repo.Create(ctx, name, metadata); err != nil {
+		if err == domain.ErrVaultAlreadyExists {
+			return err
+		}
 		return fmt.Errorf("failed to save vault: %w", err)
 	}
 
 	return nil
 }
 
-// UnlockVault authenticates and loads a vault into memory
-func (s *VaultService) UnlockVault(ctx context.Context, name, masterPassword string) error {
+// UnlockVault authenticates and loads a vault into memory.
+// It returns an opaque session token that must be presented to every
+// record operation on the vault. Vaults with two-factor unlock enabled
+// return domain.ErrTwoFactorRequired; use UnlockVaultWithCode for them.
+func (s *VaultService) UnlockVault(ctx context.Context, name, masterPassword string) (string, error) {
+	return s.UnlockVaultWithCode(ctx, name, masterPassword, "")
+}
+
+// UnlockVaultWithCode is UnlockVault with the one-time code for vaults that
+// have two-factor unlock enabled. The code is ignored for other vaults. For
+// vaults that need one, a wrong password and a wrong code both give
+// domain.ErrInvalidCredentials.
+// The code is checked before the vault contents are decrypted; without one
+// the master password is not even tried.
+func (s *VaultService) UnlockVaultWithCode(ctx context.Context, name, masterPassword, code string) (string, error) {
+	return s.UnlockVaultAsMember(ctx, name, "", masterPassword, code)
+}
+
+// UnlockVaultAsMember is UnlockVaultWithCode with the password of a member
+// of a shared vault. An empty member selects the first member, usually the
+// one who shared the vault. Single-user vaults have no members; member must
+// be empty for them.
+func (s *VaultService) UnlockVaultAsMember(ctx context.Context, name, member, masterPassword, code string) (token string, err error) {
+	if err := domain.ValidateVaultName(name); err != nil {
+		return "", err
+	}
+
 	// Load vault metadata
-	metadata, err := s.repo.Load(ctx, name)
+	metadata, err := s.loadMetadata(ctx, name)
 	if err != nil {
-		return err
+		return "", err
+	}
+	if metadata.TwoFactor != nil && code == "" {
+		return "", domain.ErrTwoFactorRequired
 	}
 
-	// Derive key from master password
-	key, err := s.crypto.DeriveKey(masterPassword, metadata.Salt)
+	// Failed unlocks slow down further attempts
+	attempt, err := s.beginUnlockAttempt(ctx, name)
 	if err != nil {
-		return fmt.Errorf("failed to derive key: %w", err)
+		return "", err
+	}
+	defer func() { attempt.finished(ctx, err) }()
+
+	// Derive key from master password using the parameters recorded in the vault
+	rawKey, memberName, privateKey, err := s.vaultKey(metadata, member, masterPassword)
+	if err != nil {
+		if err == domain.ErrInvalidMasterPassword {
+			s.logFailedUnlock(ctx, name, metadata, member, "wrong password")
+			return "", credentialsError(metadata)
+		}
+		return "", err
+	}
+	defer crypto.Wipe(privateKey)
+	key := crypto.NewSecureBuffer(rawKey)
+
+	if metadata.TwoFactor != nil {
+		if err := s.checkTwoFactor(ctx, name, metadata, key.Bytes(), code); err != nil {
+			key.Destroy()
+			switch err {
+			case domain.ErrInvalidMasterPassword:
+				s.logFailedUnlock(ctx, name, metadata, member, "wrong password")
+				return "", domain.ErrInvalidCredentials
+			case domain.ErrInvalidTwoFactorCode:
+				s.logFailedUnlock(ctx, name, metadata, member, "wrong two-factor code")
+				return "", domain.ErrInvalidCredentials
+			}
+			return "", err
+		}
 	}
 
 	// Decrypt vault
-	vaultData, err := s.crypto.Decrypt(metadata.Nonce, metadata.Encrypted, key)
+	vaultData, err := s.crypto.Decrypt(metadata.Nonce, metadata.Encrypted, key.Bytes(), vaultAssociatedData(name, metadata))
 	if err != nil {
-		return domain.ErrInvalidMasterPassword
+		key.Destroy()
+		s.logFailedUnlock(ctx, name, metadata, member, "wrong password")
+		return "", credentialsError(metadata)
 	}
+	defer crypto.Wipe(vaultData)
 
 	// Deserialize vault
 	var vault domain.Vault
 	if err := json.Unmarshal(vaultData, &vault); err != nil {
-		return fmt.Errorf("failed to unmarshal vault: %w", err)
+		key.Destroy()
+		return "", fmt.Errorf("failed to unmarshal vault: %w", err)
+	}
+
+	// Vaults written in an older format are upgraded and saved again
+	migrated, err := s.migrateVault(name, metadata, &vault, key.Bytes())
+	if err != nil {
+		key.Destroy()
+		return "", err
+	}
+
+	// The member's private key is bound to the first roster key
+	roles, err := s.memberRoles(metadata, nil)
+	if err != nil {
+		key.Destroy()
+		return "", err
+	}
+	// Owners can change the members
+	rosterKey, err := s.openRosterKey(metadata, memberName, privateKey)
+	if err != nil {
+		key.Destroy()
+		return "", err
+	}
+
+	if migrated {
+		if err := s.saveMigratedVault(ctx, name, metadata, &vault, key.Bytes()); err != nil {
+			key.Destroy()
+			rosterKey.Destroy()
+			if err == domain.ErrVaultModified || err == domain.ErrEncryptionFailed {
+				return "", err
+			}
+			return "", fmt.Errorf("failed to save vault: %w", err)
+		}
+	}
+
+	token, err = generateSessionToken()
+	if err != nil {
+		key.Destroy()
+		rosterKey.Destroy()
+		return "", fmt.Errorf("failed to generate session token: %w", err)
 	}
 
-	// Store session
+	// Store session, sharing the in-memory vault with existing sessions.
+	// The copy just read from disk is at least as recent as theirs.
 	s.mu.Lock()
-	s.sessions[name] = &session{
-		vault: &vault,
-		key:   key,
+	now := s.now()
+	sess := &session{
+		vaultName:  name,
+		member:     memberName,
+		client:     clientFromContext(ctx),
+		vault:      &openVault{Vault: &vault, revision: metadata.Revision, roles: roles, firstRosterKey: firstRosterKey(metadata)},
+		key:        key,
+		rosterKey:  rosterKey,
+		createdAt:  now,
+		lastAccess: now,
+	}
+	for _, other := range s.sessions {
+		if other.vaultName == name {
+			*other.vault = *sess.vault
+			sess.vault = other.vault
+			break
+		}
+	}
+
+	// A vault is never open without its unlock being logged
+	if err := s.logEvent(ctx, sess, domain.AuditLogEntry{Event: domain.AuditLogUnlock}); err != nil {
+		s.mu.Unlock()
+		key.Destroy()
+		rosterKey.Destroy()
+		return "", fmt.Errorf("failed to write audit log: %w", err)
 	}
+
+	s.sessions[token] = sess
 	s.mu.Unlock()
 
-	return nil
+	return token, nil
 }
 
-// LockVault removes the vault from memory
-func (s *VaultService) LockVault(ctx context.Context, name string) error {
+// LockVault ends the session identified by token.
+// Other sessions on the same vault remain unlocked.
+func (s *VaultService) LockVault(ctx context.Context, token string) error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	defer s.unlock()
 
-	if _, exists := s.sessions[name]; !exists {
-		return domain.ErrVaultNotFound
+	sess, exists := s.sessions[token]
+	if !exists {
+		return domain.ErrInvalidSession
 	}
 
-	delete(s.sessions, name)
+	s.removeSession(token, sess, LockReasonManual)
 	return nil
 }
 
-// AddPasswordRecord adds a new password record to the vault
-func (s *VaultService) AddPasswordRecord(ctx context.Context, vaultName, recordName, username, password string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// ChangeMasterPassword re-encrypts a vault under a new master password.
+// A fresh salt is generated and the key is derived again, optionally with
+// a different KDF profile; an empty kdfProfile keeps the vault's current
+// parameters. Active sessions on the vault switch to the new key and stay
+// unlocked. The backup of the previous version is removed. Vaults with
+// two-factor unlock return domain.ErrTwoFactorRequired; use
+// ChangeMemberPassword with a code for them.
+func (s *VaultService) ChangeMasterPassword(ctx context.Context, name, oldPassword, newPassword, kdfProfile string) error {
+	return s.ChangeMemberPassword(ctx, name, "", oldPassword, newPassword, kdfProfile, "")
+}
 
-	sess, exists := s.sessions[vaultName]
-	if !exists {
-		return domain.ErrVaultNotFound
+// ChangeMemberPassword is ChangeMasterPassword for a member of a shared
+// vault; an empty member selects the first one. Only the member's private
+// key is re-encrypted, the data key and the other members are unaffected.
+// Vaults with two-factor unlock need a current one-time code, like an
+// unlock, so the password alone cannot lock the other members out.
+func (s *VaultService) ChangeMemberPassword(ctx context.Context, name, member, oldPassword, newPassword, kdfProfile, code string) (err error) {
+	if err := domain.ValidateVaultName(name); err != nil {
+		return err
 	}
 
-	// Check if record already exists
-	for _, record := range sess.vault.Records {
-		if record.Name == recordName {
-			return domain.ErrRecordAlreadyExists
+	// Guessing through a password change is limited like unlocking
+	attempt, err := s.beginUnlockAttempt(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer func() { attempt.finished(ctx, err) }()
+
+	// The keys are derived without holding s.mu, so other requests are not
+	// held up; the save fails if the vault changes in the meantime
+	metadata, err := s.loadMetadata(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if metadata.TwoFactor != nil {
+		if code == "" {
+			return domain.ErrTwoFactorRequired
+		}
+		if err := s.verifyPasswordChangeCode(ctx, name, metadata, member, oldPassword, code); err != nil {
+			return err
 		}
 	}
 
-	// Create new record
-	record := domain.PasswordRecord{
-		ID:        uuid.New().String(),
-		Name:      recordName,
-		Username:  username,
-		Password:  password,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	// A shared vault keeps its data key, so only the member's private key
+	// changes
+	var newKey []byte
+	if metadata.Shared() {
+		err = s.changeMemberPassword(metadata, member, oldPassword, newPassword, kdfProfile)
+	} else if member != "" {
+		return domain.ErrInvalidMasterPassword
+	} else {
+		newKey, err = s.changeVaultPassword(name, metadata, oldPassword, newPassword, kdfProfile)
+	}
+	if err != nil {
+		return err
 	}
+	defer crypto.Wipe(newKey)
 
-	// Add to vault
-	sess.vault.Records = append(sess.vault.Records, record)
+	s.mu.Lock()
+	defer s.unlock()
 
-	// Save to disk
-	if err := s.saveVault(ctx, vaultName, sess); err != nil {
+	oldRevision := metadata.Revision
+	if err := s.repo.SaveIfUnchanged(ctx, name, metadata, oldRevision); err != nil {
+		if err == domain.ErrVaultModified {
+			return err
+		}
 		return fmt.Errorf("failed to save vault: %w", err)
 	}
 
-	return nil
+	// Keep existing sessions usable with the new key. The contents did not
+	// change, so sessions that were up to date stay up to date.
+	for _, sess := range s.sessions {
+		if sess.vaultName != name {
+			continue
+		}
+		if newKey != nil {
+			sess.key.Destroy()
+			sess.key = crypto.CopySecureBuffer(newKey)
+		}
+		if sess.vault.revision == oldRevision {
+			sess.vault.revision = metadata.Revision
+		}
+	}
+
+	// The backup still opens with the old password
+	return s.repo.RemoveBackup(ctx, name)
+}
+
+// changeVaultPassword re-encrypts the records and the two-factor key of a
+// single-user vault in metadata with the key derived from newPassword, and
+// returns that key. The caller saves metadata.
+func (s *VaultService) changeVaultPassword(name string, metadata *domain.VaultMetadata, oldPassword, newPassword, kdfProfile string) ([]byte, error) {
+	kdf := vaultKDF(metadata)
+	newKDF := kdf
+	if kdfProfile != "" {
+		var err error
+		if newKDF, err = s.crypto.KDFProfile(kdfProfile); err != nil {
+			return nil, err
+		}
+	}
+
+	// Verify the current master password
+	oldKey, err := s.crypto.DeriveKey(oldPassword, metadata.Salt, kdf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	defer crypto.Wipe(oldKey)
+
+	vaultData, err := s.crypto.Decrypt(metadata.Nonce, metadata.Encrypted, oldKey, vaultAssociatedData(name, metadata))
+	if err != nil {
+		return nil, domain.ErrInvalidMasterPassword
+	}
+	defer crypto.Wipe(vaultData)
+
+	salt, err := s.crypto.GenerateSalt()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	newKey, err := s.crypto.DeriveKey(newPassword, salt, newKDF)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+
+	// The two-factor key is encrypted with the vault key as well
+	if metadata.TwoFactor != nil {
+		additionalData := twoFactorAssociatedData(name, metadata)
+		twoFactor, err := s.openTwoFactor(metadata.TwoFactor, oldKey, additionalData)
+		if err != nil {
+			crypto.Wipe(newKey)
+			return nil, err
+		}
+		if metadata.TwoFactor, err = s.sealTwoFactor(twoFactor, newKey, additionalData); err != nil {
+			crypto.Wipe(newKey)
+			return nil, err
+		}
+	}
+
+	metadata.Salt = salt
+	metadata.KDF = &newKDF
+	if metadata.Nonce, metadata.Encrypted, err = s.crypto.Encrypt(vaultData, newKey, vaultAssociatedData(name, metadata)); err != nil {
+		crypto.Wipe(newKey)
+		return nil, domain.ErrEncryptionFailed
+	}
+	return newKey, nil
+}
+
+// AddPasswordRecord adds a new item to the vault. The input is validated
+// against the schema of its type.
+func (s *VaultService) AddPasswordRecord(ctx context.Context, token, vaultName string, input RecordInput) (RecordResult, error) {
+	var result RecordResult
+	if input.Password == "" && input.Generate != nil {
+		password, err := generator.Password(*input.Generate)
+		if err != nil {
+			return RecordResult{}, err
+		}
+		input.Password = password
+		result.GeneratedPassword = password
+	}
+
+	record, err := newRecord(input)
+	if err != nil {
+		return RecordResult{}, err
+	}
+	record.ID = uuid.New().String()
+
+	s.mu.Lock()
+	defer s.unlock()
+
+	record.CreatedAt = s.now()
+	record.UpdatedAt = record.CreatedAt
+
+	sess, err := s.getSession(token, vaultName)
+	if err != nil {
+		return RecordResult{}, err
+	}
+
+	if err := s.authorize(ctx, sess, domain.RoleEditor); err != nil {
+		return RecordResult{}, err
+	}
+
+	err = s.updateVault(ctx, sess, func(vault *domain.Vault) error {
+		// Check if record already exists
+		for _, existing := range vault.Records {
+			if existing.Name == input.Name {
+				return domain.ErrRecordAlreadyExists
+			}
+		}
+
+		vault.Records = append(vault.Records, record)
+		return nil
+	})
+	if err != nil {
+		return RecordResult{}, err
+	}
+	// The record is saved; failing to log it does not undo that
+	s.logEvent(ctx, sess, domain.AuditLogEntry{Event: domain.AuditLogAdd, Record: record.Name})
+
+	result.Warnings = s.breachWarnings(record.Password)
+	return result, nil
 }
 
 // GetPasswordRecord retrieves a password record by name
-func (s *VaultService) GetPasswordRecord(ctx context.Context, vaultName, recordName string) (*domain.PasswordRecord, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+func (s *VaultService) GetPasswordRecord(ctx context.Context, token, vaultName, recordName string) (*domain.PasswordRecord, error) {
+	s.mu.Lock()
+	defer s.unlock()
 
-	sess, exists := s.sessions[vaultName]
-	if !exists {
-		return nil, domain.ErrVaultNotFound
+	sess, err := s.getSession(token, vaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pick up changes saved by other processes
+	if err := s.refreshVault(ctx, sess); err != nil {
+		return nil, err
 	}
 
 	for _, record := range sess.vault.Records {
 		if record.Name == recordName {
+			// Nobody reads a password without it being logged
+			if err := s.logEvent(ctx, sess, domain.AuditLogEntry{Event: domain.AuditLogGet, Record: recordName}); err != nil {
+				return nil, fmt.Errorf("failed to write audit log: %w", err)
+			}
+
 			// Return a copy to prevent external modification
-			recordCopy := record
+			recordCopy := cloneRecord(record)
 			return &recordCopy, nil
 		}
 	}
@@ -203,88 +590,123 @@ func (s *VaultService) GetPasswordRecord(ctx context.Context, vaultName, recordN
 }
 
 // ListPasswordRecords returns all password records in the vault
-func (s *VaultService) ListPasswordRecords(ctx context.Context, vaultName string) ([]domain.PasswordRecord, error) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+func (s *VaultService) ListPasswordRecords(ctx context.Context, token, vaultName string) ([]domain.PasswordRecord, error) {
+	s.mu.Lock()
+	defer s.unlock()
 
-	sess, exists := s.sessions[vaultName]
-	if !exists {
-		return nil, domain.ErrVaultNotFound
+	sess, err := s.getSession(token, vaultName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Pick up changes saved by other processes
+	if err := s.refreshVault(ctx, sess); err != nil {
+		return nil, err
+	}
+
+	// The list includes the passwords, so it is logged like a read
+	if err := s.logEvent(ctx, sess, domain.AuditLogEntry{Event: domain.AuditLogList}); err != nil {
+		return nil, fmt.Errorf("failed to write audit log: %w", err)
 	}
 
 	// Return a deep copy to prevent external modification
 	records := make([]domain.PasswordRecord, len(sess.vault.Records))
-	copy(records, sess.vault.Records)
+	for i, record := range sess.vault.Records {
+		records[i] = cloneRecord(record)
+	}
 	return records, nil
 }
 
-// UpdatePasswordRecord updates an existing password record
-func (s *VaultService) UpdatePasswordRecord(ctx context.Context, vaultName, recordName, username, password string) error {
-	s.mu.Lock()
-	defer s.mu.Unlock()
+// ListPasswordRecordsByType returns the items of the given type in the vault
+func (s *VaultService) ListPasswordRecordsByType(ctx context.Context, token, vaultName string, itemType domain.ItemType) ([]domain.PasswordRecord, error) {
+	if _, err := domain.ParseItemType(string(itemType)); err != nil || itemType == "" {
+		return nil, domain.ErrUnknownItemType
+	}
 
-	sess, exists := s.sessions[vaultName]
-	if !exists {
-		return domain.ErrVaultNotFound
+	records, err := s.ListPasswordRecords(ctx, token, vaultName)
+	if err != nil {
+		return nil, err
 	}
 
-	// Find and update record
-	found := false
-	for i := range sess.vault.Records {
-		if sess.vault.Records[i].Name == recordName {
-			if username != "" {
-				sess.vault.Records[i].Username = username
-			}
-			if password != "" {
-				sess.vault.Records[i].Password = password
+	return slices.DeleteFunc(records, func(record domain.PasswordRecord) bool {
+		return record.Kind() != itemType
+	}), nil
+}
+
+// UpdatePasswordRecord updates the fields of an existing password record
+// that are set in update. A changed password is kept in the record history.
+func (s *VaultService) UpdatePasswordRecord(ctx context.Context, token, vaultName, recordName string, update RecordUpdate) (RecordResult, error) {
+	s.mu.Lock()
+	defer s.unlock()
+
+	sess, err := s.getSession(token, vaultName)
+	if err != nil {
+		return RecordResult{}, err
+	}
+
+	if err := s.authorize(ctx, sess, domain.RoleEditor); err != nil {
+		return RecordResult{}, err
+	}
+
+	err = s.updateVault(ctx, sess, func(vault *domain.Vault) error {
+		// Find and update record
+		for i := range vault.Records {
+			if vault.Records[i].Name == recordName {
+				previous := vault.Records[i].Password
+				update.apply(&vault.Records[i])
+				if err := validateRecord(&vault.Records[i]); err != nil {
+					return err
+				}
+				now := s.now()
+				recordPasswordChange(&vault.Records[i], previous, vault.Records[i].Password, now)
+				vault.Records[i].UpdatedAt = now
+				return nil
 			}
-			sess.vault.Records[i].UpdatedAt = time.Now()
-			found = true
-			break
 		}
-	}
 
-	if !found {
 		return domain.ErrRecordNotFound
+	})
+	if err != nil {
+		return RecordResult{}, err
 	}
+	s.logEvent(ctx, sess, domain.AuditLogEntry{Event: domain.AuditLogUpdate, Record: recordName})
 
-	// Save to disk
-	if err := s.saveVault(ctx, vaultName, sess); err != nil {
-		return fmt.Errorf("failed to save vault: %w", err)
+	var result RecordResult
+	if update.Password != nil {
+		result.Warnings = s.breachWarnings(*update.Password)
 	}
-
-	return nil
+	return result, nil
 }
 
 // DeletePasswordRecord removes a password record from the vault
-func (s *VaultService) DeletePasswordRecord(ctx context.Context, vaultName, recordName string) error {
+func (s *VaultService) DeletePasswordRecord(ctx context.Context, token, vaultName, recordName string) error {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	defer s.unlock()
 
-	sess, exists := s.sessions[vaultName]
-	if !exists {
-		return domain.ErrVaultNotFound
+	sess, err := s.getSession(token, vaultName)
+	if err != nil {
+		return err
 	}
 
-	// Find and delete record
-	found := false
-	for i, record := range sess.vault.Records {
-		if record.Name == recordName {
-			sess.vault.Records = append(sess.vault.Records[:i], sess.vault.Records[i+1:]...)
-			found = true
-			break
-		}
+	if err := s.authorize(ctx, sess, domain.RoleEditor); err != nil {
+		return err
 	}
 
-	if !found {
-		return domain.ErrRecordNotFound
-	}
+	err = s.updateVault(ctx, sess, func(vault *domain.Vault) error {
+		// Find and delete record
+		for i, record := range vault.Records {
+			if record.Name == recordName {
+				vault.Records = append(vault.Records[:i], vault.Records[i+1:]...)
+				return nil
+			}
+		}
 
-	// Save to disk
-	if err := s.saveVault(ctx, vaultName, sess); err != nil {
-		return fmt.Errorf("failed to save vault: %w", err)
+		return domain.ErrRecordNotFound
+	})
+	if err != nil {
+		return err
 	}
-
+	s.logEvent(ctx, sess, domain.AuditLogEntry{Event: domain.AuditLogDelete, Record: recordName})
 	return nil
 }
 
@@ -293,38 +715,250 @@ func (s *VaultService) ListVaults(ctx context.Context) ([]string, error) {
 	return s.repo.List(ctx)
 }
 
-// IsVaultUnlocked checks if a vault is currently unlocked
+// IsVaultUnlocked checks if any session currently has the vault unlocked
 func (s *VaultService) IsVaultUnlocked(ctx context.Context, vaultName string) bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	_, exists := s.sessions[vaultName]
-	return exists
+	now := s.now()
+	for _, sess := range s.sessions {
+		if sess.vaultName == vaultName && s.expiryReason(sess, now) == "" {
+			return true
+		}
+	}
+	return false
 }
 
-// saveVault encrypts and persists the vault to disk
-func (s *VaultService) saveVault(ctx context.Context, name string, sess *session) error {
-	// Serialize vault
-	vaultData, err := json.Marshal(sess.vault)
+// ValidateSession checks that token is an active session for vaultName
+func (s *VaultService) ValidateSession(ctx context.Context, token, vaultName string) error {
+	s.mu.Lock()
+	defer s.unlock()
+	_, err := s.getSession(token, vaultName)
+	return err
+}
+
+// getSession returns the session for token, verifying it belongs to vaultName
+// and has not expired, and records the access for the idle timeout.
+// A session found expired is locked on the spot instead of waiting for the
+// expiry loop. Callers must hold s.mu for writing and release it with unlock.
+func (s *VaultService) getSession(token, vaultName string) (*session, error) {
+	sess, exists := s.sessions[token]
+	if !exists || sess.vaultName != vaultName {
+		return nil, domain.ErrInvalidSession
+	}
+
+	now := s.now()
+	if reason := s.expiryReason(sess, now); reason != "" {
+		s.removeSession(token, sess, reason)
+		return nil, domain.ErrInvalidSession
+	}
+
+	sess.lastAccess = now
+	return sess, nil
+}
+
+// credentialsError is the error for a wrong password or one-time code. For
+// vaults with two-factor unlock both give domain.ErrInvalidCredentials, so
+// that the password cannot be confirmed without the second factor; the
+// audit log records which one it was.
+func credentialsError(metadata *domain.VaultMetadata) error {
+	if metadata.TwoFactor != nil {
+		return domain.ErrInvalidCredentials
+	}
+	return domain.ErrInvalidMasterPassword
+}
+
+// vaultKDF returns the key derivation parameters recorded in the vault metadata
+func vaultKDF(metadata *domain.VaultMetadata) domain.KDFParams {
+	if metadata.KDF == nil {
+		return domain.LegacyKDFParams
+	}
+	return *metadata.KDF
+}
+
+// vaultHeader is the part of the unencrypted metadata that is authenticated
+// along with the records
+type vaultHeader struct {
+	Name        string                  `json:"name"`
+	Version     string                  `json:"version"`
+	Shared      bool                    `json:"shared"`
+	Salt        []byte                  `json:"salt,omitempty"`
+	KDF         *domain.KDFParams       `json:"kdf,omitempty"`
+	TwoFactor   *domain.TwoFactorConfig `json:"two_factor,omitempty"`
+	AuditLogKey []byte                  `json:"audit_log_key,omitempty"`
+	RosterKey   []byte                  `json:"roster_key,omitempty"`
+}
+
+// hasAssociatedData reports whether the vault's format encrypts with
+// additional data. Versions before 1.2 authenticate nothing.
+func hasAssociatedData(metadata *domain.VaultMetadata) bool {
+	return metadata.Version != "1.0" && metadata.Version != "1.1"
+}
+
+// vaultAssociatedData returns the additional data the records of vault name
+// are encrypted with: a canonical encoding of its header, so that records
+// moved into another vault file, or a header with weaker parameters, an
+// older version, a removed two-factor key, or another audit log or roster
+// key, fail to decrypt. The records have to be encrypted again whenever these change.
+func vaultAssociatedData(name string, metadata *domain.VaultMetadata) []byte {
+	if !hasAssociatedData(metadata) {
+		return nil
+	}
+	header := vaultHeader{
+		Name:    name,
+		Version: metadata.Version,
+		Shared:  metadata.Shared(),
+		Salt:    metadata.Salt,
+		KDF:     metadata.KDF,
+	}
+	// 1.2 authenticated neither the two-factor key nor the audit log key,
+	// and had no roster key
+	if metadata.Version != "1.2" {
+		header.TwoFactor = metadata.TwoFactor
+		header.AuditLogKey = metadata.AuditLogKey
+		if n := len(metadata.RosterKeys); n > 0 {
+			header.RosterKey = metadata.RosterKeys[n-1].PublicKey
+		}
+	}
+	// Marshaling strings, bytes and numbers cannot fail
+	data, _ := json.Marshal(header)
+	return data
+}
+
+// generateSessionToken creates a cryptographically secure session token
+func generateSessionToken() (string, error) {
+	tokenBytes := make([]byte, SessionTokenLength)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(tokenBytes), nil
+}
+
+// updateVault applies change to a copy of the session's vault and saves it.
+// If another process saved the vault in the meantime, the latest version is
+// loaded and change is applied again on top of it, so concurrent writers
+// never silently overwrite each other. Callers must hold s.mu.
+func (s *VaultService) updateVault(ctx context.Context, sess *session, change func(vault *domain.Vault) error) error {
+	for attempt := 0; attempt < maxSaveAttempts; attempt++ {
+		if err := s.refreshVault(ctx, sess); err != nil {
+			return err
+		}
+
+		// Work on a deep copy so a failed save leaves memory matching disk
+		vault := &domain.Vault{
+			Name:     sess.vault.Name,
+			Records:  make([]domain.PasswordRecord, len(sess.vault.Records)),
+			AuditLog: sess.vault.AuditLog,
+		}
+		for i, record := range sess.vault.Records {
+			vault.Records[i] = copyRecord(record)
+		}
+		if err := change(vault); err != nil {
+			return err
+		}
+
+		revision, err := s.saveVault(ctx, sess, vault)
+		if err == domain.ErrVaultModified {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save vault: %w", err)
+		}
+
+		sess.vault.Vault = vault
+		sess.vault.revision = revision
+		return nil
+	}
+
+	return domain.ErrVaultModified
+}
+
+// refreshVault reloads the session's vault if another process saved a newer
+// revision. Callers must hold s.mu.
+func (s *VaultService) refreshVault(ctx context.Context, sess *session) error {
+	metadata, err := s.loadMetadata(ctx, sess.vaultName)
 	if err != nil {
-		return fmt.Errorf("failed to marshal vault: %w", err)
+		return err
+	}
+	if metadata.Revision == sess.vault.revision {
+		return nil
+	}
+	// Replaced by a copy in an older format, such as a backup. Unlocking
+	// again migrates it.
+	if metadata.Version != domain.VaultFormatVersion {
+		return domain.ErrVaultModified
 	}
 
-	// Encrypt vault
-	nonce, ciphertext, err := s.crypto.Encrypt(vaultData, sess.key)
+	vaultData, err := s.crypto.Decrypt(metadata.Nonce, metadata.Encrypted, sess.key.Bytes(), vaultAssociatedData(sess.vaultName, metadata))
 	if err != nil {
-		return domain.ErrEncryptionFailed
+		// Re-encrypted under another key, e.g. the master password was
+		// changed by another process. The session has to unlock again.
+		return domain.ErrVaultModified
+	}
+	defer crypto.Wipe(vaultData)
+
+	var vault domain.Vault
+	if err := json.Unmarshal(vaultData, &vault); err != nil {
+		return fmt.Errorf("failed to unmarshal vault: %w", err)
 	}
 
-	// Load existing metadata to preserve salt
-	metadata, err := s.repo.Load(ctx, name)
+	// Roles may have changed along with the records
+	roles, err := s.memberRoles(metadata, sess.vault.firstRosterKey)
 	if err != nil {
 		return err
 	}
 
-	// Update encrypted data
-	metadata.Nonce = nonce
-	metadata.Encrypted = ciphertext
+	sess.vault.Vault = &vault
+	sess.vault.revision = metadata.Revision
+	sess.vault.roles = roles
+	return nil
+}
+
+// authorize picks up changes saved by other processes, including changed
+// roles, and checks that the session's member has at least role.
+// Callers must hold s.mu.
+func (s *VaultService) authorize(ctx context.Context, sess *session, role domain.MemberRole) error {
+	if err := s.refreshVault(ctx, sess); err != nil {
+		return err
+	}
+	if !sess.role().Allows(role) {
+		return domain.ErrPermissionDenied
+	}
+	return nil
+}
+
+// saveVault encrypts vault with the session key and persists it, provided
+// nobody saved the vault since the session last read it. It returns the new
+// revision.
+func (s *VaultService) saveVault(ctx context.Context, sess *session, vault *domain.Vault) (uint64, error) {
+	s.recordAuditLogHead(ctx, sess.vaultName, vault)
+
+	// Serialize vault
+	vaultData, err := json.Marshal(vault)
+	if err != nil {
+		return 0, fmt.Errorf("failed to marshal vault: %w", err)
+	}
+	defer crypto.Wipe(vaultData)
+
+	// Load existing metadata to preserve salt and KDF parameters
+	metadata, err := s.loadMetadata(ctx, sess.vaultName)
+	if err != nil {
+		return 0, err
+	}
+
+	if vault.AuditLog != nil {
+		// Also repairs a public key replaced on disk
+		metadata.AuditLogKey = vault.AuditLog.PublicKey
+	}
+
+	// Encrypt vault
+	if metadata.Nonce, metadata.Encrypted, err = s.crypto.Encrypt(vaultData, sess.key.Bytes(), vaultAssociatedData(sess.vaultName, metadata)); err != nil {
+		return 0, domain.ErrEncryptionFailed
+	}
 
 	// Save to disk
-	return s.repo.Save(ctx, name, metadata)
+	if err := s.repo.SaveIfUnchanged(ctx, sess.vaultName, metadata, sess.vault.revision); err != nil {
+		return 0, err
+	}
+
+	return metadata.Revision, nil
 }
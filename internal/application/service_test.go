@@ -1,8 +1,11 @@
 package application
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -21,6 +24,7 @@ func setupTestService(t *testing.T) (*VaultService, string) {
 	}
 	cryptoSvc := crypto.NewService()
 	service := NewVaultService(repo, cryptoSvc)
+	t.Cleanup(service.Stop)
 	return service, vaultDir
 }
 
@@ -46,7 +50,7 @@ func TestCreateVault(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
@@ -68,24 +72,58 @@ func TestCreateVault(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "password1")
+		err := service.CreateVault(ctx, "test-vault", "password1", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.CreateVault(ctx, "test-vault", "password2")
+		err = service.CreateVault(ctx, "test-vault", "password2", "")
 		if err != domain.ErrVaultAlreadyExists {
 			t.Errorf("expected ErrVaultAlreadyExists, got %v", err)
 		}
 	})
 
+	t.Run("does not overwrite a vault created by another process", func(t *testing.T) {
+		service1, vaultDir := setupTestService(t)
+		repo2, _ := vault.NewFileRepository(vaultDir)
+		service2 := NewVaultService(repo2, crypto.NewService())
+		t.Cleanup(service2.Stop)
+		ctx := context.Background()
+
+		var wg sync.WaitGroup
+		errs := make([]error, 2)
+		for i, service := range []*VaultService{service1, service2} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = service.CreateVault(ctx, "test-vault", fmt.Sprintf("password%d", i), "interactive")
+			}()
+		}
+		wg.Wait()
+
+		winner := -1
+		for i, err := range errs {
+			if err == nil {
+				winner = i
+			} else if err != domain.ErrVaultAlreadyExists {
+				t.Fatalf("CreateVault() failed: %v", err)
+			}
+		}
+		if winner == -1 || errs[1-winner] != domain.ErrVaultAlreadyExists {
+			t.Fatalf("expected exactly one vault to be created, got %v", errs)
+		}
+		if _, err := service1.UnlockVault(ctx, "test-vault", fmt.Sprintf("password%d", winner)); err != nil {
+			t.Errorf("the vault does not open with the winner's password: %v", err)
+		}
+	})
+
 	t.Run("creates multiple vaults", func(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
 		vaults := []string{"vault1", "vault2", "vault3"}
 		for _, name := range vaults {
-			err := service.CreateVault(ctx, name, "password")
+			err := service.CreateVault(ctx, name, "password", "")
 			if err != nil {
 				t.Fatalf("CreateVault() failed for %q: %v", name, err)
 			}
@@ -100,12 +138,64 @@ func TestCreateVault(t *testing.T) {
 		}
 	})
 
+	t.Run("records the chosen KDF profile", func(t *testing.T) {
+		service, _ := setupTestService(t)
+		ctx := context.Background()
+
+		err := service.CreateVault(ctx, "test-vault", "my-password", crypto.KDFProfileInteractive)
+		if err != nil {
+			t.Fatalf("CreateVault() failed: %v", err)
+		}
+
+		metadata, err := service.repo.Load(ctx, "test-vault")
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+		expected, _ := service.crypto.KDFProfile(crypto.KDFProfileInteractive)
+		if metadata.KDF == nil || *metadata.KDF != expected {
+			t.Errorf("expected KDF params %+v, got %+v", expected, metadata.KDF)
+		}
+
+		if _, err := service.UnlockVault(ctx, "test-vault", "my-password"); err != nil {
+			t.Errorf("UnlockVault() with recorded params failed: %v", err)
+		}
+	})
+
+	t.Run("returns error for unknown KDF profile", func(t *testing.T) {
+		service, _ := setupTestService(t)
+		ctx := context.Background()
+
+		err := service.CreateVault(ctx, "test-vault", "my-password", "unknown")
+		if err != domain.ErrUnknownKDFProfile {
+			t.Errorf("expected ErrUnknownKDFProfile, got %v", err)
+		}
+	})
+
+	t.Run("rejects unsafe vault names", func(t *testing.T) {
+		service, vaultDir := setupTestService(t)
+		ctx := context.Background()
+
+		for _, name := range []string{"../escape", "a/b", ".hidden", "CON"} {
+			err := service.CreateVault(ctx, name, "my-password", "")
+			if err != domain.ErrInvalidVaultName {
+				t.Errorf("CreateVault(%q) expected ErrInvalidVaultName, got %v", name, err)
+			}
+			if _, err := service.UnlockVault(ctx, name, "my-password"); err != domain.ErrInvalidVaultName {
+				t.Errorf("UnlockVault(%q) expected ErrInvalidVaultName, got %v", name, err)
+			}
+		}
+
+		if _, err := os.Stat(filepath.Join(filepath.Dir(vaultDir), "escape.vault")); !os.IsNotExist(err) {
+			t.Error("vault file was written outside the vault directory")
+		}
+	})
+
 	t.Run("creates vault with empty password", func(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
 		// Should fail due to crypto service validation
-		err := service.CreateVault(ctx, "test-vault", "")
+		err := service.CreateVault(ctx, "test-vault", "", "")
 		if err == nil {
 			t.Error("CreateVault() should fail with empty password")
 		}
@@ -117,32 +207,38 @@ func TestUnlockVault(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
+		if token == "" {
+			t.Fatal("UnlockVault() returned an empty session token")
+		}
 
 		// Verify vault is unlocked
 		if !service.IsVaultUnlocked(ctx, "test-vault") {
 			t.Error("vault should be unlocked")
 		}
+		if err := service.ValidateSession(ctx, token, "test-vault"); err != nil {
+			t.Errorf("ValidateSession() failed: %v", err)
+		}
 	})
 
 	t.Run("returns error for wrong password", func(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "correct-password")
+		err := service.CreateVault(ctx, "test-vault", "correct-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "wrong-password")
+		_, err = service.UnlockVault(ctx, "test-vault", "wrong-password")
 		if err != domain.ErrInvalidMasterPassword {
 			t.Errorf("expected ErrInvalidMasterPassword, got %v", err)
 		}
@@ -153,11 +249,35 @@ func TestUnlockVault(t *testing.T) {
 		}
 	})
 
+	t.Run("unlocks legacy vault without recorded KDF params", func(t *testing.T) {
+		service, _ := setupTestService(t)
+		ctx := context.Background()
+
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
+		if err != nil {
+			t.Fatalf("CreateVault() failed: %v", err)
+		}
+
+		// Strip the parameters to mimic a vault written by an older version
+		metadata, err := service.repo.Load(ctx, "test-vault")
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+		key, _ := crypto.NewService().DeriveKey("my-password", metadata.Salt, *metadata.KDF)
+		rewriteOldVault(t, service, key, "1.0", func(metadata *domain.VaultMetadata) {
+			metadata.KDF = nil
+		})
+
+		if _, err := service.UnlockVault(ctx, "test-vault", "my-password"); err != nil {
+			t.Errorf("UnlockVault() failed for legacy vault: %v", err)
+		}
+	})
+
 	t.Run("returns error for non-existent vault", func(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.UnlockVault(ctx, "non-existent", "password")
+		_, err := service.UnlockVault(ctx, "non-existent", "password")
 		if err != domain.ErrVaultNotFound {
 			t.Errorf("expected ErrVaultNotFound, got %v", err)
 		}
@@ -167,22 +287,22 @@ func TestUnlockVault(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "vault1", "password1")
+		err := service.CreateVault(ctx, "vault1", "password1", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.CreateVault(ctx, "vault2", "password2")
+		err = service.CreateVault(ctx, "vault2", "password2", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "vault1", "password1")
+		token1, err := service.UnlockVault(ctx, "vault1", "password1")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "vault2", "password2")
+		token2, err := service.UnlockVault(ctx, "vault2", "password2")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
@@ -193,45 +313,107 @@ func TestUnlockVault(t *testing.T) {
 		if !service.IsVaultUnlocked(ctx, "vault2") {
 			t.Error("vault2 should be unlocked")
 		}
+		if token1 == token2 {
+			t.Error("sessions should have distinct tokens")
+		}
 	})
 
 	t.Run("re-unlocking already unlocked vault succeeds", func(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token1, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("first UnlockVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token2, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("second UnlockVault() failed: %v", err)
 		}
+
+		if token1 == token2 {
+			t.Error("each unlock should create a new session token")
+		}
+		if err := service.ValidateSession(ctx, token1, "test-vault"); err != nil {
+			t.Errorf("first session should remain valid: %v", err)
+		}
 	})
 }
 
+func TestVaultHeaderAuthenticated(t *testing.T) {
+	tests := []struct {
+		name      string
+		twoFactor bool
+		tamper    func(metadata, other *domain.VaultMetadata)
+	}{
+		{"records of another vault", false, func(metadata, other *domain.VaultMetadata) {
+			// Same password, salt and parameters: only the name differs
+			*metadata = *other
+		}},
+		{"older version", false, func(metadata, other *domain.VaultMetadata) {
+			metadata.Version = "1.1"
+		}},
+		{"stripped KDF parameters", false, func(metadata, other *domain.VaultMetadata) {
+			// The default profile derives the same key as the legacy parameters
+			metadata.KDF = nil
+		}},
+		{"removed two-factor key", true, func(metadata, other *domain.VaultMetadata) {
+			metadata.TwoFactor = nil
+		}},
+		{"audit log key of another vault", false, func(metadata, other *domain.VaultMetadata) {
+			metadata.AuditLogKey = other.AuditLogKey
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _ := setupTestService(t)
+			ctx := context.Background()
+			if tt.twoFactor {
+				service.CreateVaultWithTwoFactor(ctx, "test-vault", "my-password", "")
+			} else {
+				service.CreateVault(ctx, "test-vault", "my-password", "")
+			}
+			service.CreateVault(ctx, "other-vault", "my-password", "")
+			token, _ := service.UnlockVault(ctx, "other-vault", "my-password")
+			service.AddPasswordRecord(ctx, token, "other-vault", RecordInput{Name: "github", Password: "secret"})
+
+			metadata, _ := service.repo.Load(ctx, "test-vault")
+			other, _ := service.repo.Load(ctx, "other-vault")
+			tt.tamper(metadata, other)
+			if err := service.repo.Save(ctx, "test-vault", metadata); err != nil {
+				t.Fatalf("Save() failed: %v", err)
+			}
+
+			if _, err := service.UnlockVault(ctx, "test-vault", "my-password"); err != domain.ErrInvalidMasterPassword {
+				t.Errorf("expected ErrInvalidMasterPassword, got %v", err)
+			}
+		})
+	}
+}
+
 func TestLockVault(t *testing.T) {
 	t.Run("locks unlocked vault", func(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		err = service.LockVault(ctx, "test-vault")
+		err = service.LockVault(ctx, token)
 		if err != nil {
 			t.Fatalf("LockVault() failed: %v", err)
 		}
@@ -241,13 +423,13 @@ func TestLockVault(t *testing.T) {
 		}
 	})
 
-	t.Run("returns error for non-existent vault", func(t *testing.T) {
+	t.Run("returns error for unknown session", func(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.LockVault(ctx, "non-existent")
-		if err != domain.ErrVaultNotFound {
-			t.Errorf("expected ErrVaultNotFound, got %v", err)
+		err := service.LockVault(ctx, "invalid-token")
+		if err != domain.ErrInvalidSession {
+			t.Errorf("expected ErrInvalidSession, got %v", err)
 		}
 	})
 
@@ -255,27 +437,27 @@ func TestLockVault(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "vault1", "password1")
+		err := service.CreateVault(ctx, "vault1", "password1", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.CreateVault(ctx, "vault2", "password2")
+		err = service.CreateVault(ctx, "vault2", "password2", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "vault1", "password1")
+		token1, err := service.UnlockVault(ctx, "vault1", "password1")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "vault2", "password2")
+		token2, err := service.UnlockVault(ctx, "vault2", "password2")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		err = service.LockVault(ctx, "vault1")
+		err = service.LockVault(ctx, token1)
 		if err != nil {
 			t.Fatalf("LockVault() failed: %v", err)
 		}
@@ -286,6 +468,371 @@ func TestLockVault(t *testing.T) {
 		if !service.IsVaultUnlocked(ctx, "vault2") {
 			t.Error("vault2 should still be unlocked")
 		}
+		if err := service.ValidateSession(ctx, token2, "vault2"); err != nil {
+			t.Errorf("vault2 session should remain valid: %v", err)
+		}
+	})
+
+	t.Run("locks one session without affecting other sessions on the same vault", func(t *testing.T) {
+		service, _ := setupTestService(t)
+		ctx := context.Background()
+
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
+		if err != nil {
+			t.Fatalf("CreateVault() failed: %v", err)
+		}
+
+		token1, err := service.UnlockVault(ctx, "test-vault", "my-password")
+		if err != nil {
+			t.Fatalf("UnlockVault() failed: %v", err)
+		}
+
+		token2, err := service.UnlockVault(ctx, "test-vault", "my-password")
+		if err != nil {
+			t.Fatalf("UnlockVault() failed: %v", err)
+		}
+
+		err = service.LockVault(ctx, token1)
+		if err != nil {
+			t.Fatalf("LockVault() failed: %v", err)
+		}
+
+		_, err = service.ListPasswordRecords(ctx, token1, "test-vault")
+		if err != domain.ErrInvalidSession {
+			t.Errorf("expected ErrInvalidSession for locked session, got %v", err)
+		}
+
+		_, err = service.AddPasswordRecord(ctx, token2, "test-vault", RecordInput{Name: "gmail", Username: "user@gmail.com", Password: "secret123"})
+		if err != nil {
+			t.Fatalf("AddPasswordRecord() with remaining session failed: %v", err)
+		}
+		if !service.IsVaultUnlocked(ctx, "test-vault") {
+			t.Error("vault should remain unlocked by the second session")
+		}
+	})
+}
+
+func TestSessionTokens(t *testing.T) {
+	t.Run("rejects record access without a token", func(t *testing.T) {
+		service, _ := setupTestService(t)
+		ctx := context.Background()
+
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
+		if err != nil {
+			t.Fatalf("CreateVault() failed: %v", err)
+		}
+
+		_, err = service.UnlockVault(ctx, "test-vault", "my-password")
+		if err != nil {
+			t.Fatalf("UnlockVault() failed: %v", err)
+		}
+
+		_, err = service.ListPasswordRecords(ctx, "", "test-vault")
+		if err != domain.ErrInvalidSession {
+			t.Errorf("expected ErrInvalidSession, got %v", err)
+		}
+	})
+
+	t.Run("rejects token issued for another vault", func(t *testing.T) {
+		service, _ := setupTestService(t)
+		ctx := context.Background()
+
+		err := service.CreateVault(ctx, "vault1", "password1", "")
+		if err != nil {
+			t.Fatalf("CreateVault() failed: %v", err)
+		}
+		err = service.CreateVault(ctx, "vault2", "password2", "")
+		if err != nil {
+			t.Fatalf("CreateVault() failed: %v", err)
+		}
+
+		token1, err := service.UnlockVault(ctx, "vault1", "password1")
+		if err != nil {
+			t.Fatalf("UnlockVault() failed: %v", err)
+		}
+		_, err = service.UnlockVault(ctx, "vault2", "password2")
+		if err != nil {
+			t.Fatalf("UnlockVault() failed: %v", err)
+		}
+
+		_, err = service.ListPasswordRecords(ctx, token1, "vault2")
+		if err != domain.ErrInvalidSession {
+			t.Errorf("expected ErrInvalidSession, got %v", err)
+		}
+	})
+
+	t.Run("shares record changes between sessions on the same vault", func(t *testing.T) {
+		service, _ := setupTestService(t)
+		ctx := context.Background()
+
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
+		if err != nil {
+			t.Fatalf("CreateVault() failed: %v", err)
+		}
+
+		token1, err := service.UnlockVault(ctx, "test-vault", "my-password")
+		if err != nil {
+			t.Fatalf("UnlockVault() failed: %v", err)
+		}
+		token2, err := service.UnlockVault(ctx, "test-vault", "my-password")
+		if err != nil {
+			t.Fatalf("UnlockVault() failed: %v", err)
+		}
+
+		_, err = service.AddPasswordRecord(ctx, token1, "test-vault", RecordInput{Name: "gmail", Username: "user@gmail.com", Password: "secret123"})
+		if err != nil {
+			t.Fatalf("AddPasswordRecord() failed: %v", err)
+		}
+
+		record, err := service.GetPasswordRecord(ctx, token2, "test-vault", "gmail")
+		if err != nil {
+			t.Fatalf("GetPasswordRecord() from second session failed: %v", err)
+		}
+		if record.Password != "secret123" {
+			t.Errorf("expected password %q, got %q", "secret123", record.Password)
+		}
+	})
+}
+
+func TestChangeMasterPassword(t *testing.T) {
+	t.Run("re-encrypts vault under new password", func(t *testing.T) {
+		service, _ := setupTestService(t)
+		ctx := context.Background()
+
+		service.CreateVault(ctx, "test-vault", "old-password", "")
+		token, _ := service.UnlockVault(ctx, "test-vault", "old-password")
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass"})
+		service.LockVault(ctx, token)
+
+		before, _ := service.repo.Load(ctx, "test-vault")
+
+		err := service.ChangeMasterPassword(ctx, "test-vault", "old-password", "new-password", "")
+		if err != nil {
+			t.Fatalf("ChangeMasterPassword() failed: %v", err)
+		}
+
+		after, _ := service.repo.Load(ctx, "test-vault")
+		if bytes.Equal(before.Salt, after.Salt) {
+			t.Error("expected a new salt after password change")
+		}
+
+		if _, err := service.UnlockVault(ctx, "test-vault", "old-password"); err != domain.ErrInvalidMasterPassword {
+			t.Errorf("expected old password to be rejected, got %v", err)
+		}
+
+		token, err = service.UnlockVault(ctx, "test-vault", "new-password")
+		if err != nil {
+			t.Fatalf("UnlockVault() with new password failed: %v", err)
+		}
+		record, err := service.GetPasswordRecord(ctx, token, "test-vault", "github")
+		if err != nil {
+			t.Fatalf("GetPasswordRecord() failed: %v", err)
+		}
+		if record.Password != "pass" {
+			t.Errorf("expected password 'pass', got '%s'", record.Password)
+		}
+	})
+
+	t.Run("removes the backup under the old password", func(t *testing.T) {
+		service, vaultDir := setupTestService(t)
+		ctx := context.Background()
+
+		service.CreateVault(ctx, "test-vault", "old-password", "")
+		token, _ := service.UnlockVault(ctx, "test-vault", "old-password")
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Password: "pass"})
+
+		backupPath := filepath.Join(vaultDir, "test-vault"+vault.VaultExtension+vault.BackupExtension)
+		if _, err := os.Stat(backupPath); err != nil {
+			t.Fatalf("expected a backup after saving: %v", err)
+		}
+
+		if err := service.ChangeMasterPassword(ctx, "test-vault", "old-password", "new-password", ""); err != nil {
+			t.Fatalf("ChangeMasterPassword() failed: %v", err)
+		}
+		if _, err := os.Stat(backupPath); !os.IsNotExist(err) {
+			t.Errorf("expected the backup to be removed, got %v", err)
+		}
+	})
+
+	t.Run("returns error for wrong old password", func(t *testing.T) {
+		service, _ := setupTestService(t)
+		ctx := context.Background()
+
+		service.CreateVault(ctx, "test-vault", "old-password", "")
+
+		err := service.ChangeMasterPassword(ctx, "test-vault", "wrong-password", "new-password", "")
+		if err != domain.ErrInvalidMasterPassword {
+			t.Errorf("expected ErrInvalidMasterPassword, got %v", err)
+		}
+
+		if _, err := service.UnlockVault(ctx, "test-vault", "old-password"); err != nil {
+			t.Errorf("vault should still unlock with old password: %v", err)
+		}
+	})
+
+	t.Run("returns error for non-existent vault", func(t *testing.T) {
+		service, _ := setupTestService(t)
+		ctx := context.Background()
+
+		err := service.ChangeMasterPassword(ctx, "non-existent", "old", "new", "")
+		if err != domain.ErrVaultNotFound {
+			t.Errorf("expected ErrVaultNotFound, got %v", err)
+		}
+	})
+
+	t.Run("upgrades KDF parameters", func(t *testing.T) {
+		service, _ := setupTestService(t)
+		ctx := context.Background()
+
+		service.CreateVault(ctx, "test-vault", "old-password", crypto.KDFProfileInteractive)
+
+		err := service.ChangeMasterPassword(ctx, "test-vault", "old-password", "new-password", crypto.KDFProfileModerate)
+		if err != nil {
+			t.Fatalf("ChangeMasterPassword() failed: %v", err)
+		}
+
+		metadata, _ := service.repo.Load(ctx, "test-vault")
+		if metadata.KDF == nil || *metadata.KDF != crypto.DefaultKDFParams() {
+			t.Errorf("expected upgraded KDF params, got %+v", metadata.KDF)
+		}
+
+		if _, err := service.UnlockVault(ctx, "test-vault", "new-password"); err != nil {
+			t.Errorf("UnlockVault() failed after KDF upgrade: %v", err)
+		}
+	})
+
+	t.Run("returns error for unknown KDF profile", func(t *testing.T) {
+		service, _ := setupTestService(t)
+		ctx := context.Background()
+
+		service.CreateVault(ctx, "test-vault", "old-password", "")
+
+		err := service.ChangeMasterPassword(ctx, "test-vault", "old-password", "new-password", "unknown")
+		if err != domain.ErrUnknownKDFProfile {
+			t.Errorf("expected ErrUnknownKDFProfile, got %v", err)
+		}
+	})
+
+	t.Run("keeps active sessions working", func(t *testing.T) {
+		service, _ := setupTestService(t)
+		ctx := context.Background()
+
+		service.CreateVault(ctx, "test-vault", "old-password", "")
+		token, _ := service.UnlockVault(ctx, "test-vault", "old-password")
+
+		err := service.ChangeMasterPassword(ctx, "test-vault", "old-password", "new-password", "")
+		if err != nil {
+			t.Fatalf("ChangeMasterPassword() failed: %v", err)
+		}
+
+		// Records saved through the existing session must use the new key
+		_, err = service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass"})
+		if err != nil {
+			t.Fatalf("AddPasswordRecord() failed: %v", err)
+		}
+		service.LockVault(ctx, token)
+
+		token, err = service.UnlockVault(ctx, "test-vault", "new-password")
+		if err != nil {
+			t.Fatalf("UnlockVault() with new password failed: %v", err)
+		}
+		if _, err := service.GetPasswordRecord(ctx, token, "test-vault", "github"); err != nil {
+			t.Errorf("GetPasswordRecord() failed: %v", err)
+		}
+	})
+}
+
+func TestSharedVaultDirectory(t *testing.T) {
+	// Two services on one directory stand in for the HTTP server and the
+	// Telegram bot running as separate processes
+	setupSharedServices := func(t *testing.T) (*VaultService, *VaultService) {
+		t.Helper()
+		vaultDir := t.TempDir()
+		repo1, _ := vault.NewFileRepository(vaultDir)
+		repo2, _ := vault.NewFileRepository(vaultDir)
+		cryptoSvc := crypto.NewService()
+		service1, service2 := NewVaultService(repo1, cryptoSvc), NewVaultService(repo2, cryptoSvc)
+		t.Cleanup(service1.Stop)
+		t.Cleanup(service2.Stop)
+		return service1, service2
+	}
+
+	t.Run("does not overwrite records added by another process", func(t *testing.T) {
+		web, bot := setupSharedServices(t)
+		ctx := context.Background()
+
+		web.CreateVault(ctx, "test-vault", "my-password", "")
+		webToken, _ := web.UnlockVault(ctx, "test-vault", "my-password")
+		botToken, _ := bot.UnlockVault(ctx, "test-vault", "my-password")
+
+		if _, err := bot.AddPasswordRecord(ctx, botToken, "test-vault", RecordInput{Name: "from-bot", Username: "user", Password: "pass"}); err != nil {
+			t.Fatalf("AddPasswordRecord() via bot failed: %v", err)
+		}
+		if _, err := web.AddPasswordRecord(ctx, webToken, "test-vault", RecordInput{Name: "from-web", Username: "user", Password: "pass"}); err != nil {
+			t.Fatalf("AddPasswordRecord() via web failed: %v", err)
+		}
+
+		records, err := bot.ListPasswordRecords(ctx, botToken, "test-vault")
+		if err != nil {
+			t.Fatalf("ListPasswordRecords() failed: %v", err)
+		}
+		if len(records) != 2 {
+			t.Errorf("expected 2 records, got %d", len(records))
+		}
+	})
+
+	t.Run("sees records added by another process", func(t *testing.T) {
+		web, bot := setupSharedServices(t)
+		ctx := context.Background()
+
+		web.CreateVault(ctx, "test-vault", "my-password", "")
+		webToken, _ := web.UnlockVault(ctx, "test-vault", "my-password")
+		botToken, _ := bot.UnlockVault(ctx, "test-vault", "my-password")
+
+		bot.AddPasswordRecord(ctx, botToken, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass"})
+
+		if _, err := web.GetPasswordRecord(ctx, webToken, "test-vault", "github"); err != nil {
+			t.Errorf("GetPasswordRecord() failed: %v", err)
+		}
+	})
+
+	t.Run("applies conflicting change on top of newer version", func(t *testing.T) {
+		web, bot := setupSharedServices(t)
+		ctx := context.Background()
+
+		web.CreateVault(ctx, "test-vault", "my-password", "")
+		webToken, _ := web.UnlockVault(ctx, "test-vault", "my-password")
+		botToken, _ := bot.UnlockVault(ctx, "test-vault", "my-password")
+
+		web.AddPasswordRecord(ctx, webToken, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass"})
+		bot.DeletePasswordRecord(ctx, botToken, "test-vault", "github")
+
+		_, err := web.UpdatePasswordRecord(ctx, webToken, "test-vault", "github", RecordUpdate{Password: stringPtr("new-pass")})
+		if err != domain.ErrRecordNotFound {
+			t.Errorf("expected ErrRecordNotFound for record deleted elsewhere, got %v", err)
+		}
+	})
+
+	t.Run("returns ErrVaultModified after password change elsewhere", func(t *testing.T) {
+		web, bot := setupSharedServices(t)
+		ctx := context.Background()
+
+		web.CreateVault(ctx, "test-vault", "my-password", "")
+		webToken, _ := web.UnlockVault(ctx, "test-vault", "my-password")
+
+		if err := bot.ChangeMasterPassword(ctx, "test-vault", "my-password", "new-password", ""); err != nil {
+			t.Fatalf("ChangeMasterPassword() failed: %v", err)
+		}
+
+		_, err := web.AddPasswordRecord(ctx, webToken, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass"})
+		if err != domain.ErrVaultModified {
+			t.Errorf("expected ErrVaultModified, got %v", err)
+		}
+
+		// The vault must still be readable with the new password
+		if _, err := bot.UnlockVault(ctx, "test-vault", "new-password"); err != nil {
+			t.Errorf("UnlockVault() failed: %v", err)
+		}
 	})
 }
 
@@ -294,22 +841,22 @@ func TestAddPasswordRecord(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		err = service.AddPasswordRecord(ctx, "test-vault", "gmail", "user@gmail.com", "secret123")
+		_, err = service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "gmail", Username: "user@gmail.com", Password: "secret123"})
 		if err != nil {
 			t.Fatalf("AddPasswordRecord() failed: %v", err)
 		}
 
-		records, err := service.ListPasswordRecords(ctx, "test-vault")
+		records, err := service.ListPasswordRecords(ctx, token, "test-vault")
 		if err != nil {
 			t.Fatalf("ListPasswordRecords() failed: %v", err)
 		}
@@ -343,14 +890,14 @@ func TestAddPasswordRecord(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.AddPasswordRecord(ctx, "test-vault", "gmail", "user@gmail.com", "secret123")
-		if err != domain.ErrVaultNotFound {
-			t.Errorf("expected ErrVaultNotFound, got %v", err)
+		_, err = service.AddPasswordRecord(ctx, "", "test-vault", RecordInput{Name: "gmail", Username: "user@gmail.com", Password: "secret123"})
+		if err != domain.ErrInvalidSession {
+			t.Errorf("expected ErrInvalidSession, got %v", err)
 		}
 	})
 
@@ -358,22 +905,22 @@ func TestAddPasswordRecord(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		err = service.AddPasswordRecord(ctx, "test-vault", "gmail", "user1@gmail.com", "pass1")
+		_, err = service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "gmail", Username: "user1@gmail.com", Password: "pass1"})
 		if err != nil {
 			t.Fatalf("AddPasswordRecord() failed: %v", err)
 		}
 
-		err = service.AddPasswordRecord(ctx, "test-vault", "gmail", "user2@gmail.com", "pass2")
+		_, err = service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "gmail", Username: "user2@gmail.com", Password: "pass2"})
 		if err != domain.ErrRecordAlreadyExists {
 			t.Errorf("expected ErrRecordAlreadyExists, got %v", err)
 		}
@@ -383,12 +930,12 @@ func TestAddPasswordRecord(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
@@ -402,13 +949,13 @@ func TestAddPasswordRecord(t *testing.T) {
 		}
 
 		for _, r := range records {
-			err = service.AddPasswordRecord(ctx, "test-vault", r.name, r.username, r.password)
+			_, err = service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: r.name, Username: r.username, Password: r.password})
 			if err != nil {
 				t.Fatalf("AddPasswordRecord() failed for %q: %v", r.name, err)
 			}
 		}
 
-		list, err := service.ListPasswordRecords(ctx, "test-vault")
+		list, err := service.ListPasswordRecords(ctx, token, "test-vault")
 		if err != nil {
 			t.Fatalf("ListPasswordRecords() failed: %v", err)
 		}
@@ -422,32 +969,32 @@ func TestAddPasswordRecord(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		err = service.AddPasswordRecord(ctx, "test-vault", "gmail", "user@gmail.com", "secret123")
+		_, err = service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "gmail", Username: "user@gmail.com", Password: "secret123"})
 		if err != nil {
 			t.Fatalf("AddPasswordRecord() failed: %v", err)
 		}
 
-		err = service.LockVault(ctx, "test-vault")
+		err = service.LockVault(ctx, token)
 		if err != nil {
 			t.Fatalf("LockVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err = service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		records, err := service.ListPasswordRecords(ctx, "test-vault")
+		records, err := service.ListPasswordRecords(ctx, token, "test-vault")
 		if err != nil {
 			t.Fatalf("ListPasswordRecords() failed: %v", err)
 		}
@@ -463,22 +1010,22 @@ func TestGetPasswordRecord(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		err = service.AddPasswordRecord(ctx, "test-vault", "gmail", "user@gmail.com", "secret123")
+		_, err = service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "gmail", Username: "user@gmail.com", Password: "secret123"})
 		if err != nil {
 			t.Fatalf("AddPasswordRecord() failed: %v", err)
 		}
 
-		record, err := service.GetPasswordRecord(ctx, "test-vault", "gmail")
+		record, err := service.GetPasswordRecord(ctx, token, "test-vault", "gmail")
 		if err != nil {
 			t.Fatalf("GetPasswordRecord() failed: %v", err)
 		}
@@ -498,17 +1045,17 @@ func TestGetPasswordRecord(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		_, err = service.GetPasswordRecord(ctx, "test-vault", "non-existent")
+		_, err = service.GetPasswordRecord(ctx, token, "test-vault", "non-existent")
 		if err != domain.ErrRecordNotFound {
 			t.Errorf("expected ErrRecordNotFound, got %v", err)
 		}
@@ -518,14 +1065,14 @@ func TestGetPasswordRecord(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		_, err = service.GetPasswordRecord(ctx, "test-vault", "gmail")
-		if err != domain.ErrVaultNotFound {
-			t.Errorf("expected ErrVaultNotFound, got %v", err)
+		_, err = service.GetPasswordRecord(ctx, "", "test-vault", "gmail")
+		if err != domain.ErrInvalidSession {
+			t.Errorf("expected ErrInvalidSession, got %v", err)
 		}
 	})
 }
@@ -535,29 +1082,29 @@ func TestUpdatePasswordRecord(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		err = service.AddPasswordRecord(ctx, "test-vault", "gmail", "old@gmail.com", "oldpass")
+		_, err = service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "gmail", Username: "old@gmail.com", Password: "oldpass"})
 		if err != nil {
 			t.Fatalf("AddPasswordRecord() failed: %v", err)
 		}
 
 		time.Sleep(10 * time.Millisecond) // Ensure UpdatedAt is different
 
-		err = service.UpdatePasswordRecord(ctx, "test-vault", "gmail", "", "newpass")
+		_, err = service.UpdatePasswordRecord(ctx, token, "test-vault", "gmail", RecordUpdate{Password: stringPtr("newpass")})
 		if err != nil {
 			t.Fatalf("UpdatePasswordRecord() failed: %v", err)
 		}
 
-		record, err := service.GetPasswordRecord(ctx, "test-vault", "gmail")
+		record, err := service.GetPasswordRecord(ctx, token, "test-vault", "gmail")
 		if err != nil {
 			t.Fatalf("GetPasswordRecord() failed: %v", err)
 		}
@@ -577,27 +1124,27 @@ func TestUpdatePasswordRecord(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		err = service.AddPasswordRecord(ctx, "test-vault", "gmail", "old@gmail.com", "password")
+		_, err = service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "gmail", Username: "old@gmail.com", Password: "password"})
 		if err != nil {
 			t.Fatalf("AddPasswordRecord() failed: %v", err)
 		}
 
-		err = service.UpdatePasswordRecord(ctx, "test-vault", "gmail", "new@gmail.com", "")
+		_, err = service.UpdatePasswordRecord(ctx, token, "test-vault", "gmail", RecordUpdate{Username: stringPtr("new@gmail.com")})
 		if err != nil {
 			t.Fatalf("UpdatePasswordRecord() failed: %v", err)
 		}
 
-		record, err := service.GetPasswordRecord(ctx, "test-vault", "gmail")
+		record, err := service.GetPasswordRecord(ctx, token, "test-vault", "gmail")
 		if err != nil {
 			t.Fatalf("GetPasswordRecord() failed: %v", err)
 		}
@@ -614,27 +1161,27 @@ func TestUpdatePasswordRecord(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		err = service.AddPasswordRecord(ctx, "test-vault", "gmail", "old@gmail.com", "oldpass")
+		_, err = service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "gmail", Username: "old@gmail.com", Password: "oldpass"})
 		if err != nil {
 			t.Fatalf("AddPasswordRecord() failed: %v", err)
 		}
 
-		err = service.UpdatePasswordRecord(ctx, "test-vault", "gmail", "new@gmail.com", "newpass")
+		_, err = service.UpdatePasswordRecord(ctx, token, "test-vault", "gmail", RecordUpdate{Username: stringPtr("new@gmail.com"), Password: stringPtr("newpass")})
 		if err != nil {
 			t.Fatalf("UpdatePasswordRecord() failed: %v", err)
 		}
 
-		record, err := service.GetPasswordRecord(ctx, "test-vault", "gmail")
+		record, err := service.GetPasswordRecord(ctx, token, "test-vault", "gmail")
 		if err != nil {
 			t.Fatalf("GetPasswordRecord() failed: %v", err)
 		}
@@ -651,17 +1198,17 @@ func TestUpdatePasswordRecord(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		err = service.UpdatePasswordRecord(ctx, "test-vault", "non-existent", "user", "pass")
+		_, err = service.UpdatePasswordRecord(ctx, token, "test-vault", "non-existent", RecordUpdate{Username: stringPtr("user"), Password: stringPtr("pass")})
 		if err != domain.ErrRecordNotFound {
 			t.Errorf("expected ErrRecordNotFound, got %v", err)
 		}
@@ -671,14 +1218,14 @@ func TestUpdatePasswordRecord(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UpdatePasswordRecord(ctx, "test-vault", "gmail", "user", "pass")
-		if err != domain.ErrVaultNotFound {
-			t.Errorf("expected ErrVaultNotFound, got %v", err)
+		_, err = service.UpdatePasswordRecord(ctx, "", "test-vault", "gmail", RecordUpdate{Username: stringPtr("user"), Password: stringPtr("pass")})
+		if err != domain.ErrInvalidSession {
+			t.Errorf("expected ErrInvalidSession, got %v", err)
 		}
 	})
 
@@ -686,37 +1233,37 @@ func TestUpdatePasswordRecord(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		err = service.AddPasswordRecord(ctx, "test-vault", "gmail", "old@gmail.com", "oldpass")
+		_, err = service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "gmail", Username: "old@gmail.com", Password: "oldpass"})
 		if err != nil {
 			t.Fatalf("AddPasswordRecord() failed: %v", err)
 		}
 
-		err = service.UpdatePasswordRecord(ctx, "test-vault", "gmail", "new@gmail.com", "newpass")
+		_, err = service.UpdatePasswordRecord(ctx, token, "test-vault", "gmail", RecordUpdate{Username: stringPtr("new@gmail.com"), Password: stringPtr("newpass")})
 		if err != nil {
 			t.Fatalf("UpdatePasswordRecord() failed: %v", err)
 		}
 
-		err = service.LockVault(ctx, "test-vault")
+		err = service.LockVault(ctx, token)
 		if err != nil {
 			t.Fatalf("LockVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err = service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		record, err := service.GetPasswordRecord(ctx, "test-vault", "gmail")
+		record, err := service.GetPasswordRecord(ctx, token, "test-vault", "gmail")
 		if err != nil {
 			t.Fatalf("GetPasswordRecord() failed: %v", err)
 		}
@@ -732,27 +1279,27 @@ func TestDeletePasswordRecord(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		err = service.AddPasswordRecord(ctx, "test-vault", "gmail", "user@gmail.com", "secret123")
+		_, err = service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "gmail", Username: "user@gmail.com", Password: "secret123"})
 		if err != nil {
 			t.Fatalf("AddPasswordRecord() failed: %v", err)
 		}
 
-		err = service.DeletePasswordRecord(ctx, "test-vault", "gmail")
+		err = service.DeletePasswordRecord(ctx, token, "test-vault", "gmail")
 		if err != nil {
 			t.Fatalf("DeletePasswordRecord() failed: %v", err)
 		}
 
-		records, err := service.ListPasswordRecords(ctx, "test-vault")
+		records, err := service.ListPasswordRecords(ctx, token, "test-vault")
 		if err != nil {
 			t.Fatalf("ListPasswordRecords() failed: %v", err)
 		}
@@ -766,17 +1313,17 @@ func TestDeletePasswordRecord(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		err = service.DeletePasswordRecord(ctx, "test-vault", "non-existent")
+		err = service.DeletePasswordRecord(ctx, token, "test-vault", "non-existent")
 		if err != domain.ErrRecordNotFound {
 			t.Errorf("expected ErrRecordNotFound, got %v", err)
 		}
@@ -786,14 +1333,14 @@ func TestDeletePasswordRecord(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.DeletePasswordRecord(ctx, "test-vault", "gmail")
-		if err != domain.ErrVaultNotFound {
-			t.Errorf("expected ErrVaultNotFound, got %v", err)
+		err = service.DeletePasswordRecord(ctx, "", "test-vault", "gmail")
+		if err != domain.ErrInvalidSession {
+			t.Errorf("expected ErrInvalidSession, got %v", err)
 		}
 	})
 
@@ -801,32 +1348,32 @@ func TestDeletePasswordRecord(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		err = service.AddPasswordRecord(ctx, "test-vault", "gmail", "user@gmail.com", "pass1")
+		_, err = service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "gmail", Username: "user@gmail.com", Password: "pass1"})
 		if err != nil {
 			t.Fatalf("AddPasswordRecord() failed: %v", err)
 		}
 
-		err = service.AddPasswordRecord(ctx, "test-vault", "github", "user@github.com", "pass2")
+		_, err = service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user@github.com", Password: "pass2"})
 		if err != nil {
 			t.Fatalf("AddPasswordRecord() failed: %v", err)
 		}
 
-		err = service.DeletePasswordRecord(ctx, "test-vault", "gmail")
+		err = service.DeletePasswordRecord(ctx, token, "test-vault", "gmail")
 		if err != nil {
 			t.Fatalf("DeletePasswordRecord() failed: %v", err)
 		}
 
-		records, err := service.ListPasswordRecords(ctx, "test-vault")
+		records, err := service.ListPasswordRecords(ctx, token, "test-vault")
 		if err != nil {
 			t.Fatalf("ListPasswordRecords() failed: %v", err)
 		}
@@ -843,37 +1390,37 @@ func TestDeletePasswordRecord(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		err = service.AddPasswordRecord(ctx, "test-vault", "gmail", "user@gmail.com", "secret123")
+		_, err = service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "gmail", Username: "user@gmail.com", Password: "secret123"})
 		if err != nil {
 			t.Fatalf("AddPasswordRecord() failed: %v", err)
 		}
 
-		err = service.DeletePasswordRecord(ctx, "test-vault", "gmail")
+		err = service.DeletePasswordRecord(ctx, token, "test-vault", "gmail")
 		if err != nil {
 			t.Fatalf("DeletePasswordRecord() failed: %v", err)
 		}
 
-		err = service.LockVault(ctx, "test-vault")
+		err = service.LockVault(ctx, token)
 		if err != nil {
 			t.Fatalf("LockVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err = service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		records, err := service.ListPasswordRecords(ctx, "test-vault")
+		records, err := service.ListPasswordRecords(ctx, token, "test-vault")
 		if err != nil {
 			t.Fatalf("ListPasswordRecords() failed: %v", err)
 		}
@@ -889,25 +1436,25 @@ func TestListPasswordRecords(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
 		expected := []string{"gmail", "github", "twitter"}
 		for _, name := range expected {
-			err = service.AddPasswordRecord(ctx, "test-vault", name, "user@"+name+".com", "pass")
+			_, err = service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: name, Username: "user@" + name + ".com", Password: "pass"})
 			if err != nil {
 				t.Fatalf("AddPasswordRecord() failed: %v", err)
 			}
 		}
 
-		records, err := service.ListPasswordRecords(ctx, "test-vault")
+		records, err := service.ListPasswordRecords(ctx, token, "test-vault")
 		if err != nil {
 			t.Fatalf("ListPasswordRecords() failed: %v", err)
 		}
@@ -921,17 +1468,17 @@ func TestListPasswordRecords(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
 
-		records, err := service.ListPasswordRecords(ctx, "test-vault")
+		records, err := service.ListPasswordRecords(ctx, token, "test-vault")
 		if err != nil {
 			t.Fatalf("ListPasswordRecords() failed: %v", err)
 		}
@@ -945,14 +1492,14 @@ func TestListPasswordRecords(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		_, err = service.ListPasswordRecords(ctx, "test-vault")
-		if err != domain.ErrVaultNotFound {
-			t.Errorf("expected ErrVaultNotFound, got %v", err)
+		_, err = service.ListPasswordRecords(ctx, "", "test-vault")
+		if err != domain.ErrInvalidSession {
+			t.Errorf("expected ErrInvalidSession, got %v", err)
 		}
 	})
 }
@@ -964,7 +1511,7 @@ func TestListVaults(t *testing.T) {
 
 		vaults := []string{"personal", "work", "shared"}
 		for _, name := range vaults {
-			err := service.CreateVault(ctx, name, "password")
+			err := service.CreateVault(ctx, name, "password", "")
 			if err != nil {
 				t.Fatalf("CreateVault() failed: %v", err)
 			}
@@ -1000,12 +1547,12 @@ func TestIsVaultUnlocked(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		_, err = service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
@@ -1019,7 +1566,7 @@ func TestIsVaultUnlocked(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
@@ -1053,19 +1600,19 @@ func TestConcurrency(t *testing.T) {
 				defer wg.Done()
 				vaultName := fmt.Sprintf("vault-%d", index)
 
-				err := service.CreateVault(ctx, vaultName, "password")
+				err := service.CreateVault(ctx, vaultName, "password", "")
 				if err != nil {
 					t.Errorf("CreateVault() failed: %v", err)
 					return
 				}
 
-				err = service.UnlockVault(ctx, vaultName, "password")
+				token, err := service.UnlockVault(ctx, vaultName, "password")
 				if err != nil {
 					t.Errorf("UnlockVault() failed: %v", err)
 					return
 				}
 
-				err = service.AddPasswordRecord(ctx, vaultName, "record", "user", "pass")
+				_, err = service.AddPasswordRecord(ctx, token, vaultName, RecordInput{Name: "record", Username: "user", Password: "pass"})
 				if err != nil {
 					t.Errorf("AddPasswordRecord() failed: %v", err)
 					return
@@ -1089,12 +1636,12 @@ func TestConcurrency(t *testing.T) {
 		service, _ := setupTestService(t)
 		ctx := context.Background()
 
-		err := service.CreateVault(ctx, "test-vault", "my-password")
+		err := service.CreateVault(ctx, "test-vault", "my-password", "")
 		if err != nil {
 			t.Fatalf("CreateVault() failed: %v", err)
 		}
 
-		err = service.UnlockVault(ctx, "test-vault", "my-password")
+		token, err := service.UnlockVault(ctx, "test-vault", "my-password")
 		if err != nil {
 			t.Fatalf("UnlockVault() failed: %v", err)
 		}
@@ -1102,7 +1649,7 @@ func TestConcurrency(t *testing.T) {
 		// Add some records first
 		for i := 0; i < 5; i++ {
 			recordName := fmt.Sprintf("record-%d", i)
-			err := service.AddPasswordRecord(ctx, "test-vault", recordName, "user", "pass")
+			_, err := service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: recordName, Username: "user", Password: "pass"})
 			if err != nil {
 				t.Fatalf("AddPasswordRecord() failed: %v", err)
 			}
@@ -1116,7 +1663,7 @@ func TestConcurrency(t *testing.T) {
 		for i := 0; i < readCount; i++ {
 			go func() {
 				defer wg.Done()
-				_, err := service.ListPasswordRecords(ctx, "test-vault")
+				_, err := service.ListPasswordRecords(ctx, token, "test-vault")
 				if err != nil {
 					t.Errorf("ListPasswordRecords() failed: %v", err)
 				}
@@ -1125,7 +1672,7 @@ func TestConcurrency(t *testing.T) {
 
 		wg.Wait()
 
-		records, err := service.ListPasswordRecords(ctx, "test-vault")
+		records, err := service.ListPasswordRecords(ctx, token, "test-vault")
 		if err != nil {
 			t.Fatalf("ListPasswordRecords() failed: %v", err)
 		}
@@ -1135,3 +1682,148 @@ func TestConcurrency(t *testing.T) {
 		}
 	})
 }
+
+// recordingCrypto remembers every plaintext and key buffer handed out by or
+// passed to the crypto service so tests can check they were wiped
+type recordingCrypto struct {
+	*crypto.Service
+
+	mu      sync.Mutex
+	buffers [][]byte
+}
+
+func (c *recordingCrypto) record(buf []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buffers = append(c.buffers, buf)
+}
+
+func (c *recordingCrypto) DeriveKey(password string, salt []byte, params domain.KDFParams) ([]byte, error) {
+	key, err := c.Service.DeriveKey(password, salt, params)
+	c.record(key)
+	return key, err
+}
+
+func (c *recordingCrypto) Encrypt(plaintext, key, additionalData []byte) ([]byte, []byte, error) {
+	c.record(plaintext)
+	return c.Service.Encrypt(plaintext, key, additionalData)
+}
+
+func (c *recordingCrypto) Decrypt(nonce, ciphertext, key, additionalData []byte) ([]byte, error) {
+	plaintext, err := c.Service.Decrypt(nonce, ciphertext, key, additionalData)
+	c.record(plaintext)
+	return plaintext, err
+}
+
+// take returns the recorded buffers and starts a new recording
+func (c *recordingCrypto) take() [][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	buffers := c.buffers
+	c.buffers = nil
+	return buffers
+}
+
+func isZeroed(buf []byte) bool {
+	for _, b := range buf {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBufferWiping(t *testing.T) {
+	setup := func(t *testing.T) (*VaultService, *recordingCrypto) {
+		t.Helper()
+		repo, _ := vault.NewFileRepository(t.TempDir())
+		cryptoSvc := &recordingCrypto{Service: crypto.NewService()}
+		service := NewVaultService(repo, cryptoSvc)
+		t.Cleanup(service.Stop)
+		return service, cryptoSvc
+	}
+
+	t.Run("wipes buffers after create", func(t *testing.T) {
+		service, rec := setup(t)
+
+		service.CreateVault(context.Background(), "test-vault", "my-password", "")
+
+		for i, buf := range rec.take() {
+			if !isZeroed(buf) {
+				t.Errorf("buffer %d was not wiped after CreateVault()", i)
+			}
+		}
+	})
+
+	t.Run("wipes decrypted plaintext after unlock", func(t *testing.T) {
+		service, rec := setup(t)
+		ctx := context.Background()
+
+		service.CreateVault(ctx, "test-vault", "my-password", "")
+		rec.take()
+
+		service.UnlockVault(ctx, "test-vault", "my-password")
+
+		buffers := rec.take()
+		if len(buffers) != 2 {
+			t.Fatalf("expected key and plaintext buffers, got %d", len(buffers))
+		}
+		// buffers[0] is the session key, which stays alive until lock
+		if !isZeroed(buffers[1]) {
+			t.Error("decrypted vault JSON was not wiped after UnlockVault()")
+		}
+	})
+
+	t.Run("wipes key after failed unlock", func(t *testing.T) {
+		service, rec := setup(t)
+		ctx := context.Background()
+
+		service.CreateVault(ctx, "test-vault", "my-password", "")
+		rec.take()
+
+		service.UnlockVault(ctx, "test-vault", "wrong-password")
+
+		for i, buf := range rec.take() {
+			if !isZeroed(buf) {
+				t.Errorf("buffer %d was not wiped after failed UnlockVault()", i)
+			}
+		}
+	})
+
+	t.Run("wipes serialized plaintext after save", func(t *testing.T) {
+		service, rec := setup(t)
+		ctx := context.Background()
+
+		service.CreateVault(ctx, "test-vault", "my-password", "")
+		token, _ := service.UnlockVault(ctx, "test-vault", "my-password")
+		rec.take()
+
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "secret-pass"})
+
+		buffers := rec.take()
+		if len(buffers) == 0 {
+			t.Fatal("expected plaintext to be encrypted")
+		}
+		for i, buf := range buffers {
+			if !isZeroed(buf) {
+				t.Errorf("buffer %d was not wiped after save", i)
+			}
+		}
+	})
+
+	t.Run("wipes keys after password change", func(t *testing.T) {
+		service, rec := setup(t)
+		ctx := context.Background()
+
+		service.CreateVault(ctx, "test-vault", "my-password", "")
+		rec.take()
+
+		service.ChangeMasterPassword(ctx, "test-vault", "my-password", "new-password", "")
+
+		for i, buf := range rec.take() {
+			if !isZeroed(buf) {
+				t.Errorf("buffer %d was not wiped after ChangeMasterPassword()", i)
+			}
+		}
+	})
+}
@@ -0,0 +1,219 @@
+package application
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/crypto"
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// Default session limits
+const (
+	DefaultIdleTimeout        = 15 * time.Minute
+	DefaultMaxSessionLifetime = 8 * time.Hour
+
+	// sessionSweepInterval is how often expired sessions are locked
+	sessionSweepInterval = 15 * time.Second
+)
+
+// LockReason explains why a session was locked
+type LockReason string
+
+const (
+	// LockReasonManual means the client locked the vault itself
+	LockReasonManual LockReason = "manual"
+	// LockReasonIdle means the session was unused for longer than the idle timeout
+	LockReasonIdle LockReason = "idle_timeout"
+	// LockReasonLifetime means the session reached its maximum lifetime
+	LockReasonLifetime LockReason = "max_lifetime"
+	// LockReasonShutdown means the service was stopped
+	LockReasonShutdown LockReason = "shutdown"
+	// LockReasonAccessRevoked means the member was removed from a shared vault
+	LockReasonAccessRevoked LockReason = "access_revoked"
+)
+
+// SessionPolicy controls automatic locking of sessions.
+// A zero duration disables the corresponding limit.
+type SessionPolicy struct {
+	// IdleTimeout locks a session that has not been used for this long
+	IdleTimeout time.Duration
+	// MaxLifetime locks a session this long after unlock, however active
+	MaxLifetime time.Duration
+}
+
+// DefaultSessionPolicy returns the session limits used by NewVaultService
+func DefaultSessionPolicy() SessionPolicy {
+	return SessionPolicy{
+		IdleTimeout: DefaultIdleTimeout,
+		MaxLifetime: DefaultMaxSessionLifetime,
+	}
+}
+
+// SessionEvent describes a session that has been locked
+type SessionEvent struct {
+	Token     string
+	VaultName string
+	Reason    LockReason
+	LockedAt  time.Time
+}
+
+// session holds the decrypted vault and encryption key in memory.
+// Sessions unlocking the same vault share the decrypted vault so that
+// changes made through one session are visible to the others, but each
+// session owns its own copy of the key.
+type session struct {
+	vaultName  string
+	member     string // member of a shared vault who unlocked it
+	client     string // client that unlocked it, see WithClient
+	vault      *openVault
+	key        *crypto.SecureBuffer
+	rosterKey  *crypto.SecureBuffer // signs the members; empty unless an owner
+	createdAt  time.Time
+	lastAccess time.Time
+}
+
+// openVault is a decrypted vault together with the revision it was read at
+type openVault struct {
+	*domain.Vault
+	revision uint64
+	// roles of the members of a shared vault, checked against the members
+	// signature; nil for single-user vaults
+	roles map[string]domain.MemberRole
+	// firstRosterKey is the key the members were first signed with, which
+	// later versions of the vault must keep
+	firstRosterKey []byte
+}
+
+// role returns what the session may do. The single user of a vault that is
+// not shared owns it.
+func (sess *session) role() domain.MemberRole {
+	if sess.vault.roles == nil {
+		return domain.RoleOwner
+	}
+	return sess.vault.roles[sess.member]
+}
+
+// SetSessionPolicy changes the session limits. The new limits also apply
+// to sessions that are already unlocked.
+func (s *VaultService) SetSessionPolicy(policy SessionPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.policy = policy
+}
+
+// OnSessionLocked registers fn to be called whenever a session is locked,
+// whether by the client, by a timeout or on shutdown. fn runs outside the
+// service lock and may call back into the service.
+func (s *VaultService) OnSessionLocked(fn func(SessionEvent)) {
+	s.listenersMu.Lock()
+	defer s.listenersMu.Unlock()
+
+	s.listeners = append(s.listeners, fn)
+}
+
+// Stop ends the expiry loop and locks all remaining sessions
+func (s *VaultService) Stop() {
+	s.stopOnce.Do(func() {
+		s.sweepTicker.Stop()
+		close(s.done)
+
+		s.mu.Lock()
+		defer s.unlock()
+		for token, sess := range s.sessions {
+			s.removeSession(token, sess, LockReasonShutdown)
+		}
+	})
+}
+
+// expireSessions periodically locks sessions that exceeded their limits
+func (s *VaultService) expireSessions() {
+	for {
+		select {
+		case <-s.sweepTicker.C:
+			s.lockExpiredSessions()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// lockExpiredSessions locks every session past its idle timeout or lifetime
+func (s *VaultService) lockExpiredSessions() {
+	s.mu.Lock()
+	defer s.unlock()
+
+	now := s.now()
+	for token, sess := range s.sessions {
+		if reason := s.expiryReason(sess, now); reason != "" {
+			s.removeSession(token, sess, reason)
+		}
+	}
+}
+
+// expiryReason reports why sess has expired at now, or "" if it is still
+// valid. Callers must hold s.mu.
+func (s *VaultService) expiryReason(sess *session, now time.Time) LockReason {
+	if s.policy.MaxLifetime > 0 && now.Sub(sess.createdAt) >= s.policy.MaxLifetime {
+		return LockReasonLifetime
+	}
+	if s.policy.IdleTimeout > 0 && now.Sub(sess.lastAccess) >= s.policy.IdleTimeout {
+		return LockReasonIdle
+	}
+	return ""
+}
+
+// removeSession deletes a session and wipes its key. The decrypted records
+// are dropped once no other session shares them. The lock event is queued
+// and delivered by unlock. Callers must hold s.mu.
+func (s *VaultService) removeSession(token string, sess *session, reason LockReason) {
+	// The session is locked even if the entry cannot be written
+	s.logEvent(context.Background(), sess, domain.AuditLogEntry{Event: domain.AuditLogLock, Detail: string(reason)})
+
+	delete(s.sessions, token)
+	sess.key.Destroy()
+	sess.rosterKey.Destroy()
+
+	shared := false
+	for _, other := range s.sessions {
+		if other.vault == sess.vault {
+			shared = true
+			break
+		}
+	}
+	if !shared && sess.vault.Vault != nil {
+		clear(sess.vault.Records)
+		sess.vault.Vault = nil
+	}
+
+	s.pendingEvents = append(s.pendingEvents, SessionEvent{
+		Token:     token,
+		VaultName: sess.vaultName,
+		Reason:    reason,
+		LockedAt:  s.now(),
+	})
+}
+
+// unlock releases s.mu and then delivers queued lock events, so listeners
+// may call back into the service
+func (s *VaultService) unlock() {
+	events := s.pendingEvents
+	s.pendingEvents = nil
+	s.mu.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+
+	s.listenersMu.RLock()
+	listeners := slices.Clone(s.listeners)
+	s.listenersMu.RUnlock()
+
+	for _, event := range events {
+		for _, fn := range listeners {
+			fn(event)
+		}
+	}
+}
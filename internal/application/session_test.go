@@ -0,0 +1,270 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// fakeClock lets tests move time forward without sleeping
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// setupSessionTest returns a service with an unlocked vault, a fake clock
+// and a channel receiving lock events
+func setupSessionTest(t *testing.T, policy SessionPolicy) (*VaultService, *fakeClock, string, <-chan SessionEvent) {
+	t.Helper()
+	service, _ := setupTestService(t)
+	ctx := context.Background()
+
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	service.mu.Lock()
+	service.now = clock.Now
+	service.mu.Unlock()
+	service.SetSessionPolicy(policy)
+
+	events := make(chan SessionEvent, 10)
+	service.OnSessionLocked(func(event SessionEvent) {
+		events <- event
+	})
+
+	if err := service.CreateVault(ctx, "test-vault", "my-password", ""); err != nil {
+		t.Fatalf("CreateVault() failed: %v", err)
+	}
+	token, err := service.UnlockVault(ctx, "test-vault", "my-password")
+	if err != nil {
+		t.Fatalf("UnlockVault() failed: %v", err)
+	}
+
+	return service, clock, token, events
+}
+
+func expectLockEvent(t *testing.T, events <-chan SessionEvent, token string, reason LockReason) {
+	t.Helper()
+	select {
+	case event := <-events:
+		if event.Token != token {
+			t.Errorf("expected event for token %q, got %q", token, event.Token)
+		}
+		if event.VaultName != "test-vault" {
+			t.Errorf("expected vault name %q, got %q", "test-vault", event.VaultName)
+		}
+		if event.Reason != reason {
+			t.Errorf("expected reason %q, got %q", reason, event.Reason)
+		}
+	default:
+		t.Fatalf("expected %q lock event, got none", reason)
+	}
+}
+
+func TestSessionIdleTimeout(t *testing.T) {
+	policy := SessionPolicy{IdleTimeout: 10 * time.Minute, MaxLifetime: time.Hour}
+
+	t.Run("locks session after idle timeout", func(t *testing.T) {
+		service, clock, token, events := setupSessionTest(t, policy)
+		ctx := context.Background()
+
+		clock.Advance(11 * time.Minute)
+
+		_, err := service.ListPasswordRecords(ctx, token, "test-vault")
+		if err != domain.ErrInvalidSession {
+			t.Errorf("expected ErrInvalidSession, got %v", err)
+		}
+		expectLockEvent(t, events, token, LockReasonIdle)
+
+		if service.IsVaultUnlocked(ctx, "test-vault") {
+			t.Error("vault should be locked after idle timeout")
+		}
+	})
+
+	t.Run("activity keeps session alive", func(t *testing.T) {
+		service, clock, token, events := setupSessionTest(t, policy)
+		ctx := context.Background()
+
+		for i := 0; i < 5; i++ {
+			clock.Advance(9 * time.Minute)
+			if _, err := service.ListPasswordRecords(ctx, token, "test-vault"); err != nil {
+				t.Fatalf("ListPasswordRecords() failed after %d minutes: %v", (i+1)*9, err)
+			}
+		}
+
+		select {
+		case event := <-events:
+			t.Errorf("unexpected lock event: %+v", event)
+		default:
+		}
+	})
+
+	t.Run("expiry loop locks idle sessions", func(t *testing.T) {
+		service, clock, token, events := setupSessionTest(t, policy)
+
+		clock.Advance(10 * time.Minute)
+		service.lockExpiredSessions()
+
+		expectLockEvent(t, events, token, LockReasonIdle)
+		if service.IsVaultUnlocked(context.Background(), "test-vault") {
+			t.Error("vault should be locked by expiry loop")
+		}
+	})
+
+	t.Run("zero timeout disables idle locking", func(t *testing.T) {
+		service, clock, token, _ := setupSessionTest(t, SessionPolicy{})
+
+		clock.Advance(30 * 24 * time.Hour)
+		service.lockExpiredSessions()
+
+		if _, err := service.ListPasswordRecords(context.Background(), token, "test-vault"); err != nil {
+			t.Errorf("ListPasswordRecords() failed with limits disabled: %v", err)
+		}
+	})
+}
+
+func TestSessionMaxLifetime(t *testing.T) {
+	t.Run("locks active session after max lifetime", func(t *testing.T) {
+		policy := SessionPolicy{IdleTimeout: 10 * time.Minute, MaxLifetime: 30 * time.Minute}
+		service, clock, token, events := setupSessionTest(t, policy)
+		ctx := context.Background()
+
+		for i := 0; i < 3; i++ {
+			clock.Advance(9 * time.Minute)
+			if _, err := service.ListPasswordRecords(ctx, token, "test-vault"); err != nil {
+				t.Fatalf("ListPasswordRecords() failed: %v", err)
+			}
+		}
+
+		clock.Advance(5 * time.Minute)
+		_, err := service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass"})
+		if err != domain.ErrInvalidSession {
+			t.Errorf("expected ErrInvalidSession, got %v", err)
+		}
+		expectLockEvent(t, events, token, LockReasonLifetime)
+	})
+}
+
+func TestSessionLockEvents(t *testing.T) {
+	policy := DefaultSessionPolicy()
+
+	t.Run("manual lock emits event", func(t *testing.T) {
+		service, _, token, events := setupSessionTest(t, policy)
+
+		if err := service.LockVault(context.Background(), token); err != nil {
+			t.Fatalf("LockVault() failed: %v", err)
+		}
+		expectLockEvent(t, events, token, LockReasonManual)
+	})
+
+	t.Run("stop locks remaining sessions", func(t *testing.T) {
+		service, _, token, events := setupSessionTest(t, policy)
+
+		service.Stop()
+		expectLockEvent(t, events, token, LockReasonShutdown)
+
+		if service.IsVaultUnlocked(context.Background(), "test-vault") {
+			t.Error("vault should be locked after Stop()")
+		}
+	})
+
+	t.Run("listener may call back into the service", func(t *testing.T) {
+		service, clock, token, _ := setupSessionTest(t, SessionPolicy{IdleTimeout: time.Minute})
+		ctx := context.Background()
+
+		called := make(chan bool, 1)
+		service.OnSessionLocked(func(event SessionEvent) {
+			called <- service.IsVaultUnlocked(ctx, event.VaultName)
+		})
+
+		clock.Advance(2 * time.Minute)
+		service.ValidateSession(ctx, token, "test-vault")
+
+		select {
+		case unlocked := <-called:
+			if unlocked {
+				t.Error("vault should report locked inside listener")
+			}
+		default:
+			t.Fatal("listener was not called")
+		}
+	})
+}
+
+func TestSessionKeyWiping(t *testing.T) {
+	t.Run("zeroes key and drops records on lock", func(t *testing.T) {
+		service, _, token, _ := setupSessionTest(t, DefaultSessionPolicy())
+		ctx := context.Background()
+
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass"})
+
+		service.mu.RLock()
+		sess := service.sessions[token]
+		key := sess.key.Bytes()
+		vault := sess.vault
+		service.mu.RUnlock()
+
+		service.LockVault(ctx, token)
+
+		if !bytes.Equal(key, make([]byte, len(key))) {
+			t.Error("session key was not zeroed on lock")
+		}
+		if !sess.key.Destroyed() {
+			t.Error("session key buffer was not destroyed on lock")
+		}
+		if vault.Vault != nil {
+			t.Error("decrypted vault was not dropped after last session locked")
+		}
+	})
+
+	t.Run("zeroes keys of all sessions on stop", func(t *testing.T) {
+		service, _, token1, _ := setupSessionTest(t, DefaultSessionPolicy())
+		ctx := context.Background()
+
+		token2, _ := service.UnlockVault(ctx, "test-vault", "my-password")
+
+		service.mu.RLock()
+		keys := [][]byte{service.sessions[token1].key.Bytes(), service.sessions[token2].key.Bytes()}
+		service.mu.RUnlock()
+
+		service.Stop()
+
+		for i, key := range keys {
+			if !bytes.Equal(key, make([]byte, len(key))) {
+				t.Errorf("key of session %d was not zeroed on stop", i+1)
+			}
+		}
+	})
+
+	t.Run("keeps shared records while another session is open", func(t *testing.T) {
+		service, _, token1, _ := setupSessionTest(t, DefaultSessionPolicy())
+		ctx := context.Background()
+
+		token2, _ := service.UnlockVault(ctx, "test-vault", "my-password")
+		service.AddPasswordRecord(ctx, token1, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass"})
+
+		service.LockVault(ctx, token1)
+
+		record, err := service.GetPasswordRecord(ctx, token2, "test-vault", "github")
+		if err != nil {
+			t.Fatalf("GetPasswordRecord() failed: %v", err)
+		}
+		if record.Password != "pass" {
+			t.Errorf("expected password 'pass', got '%s'", record.Password)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package application
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// rfcSecret is the RFC 6238 SHA-1 test secret in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGetTOTPCode(t *testing.T) {
+	setup := func(t *testing.T) (*VaultService, string) {
+		service, token := setupRecordTest(t)
+		service.mu.Lock()
+		service.now = func() time.Time { return time.Unix(59, 0) }
+		service.mu.Unlock()
+		return service, token
+	}
+
+	t.Run("generates the code from a base32 secret", func(t *testing.T) {
+		service, token := setup(t)
+		ctx := context.Background()
+
+		_, err := service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass", TOTP: "gezd gnbv gy3t qojq gezd gnbv gy3t qojq"})
+		if err != nil {
+			t.Fatalf("AddPasswordRecord() failed: %v", err)
+		}
+
+		code, err := service.GetTOTPCode(ctx, token, "test-vault", "github")
+		if err != nil {
+			t.Fatalf("GetTOTPCode() failed: %v", err)
+		}
+		if code.Code != "287082" || code.Period != 30*time.Second || code.Remaining != time.Second {
+			t.Errorf("unexpected code %+v", code)
+		}
+		if !code.ExpiresAt.Equal(time.Unix(60, 0)) {
+			t.Errorf("unexpected expiry %v", code.ExpiresAt)
+		}
+	})
+
+	t.Run("honors otpauth parameters", func(t *testing.T) {
+		service, token := setup(t)
+		ctx := context.Background()
+
+		uri := "otpauth://totp/GitHub:user?secret=" + rfcSecret + "&digits=8"
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass", TOTP: uri})
+
+		code, err := service.GetTOTPCode(ctx, token, "test-vault", "github")
+		if err != nil {
+			t.Fatalf("GetTOTPCode() failed: %v", err)
+		}
+		if code.Code != "94287082" {
+			t.Errorf("expected 94287082, got %s", code.Code)
+		}
+	})
+
+	t.Run("falls back to a totp custom field", func(t *testing.T) {
+		service, token := setup(t)
+		ctx := context.Background()
+
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{
+			Name:         "github",
+			Username:     "user",
+			Password:     "pass",
+			CustomFields: []domain.CustomField{{Name: "2FA", Value: rfcSecret, Type: domain.CustomFieldTOTP}},
+		})
+
+		code, err := service.GetTOTPCode(ctx, token, "test-vault", "github")
+		if err != nil || code.Code != "287082" {
+			t.Errorf("GetTOTPCode() = %+v, %v", code, err)
+		}
+	})
+
+	t.Run("returns errors", func(t *testing.T) {
+		service, token := setup(t)
+		ctx := context.Background()
+
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "plain", Username: "user", Password: "pass"})
+
+		if _, err := service.GetTOTPCode(ctx, token, "test-vault", "plain"); err != domain.ErrNoTOTP {
+			t.Errorf("expected ErrNoTOTP, got %v", err)
+		}
+		if _, err := service.GetTOTPCode(ctx, token, "test-vault", "missing"); err != domain.ErrRecordNotFound {
+			t.Errorf("expected ErrRecordNotFound, got %v", err)
+		}
+		if _, err := service.GetTOTPCode(ctx, "invalid-token", "test-vault", "plain"); err != domain.ErrInvalidSession {
+			t.Errorf("expected ErrInvalidSession, got %v", err)
+		}
+	})
+}
+
+func TestTOTPValidation(t *testing.T) {
+	service, token := setupRecordTest(t)
+	ctx := context.Background()
+
+	for name, seed := range map[string]string{
+		"not base32": "not a secret!",
+		"hotp":       "otpauth://hotp/user?secret=" + rfcSecret + "&counter=1",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass", TOTP: seed})
+			if err != domain.ErrInvalidTOTP {
+				t.Errorf("expected ErrInvalidTOTP, got %v", err)
+			}
+		})
+	}
+
+	t.Run("only logins hold a seed", func(t *testing.T) {
+		_, err := service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Type: domain.ItemTypeSecureNote, Name: "note", Notes: "text", TOTP: rfcSecret})
+		if err != domain.ErrInvalidItem {
+			t.Errorf("expected ErrInvalidItem, got %v", err)
+		}
+	})
+
+	t.Run("can be set and cleared by an update", func(t *testing.T) {
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "gitlab", Username: "user", Password: "pass"})
+
+		if _, err := service.UpdatePasswordRecord(ctx, token, "test-vault", "gitlab", RecordUpdate{TOTP: stringPtr(rfcSecret)}); err != nil {
+			t.Fatalf("UpdatePasswordRecord() failed: %v", err)
+		}
+		if _, err := service.GetTOTPCode(ctx, token, "test-vault", "gitlab"); err != nil {
+			t.Errorf("GetTOTPCode() failed: %v", err)
+		}
+
+		service.UpdatePasswordRecord(ctx, token, "test-vault", "gitlab", RecordUpdate{TOTP: stringPtr("")})
+		if _, err := service.GetTOTPCode(ctx, token, "test-vault", "gitlab"); err != domain.ErrNoTOTP {
+			t.Errorf("expected ErrNoTOTP after clearing, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,286 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/orlan/go-password-manager/internal/domain"
+	"github.com/orlan/go-password-manager/internal/transfer"
+)
+
+// ExportOptions selects the file written by ExportVault
+type ExportOptions struct {
+	Format transfer.Format
+	// Password encrypts a transfer.FormatEncrypted export
+	Password string
+	// ConfirmPlaintext must be set to write passwords unencrypted
+	ConfirmPlaintext bool
+}
+
+// Export is a file written by ExportVault
+type Export struct {
+	Data []byte
+	// Skipped names the items the format cannot hold
+	Skipped []string
+}
+
+// ExportVault writes every item of the vault to a file. The encrypted
+// format keeps all item types and the password history; CSV only holds
+// logins and secure notes and has to be confirmed with ConfirmPlaintext.
+// Exporting a shared vault needs the owner role.
+func (s *VaultService) ExportVault(ctx context.Context, token, vaultName string, opts ExportOptions) (Export, error) {
+	if !opts.Format.CanExport() {
+		return Export{}, fmt.Errorf("%w: %q cannot be exported", transfer.ErrUnknownFormat, opts.Format)
+	}
+	if opts.Format != transfer.FormatEncrypted && !opts.ConfirmPlaintext {
+		return Export{}, domain.ErrPlaintextExportNotConfirmed
+	}
+
+	records, now, err := s.exportRecords(ctx, token, vaultName, opts.Format)
+	if err != nil {
+		return Export{}, err
+	}
+
+	// Derive the export key without holding s.mu
+	if opts.Format == transfer.FormatEncrypted {
+		data, err := transfer.ExportEncrypted(records, opts.Password, s.crypto, now)
+		if err != nil {
+			return Export{}, err
+		}
+		return Export{Data: data}, nil
+	}
+
+	data, skipped, err := transfer.ExportCSV(records)
+	if err != nil {
+		return Export{}, err
+	}
+	return Export{Data: data, Skipped: skipped}, nil
+}
+
+// exportRecords returns the records of the vault including their history,
+// logging an export in format
+func (s *VaultService) exportRecords(ctx context.Context, token, vaultName string, format transfer.Format) ([]domain.PasswordRecord, time.Time, error) {
+	s.mu.Lock()
+	defer s.unlock()
+
+	sess, err := s.getSession(token, vaultName)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	// An export copies the whole vault, so only owners may take one
+	if err := s.authorize(ctx, sess, domain.RoleOwner); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	if err := s.logEvent(ctx, sess, domain.AuditLogEntry{Event: domain.AuditLogExport, Detail: string(format)}); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to write audit log: %w", err)
+	}
+
+	// Saved records are never modified in place, so a shallow copy is safe
+	return slices.Clone(sess.vault.Records), s.now(), nil
+}
+
+// DuplicatePolicy decides what happens to an imported item whose name is
+// already taken
+type DuplicatePolicy string
+
+const (
+	// DuplicateSkip keeps the existing item and drops the imported one
+	DuplicateSkip DuplicatePolicy = "skip"
+	// DuplicateReplace overwrites the existing item. Its old password is
+	// kept in the history.
+	DuplicateReplace DuplicatePolicy = "replace"
+	// DuplicateRename imports the item as "name (2)", "name (3)" and so on
+	DuplicateRename DuplicatePolicy = "rename"
+)
+
+// ParseDuplicatePolicy converts a policy name to a DuplicatePolicy.
+// An empty name means DuplicateSkip.
+func ParseDuplicatePolicy(name string) (DuplicatePolicy, error) {
+	switch policy := DuplicatePolicy(strings.ToLower(strings.TrimSpace(name))); policy {
+	case "":
+		return DuplicateSkip, nil
+	case DuplicateSkip, DuplicateReplace, DuplicateRename:
+		return policy, nil
+	default:
+		return "", domain.ErrUnknownDuplicatePolicy
+	}
+}
+
+// ImportOptions describes the file read by ImportRecords
+type ImportOptions struct {
+	Format transfer.Format
+	// Password decrypts a transfer.FormatEncrypted export
+	Password   string
+	Duplicates DuplicatePolicy
+}
+
+// ImportResult describes the outcome of an import
+type ImportResult struct {
+	// Added counts new items, including renamed duplicates
+	Added int
+	// Replaced counts existing items overwritten by the import
+	Replaced int
+	// Renamed lists duplicates imported under a new name, as "old -> new"
+	Renamed []string
+	// Skipped lists duplicate names that were left unchanged
+	Skipped []string
+	// Problems describe entries that could not be imported
+	Problems []string
+}
+
+// ImportRecords adds the items of an exported file to the vault. Every item
+// is validated like a new record; entries that fail are reported in
+// Problems rather than failing the import. Items whose name is taken, by
+// the vault or by an earlier item of the same file, are handled according
+// to opts.Duplicates.
+func (s *VaultService) ImportRecords(ctx context.Context, token, vaultName string, data []byte, opts ImportOptions) (ImportResult, error) {
+	duplicates, err := ParseDuplicatePolicy(string(opts.Duplicates))
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	// Only editors get to make the service parse a file and derive its key
+	s.mu.Lock()
+	sess, err := s.getSession(token, vaultName)
+	if err == nil {
+		err = s.authorize(ctx, sess, domain.RoleEditor)
+	}
+	s.unlock()
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	// Parse and decrypt without holding s.mu
+	imp, err := transfer.Read(opts.Format, data, opts.Password, s.crypto)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	s.mu.RLock()
+	now := s.now()
+	s.mu.RUnlock()
+
+	problems := slices.Clone(imp.Skipped)
+	records := make([]domain.PasswordRecord, 0, len(imp.Records))
+	for _, record := range imp.Records {
+		imported, err := importRecord(record, now)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", imported.Name, err))
+			continue
+		}
+		records = append(records, imported)
+	}
+
+	// The session may have ended while the file was read
+	s.mu.Lock()
+	defer s.unlock()
+
+	sess, err = s.getSession(token, vaultName)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	if err := s.authorize(ctx, sess, domain.RoleEditor); err != nil {
+		return ImportResult{}, err
+	}
+
+	var result ImportResult
+	err = s.updateVault(ctx, sess, func(vault *domain.Vault) error {
+		// The change may be applied again on a newer vault
+		result = ImportResult{Problems: problems}
+
+		names := make(map[string]int, len(vault.Records))
+		for i, record := range vault.Records {
+			names[record.Name] = i
+		}
+
+		for _, record := range records {
+			i, taken := names[record.Name]
+			switch {
+			case !taken:
+			case duplicates == DuplicateSkip:
+				result.Skipped = append(result.Skipped, record.Name)
+				continue
+			case duplicates == DuplicateReplace:
+				existing := vault.Records[i]
+				record.ID = existing.ID
+				record.CreatedAt = existing.CreatedAt
+				record.History = existing.History
+				recordPasswordChange(&record, existing.Password, record.Password, now)
+				vault.Records[i] = record
+				result.Replaced++
+				continue
+			case duplicates == DuplicateRename:
+				name := uniqueName(record.Name, names)
+				result.Renamed = append(result.Renamed, record.Name+" -> "+name)
+				record.Name = name
+			}
+
+			names[record.Name] = len(vault.Records)
+			vault.Records = append(vault.Records, record)
+			result.Added++
+		}
+		return nil
+	})
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	s.logEvent(ctx, sess, domain.AuditLogEntry{
+		Event:  domain.AuditLogImport,
+		Detail: fmt.Sprintf("%s: %d added, %d replaced", opts.Format, result.Added, result.Replaced),
+	})
+	return result, nil
+}
+
+// importRecord validates an imported record like a new one and gives it an
+// ID. The creation date and password history of the source are kept.
+func importRecord(source domain.PasswordRecord, now time.Time) (domain.PasswordRecord, error) {
+	if source.Name = strings.TrimSpace(source.Name); source.Name == "" {
+		source.Name = "Untitled"
+	}
+
+	record, err := newRecord(RecordInput{
+		Type:          source.Type,
+		Name:          source.Name,
+		Username:      source.Username,
+		Password:      source.Password,
+		TOTP:          source.TOTP,
+		URLs:          source.URLs,
+		Notes:         source.Notes,
+		Folder:        source.Folder,
+		Tags:          source.Tags,
+		CustomFields:  source.CustomFields,
+		Card:          source.Card,
+		SSHKey:        source.SSHKey,
+		APICredential: source.APICredential,
+	})
+	if err != nil {
+		return source, err
+	}
+
+	record.ID = uuid.New().String()
+	record.History = source.History[:min(len(source.History), MaxPasswordHistory)]
+	record.CreatedAt = source.CreatedAt
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = now
+	}
+	record.UpdatedAt = now
+	return record, nil
+}
+
+// uniqueName returns the first of "name (2)", "name (3)", ... not in names
+func uniqueName(name string, names map[string]int) string {
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)", name, n)
+		if _, taken := names[candidate]; !taken {
+			return candidate
+		}
+	}
+}
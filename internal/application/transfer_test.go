@@ -0,0 +1,195 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+	"github.com/orlan/go-password-manager/internal/transfer"
+)
+
+func TestExportVault(t *testing.T) {
+	service, token := setupRecordTest(t)
+	ctx := context.Background()
+
+	service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "first"})
+	newPassword := "second"
+	service.UpdatePasswordRecord(ctx, token, "test-vault", "github", RecordUpdate{Password: &newPassword})
+	service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{
+		Type: domain.ItemTypeCard,
+		Name: "visa",
+		Card: &domain.CardData{Number: "4111111111111111"},
+	})
+
+	t.Run("plaintext needs confirmation", func(t *testing.T) {
+		_, err := service.ExportVault(ctx, token, "test-vault", ExportOptions{Format: transfer.FormatCSV})
+		if err != domain.ErrPlaintextExportNotConfirmed {
+			t.Errorf("expected ErrPlaintextExportNotConfirmed, got %v", err)
+		}
+
+		export, err := service.ExportVault(ctx, token, "test-vault", ExportOptions{Format: transfer.FormatCSV, ConfirmPlaintext: true})
+		if err != nil {
+			t.Fatalf("ExportVault() failed: %v", err)
+		}
+		if !strings.Contains(string(export.Data), "second") || !slices.Equal(export.Skipped, []string{"visa"}) {
+			t.Errorf("unexpected CSV export: %q, skipped %v", export.Data, export.Skipped)
+		}
+	})
+
+	t.Run("import formats cannot be exported", func(t *testing.T) {
+		_, err := service.ExportVault(ctx, token, "test-vault", ExportOptions{Format: transfer.FormatKeePass, ConfirmPlaintext: true})
+		if !errors.Is(err, transfer.ErrUnknownFormat) {
+			t.Errorf("expected ErrUnknownFormat, got %v", err)
+		}
+	})
+
+	t.Run("encrypted export moves to another vault", func(t *testing.T) {
+		export, err := service.ExportVault(ctx, token, "test-vault", ExportOptions{Format: transfer.FormatEncrypted, Password: "export-password"})
+		if err != nil {
+			t.Fatalf("ExportVault() failed: %v", err)
+		}
+
+		service.CreateVault(ctx, "other-vault", "other-password", "interactive")
+		otherToken, _ := service.UnlockVault(ctx, "other-vault", "other-password")
+
+		_, err = service.ImportRecords(ctx, otherToken, "other-vault", export.Data, ImportOptions{Format: transfer.FormatEncrypted, Password: "wrong-password"})
+		if err != transfer.ErrWrongPassword {
+			t.Errorf("expected ErrWrongPassword, got %v", err)
+		}
+
+		result, err := service.ImportRecords(ctx, otherToken, "other-vault", export.Data, ImportOptions{Format: transfer.FormatEncrypted, Password: "export-password"})
+		if err != nil {
+			t.Fatalf("ImportRecords() failed: %v", err)
+		}
+		if result.Added != 2 || len(result.Problems) != 0 {
+			t.Errorf("unexpected result: %+v", result)
+		}
+
+		history, err := service.GetRecordHistory(ctx, otherToken, "other-vault", "github")
+		if err != nil || len(history) != 1 || history[0].Password != "first" {
+			t.Errorf("expected the history to move along, got %+v, %v", history, err)
+		}
+		card, err := service.GetPasswordRecord(ctx, otherToken, "other-vault", "visa")
+		if err != nil || card.Card == nil {
+			t.Errorf("expected the card to move along, got %+v, %v", card, err)
+		}
+	})
+}
+
+func TestImportRecordsDuplicates(t *testing.T) {
+	file := []byte("name,username,password\ngithub,imported,new-password\ngithub,again,newer-password\n")
+
+	tests := []struct {
+		policy   DuplicatePolicy
+		want     ImportResult
+		password string
+		names    []string
+	}{
+		{
+			policy:   DuplicateSkip,
+			want:     ImportResult{Skipped: []string{"github", "github"}},
+			password: "old-password",
+			names:    []string{"github"},
+		},
+		{
+			policy:   DuplicateReplace,
+			want:     ImportResult{Replaced: 2},
+			password: "newer-password",
+			names:    []string{"github"},
+		},
+		{
+			policy:   DuplicateRename,
+			want:     ImportResult{Added: 2, Renamed: []string{"github -> github (2)", "github -> github (3)"}},
+			password: "old-password",
+			names:    []string{"github", "github (2)", "github (3)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			service, token := setupRecordTest(t)
+			ctx := context.Background()
+			service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "old-password"})
+
+			result, err := service.ImportRecords(ctx, token, "test-vault", file, ImportOptions{Format: transfer.FormatCSV, Duplicates: tt.policy})
+			if err != nil {
+				t.Fatalf("ImportRecords() failed: %v", err)
+			}
+			if result.Added != tt.want.Added || result.Replaced != tt.want.Replaced ||
+				!slices.Equal(result.Skipped, tt.want.Skipped) || !slices.Equal(result.Renamed, tt.want.Renamed) {
+				t.Errorf("got %+v, want %+v", result, tt.want)
+			}
+
+			record, _ := service.GetPasswordRecord(ctx, token, "test-vault", "github")
+			if record.Password != tt.password {
+				t.Errorf("expected password %q, got %q", tt.password, record.Password)
+			}
+
+			records, _ := service.ListPasswordRecords(ctx, token, "test-vault")
+			var names []string
+			for _, record := range records {
+				names = append(names, record.Name)
+			}
+			if !slices.Equal(names, tt.names) {
+				t.Errorf("expected records %v, got %v", tt.names, names)
+			}
+		})
+	}
+
+	t.Run("replace keeps the old passwords", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		ctx := context.Background()
+		service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Password: "old-password"})
+
+		service.ImportRecords(ctx, token, "test-vault", file, ImportOptions{Format: transfer.FormatCSV, Duplicates: DuplicateReplace})
+
+		history, _ := service.GetRecordHistory(ctx, token, "test-vault", "github")
+		if len(history) != 2 || history[0].Password != "new-password" || history[1].Password != "old-password" {
+			t.Errorf("unexpected history: %+v", history)
+		}
+	})
+
+	t.Run("unknown policy", func(t *testing.T) {
+		service, token := setupRecordTest(t)
+		_, err := service.ImportRecords(context.Background(), token, "test-vault", file, ImportOptions{Format: transfer.FormatCSV, Duplicates: "merge"})
+		if err != domain.ErrUnknownDuplicatePolicy {
+			t.Errorf("expected ErrUnknownDuplicatePolicy, got %v", err)
+		}
+	})
+}
+
+func TestImportRecordsProblems(t *testing.T) {
+	service, token := setupRecordTest(t)
+	ctx := context.Background()
+
+	file := []byte("type,name,username,password,totp,notes\n" +
+		"login,,user,password,,\n" +
+		"login,broken,user,password,otpauth://hotp/x,\n" +
+		"note,empty note,,,,\n" +
+		"identity,passport,,,,\n")
+
+	result, err := service.ImportRecords(ctx, token, "test-vault", file, ImportOptions{Format: transfer.FormatCSV})
+	if err != nil {
+		t.Fatalf("ImportRecords() failed: %v", err)
+	}
+	if result.Added != 1 || len(result.Problems) != 3 {
+		t.Errorf("expected one import and three problems, got %+v", result)
+	}
+	if _, err := service.GetPasswordRecord(ctx, token, "test-vault", "Untitled"); err != nil {
+		t.Errorf("expected a nameless entry to be imported as Untitled: %v", err)
+	}
+
+	if _, err := service.ImportRecords(ctx, "bad-token", "test-vault", file, ImportOptions{Format: transfer.FormatCSV}); err != domain.ErrInvalidSession {
+		t.Errorf("expected ErrInvalidSession, got %v", err)
+	}
+	// The session is checked before the file is read
+	if _, err := service.ImportRecords(ctx, "bad-token", "test-vault", []byte("{"), ImportOptions{Format: transfer.FormatBitwarden}); err != domain.ErrInvalidSession {
+		t.Errorf("expected ErrInvalidSession for a malformed file, got %v", err)
+	}
+	if _, err := service.ImportRecords(ctx, token, "test-vault", []byte("{"), ImportOptions{Format: transfer.FormatBitwarden}); !errors.Is(err, transfer.ErrMalformed) {
+		t.Errorf("expected ErrMalformed, got %v", err)
+	}
+}
@@ -0,0 +1,351 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+
+	"github.com/orlan/go-password-manager/internal/crypto"
+	"github.com/orlan/go-password-manager/internal/domain"
+	"github.com/orlan/go-password-manager/internal/otp"
+)
+
+// TwoFactorIssuer labels vault keys in authenticator apps
+const TwoFactorIssuer = "Go Password Manager"
+
+// twoFactorSkew is how many periods a code may lag or lead the server clock
+const twoFactorSkew = 1
+
+// TwoFactorSetup is a new TOTP key for unlocking a vault. URI can be shown
+// as a QR code, Secret is for entering the key by hand.
+type TwoFactorSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// NewTwoFactorSetup generates a TOTP key for vaultName. Nothing is stored;
+// pass the URI to EnableTwoFactor together with a code to turn it on.
+func NewTwoFactorSetup(vaultName string) (TwoFactorSetup, error) {
+	key, err := otp.GenerateSecret(TwoFactorIssuer, vaultName)
+	if err != nil {
+		return TwoFactorSetup{}, fmt.Errorf("failed to generate two-factor secret: %w", err)
+	}
+	return TwoFactorSetup{Secret: otp.EncodeSecret(key.Secret), URI: key.URI()}, nil
+}
+
+// CreateVaultWithTwoFactor creates a vault that needs a one-time code to
+// unlock. The returned key has to be added to an authenticator app before
+// the vault can be opened.
+func (s *VaultService) CreateVaultWithTwoFactor(ctx context.Context, name, masterPassword, kdfProfile string) (TwoFactorSetup, error) {
+	setup, err := NewTwoFactorSetup(name)
+	if err != nil {
+		return TwoFactorSetup{}, err
+	}
+	key, err := parseTOTP(setup.URI)
+	if err != nil {
+		return TwoFactorSetup{}, err
+	}
+
+	if err := s.createVault(ctx, name, masterPassword, kdfProfile, key); err != nil {
+		return TwoFactorSetup{}, err
+	}
+	return setup, nil
+}
+
+// TwoFactorEnabled reports whether unlocking the vault needs a one-time code
+func (s *VaultService) TwoFactorEnabled(ctx context.Context, vaultName string) (bool, error) {
+	if err := domain.ValidateVaultName(vaultName); err != nil {
+		return false, err
+	}
+
+	metadata, err := s.loadMetadata(ctx, vaultName)
+	if err != nil {
+		return false, err
+	}
+	return metadata.TwoFactor != nil, nil
+}
+
+// EnableTwoFactor turns on two-factor unlock with the TOTP key in secret,
+// an otpauth:// URI or base32 secret as returned by NewTwoFactorSetup. The
+// code proves the key was added to an authenticator app. In a shared vault
+// only owners can change the second factor.
+func (s *VaultService) EnableTwoFactor(ctx context.Context, token, vaultName, secret, code string) error {
+	key, err := parseTOTP(strings.TrimSpace(secret))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.unlock()
+
+	sess, err := s.getSession(token, vaultName)
+	if err != nil {
+		return err
+	}
+
+	// The second factor guards every member's unlock
+	if err := s.authorize(ctx, sess, domain.RoleOwner); err != nil {
+		return err
+	}
+
+	// Check first so the code is not used up for nothing
+	metadata, err := s.loadMetadata(ctx, vaultName)
+	if err != nil {
+		return err
+	}
+	if metadata.TwoFactor != nil {
+		return domain.ErrTwoFactorEnabled
+	}
+
+	if err := s.verifyTwoFactorCode(ctx, vaultName, key, code); err != nil {
+		return err
+	}
+	config, err := s.sealTwoFactor(key, sess.key.Bytes(), twoFactorAssociatedData(vaultName, metadata))
+	if err != nil {
+		return err
+	}
+
+	err = s.updateMetadata(ctx, sess, func(metadata *domain.VaultMetadata) error {
+		if metadata.TwoFactor != nil {
+			return domain.ErrTwoFactorEnabled
+		}
+		metadata.TwoFactor = config
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The backup still opens without a code
+	return s.repo.RemoveBackup(ctx, vaultName)
+}
+
+// DisableTwoFactor turns off two-factor unlock. A current code is required
+// so that an unattended session cannot remove the second factor.
+func (s *VaultService) DisableTwoFactor(ctx context.Context, token, vaultName, code string) error {
+	s.mu.Lock()
+	defer s.unlock()
+
+	sess, err := s.getSession(token, vaultName)
+	if err != nil {
+		return err
+	}
+
+	if err := s.authorize(ctx, sess, domain.RoleOwner); err != nil {
+		return err
+	}
+	metadata, err := s.loadMetadata(ctx, vaultName)
+	if err != nil {
+		return err
+	}
+	if metadata.TwoFactor == nil {
+		return domain.ErrTwoFactorNotEnabled
+	}
+	key, err := s.openTwoFactor(metadata.TwoFactor, sess.key.Bytes(), twoFactorAssociatedData(vaultName, metadata))
+	if err != nil {
+		return err
+	}
+	if err := s.verifyTwoFactorCode(ctx, vaultName, key, code); err != nil {
+		return err
+	}
+
+	err = s.updateMetadata(ctx, sess, func(metadata *domain.VaultMetadata) error {
+		if metadata.TwoFactor == nil {
+			return domain.ErrTwoFactorNotEnabled
+		}
+		metadata.TwoFactor = nil
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// A step left behind only refuses the codes of a new key for a while
+	s.twoFactorSteps.UpdateTwoFactorSteps(ctx, func(steps map[string]uint64) error {
+		delete(steps, vaultName)
+		return nil
+	})
+	return nil
+}
+
+// checkTwoFactor verifies code against the vault's two-factor key, which
+// is decrypted with the key derived from the master password
+func (s *VaultService) checkTwoFactor(ctx context.Context, vaultName string, metadata *domain.VaultMetadata, vaultKey []byte, code string) error {
+	key, err := s.openTwoFactor(metadata.TwoFactor, vaultKey, twoFactorAssociatedData(vaultName, metadata))
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.verifyTwoFactorCode(ctx, vaultName, key, code)
+}
+
+// verifyPasswordChangeCode checks the one-time code of a password change
+// with the two-factor key opened by the old password. Like an unlock, a
+// wrong password and a wrong code both give domain.ErrInvalidCredentials.
+// Callers must not hold s.mu.
+func (s *VaultService) verifyPasswordChangeCode(ctx context.Context, vaultName string, metadata *domain.VaultMetadata, member, password, code string) error {
+	vaultKey, _, privateKey, err := s.vaultKey(metadata, member, password)
+	if err != nil {
+		if err == domain.ErrInvalidMasterPassword {
+			return domain.ErrInvalidCredentials
+		}
+		return err
+	}
+	defer crypto.Wipe(vaultKey)
+	crypto.Wipe(privateKey)
+
+	err = s.checkTwoFactor(ctx, vaultName, metadata, vaultKey, code)
+	if err == domain.ErrInvalidMasterPassword || err == domain.ErrInvalidTwoFactorCode {
+		return domain.ErrInvalidCredentials
+	}
+	return err
+}
+
+// verifyTwoFactorCode checks code against key and remembers its time step
+// in the step store, so every code is accepted once. Callers must hold s.mu.
+func (s *VaultService) verifyTwoFactorCode(ctx context.Context, vaultName string, key *otp.Key, code string) error {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+
+	step, ok := key.Verify(code, s.now(), twoFactorSkew)
+	if !ok {
+		return domain.ErrInvalidTwoFactorCode
+	}
+
+	return s.twoFactorSteps.UpdateTwoFactorSteps(ctx, func(steps map[string]uint64) error {
+		if last, used := steps[vaultName]; used && step <= last {
+			return domain.ErrInvalidTwoFactorCode
+		}
+		steps[vaultName] = step
+		return nil
+	})
+}
+
+// SetTwoFactorStepStore makes the record of used one-time codes persistent.
+// By default it is kept in memory, so a code could be used again after a
+// restart or with another process.
+func (s *VaultService) SetTwoFactorStepStore(store domain.TwoFactorStepStore) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.twoFactorSteps = store
+}
+
+// memoryTwoFactorStepStore keeps used two-factor steps for the lifetime of
+// the process
+type memoryTwoFactorStepStore struct {
+	mu    sync.Mutex
+	steps map[string]uint64
+}
+
+func newMemoryTwoFactorStepStore() *memoryTwoFactorStepStore {
+	return &memoryTwoFactorStepStore{steps: make(map[string]uint64)}
+}
+
+// UpdateTwoFactorSteps implements domain.TwoFactorStepStore. fn works on a
+// copy, so a failed update leaves the steps unchanged.
+func (m *memoryTwoFactorStepStore) UpdateTwoFactorSteps(ctx context.Context, fn func(steps map[string]uint64) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	steps := maps.Clone(m.steps)
+	if err := fn(steps); err != nil {
+		return err
+	}
+
+	m.steps = steps
+	return nil
+}
+
+// twoFactorAssociatedData binds the two-factor key of vault name to that
+// vault, so that it cannot be copied into another vault encrypted with the
+// same key
+func twoFactorAssociatedData(name string, metadata *domain.VaultMetadata) []byte {
+	if !hasAssociatedData(metadata) {
+		return nil
+	}
+	return []byte("two-factor:" + name)
+}
+
+// sealTwoFactor encrypts key with the vault key for storage in the metadata,
+// authenticating additionalData from twoFactorAssociatedData
+func (s *VaultService) sealTwoFactor(key *otp.Key, vaultKey, additionalData []byte) (*domain.TwoFactorConfig, error) {
+	uri := []byte(key.URI())
+	defer crypto.Wipe(uri)
+
+	nonce, ciphertext, err := s.crypto.Encrypt(uri, vaultKey, additionalData)
+	if err != nil {
+		return nil, domain.ErrEncryptionFailed
+	}
+	return &domain.TwoFactorConfig{Nonce: nonce, Secret: ciphertext}, nil
+}
+
+// openTwoFactor decrypts the two-factor key. It fails with
+// domain.ErrInvalidMasterPassword when vaultKey is wrong or the key was
+// sealed for another vault.
+func (s *VaultService) openTwoFactor(config *domain.TwoFactorConfig, vaultKey, additionalData []byte) (*otp.Key, error) {
+	uri, err := s.crypto.Decrypt(config.Nonce, config.Secret, vaultKey, additionalData)
+	if err != nil {
+		return nil, domain.ErrInvalidMasterPassword
+	}
+	defer crypto.Wipe(uri)
+
+	key, err := parseTOTP(string(uri))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read two-factor key: %w", err)
+	}
+	return key, nil
+}
+
+// updateMetadata applies change to the vault metadata and saves it. The
+// records are encrypted again for the changed header but keep their
+// contents, so sessions that were up to date stay up to date.
+// Callers must hold s.mu.
+func (s *VaultService) updateMetadata(ctx context.Context, sess *session, change func(metadata *domain.VaultMetadata) error) error {
+	for range maxSaveAttempts {
+		// Also fails if the vault was re-encrypted under another key
+		if err := s.refreshVault(ctx, sess); err != nil {
+			return err
+		}
+
+		metadata, err := s.loadMetadata(ctx, sess.vaultName)
+		if err != nil {
+			return err
+		}
+		if metadata.Revision != sess.vault.revision {
+			continue
+		}
+
+		// The records are authenticated with the header and have to be
+		// encrypted again for the changed one
+		vaultData, err := s.crypto.Decrypt(metadata.Nonce, metadata.Encrypted, sess.key.Bytes(), vaultAssociatedData(sess.vaultName, metadata))
+		if err != nil {
+			return domain.ErrVaultModified
+		}
+		if err := change(metadata); err != nil {
+			crypto.Wipe(vaultData)
+			return err
+		}
+		metadata.Nonce, metadata.Encrypted, err = s.crypto.Encrypt(vaultData, sess.key.Bytes(), vaultAssociatedData(sess.vaultName, metadata))
+		crypto.Wipe(vaultData)
+		if err != nil {
+			return domain.ErrEncryptionFailed
+		}
+
+		err = s.repo.SaveIfUnchanged(ctx, sess.vaultName, metadata, sess.vault.revision)
+		if err == domain.ErrVaultModified {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save vault: %w", err)
+		}
+
+		sess.vault.revision = metadata.Revision
+		return nil
+	}
+
+	return domain.ErrVaultModified
+}
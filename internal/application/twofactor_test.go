@@ -0,0 +1,228 @@
+package application
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/crypto"
+	"github.com/orlan/go-password-manager/internal/domain"
+	"github.com/orlan/go-password-manager/internal/otp"
+	"github.com/orlan/go-password-manager/internal/vault"
+)
+
+// twoFactorCode returns the code of the setup key at t
+func twoFactorCode(t *testing.T, setup TwoFactorSetup, at time.Time) string {
+	t.Helper()
+
+	key, err := otp.Parse(setup.URI)
+	if err != nil {
+		t.Fatalf("otp.Parse(%q) failed: %v", setup.URI, err)
+	}
+	code, err := key.Code(at)
+	if err != nil {
+		t.Fatalf("Code() failed: %v", err)
+	}
+	return code
+}
+
+func setClock(service *VaultService, at time.Time) {
+	service.mu.Lock()
+	service.now = func() time.Time { return at }
+	service.mu.Unlock()
+}
+
+func TestCreateVaultWithTwoFactor(t *testing.T) {
+	service, _ := setupTestService(t)
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	setClock(service, now)
+
+	setup, err := service.CreateVaultWithTwoFactor(ctx, "guarded", "my-password", "interactive")
+	if err != nil {
+		t.Fatalf("CreateVaultWithTwoFactor() failed: %v", err)
+	}
+	if setup.Secret == "" || setup.URI == "" {
+		t.Fatalf("expected a secret and URI, got %+v", setup)
+	}
+
+	enabled, err := service.TwoFactorEnabled(ctx, "guarded")
+	if err != nil || !enabled {
+		t.Fatalf("TwoFactorEnabled() = %v, %v; want true", enabled, err)
+	}
+
+	t.Run("requires a code", func(t *testing.T) {
+		if _, err := service.UnlockVault(ctx, "guarded", "my-password"); err != domain.ErrTwoFactorRequired {
+			t.Errorf("expected ErrTwoFactorRequired, got %v", err)
+		}
+	})
+
+	// Both fail alike, so the password cannot be guessed without the code
+	t.Run("rejects a wrong code", func(t *testing.T) {
+		wrong := twoFactorCode(t, setup, now.Add(-10*otp.DefaultPeriod))
+		if _, err := service.UnlockVaultWithCode(ctx, "guarded", "my-password", wrong); err != domain.ErrInvalidCredentials {
+			t.Errorf("expected ErrInvalidCredentials, got %v", err)
+		}
+	})
+
+	t.Run("rejects a wrong password", func(t *testing.T) {
+		code := twoFactorCode(t, setup, now)
+		if _, err := service.UnlockVaultWithCode(ctx, "guarded", "wrong-password", code); err != domain.ErrInvalidCredentials {
+			t.Errorf("expected ErrInvalidCredentials, got %v", err)
+		}
+	})
+
+	t.Run("unlocks with password and code", func(t *testing.T) {
+		code := twoFactorCode(t, setup, now)
+		token, err := service.UnlockVaultWithCode(ctx, "guarded", "my-password", code)
+		if err != nil {
+			t.Fatalf("UnlockVaultWithCode() failed: %v", err)
+		}
+		if err := service.ValidateSession(ctx, token, "guarded"); err != nil {
+			t.Errorf("expected a valid session, got %v", err)
+		}
+
+		// Only the audit log tells the failures apart
+		entries, err := service.AuditLog(ctx, token, "guarded")
+		if err != nil {
+			t.Fatalf("AuditLog() failed: %v", err)
+		}
+		if len(entries) != 3 || entries[0].Detail != "wrong two-factor code" || entries[1].Detail != "wrong password" {
+			t.Errorf("unexpected audit log %+v", entries)
+		}
+
+		// The same code cannot be used again
+		if _, err := service.UnlockVaultWithCode(ctx, "guarded", "my-password", code); err != domain.ErrInvalidCredentials {
+			t.Errorf("expected replayed code to be rejected, got %v", err)
+		}
+	})
+
+	t.Run("password change requires a code", func(t *testing.T) {
+		if err := service.ChangeMasterPassword(ctx, "guarded", "my-password", "stolen-password", ""); err != domain.ErrTwoFactorRequired {
+			t.Errorf("expected ErrTwoFactorRequired, got %v", err)
+		}
+		wrong := twoFactorCode(t, setup, now.Add(-10*otp.DefaultPeriod))
+		if err := service.ChangeMemberPassword(ctx, "guarded", "", "my-password", "stolen-password", "", wrong); err != domain.ErrInvalidCredentials {
+			t.Errorf("wrong code: expected ErrInvalidCredentials, got %v", err)
+		}
+		if err := service.ChangeMemberPassword(ctx, "guarded", "", "wrong-password", "stolen-password", "", twoFactorCode(t, setup, now)); err != domain.ErrInvalidCredentials {
+			t.Errorf("wrong password: expected ErrInvalidCredentials, got %v", err)
+		}
+	})
+
+	t.Run("survives a master password change", func(t *testing.T) {
+		now := now.Add(otp.DefaultPeriod)
+		setClock(service, now)
+		if err := service.ChangeMemberPassword(ctx, "guarded", "", "my-password", "new-password", "", twoFactorCode(t, setup, now)); err != nil {
+			t.Fatalf("ChangeMemberPassword() failed: %v", err)
+		}
+
+		later := now.Add(otp.DefaultPeriod * 2)
+		setClock(service, later)
+		if _, err := service.UnlockVaultWithCode(ctx, "guarded", "new-password", twoFactorCode(t, setup, later)); err != nil {
+			t.Errorf("UnlockVaultWithCode() after password change failed: %v", err)
+		}
+	})
+}
+
+func TestTwoFactorKeyBoundToVault(t *testing.T) {
+	service, _ := setupTestService(t)
+	ctx := context.Background()
+	service.CreateVaultWithTwoFactor(ctx, "guarded", "my-password", "interactive")
+
+	// Another vault encrypted with the same key cannot reuse the sealed key
+	metadata, _ := service.repo.Load(ctx, "guarded")
+	key, _ := crypto.NewService().DeriveKey("my-password", metadata.Salt, *metadata.KDF)
+	if _, err := service.openTwoFactor(metadata.TwoFactor, key, twoFactorAssociatedData("guarded", metadata)); err != nil {
+		t.Fatalf("openTwoFactor() failed: %v", err)
+	}
+	if _, err := service.openTwoFactor(metadata.TwoFactor, key, twoFactorAssociatedData("other", metadata)); err != domain.ErrInvalidMasterPassword {
+		t.Errorf("expected ErrInvalidMasterPassword for another vault, got %v", err)
+	}
+}
+
+func TestEnableTwoFactor(t *testing.T) {
+	service, token := setupRecordTest(t)
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	setClock(service, now)
+
+	setup, err := NewTwoFactorSetup("test-vault")
+	if err != nil {
+		t.Fatalf("NewTwoFactorSetup() failed: %v", err)
+	}
+
+	if err := service.EnableTwoFactor(ctx, token, "test-vault", setup.URI, "000000x"); err != domain.ErrInvalidTwoFactorCode {
+		t.Errorf("expected ErrInvalidTwoFactorCode, got %v", err)
+	}
+	if err := service.EnableTwoFactor(ctx, token, "test-vault", "not base32!", "123456"); err != domain.ErrInvalidTOTP {
+		t.Errorf("expected ErrInvalidTOTP, got %v", err)
+	}
+
+	if err := service.EnableTwoFactor(ctx, token, "test-vault", setup.Secret, twoFactorCode(t, setup, now)); err != nil {
+		t.Fatalf("EnableTwoFactor() failed: %v", err)
+	}
+	if err := service.EnableTwoFactor(ctx, token, "test-vault", setup.URI, twoFactorCode(t, setup, now.Add(otp.DefaultPeriod))); err != domain.ErrTwoFactorEnabled {
+		t.Errorf("expected ErrTwoFactorEnabled, got %v", err)
+	}
+
+	// The session stays usable after the metadata change
+	if _, err := service.AddPasswordRecord(ctx, token, "test-vault", RecordInput{Name: "github", Username: "user", Password: "pass"}); err != nil {
+		t.Fatalf("AddPasswordRecord() failed: %v", err)
+	}
+	if _, err := service.UnlockVault(ctx, "test-vault", "my-password"); err != domain.ErrTwoFactorRequired {
+		t.Errorf("expected ErrTwoFactorRequired, got %v", err)
+	}
+
+	later := now.Add(5 * otp.DefaultPeriod)
+	setClock(service, later)
+	other, err := service.UnlockVaultWithCode(ctx, "test-vault", "my-password", twoFactorCode(t, setup, later))
+	if err != nil {
+		t.Fatalf("UnlockVaultWithCode() failed: %v", err)
+	}
+	if _, err := service.GetPasswordRecord(ctx, other, "test-vault", "github"); err != nil {
+		t.Errorf("GetPasswordRecord() failed: %v", err)
+	}
+
+	t.Run("disable needs a valid code", func(t *testing.T) {
+		if err := service.DisableTwoFactor(ctx, token, "test-vault", twoFactorCode(t, setup, later)); err != domain.ErrInvalidTwoFactorCode {
+			t.Errorf("expected replayed code to be rejected, got %v", err)
+		}
+
+		next := later.Add(otp.DefaultPeriod)
+		setClock(service, next)
+		if err := service.DisableTwoFactor(ctx, token, "test-vault", twoFactorCode(t, setup, next)); err != nil {
+			t.Fatalf("DisableTwoFactor() failed: %v", err)
+		}
+		if err := service.DisableTwoFactor(ctx, token, "test-vault", "123456"); err != domain.ErrTwoFactorNotEnabled {
+			t.Errorf("expected ErrTwoFactorNotEnabled, got %v", err)
+		}
+		if _, err := service.UnlockVault(ctx, "test-vault", "my-password"); err != nil {
+			t.Errorf("UnlockVault() without code failed: %v", err)
+		}
+	})
+}
+
+func TestTwoFactorCodeUsedByOtherProcess(t *testing.T) {
+	service1, vaultDir := setupTestService(t)
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0)
+	setClock(service1, now)
+	repo1, _ := vault.NewFileRepository(vaultDir)
+	service1.SetTwoFactorStepStore(repo1)
+
+	repo2, _ := vault.NewFileRepository(vaultDir)
+	service2 := NewVaultService(repo2, crypto.NewService())
+	t.Cleanup(service2.Stop)
+	setClock(service2, now)
+	service2.SetTwoFactorStepStore(repo2)
+
+	setup, _ := service1.CreateVaultWithTwoFactor(ctx, "guarded", "my-password", "interactive")
+	code := twoFactorCode(t, setup, now)
+	if _, err := service1.UnlockVaultWithCode(ctx, "guarded", "my-password", code); err != nil {
+		t.Fatalf("UnlockVaultWithCode() failed: %v", err)
+	}
+	if _, err := service2.UnlockVaultWithCode(ctx, "guarded", "my-password", code); err != domain.ErrInvalidCredentials {
+		t.Errorf("expected the code used by the other service to be rejected, got %v", err)
+	}
+}
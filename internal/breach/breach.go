@@ -0,0 +1,224 @@
+// Package breach checks passwords against a local copy of the Have I Been
+// Pwned "Pwned Passwords" list, without any network access at check time.
+//
+// Two on-disk layouts are supported:
+//
+//   - A single file of full SHA-1 hashes sorted by hash, one "HASH:COUNT"
+//     line each, as produced by the official downloader with the
+//     single-file option. Lookups binary-search the file in place.
+//   - A directory of range files named after the first five hex digits of
+//     the hash (for example "5BAA6" or "5BAA6.txt"), each holding the
+//     sorted "SUFFIX:COUNT" lines the range API returns for that prefix.
+//     This is a compact prefix index: a lookup reads a single small file.
+//
+// Hashes are compared case-insensitively and CRLF line endings are accepted.
+package breach
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// PrefixLength is the number of hex digits in a range file name
+const PrefixLength = 5
+
+// hashLength is the number of hex digits in a SHA-1 hash
+const hashLength = 40
+
+// maxLineLength bounds a line in the hash list, which is a 40 digit hash,
+// a colon, a count and a line ending
+const maxLineLength = 128
+
+var (
+	// ErrInvalidHash is returned for a hash that is not 40 hex digits
+	ErrInvalidHash = errors.New("breach: hash must be 40 hex digits")
+
+	// ErrMalformedList is returned when the hash list cannot be parsed
+	ErrMalformedList = errors.New("breach: malformed hash list")
+)
+
+// Store looks up password hashes in a local Pwned Passwords list. It is
+// safe for concurrent use.
+type Store struct {
+	path string
+
+	// file is the sorted hash file, nil when path is a range directory
+	file *os.File
+	size int64
+}
+
+// Open opens a sorted hash file or a directory of range files
+func Open(path string) (*Store, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("breach: %w", err)
+	}
+
+	if info.IsDir() {
+		return &Store{path: path}, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("breach: %w", err)
+	}
+	return &Store{path: path, file: file, size: info.Size()}, nil
+}
+
+// Close releases the hash file
+func (s *Store) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	return s.file.Close()
+}
+
+// Count returns how many times password appears in the list, or 0 if it
+// does not appear
+func (s *Store) Count(password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	return s.CountHash(hex.EncodeToString(sum[:]))
+}
+
+// CountHash returns how many times the password with the given SHA-1 hash
+// appears in the list
+func (s *Store) CountHash(hash string) (int, error) {
+	hash = strings.ToUpper(hash)
+	if len(hash) != hashLength {
+		return 0, ErrInvalidHash
+	}
+	if _, err := hex.DecodeString(hash); err != nil {
+		return 0, ErrInvalidHash
+	}
+
+	if s.file != nil {
+		return search(s.file, s.size, hash)
+	}
+	return s.countInRange(hash)
+}
+
+// countInRange searches the range file for the first five digits of hash
+func (s *Store) countInRange(hash string) (int, error) {
+	prefix, suffix := hash[:PrefixLength], hash[PrefixLength:]
+
+	for _, name := range []string{prefix, prefix + ".txt", strings.ToLower(prefix), strings.ToLower(prefix) + ".txt"} {
+		file, err := os.Open(filepath.Join(s.path, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("breach: %w", err)
+		}
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil {
+			return 0, fmt.Errorf("breach: %w", err)
+		}
+		return search(file, info.Size(), suffix)
+	}
+
+	// No range file means no hash with this prefix was downloaded
+	return 0, nil
+}
+
+// search binary-searches a sorted list of "KEY:COUNT" lines for key. Only
+// lines that start within [lo, hi) remain candidates, so each step either
+// moves lo past a line or moves hi down.
+func search(r io.ReaderAt, size int64, key string) (int, error) {
+	lo, hi := int64(0), size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+
+		start, err := lineStart(r, size, mid)
+		if err != nil {
+			return 0, err
+		}
+		if start >= hi {
+			hi = mid
+			continue
+		}
+
+		line, next, err := readLine(r, size, start)
+		if err != nil {
+			return 0, err
+		}
+
+		lineKey, count, err := parseLine(line)
+		if err != nil {
+			return 0, err
+		}
+
+		switch cmp := strings.Compare(lineKey, key); {
+		case cmp < 0:
+			lo = next
+		case cmp > 0:
+			hi = start
+		default:
+			return count, nil
+		}
+	}
+	return 0, nil
+}
+
+// lineStart returns the offset of the first line starting at or after off
+func lineStart(r io.ReaderAt, size, off int64) (int64, error) {
+	if off == 0 {
+		return 0, nil
+	}
+
+	buf := make([]byte, maxLineLength)
+	n, err := r.ReadAt(buf, off-1)
+	if err != nil && err != io.EOF {
+		return 0, fmt.Errorf("breach: %w", err)
+	}
+
+	i := bytes.IndexByte(buf[:n], '\n')
+	if i < 0 {
+		if off-1+int64(n) >= size {
+			return size, nil
+		}
+		return 0, ErrMalformedList
+	}
+	return off + int64(i), nil
+}
+
+// readLine returns the line starting at off and the offset of the next line
+func readLine(r io.ReaderAt, size, off int64) (string, int64, error) {
+	buf := make([]byte, maxLineLength)
+	n, err := r.ReadAt(buf, off)
+	if err != nil && err != io.EOF {
+		return "", 0, fmt.Errorf("breach: %w", err)
+	}
+
+	i := bytes.IndexByte(buf[:n], '\n')
+	if i < 0 {
+		if off+int64(n) < size {
+			return "", 0, ErrMalformedList
+		}
+		return string(buf[:n]), size, nil
+	}
+	return string(buf[:i]), off + int64(i) + 1, nil
+}
+
+// parseLine splits a "KEY:COUNT" line
+func parseLine(line string) (string, int, error) {
+	key, count, ok := strings.Cut(strings.TrimRight(line, "\r"), ":")
+	if !ok {
+		return "", 0, fmt.Errorf("%w: line %q", ErrMalformedList, line)
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil || n < 0 {
+		return "", 0, fmt.Errorf("%w: line %q", ErrMalformedList, line)
+	}
+	return strings.ToUpper(key), n, nil
+}
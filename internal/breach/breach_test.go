@@ -0,0 +1,143 @@
+package breach
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The fixtures are synthetic: a few common passwords with made-up counts
+// among random hashes, in the same format as the real downloads.
+const (
+	sampleFile = "testdata/pwned-passwords-sample.txt"
+	rangeDir   = "testdata/ranges"
+)
+
+var knownPasswords = map[string]int{
+	"password": 9545824,
+	"123456":   37359195,
+	"qwerty":   10556095,
+	"P@ssw0rd": 120341,
+	"abc123":   4772282,
+}
+
+func openStore(t *testing.T, path string) *Store {
+	t.Helper()
+	store, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open(%q) failed: %v", path, err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestStoreCount(t *testing.T) {
+	for _, path := range []string{sampleFile, rangeDir} {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			store := openStore(t, path)
+
+			for password, want := range knownPasswords {
+				count, err := store.Count(password)
+				if err != nil {
+					t.Fatalf("Count(%q) failed: %v", password, err)
+				}
+				if count != want {
+					t.Errorf("Count(%q) = %d, want %d", password, count, want)
+				}
+			}
+
+			for _, password := range []string{"hJ4&kq9!Xz2@Lw", "Password", ""} {
+				count, err := store.Count(password)
+				if err != nil {
+					t.Fatalf("Count(%q) failed: %v", password, err)
+				}
+				if count != 0 {
+					t.Errorf("Count(%q) = %d, want 0", password, count)
+				}
+			}
+		})
+	}
+}
+
+func TestStoreFindsEveryEntry(t *testing.T) {
+	store := openStore(t, sampleFile)
+
+	file, err := os.Open(sampleFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		hash, want, err := parseLine(scanner.Text())
+		if err != nil {
+			t.Fatalf("parseLine() failed: %v", err)
+		}
+
+		// Lowercase hashes are accepted too
+		count, err := store.CountHash(strings.ToLower(hash))
+		if err != nil {
+			t.Fatalf("CountHash(%s) failed: %v", hash, err)
+		}
+		if count != want {
+			t.Errorf("CountHash(%s) = %d, want %d", hash, count, want)
+		}
+	}
+}
+
+func TestStoreEdgeCases(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	hashOf := func(password string) string {
+		sum := sha1.Sum([]byte(password))
+		return strings.ToUpper(hex.EncodeToString(sum[:]))
+	}
+
+	t.Run("empty file", func(t *testing.T) {
+		store := openStore(t, write("empty.txt", ""))
+		if count, err := store.Count("password"); err != nil || count != 0 {
+			t.Errorf("Count() = %d, %v; want 0, nil", count, err)
+		}
+	})
+
+	t.Run("single line without newline", func(t *testing.T) {
+		store := openStore(t, write("single.txt", hashOf("password")+":3"))
+		if count, err := store.Count("password"); err != nil || count != 3 {
+			t.Errorf("Count() = %d, %v; want 3, nil", count, err)
+		}
+	})
+
+	t.Run("malformed line", func(t *testing.T) {
+		store := openStore(t, write("bad.txt", hashOf("password")+"\n"))
+		if _, err := store.Count("password"); !errors.Is(err, ErrMalformedList) {
+			t.Errorf("expected ErrMalformedList, got %v", err)
+		}
+	})
+
+	t.Run("invalid hash", func(t *testing.T) {
+		store := openStore(t, sampleFile)
+		for _, hash := range []string{"", "5BAA6", strings.Repeat("Z", 40)} {
+			if _, err := store.CountHash(hash); err != ErrInvalidHash {
+				t.Errorf("CountHash(%q): expected ErrInvalidHash, got %v", hash, err)
+			}
+		}
+	})
+
+	t.Run("missing path", func(t *testing.T) {
+		if _, err := Open(filepath.Join(dir, "missing")); err == nil {
+			t.Error("expected an error for a missing path")
+		}
+	})
+}
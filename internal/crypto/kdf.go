@@ -0,0 +1,117 @@
+package crypto
+
+import (
+	"fmt"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// Named key derivation profiles that can be chosen when creating a vault
+const (
+	// KDFProfileInteractive trades some brute-force resistance for faster
+	// unlocks on constrained hardware
+	KDFProfileInteractive = "interactive"
+
+	// KDFProfileModerate is the default profile (OWASP high-security settings)
+	KDFProfileModerate = "moderate"
+
+	// KDFProfileSensitive doubles memory and adds an iteration for vaults
+	// that are rarely unlocked
+	KDFProfileSensitive = "sensitive"
+
+	// DefaultKDFProfile is used when no profile is requested
+	DefaultKDFProfile = KDFProfileModerate
+)
+
+// Bounds accepted for derivation parameters. Parameters are read from vault
+// files, so they are capped to keep a crafted file from exhausting memory or
+// CPU, and floored to keep new vaults from being created with weak settings.
+const (
+	MinArgon2Time    = 1
+	MaxArgon2Time    = 16
+	MinArgon2Memory  = 19 * 1024   // 19 MB - OWASP minimum for Argon2id
+	MaxArgon2Memory  = 1024 * 1024 // 1 GB
+	MinArgon2Threads = 1
+	MaxArgon2Threads = 16
+)
+
+var kdfProfiles = map[string]domain.KDFParams{
+	KDFProfileInteractive: {
+		Algorithm: domain.KDFAlgorithmArgon2id,
+		Time:      2,
+		Memory:    64 * 1024,
+		Threads:   Argon2Threads,
+		KeyLength: Argon2KeyLen,
+	},
+	KDFProfileModerate: DefaultKDFParams(),
+	KDFProfileSensitive: {
+		Algorithm: domain.KDFAlgorithmArgon2id,
+		Time:      4,
+		Memory:    256 * 1024,
+		Threads:   Argon2Threads,
+		KeyLength: Argon2KeyLen,
+	},
+}
+
+// DefaultKDFParams returns the Argon2id parameters used for new vaults.
+// Vaults created before parameters were stored in the vault file were
+// derived with these same values.
+func DefaultKDFParams() domain.KDFParams {
+	return domain.KDFParams{
+		Algorithm: domain.KDFAlgorithmArgon2id,
+		Time:      Argon2Time,
+		Memory:    Argon2Memory,
+		Threads:   Argon2Threads,
+		KeyLength: Argon2KeyLen,
+	}
+}
+
+// KDFProfile returns the derivation parameters for a named profile
+func (s *Service) KDFProfile(name string) (domain.KDFParams, error) {
+	if name == "" {
+		name = DefaultKDFProfile
+	}
+
+	params, exists := kdfProfiles[name]
+	if !exists {
+		return domain.KDFParams{}, domain.ErrUnknownKDFProfile
+	}
+
+	return params, nil
+}
+
+// ValidateKDFParams checks that derivation parameters are supported and
+// within safe bounds
+func ValidateKDFParams(params domain.KDFParams) error {
+	if params.Algorithm != domain.KDFAlgorithmArgon2id {
+		return fmt.Errorf("unsupported key derivation algorithm %q", params.Algorithm)
+	}
+	if params.Time < MinArgon2Time || params.Time > MaxArgon2Time {
+		return fmt.Errorf("invalid argon2 time: must be between %d and %d, got %d", MinArgon2Time, MaxArgon2Time, params.Time)
+	}
+	if params.Memory < MinArgon2Memory || params.Memory > MaxArgon2Memory {
+		return fmt.Errorf("invalid argon2 memory: must be between %d and %d KiB, got %d", MinArgon2Memory, MaxArgon2Memory, params.Memory)
+	}
+	if params.Threads < MinArgon2Threads || params.Threads > MaxArgon2Threads {
+		return fmt.Errorf("invalid argon2 threads: must be between %d and %d, got %d", MinArgon2Threads, MaxArgon2Threads, params.Threads)
+	}
+	if params.KeyLength != Argon2KeyLen {
+		return fmt.Errorf("invalid key length: expected %d, got %d", Argon2KeyLen, params.KeyLength)
+	}
+	return nil
+}
+
+// ValidateKDFCost checks that derivation parameters are valid and cost no
+// more than the strongest profile. Parameters from files that anyone can
+// upload, unlike vault files, are held to what this service writes itself.
+func ValidateKDFCost(params domain.KDFParams) error {
+	if err := ValidateKDFParams(params); err != nil {
+		return err
+	}
+
+	strongest := kdfProfiles[KDFProfileSensitive]
+	if params.Time > strongest.Time || params.Memory > strongest.Memory || params.Threads > strongest.Threads {
+		return fmt.Errorf("argon2 parameters exceed the %s profile", KDFProfileSensitive)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+func TestKDFProfile(t *testing.T) {
+	service := NewService()
+
+	t.Run("empty name returns default profile", func(t *testing.T) {
+		params, err := service.KDFProfile("")
+		if err != nil {
+			t.Fatalf("KDFProfile() failed: %v", err)
+		}
+		if params != DefaultKDFParams() {
+			t.Errorf("expected default params %+v, got %+v", DefaultKDFParams(), params)
+		}
+	})
+
+	t.Run("default profile matches legacy vault parameters", func(t *testing.T) {
+		if DefaultKDFParams() != domain.LegacyKDFParams {
+			t.Error("changing the default profile requires keeping LegacyKDFParams for old vaults")
+		}
+	})
+
+	t.Run("returns named profiles", func(t *testing.T) {
+		for _, name := range []string{KDFProfileInteractive, KDFProfileModerate, KDFProfileSensitive} {
+			params, err := service.KDFProfile(name)
+			if err != nil {
+				t.Fatalf("KDFProfile(%q) failed: %v", name, err)
+			}
+			if err := ValidateKDFParams(params); err != nil {
+				t.Errorf("profile %q has invalid params: %v", name, err)
+			}
+		}
+	})
+
+	t.Run("returns error for unknown profile", func(t *testing.T) {
+		_, err := service.KDFProfile("paranoid")
+		if err != domain.ErrUnknownKDFProfile {
+			t.Errorf("expected ErrUnknownKDFProfile, got %v", err)
+		}
+	})
+}
+
+func TestValidateKDFParams(t *testing.T) {
+	valid := DefaultKDFParams()
+
+	tests := []struct {
+		name   string
+		modify func(p *domain.KDFParams)
+	}{
+		{"unsupported algorithm", func(p *domain.KDFParams) { p.Algorithm = "scrypt" }},
+		{"zero time", func(p *domain.KDFParams) { p.Time = 0 }},
+		{"excessive time", func(p *domain.KDFParams) { p.Time = MaxArgon2Time + 1 }},
+		{"too little memory", func(p *domain.KDFParams) { p.Memory = 1024 }},
+		{"excessive memory", func(p *domain.KDFParams) { p.Memory = MaxArgon2Memory + 1 }},
+		{"zero threads", func(p *domain.KDFParams) { p.Threads = 0 }},
+		{"excessive threads", func(p *domain.KDFParams) { p.Threads = MaxArgon2Threads + 1 }},
+		{"wrong key length", func(p *domain.KDFParams) { p.KeyLength = 16 }},
+	}
+
+	if err := ValidateKDFParams(valid); err != nil {
+		t.Fatalf("default params should be valid: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := valid
+			tt.modify(&params)
+			if err := ValidateKDFParams(params); err == nil {
+				t.Errorf("expected error for %s", tt.name)
+			}
+		})
+	}
+}
+
+func TestValidateKDFCost(t *testing.T) {
+	service := NewService()
+
+	for _, name := range []string{KDFProfileInteractive, KDFProfileModerate, KDFProfileSensitive} {
+		params, _ := service.KDFProfile(name)
+		if err := ValidateKDFCost(params); err != nil {
+			t.Errorf("profile %q rejected: %v", name, err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(p *domain.KDFParams)
+	}{
+		{"more time", func(p *domain.KDFParams) { p.Time++ }},
+		{"more memory", func(p *domain.KDFParams) { p.Memory *= 2 }},
+		{"more threads", func(p *domain.KDFParams) { p.Threads++ }},
+		{"invalid", func(p *domain.KDFParams) { p.Threads = 0 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, _ := service.KDFProfile(KDFProfileSensitive)
+			tt.modify(&params)
+			if err := ValidateKDFCost(params); err == nil {
+				t.Errorf("expected error for %s", tt.name)
+			}
+		})
+	}
+}
+
+func TestDeriveKeyWithParams(t *testing.T) {
+	service := NewService()
+	salt := make([]byte, SaltLength)
+	_, _ = rand.Read(salt)
+
+	t.Run("different parameters derive different keys", func(t *testing.T) {
+		interactive, _ := service.KDFProfile(KDFProfileInteractive)
+
+		key1, err := service.DeriveKey("password", salt, interactive)
+		if err != nil {
+			t.Fatalf("DeriveKey() failed: %v", err)
+		}
+		key2, err := service.DeriveKey("password", salt, DefaultKDFParams())
+		if err != nil {
+			t.Fatalf("DeriveKey() failed: %v", err)
+		}
+
+		if bytes.Equal(key1, key2) {
+			t.Error("DeriveKey() returned same key for different parameters")
+		}
+	})
+
+	t.Run("rejects invalid parameters", func(t *testing.T) {
+		params := DefaultKDFParams()
+		params.Memory = MaxArgon2Memory * 4
+
+		_, err := service.DeriveKey("password", salt, params)
+		if err == nil {
+			t.Error("DeriveKey() should reject out-of-range parameters")
+		}
+	})
+}
@@ -0,0 +1,111 @@
+package crypto
+
+import (
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"slices"
+)
+
+// Shared vaults encrypt their contents with a random data key. Each member
+// holds an X25519 key pair whose private half is encrypted with the key
+// derived from the member's password, and the data key is sealed to every
+// member's public key. The data key can therefore be replaced and sealed to
+// the remaining members without knowing their passwords.
+
+const (
+	// DataKeyLength is the size of a vault data key (AES-256)
+	DataKeyLength = 32
+
+	// keyWrapInfo binds the HKDF output to sealing vault data keys
+	keyWrapInfo = "go-password-manager vault data key v1"
+)
+
+// GenerateDataKey creates a random vault data key
+func (s *Service) GenerateDataKey() ([]byte, error) {
+	key := make([]byte, DataKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	return key, nil
+}
+
+// GenerateKeyPair creates an X25519 key pair for a vault member
+func (s *Service) GenerateKeyPair() (publicKey, privateKey []byte, err error) {
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate key pair: %w", err)
+	}
+	return private.PublicKey().Bytes(), private.Bytes(), nil
+}
+
+// SealKey encrypts key so that only the holder of the private key matching
+// publicKey can read it. The result is an ephemeral X25519 public key
+// followed by the AES-256-GCM nonce and ciphertext; the AES key is derived
+// from the shared secret with HKDF-SHA256.
+func (s *Service) SealKey(key, publicKey []byte) ([]byte, error) {
+	recipient, err := ecdh.X25519().NewPublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key: %w", err)
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	wrapKey, err := wrappingKey(ephemeral, recipient, ephemeral.PublicKey().Bytes())
+	if err != nil {
+		return nil, err
+	}
+	defer Wipe(wrapKey)
+
+	nonce, ciphertext, err := s.Encrypt(key, wrapKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Concat(ephemeral.PublicKey().Bytes(), nonce, ciphertext), nil
+}
+
+// OpenKey decrypts a key sealed by SealKey with the recipient's private key
+func (s *Service) OpenKey(sealed, privateKey []byte) ([]byte, error) {
+	const publicKeySize = 32
+	if len(sealed) < publicKeySize+NonceSize {
+		return nil, fmt.Errorf("sealed key is too short")
+	}
+
+	private, err := ecdh.X25519().NewPrivateKey(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %w", err)
+	}
+	ephemeralBytes := sealed[:publicKeySize]
+	ephemeral, err := ecdh.X25519().NewPublicKey(ephemeralBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sealed key: %w", err)
+	}
+
+	wrapKey, err := wrappingKey(private, ephemeral, ephemeralBytes)
+	if err != nil {
+		return nil, err
+	}
+	defer Wipe(wrapKey)
+
+	return s.Decrypt(sealed[publicKeySize:publicKeySize+NonceSize], sealed[publicKeySize+NonceSize:], wrapKey, nil)
+}
+
+// wrappingKey derives the AES key shared by private and peer. The ephemeral
+// public key is mixed in as the salt, so every sealed key uses its own AES key.
+func wrappingKey(private *ecdh.PrivateKey, peer *ecdh.PublicKey, ephemeralPublic []byte) ([]byte, error) {
+	secret, err := private.ECDH(peer)
+	if err != nil {
+		return nil, fmt.Errorf("key agreement failed: %w", err)
+	}
+	defer Wipe(secret)
+
+	key, err := hkdf.Key(sha256.New, secret, ephemeralPublic, keyWrapInfo, Argon2KeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive wrapping key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,74 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSealKey(t *testing.T) {
+	service := NewService()
+
+	dataKey, err := service.GenerateDataKey()
+	if err != nil {
+		t.Fatalf("GenerateDataKey() failed: %v", err)
+	}
+	if len(dataKey) != DataKeyLength {
+		t.Fatalf("expected data key length %d, got %d", DataKeyLength, len(dataKey))
+	}
+
+	publicKey, privateKey, err := service.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair() failed: %v", err)
+	}
+
+	t.Run("round trip", func(t *testing.T) {
+		sealed, err := service.SealKey(dataKey, publicKey)
+		if err != nil {
+			t.Fatalf("SealKey() failed: %v", err)
+		}
+		if bytes.Contains(sealed, dataKey) {
+			t.Fatal("sealed key contains the data key")
+		}
+
+		opened, err := service.OpenKey(sealed, privateKey)
+		if err != nil {
+			t.Fatalf("OpenKey() failed: %v", err)
+		}
+		if !bytes.Equal(opened, dataKey) {
+			t.Error("OpenKey() returned a different key")
+		}
+	})
+
+	t.Run("sealing twice gives different results", func(t *testing.T) {
+		first, _ := service.SealKey(dataKey, publicKey)
+		second, _ := service.SealKey(dataKey, publicKey)
+		if bytes.Equal(first, second) {
+			t.Error("SealKey() reused its ephemeral key")
+		}
+	})
+
+	t.Run("other private key fails", func(t *testing.T) {
+		sealed, _ := service.SealKey(dataKey, publicKey)
+		_, otherPrivate, _ := service.GenerateKeyPair()
+		if _, err := service.OpenKey(sealed, otherPrivate); err == nil {
+			t.Error("expected OpenKey() to fail with another private key")
+		}
+	})
+
+	t.Run("tampered key fails", func(t *testing.T) {
+		sealed, _ := service.SealKey(dataKey, publicKey)
+		sealed[len(sealed)-1] ^= 1
+		if _, err := service.OpenKey(sealed, privateKey); err == nil {
+			t.Error("expected OpenKey() to fail on a tampered key")
+		}
+		if _, err := service.OpenKey(sealed[:20], privateKey); err == nil {
+			t.Error("expected OpenKey() to fail on a truncated key")
+		}
+	})
+
+	t.Run("invalid public key", func(t *testing.T) {
+		if _, err := service.SealKey(dataKey, []byte("short")); err == nil {
+			t.Error("expected SealKey() to reject an invalid public key")
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package crypto
+
+import (
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+)
+
+// macInfo separates the MAC key from other uses of the same key
+const macInfo = "go-password-manager metadata mac v1"
+
+// MAC computes HMAC-SHA256 of message. The HMAC key is derived from key with
+// HKDF, so a vault key can also authenticate metadata without being used
+// for two algorithms.
+func (s *Service) MAC(message, key []byte) ([]byte, error) {
+	if len(key) != Argon2KeyLen {
+		return nil, fmt.Errorf("invalid key length: expected %d, got %d", Argon2KeyLen, len(key))
+	}
+
+	macKey, err := hkdf.Key(sha256.New, key, nil, macInfo, sha256.Size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive MAC key: %w", err)
+	}
+	defer Wipe(macKey)
+
+	h := hmac.New(sha256.New, macKey)
+	h.Write(message)
+	return h.Sum(nil), nil
+}
+
+// VerifyMAC reports whether mac is the MAC of message under key, in
+// constant time
+func (s *Service) VerifyMAC(message, mac, key []byte) bool {
+	expected, err := s.MAC(message, key)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(expected, mac)
+}
@@ -0,0 +1,50 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMAC(t *testing.T) {
+	service := NewService()
+	key, _ := service.GenerateDataKey()
+	message := []byte(`[{"name":"alice","role":"owner"}]`)
+
+	mac, err := service.MAC(message, key)
+	if err != nil {
+		t.Fatalf("MAC() failed: %v", err)
+	}
+	if len(mac) != 32 {
+		t.Errorf("expected a 32-byte MAC, got %d bytes", len(mac))
+	}
+	if !service.VerifyMAC(message, mac, key) {
+		t.Error("VerifyMAC() rejected a valid MAC")
+	}
+
+	otherKey, _ := service.GenerateDataKey()
+	tampered := bytes.Replace(message, []byte("owner"), []byte("admin"), 1)
+	tests := []struct {
+		name    string
+		message []byte
+		mac     []byte
+		key     []byte
+	}{
+		{"changed message", tampered, mac, key},
+		{"other key", message, mac, otherKey},
+		{"missing MAC", message, nil, key},
+		{"invalid key", message, mac, []byte("short")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if service.VerifyMAC(tt.message, tt.mac, tt.key) {
+				t.Error("VerifyMAC() accepted an invalid MAC")
+			}
+		})
+	}
+
+	t.Run("rejects an invalid key", func(t *testing.T) {
+		if _, err := service.MAC(message, []byte("short")); err == nil {
+			t.Error("expected MAC() to reject an invalid key")
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package crypto
+
+import "sync"
+
+// SecureBuffer holds secret bytes such as encryption keys and wipes them
+// when destroyed. The Go garbage collector does not move heap objects, so
+// zeroing the buffer removes the only copy the buffer owns; callers must
+// avoid making further copies of Bytes().
+type SecureBuffer struct {
+	mu   sync.RWMutex
+	data []byte
+}
+
+// NewSecureBuffer takes ownership of data. The caller must not use or
+// retain data afterwards; it is zeroed when the buffer is destroyed.
+func NewSecureBuffer(data []byte) *SecureBuffer {
+	return &SecureBuffer{data: data}
+}
+
+// CopySecureBuffer returns a new buffer holding a copy of data.
+// data itself is left untouched.
+func CopySecureBuffer(data []byte) *SecureBuffer {
+	return &SecureBuffer{data: append([]byte(nil), data...)}
+}
+
+// Bytes returns the secret. The slice is only valid until Destroy is called
+// and must not be modified or retained.
+func (b *SecureBuffer) Bytes() []byte {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.data
+}
+
+// Len returns the number of secret bytes, or 0 once destroyed
+func (b *SecureBuffer) Len() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return len(b.data)
+}
+
+// Destroyed reports whether Destroy has been called
+func (b *SecureBuffer) Destroyed() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.data == nil
+}
+
+// Destroy zeroes the secret and releases it. It is safe to call more than once.
+func (b *SecureBuffer) Destroy() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	Wipe(b.data)
+	b.data = nil
+}
+
+// Wipe overwrites buf with zeros. Use it for intermediate plaintext such as
+// decrypted vault JSON once it is no longer needed.
+func Wipe(buf []byte) {
+	clear(buf)
+}
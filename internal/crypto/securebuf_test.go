@@ -0,0 +1,95 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSecureBuffer(t *testing.T) {
+	t.Run("takes ownership of data", func(t *testing.T) {
+		data := []byte{1, 2, 3, 4}
+		buf := NewSecureBuffer(data)
+
+		if !bytes.Equal(buf.Bytes(), []byte{1, 2, 3, 4}) {
+			t.Errorf("unexpected contents: %v", buf.Bytes())
+		}
+		if buf.Len() != 4 {
+			t.Errorf("expected length 4, got %d", buf.Len())
+		}
+
+		buf.Destroy()
+
+		if !bytes.Equal(data, []byte{0, 0, 0, 0}) {
+			t.Errorf("underlying data was not zeroed: %v", data)
+		}
+	})
+
+	t.Run("copy leaves source untouched", func(t *testing.T) {
+		data := []byte{1, 2, 3, 4}
+		buf := CopySecureBuffer(data)
+		secret := buf.Bytes()
+
+		buf.Destroy()
+
+		if !bytes.Equal(data, []byte{1, 2, 3, 4}) {
+			t.Errorf("source was modified: %v", data)
+		}
+		if !bytes.Equal(secret, []byte{0, 0, 0, 0}) {
+			t.Errorf("copy was not zeroed: %v", secret)
+		}
+	})
+
+	t.Run("is empty after destroy", func(t *testing.T) {
+		buf := NewSecureBuffer([]byte{1, 2, 3})
+		if buf.Destroyed() {
+			t.Error("new buffer reports destroyed")
+		}
+
+		buf.Destroy()
+		buf.Destroy() // second call must be harmless
+
+		if !buf.Destroyed() {
+			t.Error("buffer does not report destroyed")
+		}
+		if buf.Bytes() != nil || buf.Len() != 0 {
+			t.Error("destroyed buffer still exposes data")
+		}
+	})
+
+	t.Run("holds a derived key", func(t *testing.T) {
+		service := NewService()
+		salt, _ := service.GenerateSalt()
+		rawKey, err := service.DeriveKey("password", salt, DefaultKDFParams())
+		if err != nil {
+			t.Fatalf("DeriveKey() failed: %v", err)
+		}
+
+		key := NewSecureBuffer(rawKey)
+		nonce, ciphertext, err := service.Encrypt([]byte("secret"), key.Bytes(), nil)
+		if err != nil {
+			t.Fatalf("Encrypt() failed: %v", err)
+		}
+
+		key.Destroy()
+
+		if !bytes.Equal(rawKey, make([]byte, len(rawKey))) {
+			t.Error("derived key was not zeroed")
+		}
+		if _, err := service.Decrypt(nonce, ciphertext, rawKey, nil); err == nil {
+			t.Error("Decrypt() should fail with a wiped key")
+		}
+	})
+}
+
+func TestWipe(t *testing.T) {
+	buf := []byte("plaintext vault json")
+	Wipe(buf)
+
+	if !bytes.Equal(buf, make([]byte, len(buf))) {
+		t.Errorf("buffer was not wiped: %v", buf)
+	}
+
+	// Wiping nil or empty buffers must not panic
+	Wipe(nil)
+	Wipe([]byte{})
+}
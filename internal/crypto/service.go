@@ -6,6 +6,7 @@ import (
 	"crypto/rand"
 	"fmt"
 
+	"github.com/orlan/go-password-manager/internal/domain"
 	"golang.org/x/crypto/argon2"
 )
 
@@ -22,7 +23,7 @@ const (
 	//
 	// Performance impact: ~300-500ms on modern hardware (acceptable for password
 	// manager authentication). Existing vaults remain compatible as each vault
-	// stores its own derivation parameters.
+	// stores its own derivation parameters (see kdf.go for the profiles).
 	Argon2Time    = 3          // 3 iterations - balances security and performance
 	Argon2Memory  = 128 * 1024 // 128 MB - OWASP minimum for high-value secrets
 	Argon2Threads = 4          // Parallelism factor
@@ -45,21 +46,29 @@ func NewService() *Service {
 }
 
 // DeriveKey derives an encryption key from a master password using Argon2id
-func (s *Service) DeriveKey(password string, salt []byte) ([]byte, error) {
+// with the given parameters
+func (s *Service) DeriveKey(password string, salt []byte, params domain.KDFParams) ([]byte, error) {
 	if len(password) == 0 {
 		return nil, fmt.Errorf("password cannot be empty")
 	}
 	if len(salt) == 0 {
 		return nil, fmt.Errorf("salt cannot be empty")
 	}
+	if err := ValidateKDFParams(params); err != nil {
+		return nil, err
+	}
+
+	// Wipe the password copy once the key has been derived
+	passwordBytes := []byte(password)
+	defer Wipe(passwordBytes)
 
 	key := argon2.IDKey(
-		[]byte(password),
+		passwordBytes,
 		salt,
-		Argon2Time,
-		Argon2Memory,
-		Argon2Threads,
-		Argon2KeyLen,
+		params.Time,
+		params.Memory,
+		params.Threads,
+		params.KeyLength,
 	)
 
 	return key, nil
@@ -75,8 +84,9 @@ func (s *Service) GenerateSalt() ([]byte, error) {
 	return salt, nil
 }
 
-// Encrypt encrypts plaintext using AES-256-GCM
-func (s *Service) Encrypt(plaintext, key []byte) (nonce, ciphertext []byte, err error) {
+// Encrypt encrypts plaintext using AES-256-GCM. additionalData is
+// authenticated along with the ciphertext but not encrypted; it may be nil.
+func (s *Service) Encrypt(plaintext, key, additionalData []byte) (nonce, ciphertext []byte, err error) {
 	if len(key) != Argon2KeyLen {
 		return nil, nil, fmt.Errorf("invalid key length: expected %d, got %d", Argon2KeyLen, len(key))
 	}
@@ -97,12 +107,13 @@ func (s *Service) Encrypt(plaintext, key []byte) (nonce, ciphertext []byte, err
 		return nil, nil, fmt.Errorf("failed to generate nonce: %w", err)
 	}
 
-	ciphertext = gcm.Seal(nil, nonce, plaintext, nil)
+	ciphertext = gcm.Seal(nil, nonce, plaintext, additionalData)
 	return nonce, ciphertext, nil
 }
 
-// Decrypt decrypts ciphertext using AES-256-GCM
-func (s *Service) Decrypt(nonce, ciphertext, key []byte) ([]byte, error) {
+// Decrypt decrypts ciphertext using AES-256-GCM. It fails unless
+// additionalData is the same as when the plaintext was encrypted.
+func (s *Service) Decrypt(nonce, ciphertext, key, additionalData []byte) ([]byte, error) {
 	if len(key) != Argon2KeyLen {
 		return nil, fmt.Errorf("invalid key length: expected %d, got %d", Argon2KeyLen, len(key))
 	}
@@ -121,7 +132,7 @@ func (s *Service) Decrypt(nonce, ciphertext, key []byte) ([]byte, error) {
 		return nil, fmt.Errorf("invalid nonce size: expected %d, got %d", gcm.NonceSize(), len(nonce))
 	}
 
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData)
 	if err != nil {
 		return nil, fmt.Errorf("decryption failed: %w", err)
 	}
@@ -68,7 +68,7 @@ func TestDeriveKey(t *testing.T) {
 		salt := make([]byte, SaltLength)
 		_, _ = rand.Read(salt)
 
-		key, err := service.DeriveKey(password, salt)
+		key, err := service.DeriveKey(password, salt, DefaultKDFParams())
 		if err != nil {
 			t.Fatalf("DeriveKey() failed: %v", err)
 		}
@@ -82,11 +82,11 @@ func TestDeriveKey(t *testing.T) {
 		salt := make([]byte, SaltLength)
 		_, _ = rand.Read(salt)
 
-		key1, err := service.DeriveKey(password, salt)
+		key1, err := service.DeriveKey(password, salt, DefaultKDFParams())
 		if err != nil {
 			t.Fatalf("DeriveKey() failed: %v", err)
 		}
-		key2, err := service.DeriveKey(password, salt)
+		key2, err := service.DeriveKey(password, salt, DefaultKDFParams())
 		if err != nil {
 			t.Fatalf("DeriveKey() failed: %v", err)
 		}
@@ -100,11 +100,11 @@ func TestDeriveKey(t *testing.T) {
 		salt := make([]byte, SaltLength)
 		_, _ = rand.Read(salt)
 
-		key1, err := service.DeriveKey("password1", salt)
+		key1, err := service.DeriveKey("password1", salt, DefaultKDFParams())
 		if err != nil {
 			t.Fatalf("DeriveKey() failed: %v", err)
 		}
-		key2, err := service.DeriveKey("password2", salt)
+		key2, err := service.DeriveKey("password2", salt, DefaultKDFParams())
 		if err != nil {
 			t.Fatalf("DeriveKey() failed: %v", err)
 		}
@@ -121,11 +121,11 @@ func TestDeriveKey(t *testing.T) {
 		_, _ = rand.Read(salt1)
 		_, _ = rand.Read(salt2)
 
-		key1, err := service.DeriveKey(password, salt1)
+		key1, err := service.DeriveKey(password, salt1, DefaultKDFParams())
 		if err != nil {
 			t.Fatalf("DeriveKey() failed: %v", err)
 		}
-		key2, err := service.DeriveKey(password, salt2)
+		key2, err := service.DeriveKey(password, salt2, DefaultKDFParams())
 		if err != nil {
 			t.Fatalf("DeriveKey() failed: %v", err)
 		}
@@ -139,7 +139,7 @@ func TestDeriveKey(t *testing.T) {
 		salt := make([]byte, SaltLength)
 		_, _ = rand.Read(salt)
 
-		_, err := service.DeriveKey("", salt)
+		_, err := service.DeriveKey("", salt, DefaultKDFParams())
 		if err == nil {
 			t.Error("DeriveKey() should return error for empty password")
 		}
@@ -150,7 +150,7 @@ func TestDeriveKey(t *testing.T) {
 	})
 
 	t.Run("returns error for empty salt", func(t *testing.T) {
-		_, err := service.DeriveKey("password", []byte{})
+		_, err := service.DeriveKey("password", []byte{}, DefaultKDFParams())
 		if err == nil {
 			t.Error("DeriveKey() should return error for empty salt")
 		}
@@ -161,7 +161,7 @@ func TestDeriveKey(t *testing.T) {
 	})
 
 	t.Run("returns error for nil salt", func(t *testing.T) {
-		_, err := service.DeriveKey("password", nil)
+		_, err := service.DeriveKey("password", nil, DefaultKDFParams())
 		if err == nil {
 			t.Error("DeriveKey() should return error for nil salt")
 		}
@@ -176,7 +176,7 @@ func TestEncrypt(t *testing.T) {
 		_, _ = rand.Read(key)
 		plaintext := []byte("secret data")
 
-		nonce, ciphertext, err := service.Encrypt(plaintext, key)
+		nonce, ciphertext, err := service.Encrypt(plaintext, key, nil)
 		if err != nil {
 			t.Fatalf("Encrypt() failed: %v", err)
 		}
@@ -196,11 +196,11 @@ func TestEncrypt(t *testing.T) {
 		_, _ = rand.Read(key)
 		plaintext := []byte("secret data")
 
-		nonce1, _, err := service.Encrypt(plaintext, key)
+		nonce1, _, err := service.Encrypt(plaintext, key, nil)
 		if err != nil {
 			t.Fatalf("Encrypt() failed: %v", err)
 		}
-		nonce2, _, err := service.Encrypt(plaintext, key)
+		nonce2, _, err := service.Encrypt(plaintext, key, nil)
 		if err != nil {
 			t.Fatalf("Encrypt() failed: %v", err)
 		}
@@ -215,7 +215,7 @@ func TestEncrypt(t *testing.T) {
 		_, _ = rand.Read(key)
 		plaintext := []byte("")
 
-		nonce, ciphertext, err := service.Encrypt(plaintext, key)
+		nonce, ciphertext, err := service.Encrypt(plaintext, key, nil)
 		if err != nil {
 			t.Fatalf("Encrypt() failed: %v", err)
 		}
@@ -231,7 +231,7 @@ func TestEncrypt(t *testing.T) {
 		key := make([]byte, 16) // Wrong size
 		plaintext := []byte("secret data")
 
-		_, _, err := service.Encrypt(plaintext, key)
+		_, _, err := service.Encrypt(plaintext, key, nil)
 		if err == nil {
 			t.Error("Encrypt() should return error for invalid key length")
 		}
@@ -240,7 +240,7 @@ func TestEncrypt(t *testing.T) {
 	t.Run("returns error for nil key", func(t *testing.T) {
 		plaintext := []byte("secret data")
 
-		_, _, err := service.Encrypt(plaintext, nil)
+		_, _, err := service.Encrypt(plaintext, nil, nil)
 		if err == nil {
 			t.Error("Encrypt() should return error for nil key")
 		}
@@ -252,7 +252,7 @@ func TestEncrypt(t *testing.T) {
 		plaintext := make([]byte, 1024*1024) // 1MB
 		_, _ = rand.Read(plaintext)
 
-		nonce, ciphertext, err := service.Encrypt(plaintext, key)
+		nonce, ciphertext, err := service.Encrypt(plaintext, key, nil)
 		if err != nil {
 			t.Fatalf("Encrypt() failed: %v", err)
 		}
@@ -273,12 +273,12 @@ func TestDecrypt(t *testing.T) {
 		_, _ = rand.Read(key)
 		plaintext := []byte("secret data")
 
-		nonce, ciphertext, err := service.Encrypt(plaintext, key)
+		nonce, ciphertext, err := service.Encrypt(plaintext, key, nil)
 		if err != nil {
 			t.Fatalf("Encrypt() failed: %v", err)
 		}
 
-		decrypted, err := service.Decrypt(nonce, ciphertext, key)
+		decrypted, err := service.Decrypt(nonce, ciphertext, key, nil)
 		if err != nil {
 			t.Fatalf("Decrypt() failed: %v", err)
 		}
@@ -292,12 +292,12 @@ func TestDecrypt(t *testing.T) {
 		_, _ = rand.Read(key)
 		plaintext := []byte("")
 
-		nonce, ciphertext, err := service.Encrypt(plaintext, key)
+		nonce, ciphertext, err := service.Encrypt(plaintext, key, nil)
 		if err != nil {
 			t.Fatalf("Encrypt() failed: %v", err)
 		}
 
-		decrypted, err := service.Decrypt(nonce, ciphertext, key)
+		decrypted, err := service.Decrypt(nonce, ciphertext, key, nil)
 		if err != nil {
 			t.Fatalf("Decrypt() failed: %v", err)
 		}
@@ -312,12 +312,12 @@ func TestDecrypt(t *testing.T) {
 		plaintext := make([]byte, 1024*1024) // 1MB
 		_, _ = rand.Read(plaintext)
 
-		nonce, ciphertext, err := service.Encrypt(plaintext, key)
+		nonce, ciphertext, err := service.Encrypt(plaintext, key, nil)
 		if err != nil {
 			t.Fatalf("Encrypt() failed: %v", err)
 		}
 
-		decrypted, err := service.Decrypt(nonce, ciphertext, key)
+		decrypted, err := service.Decrypt(nonce, ciphertext, key, nil)
 		if err != nil {
 			t.Fatalf("Decrypt() failed: %v", err)
 		}
@@ -333,12 +333,12 @@ func TestDecrypt(t *testing.T) {
 		_, _ = rand.Read(key2)
 		plaintext := []byte("secret data")
 
-		nonce, ciphertext, err := service.Encrypt(plaintext, key1)
+		nonce, ciphertext, err := service.Encrypt(plaintext, key1, nil)
 		if err != nil {
 			t.Fatalf("Encrypt() failed: %v", err)
 		}
 
-		_, err = service.Decrypt(nonce, ciphertext, key2)
+		_, err = service.Decrypt(nonce, ciphertext, key2, nil)
 		if err == nil {
 			t.Error("Decrypt() should fail with wrong key")
 		}
@@ -349,13 +349,13 @@ func TestDecrypt(t *testing.T) {
 		_, _ = rand.Read(key)
 		plaintext := []byte("secret data")
 
-		_, ciphertext, err := service.Encrypt(plaintext, key)
+		_, ciphertext, err := service.Encrypt(plaintext, key, nil)
 		if err != nil {
 			t.Fatalf("Encrypt() failed: %v", err)
 		}
 
 		wrongNonce := make([]byte, 8) // Wrong size
-		_, err = service.Decrypt(wrongNonce, ciphertext, key)
+		_, err = service.Decrypt(wrongNonce, ciphertext, key, nil)
 		if err == nil {
 			t.Error("Decrypt() should return error for invalid nonce size")
 		}
@@ -366,13 +366,13 @@ func TestDecrypt(t *testing.T) {
 		_, _ = rand.Read(key)
 		plaintext := []byte("secret data")
 
-		nonce, ciphertext, err := service.Encrypt(plaintext, key)
+		nonce, ciphertext, err := service.Encrypt(plaintext, key, nil)
 		if err != nil {
 			t.Fatalf("Encrypt() failed: %v", err)
 		}
 
 		wrongKey := make([]byte, 16) // Wrong size
-		_, err = service.Decrypt(nonce, ciphertext, wrongKey)
+		_, err = service.Decrypt(nonce, ciphertext, wrongKey, nil)
 		if err == nil {
 			t.Error("Decrypt() should return error for invalid key length")
 		}
@@ -383,7 +383,7 @@ func TestDecrypt(t *testing.T) {
 		_, _ = rand.Read(key)
 		plaintext := []byte("secret data")
 
-		nonce, ciphertext, err := service.Encrypt(plaintext, key)
+		nonce, ciphertext, err := service.Encrypt(plaintext, key, nil)
 		if err != nil {
 			t.Fatalf("Encrypt() failed: %v", err)
 		}
@@ -393,17 +393,43 @@ func TestDecrypt(t *testing.T) {
 			ciphertext[0] ^= 0xFF
 		}
 
-		_, err = service.Decrypt(nonce, ciphertext, key)
+		_, err = service.Decrypt(nonce, ciphertext, key, nil)
 		if err == nil {
 			t.Error("Decrypt() should fail for tampered ciphertext")
 		}
 	})
 
+	t.Run("authenticates additional data", func(t *testing.T) {
+		key := make([]byte, Argon2KeyLen)
+		_, _ = rand.Read(key)
+		plaintext := []byte("secret data")
+		additionalData := []byte(`{"name":"personal"}`)
+
+		nonce, ciphertext, err := service.Encrypt(plaintext, key, additionalData)
+		if err != nil {
+			t.Fatalf("Encrypt() failed: %v", err)
+		}
+
+		decrypted, err := service.Decrypt(nonce, ciphertext, key, additionalData)
+		if err != nil {
+			t.Fatalf("Decrypt() failed: %v", err)
+		}
+		if !bytes.Equal(plaintext, decrypted) {
+			t.Errorf("Decrypt() = %q, want %q", decrypted, plaintext)
+		}
+
+		for _, wrong := range [][]byte{nil, []byte(`{"name":"work"}`)} {
+			if _, err := service.Decrypt(nonce, ciphertext, key, wrong); err == nil {
+				t.Errorf("Decrypt() should fail with additional data %q", wrong)
+			}
+		}
+	})
+
 	t.Run("returns error for nil key", func(t *testing.T) {
 		nonce := make([]byte, NonceSize)
 		ciphertext := []byte("fake ciphertext")
 
-		_, err := service.Decrypt(nonce, ciphertext, nil)
+		_, err := service.Decrypt(nonce, ciphertext, nil, nil)
 		if err == nil {
 			t.Error("Decrypt() should return error for nil key")
 		}
@@ -433,12 +459,12 @@ func TestEncryptDecryptRoundTrip(t *testing.T) {
 				_, _ = rand.Read(tc.plaintext)
 			}
 
-			nonce, ciphertext, err := service.Encrypt(tc.plaintext, key)
+			nonce, ciphertext, err := service.Encrypt(tc.plaintext, key, nil)
 			if err != nil {
 				t.Fatalf("Encrypt() failed: %v", err)
 			}
 
-			decrypted, err := service.Decrypt(nonce, ciphertext, key)
+			decrypted, err := service.Decrypt(nonce, ciphertext, key, nil)
 			if err != nil {
 				t.Fatalf("Decrypt() failed: %v", err)
 			}
@@ -461,24 +487,24 @@ func TestFullCryptoWorkflow(t *testing.T) {
 
 	// Derive key from password
 	password := "my-master-password-123"
-	key, err := service.DeriveKey(password, salt)
+	key, err := service.DeriveKey(password, salt, DefaultKDFParams())
 	if err != nil {
 		t.Fatalf("DeriveKey() failed: %v", err)
 	}
 
 	// Encrypt data
 	plaintext := []byte(`{"site":"example.com","username":"user@example.com","password":"P@ssw0rd"}`)
-	nonce, ciphertext, err := service.Encrypt(plaintext, key)
+	nonce, ciphertext, err := service.Encrypt(plaintext, key, nil)
 	if err != nil {
 		t.Fatalf("Encrypt() failed: %v", err)
 	}
 
 	// Decrypt data with correct password
-	decryptedKey, err := service.DeriveKey(password, salt)
+	decryptedKey, err := service.DeriveKey(password, salt, DefaultKDFParams())
 	if err != nil {
 		t.Fatalf("DeriveKey() failed: %v", err)
 	}
-	decrypted, err := service.Decrypt(nonce, ciphertext, decryptedKey)
+	decrypted, err := service.Decrypt(nonce, ciphertext, decryptedKey, nil)
 	if err != nil {
 		t.Fatalf("Decrypt() failed: %v", err)
 	}
@@ -488,11 +514,11 @@ func TestFullCryptoWorkflow(t *testing.T) {
 	}
 
 	// Try to decrypt with wrong password
-	wrongKey, err := service.DeriveKey("wrong-password", salt)
+	wrongKey, err := service.DeriveKey("wrong-password", salt, DefaultKDFParams())
 	if err != nil {
 		t.Fatalf("DeriveKey() failed: %v", err)
 	}
-	_, err = service.Decrypt(nonce, ciphertext, wrongKey)
+	_, err = service.Decrypt(nonce, ciphertext, wrongKey, nil)
 	if err == nil {
 		t.Error("Decrypt() should fail with wrong password")
 	}
@@ -514,7 +540,7 @@ func BenchmarkDeriveKey(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, _ = service.DeriveKey(password, salt)
+		_, _ = service.DeriveKey(password, salt, DefaultKDFParams())
 	}
 }
 
@@ -526,7 +552,7 @@ func BenchmarkEncrypt(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, _, _ = service.Encrypt(plaintext, key)
+		_, _, _ = service.Encrypt(plaintext, key, nil)
 	}
 }
 
@@ -535,10 +561,10 @@ func BenchmarkDecrypt(b *testing.B) {
 	key := make([]byte, Argon2KeyLen)
 	_, _ = rand.Read(key)
 	plaintext := []byte("This is a secret password that needs to be encrypted")
-	nonce, ciphertext, _ := service.Encrypt(plaintext, key)
+	nonce, ciphertext, _ := service.Encrypt(plaintext, key, nil)
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, _ = service.Decrypt(nonce, ciphertext, key)
+		_, _ = service.Decrypt(nonce, ciphertext, key, nil)
 	}
 }
@@ -0,0 +1,39 @@
+package crypto
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+)
+
+// GenerateSigningKey creates an Ed25519 key pair. The private key is the
+// 32-byte seed, which can be sealed like a data key.
+func (s *Service) GenerateSigningKey() (publicKey, privateKey []byte, err error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	seed := private.Seed()
+	Wipe(private)
+	return public, seed, nil
+}
+
+// Sign signs message with a private key from GenerateSigningKey
+func (s *Service) Sign(message, privateKey []byte) ([]byte, error) {
+	if len(privateKey) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid signing key length: expected %d, got %d", ed25519.SeedSize, len(privateKey))
+	}
+
+	private := ed25519.NewKeyFromSeed(privateKey)
+	defer Wipe(private)
+	return ed25519.Sign(private, message), nil
+}
+
+// VerifySignature reports whether signature is a signature of message by
+// the private key matching publicKey
+func (s *Service) VerifySignature(message, signature, publicKey []byte) bool {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return false
+	}
+	return ed25519.Verify(publicKey, message, signature)
+}
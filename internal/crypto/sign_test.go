@@ -0,0 +1,50 @@
+package crypto
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSign(t *testing.T) {
+	service := NewService()
+	publicKey, privateKey, err := service.GenerateSigningKey()
+	if err != nil {
+		t.Fatalf("GenerateSigningKey() failed: %v", err)
+	}
+	message := []byte(`[{"name":"alice","role":"owner"}]`)
+
+	signature, err := service.Sign(message, privateKey)
+	if err != nil {
+		t.Fatalf("Sign() failed: %v", err)
+	}
+	if !service.VerifySignature(message, signature, publicKey) {
+		t.Error("VerifySignature() rejected a valid signature")
+	}
+
+	otherKey, _, _ := service.GenerateSigningKey()
+	tampered := bytes.Replace(message, []byte("owner"), []byte("admin"), 1)
+	tests := []struct {
+		name      string
+		message   []byte
+		signature []byte
+		publicKey []byte
+	}{
+		{"changed message", tampered, signature, publicKey},
+		{"other key", message, signature, otherKey},
+		{"missing signature", message, nil, publicKey},
+		{"invalid key", message, signature, []byte("short")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if service.VerifySignature(tt.message, tt.signature, tt.publicKey) {
+				t.Error("VerifySignature() accepted an invalid signature")
+			}
+		})
+	}
+
+	t.Run("rejects an invalid key", func(t *testing.T) {
+		if _, err := service.Sign(message, []byte("short")); err == nil {
+			t.Error("expected Sign() to reject an invalid key")
+		}
+	})
+}
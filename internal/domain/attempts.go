@@ -0,0 +1,31 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// UnlockAttempts counts consecutive failed unlocks of a vault or by a client
+type UnlockAttempts struct {
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure"`
+}
+
+// AttemptStore persists failed unlock counters, so that restarting the
+// service does not reset them
+type AttemptStore interface {
+	// UpdateAttempts passes all counters, keyed by vault or client, to fn and
+	// stores the map fn leaves behind unless fn fails. Other writers wait
+	// until the update is done.
+	UpdateAttempts(ctx context.Context, fn func(attempts map[string]UnlockAttempts) error) error
+}
+
+// TwoFactorStepStore remembers the time step of the last one-time code
+// accepted for each vault, so that every code is accepted once, also
+// after a restart or by another process using the same vaults
+type TwoFactorStepStore interface {
+	// UpdateTwoFactorSteps passes the last accepted steps, keyed by vault,
+	// to fn and stores the map fn leaves behind unless fn fails. Other
+	// writers wait until the update is done.
+	UpdateTwoFactorSteps(ctx context.Context, fn func(steps map[string]uint64) error) error
+}
@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// AuditLogEvent names an operation recorded in the audit log
+type AuditLogEvent string
+
+const (
+	AuditLogUnlock       AuditLogEvent = "unlock"
+	AuditLogUnlockFailed AuditLogEvent = "unlock_failed"
+	AuditLogLock         AuditLogEvent = "lock"
+	AuditLogList         AuditLogEvent = "list"
+	AuditLogGet          AuditLogEvent = "get"
+	AuditLogAdd          AuditLogEvent = "add"
+	AuditLogUpdate       AuditLogEvent = "update"
+	AuditLogDelete       AuditLogEvent = "delete"
+	AuditLogImport       AuditLogEvent = "import"
+	AuditLogExport       AuditLogEvent = "export"
+)
+
+// AuditLogEntry is a decrypted entry of a vault's audit log
+type AuditLogEntry struct {
+	Sequence uint64        `json:"sequence"`
+	Time     time.Time     `json:"time"`
+	Event    AuditLogEvent `json:"event"`
+	// Actor is the client that caused the event, such as a remote address
+	// or a Telegram user
+	Actor string `json:"actor,omitempty"`
+	// Member of a shared vault whose session or password was used
+	Member string `json:"member,omitempty"`
+	Record string `json:"record,omitempty"`
+	// Detail adds event specific information, such as why a vault was locked
+	Detail string `json:"detail,omitempty"`
+}
+
+// AuditLogRecord is an audit log entry as stored. Entry is sealed to the
+// public key of the vault's audit log, so entries can be appended without
+// the vault key. Hash chains every record to the one before it.
+type AuditLogRecord struct {
+	Sequence uint64 `json:"seq"`
+	Entry    []byte `json:"entry"`
+	Hash     []byte `json:"hash"`
+	// MAC authenticates Hash, and with it every earlier record, with the
+	// private key of the audit log. It is missing on records written
+	// without the vault key, such as failed unlocks.
+	MAC []byte `json:"mac,omitempty"`
+}
+
+// AuditLogKey is the key pair audit log entries are sealed with. It is
+// kept inside the encrypted vault.
+type AuditLogKey struct {
+	PublicKey  []byte `json:"public_key"`
+	PrivateKey []byte `json:"private_key"`
+	// Sequence and Hash are those of the head of the log when the vault was
+	// last saved, so that a log deleted along with its head is noticed.
+	// They are unset until the first save after a record with a MAC.
+	Sequence uint64 `json:"sequence,omitempty"`
+	Hash     []byte `json:"hash,omitempty"`
+	// Start is the sequence of the first record written with this key, and
+	// Previous the keys of the records before it, oldest first. The key is
+	// replaced when a member is removed from a shared vault.
+	Start    uint64        `json:"start,omitempty"`
+	Previous []AuditLogKey `json:"previous,omitempty"`
+}
+
+// AuditLogStore keeps the append-only audit logs of vaults
+type AuditLogStore interface {
+	// AppendAuditLog passes the last record of the vault's log, or nil if
+	// the log is empty, to fn and appends the record fn returns unless fn
+	// fails. A record with a MAC also becomes the head of the log. Other
+	// writers wait until the append is done.
+	AppendAuditLog(ctx context.Context, vaultName string, fn func(last *AuditLogRecord) (AuditLogRecord, error)) error
+
+	// LoadAuditLog returns the records of the vault's log, oldest first
+	LoadAuditLog(ctx context.Context, vaultName string) ([]AuditLogRecord, error)
+
+	// LoadAuditLogHead returns the last record with a MAC appended to the
+	// vault's log, or nil if there is none. It is kept apart from the log,
+	// so records cut off the end of the log are noticed.
+	LoadAuditLogHead(ctx context.Context, vaultName string) (*AuditLogRecord, error)
+}
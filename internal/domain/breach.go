@@ -0,0 +1,8 @@
+package domain
+
+// BreachChecker looks up passwords in a list of known breached passwords
+type BreachChecker interface {
+	// Count returns how many times password appears in known data breaches,
+	// or 0 if it does not appear
+	Count(password string) (int, error)
+}
@@ -1,16 +1,74 @@
 package domain
 
+// KDFAlgorithmArgon2id identifies the Argon2id key derivation function
+const KDFAlgorithmArgon2id = "argon2id"
+
+// KDFParams describes how a vault key is derived from its master password.
+// They are stored in the vault file so that tuning the defaults never locks
+// users out of existing vaults.
+type KDFParams struct {
+	Algorithm string `json:"algorithm"`
+	Time      uint32 `json:"time"`
+	Memory    uint32 `json:"memory"` // in KiB
+	Threads   uint8  `json:"threads"`
+	KeyLength uint32 `json:"key_length"`
+}
+
+// LegacyKDFParams are the parameters used by vaults written before
+// derivation parameters were recorded in VaultMetadata
+var LegacyKDFParams = KDFParams{
+	Algorithm: KDFAlgorithmArgon2id,
+	Time:      3,
+	Memory:    128 * 1024,
+	Threads:   4,
+	KeyLength: 32,
+}
+
 // CryptoService defines the interface for cryptographic operations
 type CryptoService interface {
 	// DeriveKey derives an encryption key from a master password and salt
-	DeriveKey(password string, salt []byte) ([]byte, error)
+	// using the given key derivation parameters
+	DeriveKey(password string, salt []byte, params KDFParams) ([]byte, error)
+
+	// KDFProfile returns the key derivation parameters for a named profile.
+	// An empty name selects the default profile.
+	KDFProfile(name string) (KDFParams, error)
 
 	// GenerateSalt creates a random salt for key derivation
 	GenerateSalt() ([]byte, error)
 
-	// Encrypt encrypts plaintext using AES-256-GCM
-	Encrypt(plaintext, key []byte) (nonce, ciphertext []byte, err error)
+	// Encrypt encrypts plaintext using AES-256-GCM. additionalData is
+	// authenticated but not encrypted, and may be nil.
+	Encrypt(plaintext, key, additionalData []byte) (nonce, ciphertext []byte, err error)
+
+	// Decrypt decrypts ciphertext using AES-256-GCM. It fails unless
+	// additionalData matches the one given to Encrypt.
+	Decrypt(nonce, ciphertext, key, additionalData []byte) ([]byte, error)
+
+	// GenerateDataKey creates a random key for encrypting a shared vault
+	GenerateDataKey() ([]byte, error)
+
+	// GenerateKeyPair creates a key pair that data keys can be sealed to
+	GenerateKeyPair() (publicKey, privateKey []byte, err error)
+
+	// SealKey encrypts key so that only the owner of publicKey can open it
+	SealKey(key, publicKey []byte) ([]byte, error)
+
+	// OpenKey decrypts a key sealed by SealKey
+	OpenKey(sealed, privateKey []byte) ([]byte, error)
+
+	// MAC authenticates message with a key derived from key
+	MAC(message, key []byte) ([]byte, error)
+
+	// VerifyMAC reports whether mac authenticates message under key
+	VerifyMAC(message, mac, key []byte) bool
+
+	// GenerateSigningKey creates a key pair for signing messages
+	GenerateSigningKey() (publicKey, privateKey []byte, err error)
+
+	// Sign signs message with a private key from GenerateSigningKey
+	Sign(message, privateKey []byte) ([]byte, error)
 
-	// Decrypt decrypts ciphertext using AES-256-GCM
-	Decrypt(nonce, ciphertext, key []byte) ([]byte, error)
+	// VerifySignature reports whether signature signs message under publicKey
+	VerifySignature(message, signature, publicKey []byte) bool
 }
@@ -6,21 +6,111 @@ var (
 	// ErrVaultNotFound indicates the requested vault does not exist
 	ErrVaultNotFound = errors.New("vault not found")
 
+	// ErrInvalidVaultName indicates a vault name that is empty, too long or
+	// contains characters that are not allowed in file names
+	ErrInvalidVaultName = errors.New("invalid vault name: use up to 64 letters, digits, '-', '_' or '.'")
+
 	// ErrVaultAlreadyExists indicates a vault with the given name already exists
 	ErrVaultAlreadyExists = errors.New("vault already exists")
 
 	// ErrInvalidMasterPassword indicates authentication failed
 	ErrInvalidMasterPassword = errors.New("invalid master password")
 
+	// ErrTwoFactorRequired indicates the vault needs a one-time code to unlock
+	ErrTwoFactorRequired = errors.New("one-time code required")
+
+	// ErrInvalidCredentials indicates a failed unlock of a vault with
+	// two-factor unlock. It does not tell whether the password or the code
+	// was wrong, so the password cannot be guessed without the second factor.
+	ErrInvalidCredentials = errors.New("invalid master password or one-time code")
+
+	// ErrInvalidTwoFactorCode indicates a wrong, expired or already used one-time code
+	ErrInvalidTwoFactorCode = errors.New("invalid one-time code")
+
+	// ErrTwoFactorEnabled indicates two-factor unlock is already set up for the vault
+	ErrTwoFactorEnabled = errors.New("two-factor authentication is already enabled")
+
+	// ErrTwoFactorNotEnabled indicates the vault does not use two-factor unlock
+	ErrTwoFactorNotEnabled = errors.New("two-factor authentication is not enabled")
+
+	// ErrTooManyAttempts indicates unlocking is delayed or locked out after failed attempts
+	ErrTooManyAttempts = errors.New("too many failed unlock attempts")
+
+	// ErrInvalidMemberName indicates a member name that is empty, too long or
+	// contains characters that are not allowed
+	ErrInvalidMemberName = errors.New("invalid member name: use up to 64 letters, digits, '-', '_', '.', '@' or '+'")
+
+	// ErrMemberNotFound indicates the vault has no member with the given name
+	ErrMemberNotFound = errors.New("vault member not found")
+
+	// ErrMemberAlreadyExists indicates the vault already has a member with the given name
+	ErrMemberAlreadyExists = errors.New("vault member already exists")
+
+	// ErrLastMember indicates the only member of a shared vault cannot be removed
+	ErrLastMember = errors.New("the last member of a vault cannot be removed")
+
+	// ErrLastOwner indicates a change that would leave a shared vault without an owner
+	ErrLastOwner = errors.New("a vault needs at least one owner")
+
+	// ErrUnknownRole indicates a member role that is not supported
+	ErrUnknownRole = errors.New("unknown role: use owner, editor or viewer")
+
+	// ErrPermissionDenied indicates the member's role does not allow the operation
+	ErrPermissionDenied = errors.New("permission denied: your role in this vault does not allow this")
+
+	// ErrVaultTampered indicates vault metadata that failed its integrity check
+	ErrVaultTampered = errors.New("vault metadata failed its integrity check")
+
+	// ErrUnsupportedVaultVersion indicates a vault file format this program cannot read
+	ErrUnsupportedVaultVersion = errors.New("unsupported vault format version: the vault may have been written by a newer version of the password manager")
+
+	// ErrAuditLogTampered indicates an audit log whose hash chain or MACs do not match
+	ErrAuditLogTampered = errors.New("audit log failed its integrity check")
+
 	// ErrRecordNotFound indicates the requested password record does not exist
 	ErrRecordNotFound = errors.New("password record not found")
 
 	// ErrRecordAlreadyExists indicates a record with the given name already exists
 	ErrRecordAlreadyExists = errors.New("password record already exists")
 
+	// ErrInvalidCustomField indicates a custom field without a name or with an unknown type
+	ErrInvalidCustomField = errors.New("invalid custom field: name is required and type must be text, hidden or totp")
+
+	// ErrHistoryVersionNotFound indicates the requested password history entry does not exist
+	ErrHistoryVersionNotFound = errors.New("password history version not found")
+
+	// ErrUnknownItemType indicates an item type that is not supported
+	ErrUnknownItemType = errors.New("unknown item type: use login, secure_note, card, ssh_key or api_credential")
+
+	// ErrInvalidItem indicates an item whose fields do not match the schema of its type
+	ErrInvalidItem = errors.New("invalid item: required fields are missing or do not match its type")
+
+	// ErrInvalidTOTP indicates a TOTP seed that is neither a base32 secret nor an otpauth://totp URI
+	ErrInvalidTOTP = errors.New("invalid TOTP secret: use a base32 secret or an otpauth://totp URI")
+
+	// ErrNoTOTP indicates a record without a TOTP secret
+	ErrNoTOTP = errors.New("password record has no TOTP secret")
+
+	// ErrPlaintextExportNotConfirmed indicates a plaintext export was requested without confirming it
+	ErrPlaintextExportNotConfirmed = errors.New("plaintext export must be confirmed: the file will contain every password unencrypted")
+
+	// ErrUnknownDuplicatePolicy indicates an import duplicate policy that is not supported
+	ErrUnknownDuplicatePolicy = errors.New("unknown duplicate policy: use skip, replace or rename")
+
 	// ErrEncryptionFailed indicates encryption operation failed
 	ErrEncryptionFailed = errors.New("encryption failed")
 
 	// ErrDecryptionFailed indicates decryption operation failed
 	ErrDecryptionFailed = errors.New("decryption failed")
+
+	// ErrUnknownKDFProfile indicates the requested key derivation profile does not exist
+	ErrUnknownKDFProfile = errors.New("unknown key derivation profile")
+
+	// ErrInvalidSession indicates the session token is missing, unknown or
+	// does not belong to the requested vault
+	ErrInvalidSession = errors.New("invalid or expired session")
+
+	// ErrVaultModified indicates the vault was changed by another writer
+	// and the change could not be applied on top of it
+	ErrVaultModified = errors.New("vault was modified concurrently")
 )
@@ -0,0 +1,170 @@
+package domain
+
+import (
+	"encoding/pem"
+	"strings"
+)
+
+// ItemType discriminates the kinds of items a vault can hold
+type ItemType string
+
+const (
+	// ItemTypeLogin is a username and password for a website or service
+	ItemTypeLogin ItemType = "login"
+	// ItemTypeSecureNote is free-form text kept in Notes
+	ItemTypeSecureNote ItemType = "secure_note"
+	// ItemTypeCard is a payment card
+	ItemTypeCard ItemType = "card"
+	// ItemTypeSSHKey is an SSH key pair
+	ItemTypeSSHKey ItemType = "ssh_key"
+	// ItemTypeAPICredential is an API key or token
+	ItemTypeAPICredential ItemType = "api_credential"
+)
+
+// ParseItemType converts a type name to an ItemType.
+// An empty name means ItemTypeLogin.
+func ParseItemType(name string) (ItemType, error) {
+	switch itemType := ItemType(name); itemType {
+	case "":
+		return ItemTypeLogin, nil
+	case ItemTypeLogin, ItemTypeSecureNote, ItemTypeCard, ItemTypeSSHKey, ItemTypeAPICredential:
+		return itemType, nil
+	default:
+		return "", ErrUnknownItemType
+	}
+}
+
+// CardData holds the fields of a card item
+type CardData struct {
+	Cardholder string `json:"cardholder,omitempty"`
+	Brand      string `json:"brand,omitempty"`
+	Number     string `json:"number"`
+	ExpMonth   int    `json:"exp_month,omitempty"`
+	ExpYear    int    `json:"exp_year,omitempty"`
+	CVV        string `json:"cvv,omitempty"`
+}
+
+// SSHKeyData holds the fields of an SSH key item
+type SSHKeyData struct {
+	PrivateKey  string `json:"private_key"`
+	PublicKey   string `json:"public_key,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Passphrase  string `json:"passphrase,omitempty"`
+}
+
+// APICredentialData holds the fields of an API credential item
+type APICredentialData struct {
+	KeyID    string `json:"key_id,omitempty"`
+	Secret   string `json:"secret"`
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// Kind returns the item type of the record. Records written before items
+// were typed have no type and are logins.
+func (r PasswordRecord) Kind() ItemType {
+	if r.Type == "" {
+		return ItemTypeLogin
+	}
+	return r.Type
+}
+
+// Validate checks the record against the schema of its type: the data for
+// its own type must be present and valid, and data belonging to other
+// types must be absent.
+func (r PasswordRecord) Validate() error {
+	if _, err := ParseItemType(string(r.Type)); err != nil {
+		return err
+	}
+	if err := ValidateCustomFields(r.CustomFields); err != nil {
+		return err
+	}
+
+	kind := r.Kind()
+	if (r.Card != nil) != (kind == ItemTypeCard) ||
+		(r.SSHKey != nil) != (kind == ItemTypeSSHKey) ||
+		(r.APICredential != nil) != (kind == ItemTypeAPICredential) {
+		return ErrInvalidItem
+	}
+	// Secrets of other types live in their own data, not in Password or TOTP
+	if kind != ItemTypeLogin && (r.Password != "" || r.TOTP != "") {
+		return ErrInvalidItem
+	}
+
+	switch kind {
+	case ItemTypeSecureNote:
+		if strings.TrimSpace(r.Notes) == "" || r.Username != "" {
+			return ErrInvalidItem
+		}
+	case ItemTypeCard:
+		if r.Username != "" || !r.Card.valid() {
+			return ErrInvalidItem
+		}
+	case ItemTypeSSHKey:
+		if block, _ := pem.Decode([]byte(r.SSHKey.PrivateKey)); block == nil {
+			return ErrInvalidItem
+		}
+	case ItemTypeAPICredential:
+		if r.Username != "" || r.APICredential.Secret == "" {
+			return ErrInvalidItem
+		}
+	}
+
+	return nil
+}
+
+// valid checks the card number with the Luhn algorithm and the optional
+// expiry and CVV for plausible values
+func (c *CardData) valid() bool {
+	number := CardDigits(c.Number)
+	if len(number) < 12 || len(number) > 19 || !luhn(number) {
+		return false
+	}
+	if c.ExpMonth < 0 || c.ExpMonth > 12 {
+		return false
+	}
+	if c.ExpYear != 0 && (c.ExpYear < 1000 || c.ExpYear > 9999) {
+		return false
+	}
+	if c.CVV != "" && (len(c.CVV) < 3 || len(c.CVV) > 4 || !allDigits(c.CVV)) {
+		return false
+	}
+	return true
+}
+
+// CardDigits strips the spaces and dashes commonly used to group card
+// numbers. Any other non-digit is kept so validation rejects it.
+func CardDigits(number string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(number)
+}
+
+// luhn reports whether a string of digits has a valid Luhn check digit
+func luhn(number string) bool {
+	if !allDigits(number) {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// allDigits reports whether s consists of ASCII digits only
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
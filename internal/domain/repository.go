@@ -7,6 +7,21 @@ type VaultRepository interface {
 	// Save persists vault metadata to disk
 	Save(ctx context.Context, name string, metadata *VaultMetadata) error
 
+	// Create persists the metadata of a new vault. It returns
+	// ErrVaultAlreadyExists, and leaves the existing vault alone, if one with
+	// that name exists, even if another process created it a moment ago.
+	Create(ctx context.Context, name string, metadata *VaultMetadata) error
+
+	// SaveIfUnchanged persists vault metadata only if the stored revision
+	// equals expectedRevision, and bumps the revision. It returns
+	// ErrVaultModified if another writer saved the vault in the meantime.
+	SaveIfUnchanged(ctx context.Context, name string, metadata *VaultMetadata, expectedRevision uint64) error
+
+	// RemoveBackup deletes the copy of the previous version kept by Save, if
+	// any. Call it after a save that replaces a key, so the old key does not
+	// keep opening the vault.
+	RemoveBackup(ctx context.Context, name string) error
+
 	// Load retrieves vault metadata from disk
 	Load(ctx context.Context, name string) (*VaultMetadata, error)
 
@@ -1,27 +1,306 @@
 package domain
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
-// PasswordRecord represents a single password entry in the vault
+// PasswordRecord represents a single password entry in the vault.
+// Fields added after the first release are optional in JSON, so records
+// from older vaults decode with them empty.
 type PasswordRecord struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Username  string    `json:"username"`
-	Password  string    `json:"password"`
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+
+	// TOTP is the two-factor seed of a login, as an otpauth:// URI or a
+	// base32 secret
+	TOTP string `json:"totp,omitempty"`
+
+	// Type selects the item schema; see Kind for records without one.
+	// Exactly the data for the record's own type is set.
+	Type          ItemType           `json:"type,omitempty"`
+	Card          *CardData          `json:"card,omitempty"`
+	SSHKey        *SSHKeyData        `json:"ssh_key,omitempty"`
+	APICredential *APICredentialData `json:"api_credential,omitempty"`
+
+	URLs         []string      `json:"urls,omitempty"`
+	Notes        string        `json:"notes,omitempty"`
+	Folder       string        `json:"folder,omitempty"`
+	Tags         []string      `json:"tags,omitempty"`
+	CustomFields []CustomField `json:"custom_fields,omitempty"`
+
+	// History holds previous passwords, newest first
+	History []PasswordHistoryEntry `json:"history,omitempty"`
+
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// CustomFieldType describes how a custom field value is used and displayed
+type CustomFieldType string
+
+const (
+	// CustomFieldText is a plain value shown as-is
+	CustomFieldText CustomFieldType = "text"
+	// CustomFieldHidden is a secret value masked until revealed
+	CustomFieldHidden CustomFieldType = "hidden"
+	// CustomFieldTOTP is a base32 TOTP seed
+	CustomFieldTOTP CustomFieldType = "totp"
+)
+
+// CustomField is a user-defined name/value pair on a record
+type CustomField struct {
+	Name  string          `json:"name"`
+	Value string          `json:"value"`
+	Type  CustomFieldType `json:"type"`
+}
+
+// IsSecret reports whether the field value should be treated like a password
+func (f CustomField) IsSecret() bool {
+	return f.Type == CustomFieldHidden || f.Type == CustomFieldTOTP
+}
+
+// ValidateCustomFields checks that every field has a name and a known type.
+// An empty type is accepted and means CustomFieldText.
+func ValidateCustomFields(fields []CustomField) error {
+	for _, field := range fields {
+		if strings.TrimSpace(field.Name) == "" {
+			return ErrInvalidCustomField
+		}
+		switch field.Type {
+		case "", CustomFieldText, CustomFieldHidden, CustomFieldTOTP:
+		default:
+			return ErrInvalidCustomField
+		}
+	}
+	return nil
+}
+
+// PasswordHistoryEntry is a password a record used before it was changed
+type PasswordHistoryEntry struct {
+	// Version numbers increase with every change and identify the entry
+	Version   int       `json:"version"`
+	Password  string    `json:"password"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
 // Vault represents the encrypted vault structure
 type Vault struct {
 	Name    string           `json:"name"`
 	Records []PasswordRecord `json:"records"`
+	// AuditLog is nil in vaults written before the audit log existed, until
+	// they are migrated
+	AuditLog *AuditLogKey `json:"audit_log,omitempty"`
 }
 
+// VaultFormatVersion is the format version of the vault files this program
+// writes. Older versions are migrated when a vault is unlocked.
+const VaultFormatVersion = "1.3"
+
 // VaultMetadata contains unencrypted vault information
 type VaultMetadata struct {
-	Version   string `json:"version"`
-	Salt      []byte `json:"salt"`
-	Nonce     []byte `json:"nonce"`
-	Encrypted []byte `json:"encrypted"`
+	// Version is the format version of the vault file
+	Version string `json:"version"`
+	// Revision increases with every save and detects concurrent writers
+	Revision uint64 `json:"revision"`
+	// Salt and KDF derive the key of a single-user vault from its master
+	// password. Shared vaults keep them per member instead.
+	Salt []byte `json:"salt,omitempty"`
+	// KDF is nil for vaults created before derivation parameters were stored
+	KDF       *KDFParams `json:"kdf,omitempty"`
+	Nonce     []byte     `json:"nonce"`
+	Encrypted []byte     `json:"encrypted"`
+
+	// TwoFactor is set when unlocking also requires a one-time code
+	TwoFactor *TwoFactorConfig `json:"two_factor,omitempty"`
+
+	// AuditLogKey is the public key of the vault's audit log, so failed
+	// unlocks can be logged without the vault key
+	AuditLogKey []byte `json:"audit_log_key,omitempty"`
+
+	// Members is set for shared vaults, whose records are encrypted with a
+	// random data key instead of a key derived from a master password
+	Members []VaultMember `json:"members,omitempty"`
+	// RosterKeys are the public keys the members have been signed with,
+	// oldest first. The private half of the last one is sealed to the
+	// owners only; each key after the first is signed by the one before.
+	RosterKeys []RosterKey `json:"roster_keys,omitempty"`
+	// MembersSignature signs the names, roles and public keys of Members
+	// with the last of RosterKeys, so only an owner can change them
+	MembersSignature []byte `json:"members_signature,omitempty"`
+	// MembersMAC is a MAC over the names, roles and public keys of Members
+	// with a key derived from the data key, which every member holds. Vaults
+	// before version 1.3 have it instead of MembersSignature.
+	MembersMAC []byte `json:"members_mac,omitempty"`
+}
+
+// RosterKey is a public key that signs the members of a shared vault. It is
+// replaced when an owner leaves, since every owner knows its private half.
+type RosterKey struct {
+	PublicKey []byte `json:"public_key"`
+	// Signature of PublicKey by the key before it; empty for the first key
+	Signature []byte `json:"signature,omitempty"`
+}
+
+// Shared reports whether the vault is encrypted with a data key held by members
+func (m *VaultMetadata) Shared() bool {
+	return len(m.Members) > 0
+}
+
+// Member returns the member called name, or nil
+func (m *VaultMetadata) Member(name string) *VaultMember {
+	for i := range m.Members {
+		if m.Members[i].Name == name {
+			return &m.Members[i]
+		}
+	}
+	return nil
+}
+
+// VaultMember is a person who opens a shared vault with their own password.
+// The key derived from that password decrypts the member's private key,
+// which in turn opens the vault data key sealed to the member.
+type VaultMember struct {
+	Name string     `json:"name"`
+	Role MemberRole `json:"role"`
+	Salt []byte     `json:"salt"`
+	KDF  KDFParams  `json:"kdf"`
+
+	PublicKey       []byte `json:"public_key"`
+	PrivateKeyNonce []byte `json:"private_key_nonce"`
+	// PrivateKey is encrypted with the key derived from the member's
+	// password. The first of the vault's RosterKeys is its additional data,
+	// so the member notices when other members replace them; members of
+	// vaults shared before version 1.3 have none until they change their
+	// password.
+	PrivateKey []byte `json:"private_key"`
+	// DataKey is the vault data key sealed to PublicKey
+	DataKey []byte `json:"data_key"`
+	// RosterKey is the private key that signs the members, sealed to
+	// PublicKey. Only owners have it.
+	RosterKey []byte `json:"roster_key,omitempty"`
+
+	AddedAt time.Time `json:"added_at"`
+}
+
+// MemberRole decides what a member of a shared vault may do
+type MemberRole string
+
+const (
+	// RoleOwner manages the members and keys of the vault and can do
+	// everything an editor can
+	RoleOwner MemberRole = "owner"
+	// RoleEditor adds, changes and deletes items
+	RoleEditor MemberRole = "editor"
+	// RoleViewer lists and reads items
+	RoleViewer MemberRole = "viewer"
+)
+
+// ParseMemberRole converts a role name to a MemberRole.
+// An empty name means RoleViewer.
+func ParseMemberRole(name string) (MemberRole, error) {
+	switch role := MemberRole(name); role {
+	case "":
+		return RoleViewer, nil
+	case RoleOwner, RoleEditor, RoleViewer:
+		return role, nil
+	default:
+		return "", ErrUnknownRole
+	}
+}
+
+// Allows reports whether r includes the permissions of required
+func (r MemberRole) Allows(required MemberRole) bool {
+	return r.rank() >= required.rank() && r.rank() > 0
+}
+
+// rank orders the roles by their permissions; unknown roles have none
+func (r MemberRole) rank() int {
+	switch r {
+	case RoleViewer:
+		return 1
+	case RoleEditor:
+		return 2
+	case RoleOwner:
+		return 3
+	}
+	return 0
+}
+
+// MaxMemberNameLength is the longest member name accepted
+const MaxMemberNameLength = 64
+
+// ValidateMemberName checks a member name. Names are 1-64 characters of
+// ASCII letters, digits, '-', '_', '.', '@' and '+' and start with a letter
+// or digit, so that an email address can be used.
+func ValidateMemberName(name string) error {
+	if name == "" || len(name) > MaxMemberNameLength {
+		return ErrInvalidMemberName
+	}
+
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.IndexByte("-_.@+", c) >= 0 && i > 0:
+		default:
+			return ErrInvalidMemberName
+		}
+	}
+	return nil
+}
+
+// TwoFactorConfig holds the TOTP key that guards a vault. The key is
+// encrypted with the vault key but stored apart from the records, so a code
+// can be checked before the vault itself is decrypted.
+type TwoFactorConfig struct {
+	Nonce []byte `json:"nonce"`
+	// Secret is the encrypted otpauth:// URI of the key
+	Secret []byte `json:"secret"`
+}
+
+// MaxVaultNameLength is the longest vault name accepted
+const MaxVaultNameLength = 64
+
+// reservedVaultNames are device names that cannot be used as file names on
+// Windows, even with an extension
+var reservedVaultNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// ValidateVaultName checks that name is safe to use as a vault file name.
+// Names are 1-64 characters of ASCII letters, digits, '-', '_' and '.',
+// must start with a letter or digit and must not end with a dot. This rules
+// out path separators, dot segments and hidden files.
+func ValidateVaultName(name string) error {
+	if name == "" || len(name) > MaxVaultNameLength {
+		return ErrInvalidVaultName
+	}
+
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case (c == '-' || c == '_' || c == '.') && i > 0:
+		default:
+			return ErrInvalidVaultName
+		}
+	}
+
+	if strings.HasSuffix(name, ".") || strings.Contains(name, "..") {
+		return ErrInvalidVaultName
+	}
+
+	base, _, _ := strings.Cut(name, ".")
+	if reservedVaultNames[strings.ToUpper(base)] {
+		return ErrInvalidVaultName
+	}
+
+	return nil
 }
@@ -0,0 +1,210 @@
+// Package generator creates random passwords and passphrases using
+// crypto/rand.
+package generator
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// Character classes used for passwords. The symbols match the web frontend.
+const (
+	lowercaseChars = "abcdefghijklmnopqrstuvwxyz"
+	uppercaseChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitChars     = "0123456789"
+	symbolChars    = "!@#$%^&*()_+-=[]{}|;:,.<>?"
+
+	// ambiguousChars are easily confused when read or typed by hand
+	ambiguousChars = "Il1|O0o"
+)
+
+// Password length limits
+const (
+	MinPasswordLength     = 4
+	MaxPasswordLength     = 128
+	DefaultPasswordLength = 20
+)
+
+// ErrInvalidPolicy indicates a policy that cannot produce a secret, such as
+// a length out of range or more required characters than the length allows
+var ErrInvalidPolicy = errors.New("invalid generator policy")
+
+// PasswordPolicy describes the password to generate. Every enabled class
+// appears at least once, or at least its Min* count when that is higher.
+type PasswordPolicy struct {
+	Length    int
+	Lowercase bool
+	Uppercase bool
+	Digits    bool
+	Symbols   bool
+	// ExcludeAmbiguous leaves out characters such as l, 1, O and 0
+	ExcludeAmbiguous bool
+
+	MinLowercase int
+	MinUppercase int
+	MinDigits    int
+	MinSymbols   int
+}
+
+// DefaultPasswordPolicy returns a 20 character policy using every class
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		Length:    DefaultPasswordLength,
+		Lowercase: true,
+		Uppercase: true,
+		Digits:    true,
+		Symbols:   true,
+	}
+}
+
+// charClass is an enabled character class and how many characters of it
+// the password must contain
+type charClass struct {
+	chars string
+	min   int
+}
+
+// classes returns the enabled character classes of the policy
+func (p PasswordPolicy) classes() ([]charClass, error) {
+	all := []struct {
+		enabled bool
+		chars   string
+		min     int
+	}{
+		{p.Lowercase, lowercaseChars, p.MinLowercase},
+		{p.Uppercase, uppercaseChars, p.MinUppercase},
+		{p.Digits, digitChars, p.MinDigits},
+		{p.Symbols, symbolChars, p.MinSymbols},
+	}
+
+	var classes []charClass
+	required := 0
+	for _, class := range all {
+		if class.min < 0 || (!class.enabled && class.min > 0) {
+			return nil, fmt.Errorf("%w: minimums must be zero or positive and only set for enabled classes", ErrInvalidPolicy)
+		}
+		if !class.enabled {
+			continue
+		}
+
+		chars := class.chars
+		if p.ExcludeAmbiguous {
+			chars = removeChars(chars, ambiguousChars)
+		}
+		classes = append(classes, charClass{chars: chars, min: max(class.min, 1)})
+		required += max(class.min, 1)
+	}
+
+	if p.Length < MinPasswordLength || p.Length > MaxPasswordLength {
+		return nil, fmt.Errorf("%w: length must be between %d and %d", ErrInvalidPolicy, MinPasswordLength, MaxPasswordLength)
+	}
+	if len(classes) == 0 {
+		return nil, fmt.Errorf("%w: at least one character class is required", ErrInvalidPolicy)
+	}
+	if required > p.Length {
+		return nil, fmt.Errorf("%w: the required characters do not fit in %d characters", ErrInvalidPolicy, p.Length)
+	}
+
+	return classes, nil
+}
+
+// Validate reports whether the policy can generate a password
+func (p PasswordPolicy) Validate() error {
+	_, err := p.classes()
+	return err
+}
+
+// Entropy returns the strength of passwords generated with the policy in
+// bits, ignoring the small loss from the required minimums
+func (p PasswordPolicy) Entropy() float64 {
+	classes, err := p.classes()
+	if err != nil {
+		return 0
+	}
+
+	size := 0
+	for _, class := range classes {
+		size += len(class.chars)
+	}
+	return float64(p.Length) * math.Log2(float64(size))
+}
+
+// Password generates a password that satisfies the policy
+func Password(policy PasswordPolicy) (string, error) {
+	classes, err := policy.classes()
+	if err != nil {
+		return "", err
+	}
+
+	var all strings.Builder
+	password := make([]byte, 0, policy.Length)
+	for _, class := range classes {
+		all.WriteString(class.chars)
+		for i := 0; i < class.min; i++ {
+			c, err := randomChar(class.chars)
+			if err != nil {
+				return "", err
+			}
+			password = append(password, c)
+		}
+	}
+
+	for len(password) < policy.Length {
+		c, err := randomChar(all.String())
+		if err != nil {
+			return "", err
+		}
+		password = append(password, c)
+	}
+
+	// Spread the required characters over the whole password
+	if err := shuffle(password); err != nil {
+		return "", err
+	}
+
+	return string(password), nil
+}
+
+// randomIndex returns a uniformly distributed integer in [0, n)
+func randomIndex(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read random data: %w", err)
+	}
+	return int(i.Int64()), nil
+}
+
+// randomChar picks a random byte of an ASCII character set
+func randomChar(chars string) (byte, error) {
+	i, err := randomIndex(len(chars))
+	if err != nil {
+		return 0, err
+	}
+	return chars[i], nil
+}
+
+// shuffle permutes b in place with the Fisher-Yates algorithm
+func shuffle(b []byte) error {
+	for i := len(b) - 1; i > 0; i-- {
+		j, err := randomIndex(i + 1)
+		if err != nil {
+			return err
+		}
+		b[i], b[j] = b[j], b[i]
+	}
+	return nil
+}
+
+// removeChars returns chars without any of the bytes in remove
+func removeChars(chars, remove string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(remove, r) {
+			return -1
+		}
+		return r
+	}, chars)
+}
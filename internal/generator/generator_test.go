@@ -0,0 +1,108 @@
+package generator
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func countIn(s, chars string) int {
+	n := 0
+	for _, r := range s {
+		if strings.ContainsRune(chars, r) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestPassword(t *testing.T) {
+	t.Run("default policy uses every class", func(t *testing.T) {
+		for i := 0; i < 50; i++ {
+			password, err := Password(DefaultPasswordPolicy())
+			if err != nil {
+				t.Fatalf("Password() failed: %v", err)
+			}
+			if len(password) != DefaultPasswordLength {
+				t.Fatalf("expected length %d, got %d", DefaultPasswordLength, len(password))
+			}
+			for _, chars := range []string{lowercaseChars, uppercaseChars, digitChars, symbolChars} {
+				if countIn(password, chars) == 0 {
+					t.Fatalf("password %q is missing a character from %q", password, chars)
+				}
+			}
+		}
+	})
+
+	t.Run("honors minimums", func(t *testing.T) {
+		policy := PasswordPolicy{Length: 12, Lowercase: true, Digits: true, MinDigits: 8}
+		for i := 0; i < 50; i++ {
+			password, err := Password(policy)
+			if err != nil {
+				t.Fatalf("Password() failed: %v", err)
+			}
+			if countIn(password, digitChars) < 8 {
+				t.Fatalf("password %q has fewer than 8 digits", password)
+			}
+			if countIn(password, uppercaseChars+symbolChars) != 0 {
+				t.Fatalf("password %q uses a disabled class", password)
+			}
+		}
+	})
+
+	t.Run("excludes ambiguous characters", func(t *testing.T) {
+		policy := DefaultPasswordPolicy()
+		policy.Length = MaxPasswordLength
+		policy.ExcludeAmbiguous = true
+		for i := 0; i < 20; i++ {
+			password, err := Password(policy)
+			if err != nil {
+				t.Fatalf("Password() failed: %v", err)
+			}
+			if countIn(password, ambiguousChars) != 0 {
+				t.Fatalf("password %q contains ambiguous characters", password)
+			}
+		}
+	})
+
+	t.Run("passwords differ", func(t *testing.T) {
+		seen := make(map[string]bool)
+		for i := 0; i < 100; i++ {
+			password, _ := Password(DefaultPasswordPolicy())
+			if seen[password] {
+				t.Fatalf("password %q generated twice", password)
+			}
+			seen[password] = true
+		}
+	})
+
+	t.Run("rejects invalid policies", func(t *testing.T) {
+		policies := map[string]PasswordPolicy{
+			"too short":         {Length: MinPasswordLength - 1, Lowercase: true},
+			"too long":          {Length: MaxPasswordLength + 1, Lowercase: true},
+			"no classes":        {Length: 16},
+			"min on disabled":   {Length: 16, Lowercase: true, MinDigits: 2},
+			"negative min":      {Length: 16, Lowercase: true, MinLowercase: -1},
+			"minimums too long": {Length: 8, Lowercase: true, Digits: true, MinLowercase: 4, MinDigits: 5},
+		}
+		for name, policy := range policies {
+			if _, err := Password(policy); !errors.Is(err, ErrInvalidPolicy) {
+				t.Errorf("%s: expected ErrInvalidPolicy, got %v", name, err)
+			}
+			if policy.Entropy() != 0 {
+				t.Errorf("%s: invalid policy should have no entropy", name)
+			}
+		}
+	})
+}
+
+func TestPasswordEntropy(t *testing.T) {
+	policy := PasswordPolicy{Length: 10, Digits: true}
+	if got := policy.Entropy(); got < 33.2 || got > 33.3 {
+		t.Errorf("expected about 33.2 bits for 10 digits, got %.2f", got)
+	}
+
+	if DefaultPasswordPolicy().Entropy() < 128 {
+		t.Errorf("default policy should exceed 128 bits, got %.2f", DefaultPasswordPolicy().Entropy())
+	}
+}
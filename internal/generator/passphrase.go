@@ -0,0 +1,101 @@
+package generator
+
+import (
+	_ "embed"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// Passphrase length limits in words
+const (
+	MinPassphraseWords     = 3
+	MaxPassphraseWords     = 20
+	DefaultPassphraseWords = 6
+)
+
+// maxSeparatorLength keeps separators to a few characters
+const maxSeparatorLength = 3
+
+//go:embed wordlist.txt
+var wordlistData string
+
+// wordlist holds the embedded list of short, common English words
+var wordlist = strings.Fields(wordlistData)
+
+// PassphrasePolicy describes a diceware-style passphrase of random words
+type PassphrasePolicy struct {
+	Words     int
+	Separator string
+	// Capitalize upper-cases the first letter of every word
+	Capitalize bool
+	// IncludeNumber appends a random digit to one of the words
+	IncludeNumber bool
+}
+
+// DefaultPassphrasePolicy returns a six word policy separated by dashes
+func DefaultPassphrasePolicy() PassphrasePolicy {
+	return PassphrasePolicy{
+		Words:     DefaultPassphraseWords,
+		Separator: "-",
+	}
+}
+
+// Validate reports whether the policy can generate a passphrase
+func (p PassphrasePolicy) Validate() error {
+	if p.Words < MinPassphraseWords || p.Words > MaxPassphraseWords {
+		return fmt.Errorf("%w: passphrases need between %d and %d words", ErrInvalidPolicy, MinPassphraseWords, MaxPassphraseWords)
+	}
+	if utf8.RuneCountInString(p.Separator) > maxSeparatorLength {
+		return fmt.Errorf("%w: the separator can be at most %d characters", ErrInvalidPolicy, maxSeparatorLength)
+	}
+	return nil
+}
+
+// Entropy returns the strength of passphrases generated with the policy in bits
+func (p PassphrasePolicy) Entropy() float64 {
+	if p.Validate() != nil {
+		return 0
+	}
+
+	bits := float64(p.Words) * math.Log2(float64(len(wordlist)))
+	if p.IncludeNumber {
+		bits += math.Log2(float64(p.Words * len(digitChars)))
+	}
+	return bits
+}
+
+// Passphrase generates a passphrase of words picked uniformly from the
+// embedded wordlist
+func Passphrase(policy PassphrasePolicy) (string, error) {
+	if err := policy.Validate(); err != nil {
+		return "", err
+	}
+
+	words := make([]string, policy.Words)
+	for i := range words {
+		n, err := randomIndex(len(wordlist))
+		if err != nil {
+			return "", err
+		}
+		words[i] = wordlist[n]
+		if policy.Capitalize {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+	}
+
+	if policy.IncludeNumber {
+		i, err := randomIndex(len(words))
+		if err != nil {
+			return "", err
+		}
+		digit, err := randomChar(digitChars)
+		if err != nil {
+			return "", err
+		}
+		words[i] += string(digit)
+	}
+
+	return strings.Join(words, policy.Separator), nil
+}
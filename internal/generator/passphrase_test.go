@@ -0,0 +1,89 @@
+package generator
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+func TestWordlist(t *testing.T) {
+	if len(wordlist) < 1296 {
+		t.Fatalf("wordlist has %d words, want at least 1296", len(wordlist))
+	}
+
+	valid := regexp.MustCompile(`^[a-z]{3,10}$`)
+	for i, word := range wordlist {
+		if !valid.MatchString(word) {
+			t.Errorf("invalid word %q", word)
+		}
+		if i > 0 && wordlist[i-1] >= word {
+			t.Errorf("wordlist is not sorted or has duplicates at %q", word)
+		}
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	t.Run("default policy", func(t *testing.T) {
+		passphrase, err := Passphrase(DefaultPassphrasePolicy())
+		if err != nil {
+			t.Fatalf("Passphrase() failed: %v", err)
+		}
+
+		words := strings.Split(passphrase, "-")
+		if len(words) != DefaultPassphraseWords {
+			t.Fatalf("expected %d words, got %q", DefaultPassphraseWords, passphrase)
+		}
+		for _, word := range words {
+			if _, found := slices.BinarySearch(wordlist, word); !found {
+				t.Errorf("word %q is not in the wordlist", word)
+			}
+		}
+	})
+
+	t.Run("capitalizes and adds a number", func(t *testing.T) {
+		policy := PassphrasePolicy{Words: 4, Separator: " ", Capitalize: true, IncludeNumber: true}
+		passphrase, err := Passphrase(policy)
+		if err != nil {
+			t.Fatalf("Passphrase() failed: %v", err)
+		}
+
+		words := strings.Split(passphrase, " ")
+		if len(words) != 4 {
+			t.Fatalf("expected 4 words, got %q", passphrase)
+		}
+		digits := 0
+		for _, word := range words {
+			if !unicode.IsUpper(rune(word[0])) {
+				t.Errorf("word %q is not capitalized", word)
+			}
+			if unicode.IsDigit(rune(word[len(word)-1])) {
+				digits++
+			}
+		}
+		if digits != 1 {
+			t.Errorf("expected one word with a digit, got %q", passphrase)
+		}
+	})
+
+	t.Run("rejects invalid policies", func(t *testing.T) {
+		policies := []PassphrasePolicy{
+			{Words: MinPassphraseWords - 1, Separator: "-"},
+			{Words: MaxPassphraseWords + 1, Separator: "-"},
+			{Words: 5, Separator: "----"},
+		}
+		for _, policy := range policies {
+			if _, err := Passphrase(policy); !errors.Is(err, ErrInvalidPolicy) {
+				t.Errorf("%+v: expected ErrInvalidPolicy, got %v", policy, err)
+			}
+		}
+	})
+
+	t.Run("entropy", func(t *testing.T) {
+		if bits := DefaultPassphrasePolicy().Entropy(); bits < 60 {
+			t.Errorf("six words should give at least 60 bits, got %.2f", bits)
+		}
+	})
+}
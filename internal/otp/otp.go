@@ -0,0 +1,318 @@
+// Package otp implements one-time passwords: HOTP (RFC 4226) and TOTP
+// (RFC 6238), with keys given as a base32 secret or an otpauth:// URI as
+// used in authenticator app QR codes.
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default key parameters, used by nearly every service
+const (
+	DefaultDigits = 6
+	DefaultPeriod = 30 * time.Second
+
+	// SecretSize is the length of generated secrets, the HMAC-SHA1 output
+	// size recommended by RFC 4226
+	SecretSize = 20
+)
+
+// ErrInvalidKey is returned for a secret or URI that cannot be used
+var ErrInvalidKey = errors.New("otp: invalid key")
+
+// Type distinguishes time-based from counter-based keys
+type Type string
+
+const (
+	// TypeTOTP keys derive the counter from the current time
+	TypeTOTP Type = "totp"
+	// TypeHOTP keys use an explicit counter
+	TypeHOTP Type = "hotp"
+)
+
+// Algorithm is the HMAC hash function of a key
+type Algorithm string
+
+const (
+	AlgorithmSHA1   Algorithm = "SHA1"
+	AlgorithmSHA256 Algorithm = "SHA256"
+	AlgorithmSHA512 Algorithm = "SHA512"
+)
+
+// hash returns the constructor for the algorithm
+func (a Algorithm) hash() (func() hash.Hash, error) {
+	switch a {
+	case AlgorithmSHA1:
+		return sha1.New, nil
+	case AlgorithmSHA256:
+		return sha256.New, nil
+	case AlgorithmSHA512:
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidKey, a)
+}
+
+// Key holds the parameters needed to generate codes
+type Key struct {
+	Type      Type
+	Secret    []byte
+	Algorithm Algorithm
+	Digits    int
+	// Period is the lifetime of a TOTP code
+	Period time.Duration
+	// Counter is the next HOTP counter value
+	Counter uint64
+	// Issuer and Account label the key in authenticator apps
+	Issuer  string
+	Account string
+}
+
+// Parse reads a key from an otpauth:// URI or a bare base32 secret. A bare
+// secret is a TOTP key with the default parameters.
+func Parse(s string) (*Key, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(strings.ToLower(s), "otpauth://") {
+		return parseURI(s)
+	}
+
+	secret, err := DecodeSecret(s)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{
+		Type:      TypeTOTP,
+		Secret:    secret,
+		Algorithm: AlgorithmSHA1,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+	}, nil
+}
+
+// parseURI reads a key in the Key Uri Format used by authenticator apps:
+// otpauth://TYPE/LABEL?secret=SECRET&issuer=ISSUER&algorithm=SHA1&digits=6&period=30
+func parseURI(s string) (*Key, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidKey, err)
+	}
+
+	key := &Key{
+		Type:      Type(strings.ToLower(u.Host)),
+		Algorithm: AlgorithmSHA1,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+	}
+	if key.Type != TypeTOTP && key.Type != TypeHOTP {
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidKey, u.Host)
+	}
+
+	// The label is "Issuer:Account" or just "Account"
+	label := strings.TrimPrefix(u.Path, "/")
+	if issuer, account, ok := strings.Cut(label, ":"); ok {
+		key.Issuer, key.Account = strings.TrimSpace(issuer), strings.TrimSpace(account)
+	} else {
+		key.Account = label
+	}
+
+	query := u.Query()
+	if key.Secret, err = DecodeSecret(query.Get("secret")); err != nil {
+		return nil, err
+	}
+	if issuer := query.Get("issuer"); issuer != "" {
+		key.Issuer = issuer
+	}
+	if algorithm := query.Get("algorithm"); algorithm != "" {
+		key.Algorithm = Algorithm(strings.ToUpper(algorithm))
+		if _, err := key.Algorithm.hash(); err != nil {
+			return nil, err
+		}
+	}
+	if digits := query.Get("digits"); digits != "" {
+		n, err := strconv.Atoi(digits)
+		if err != nil || n < 6 || n > 10 {
+			return nil, fmt.Errorf("%w: digits must be between 6 and 10", ErrInvalidKey)
+		}
+		key.Digits = n
+	}
+	if period := query.Get("period"); period != "" {
+		n, err := strconv.Atoi(period)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("%w: period must be a positive number of seconds", ErrInvalidKey)
+		}
+		key.Period = time.Duration(n) * time.Second
+	}
+	if counter := query.Get("counter"); counter != "" {
+		n, err := strconv.ParseUint(counter, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid counter", ErrInvalidKey)
+		}
+		key.Counter = n
+	} else if key.Type == TypeHOTP {
+		return nil, fmt.Errorf("%w: hotp keys need a counter", ErrInvalidKey)
+	}
+
+	return key, nil
+}
+
+// DecodeSecret decodes a base32 secret. Case, spaces, dashes and missing
+// padding are tolerated, as secrets are often typed in by hand.
+func DecodeSecret(s string) ([]byte, error) {
+	s = strings.ToUpper(strings.NewReplacer(" ", "", "-", "", "=", "").Replace(s))
+	if s == "" {
+		return nil, fmt.Errorf("%w: missing secret", ErrInvalidKey)
+	}
+
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+	if err != nil || len(secret) == 0 {
+		return nil, fmt.Errorf("%w: secret is not valid base32", ErrInvalidKey)
+	}
+	return secret, nil
+}
+
+// EncodeSecret returns secret as unpadded base32, the form expected by
+// authenticator apps
+func EncodeSecret(secret []byte) string {
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(secret)
+}
+
+// GenerateSecret returns a random TOTP key with the default parameters
+func GenerateSecret(issuer, account string) (*Key, error) {
+	secret := make([]byte, SecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return &Key{
+		Type:      TypeTOTP,
+		Secret:    secret,
+		Algorithm: AlgorithmSHA1,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+		Issuer:    issuer,
+		Account:   account,
+	}, nil
+}
+
+// URI returns the key in the otpauth:// format read by Parse, suitable for
+// a QR code
+func (k *Key) URI() string {
+	label := k.Account
+	if k.Issuer != "" {
+		label = k.Issuer + ":" + k.Account
+	}
+
+	query := url.Values{}
+	query.Set("secret", EncodeSecret(k.Secret))
+	if k.Issuer != "" {
+		query.Set("issuer", k.Issuer)
+	}
+	query.Set("algorithm", string(k.Algorithm))
+	query.Set("digits", strconv.Itoa(k.Digits))
+	if k.Type == TypeHOTP {
+		query.Set("counter", strconv.FormatUint(k.Counter, 10))
+	} else {
+		query.Set("period", strconv.Itoa(int(k.Period/time.Second)))
+	}
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     string(k.Type),
+		Path:     "/" + label,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// HOTP computes the code for counter as specified in RFC 4226
+func HOTP(secret []byte, counter uint64, digits int, algorithm Algorithm) (string, error) {
+	newHash, err := algorithm.hash()
+	if err != nil {
+		return "", err
+	}
+	if digits < 1 || digits > 10 {
+		return "", fmt.Errorf("%w: digits must be between 1 and 10", ErrInvalidKey)
+	}
+
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], counter)
+	mac := hmac.New(newHash, secret)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation: the low nibble of the last byte picks four bytes
+	offset := sum[len(sum)-1] & 0x0f
+	value := uint64(binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff)
+
+	modulus := uint64(1)
+	for range digits {
+		modulus *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%modulus), nil
+}
+
+// TOTP computes the code valid at t as specified in RFC 6238
+func TOTP(secret []byte, t time.Time, period time.Duration, digits int, algorithm Algorithm) (string, error) {
+	if period < time.Second {
+		return "", fmt.Errorf("%w: period must be at least a second", ErrInvalidKey)
+	}
+	return HOTP(secret, timeStep(t, period), digits, algorithm)
+}
+
+// Code returns the code of the key at t. For HOTP keys t is ignored and
+// the code for the current counter is returned.
+func (k *Key) Code(t time.Time) (string, error) {
+	if k.Type == TypeHOTP {
+		return HOTP(k.Secret, k.Counter, k.Digits, k.Algorithm)
+	}
+	return TOTP(k.Secret, t, k.Period, k.Digits, k.Algorithm)
+}
+
+// Verify checks a TOTP code entered at t, accepting codes from up to skew
+// periods before or after to allow for clock drift. It returns the time
+// step the code belongs to, so callers can refuse to accept a step twice.
+func (k *Key) Verify(code string, t time.Time, skew int) (uint64, bool) {
+	if k.Type != TypeTOTP || len(code) != k.Digits || k.Period < time.Second {
+		return 0, false
+	}
+
+	current := timeStep(t, k.Period)
+	for offset := -skew; offset <= skew; offset++ {
+		if offset < 0 && current < uint64(-offset) {
+			continue
+		}
+		step := current + uint64(offset)
+		expected, err := HOTP(k.Secret, step, k.Digits, k.Algorithm)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// Remaining returns how long the TOTP code at t stays valid
+func (k *Key) Remaining(t time.Time) time.Duration {
+	step := int64(k.Period / time.Second)
+	next := (t.Unix()/step + 1) * step
+	return time.Unix(next, 0).Sub(t)
+}
+
+// timeStep is the number of whole periods since the Unix epoch
+func timeStep(t time.Time, period time.Duration) uint64 {
+	return uint64(t.Unix() / int64(period/time.Second))
+}
@@ -0,0 +1,194 @@
+package otp
+
+import (
+	"bytes"
+	"encoding/base32"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHOTP(t *testing.T) {
+	// RFC 4226, Appendix D
+	secret := []byte("12345678901234567890")
+	expected := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+
+	for counter, want := range expected {
+		code, err := HOTP(secret, uint64(counter), 6, AlgorithmSHA1)
+		if err != nil {
+			t.Fatalf("HOTP() failed: %v", err)
+		}
+		if code != want {
+			t.Errorf("HOTP(counter=%d) = %s, want %s", counter, code, want)
+		}
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	// RFC 6238, Appendix B
+	secrets := map[Algorithm][]byte{
+		AlgorithmSHA1:   []byte("12345678901234567890"),
+		AlgorithmSHA256: []byte("12345678901234567890123456789012"),
+		AlgorithmSHA512: []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+	tests := []struct {
+		unix  int64
+		codes map[Algorithm]string
+	}{
+		{59, map[Algorithm]string{AlgorithmSHA1: "94287082", AlgorithmSHA256: "46119246", AlgorithmSHA512: "90693936"}},
+		{1111111109, map[Algorithm]string{AlgorithmSHA1: "07081804", AlgorithmSHA256: "68084774", AlgorithmSHA512: "25091201"}},
+		{1111111111, map[Algorithm]string{AlgorithmSHA1: "14050471", AlgorithmSHA256: "67062674", AlgorithmSHA512: "99943326"}},
+		{1234567890, map[Algorithm]string{AlgorithmSHA1: "89005924", AlgorithmSHA256: "91819424", AlgorithmSHA512: "93441116"}},
+		{2000000000, map[Algorithm]string{AlgorithmSHA1: "69279037", AlgorithmSHA256: "90698825", AlgorithmSHA512: "38618901"}},
+		{20000000000, map[Algorithm]string{AlgorithmSHA1: "65353130", AlgorithmSHA256: "77737706", AlgorithmSHA512: "47863826"}},
+	}
+
+	for _, tt := range tests {
+		for algorithm, want := range tt.codes {
+			code, err := TOTP(secrets[algorithm], time.Unix(tt.unix, 0), DefaultPeriod, 8, algorithm)
+			if err != nil {
+				t.Fatalf("TOTP() failed: %v", err)
+			}
+			if code != want {
+				t.Errorf("TOTP(%s, %d) = %s, want %s", algorithm, tt.unix, code, want)
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	t.Run("bare secret", func(t *testing.T) {
+		// Lowercase, grouped and unpadded secrets are accepted
+		key, err := Parse("gezd gnbv gy3t qojq gezd gnbv gy3t qojq")
+		if err != nil {
+			t.Fatalf("Parse() failed: %v", err)
+		}
+		if key.Type != TypeTOTP || key.Digits != DefaultDigits || key.Period != DefaultPeriod || key.Algorithm != AlgorithmSHA1 {
+			t.Errorf("unexpected defaults %+v", key)
+		}
+		if string(key.Secret) != "12345678901234567890" {
+			t.Errorf("unexpected secret %q", key.Secret)
+		}
+	})
+
+	t.Run("otpauth uri", func(t *testing.T) {
+		key, err := Parse("otpauth://totp/Example:alice@example.com?secret=" + secret + "&algorithm=SHA256&digits=8&period=60")
+		if err != nil {
+			t.Fatalf("Parse() failed: %v", err)
+		}
+		if key.Issuer != "Example" || key.Account != "alice@example.com" {
+			t.Errorf("unexpected label %q / %q", key.Issuer, key.Account)
+		}
+		if key.Algorithm != AlgorithmSHA256 || key.Digits != 8 || key.Period != time.Minute {
+			t.Errorf("unexpected parameters %+v", key)
+		}
+	})
+
+	t.Run("issuer parameter wins over label", func(t *testing.T) {
+		key, err := Parse("otpauth://totp/Old:alice?secret=" + secret + "&issuer=New")
+		if err != nil {
+			t.Fatalf("Parse() failed: %v", err)
+		}
+		if key.Issuer != "New" {
+			t.Errorf("expected issuer New, got %q", key.Issuer)
+		}
+	})
+
+	t.Run("hotp uri", func(t *testing.T) {
+		key, err := Parse("otpauth://hotp/alice?secret=" + secret + "&counter=3")
+		if err != nil {
+			t.Fatalf("Parse() failed: %v", err)
+		}
+		code, _ := key.Code(time.Now())
+		if key.Type != TypeHOTP || code != "969429" {
+			t.Errorf("unexpected hotp key %+v with code %s", key, code)
+		}
+	})
+
+	for name, input := range map[string]string{
+		"empty":              "",
+		"not base32":         "not a secret!",
+		"unknown type":       "otpauth://motp/alice?secret=" + secret,
+		"missing secret":     "otpauth://totp/alice",
+		"bad algorithm":      "otpauth://totp/alice?secret=" + secret + "&algorithm=MD5",
+		"bad digits":         "otpauth://totp/alice?secret=" + secret + "&digits=4",
+		"bad period":         "otpauth://totp/alice?secret=" + secret + "&period=0",
+		"hotp without count": "otpauth://hotp/alice?secret=" + secret,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Parse(input); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("Parse(%q): expected ErrInvalidKey, got %v", input, err)
+			}
+		})
+	}
+}
+
+func TestKeyRemaining(t *testing.T) {
+	key := &Key{Type: TypeTOTP, Period: DefaultPeriod}
+
+	tests := []struct {
+		unix int64
+		want time.Duration
+	}{
+		{0, 30 * time.Second},
+		{59, time.Second},
+		{61, 29 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := key.Remaining(time.Unix(tt.unix, 0)); got != tt.want {
+			t.Errorf("Remaining(%d) = %v, want %v", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestKeyURIRoundTrip(t *testing.T) {
+	key, err := GenerateSecret("Go Password Manager", "personal vault")
+	if err != nil {
+		t.Fatalf("GenerateSecret failed: %v", err)
+	}
+	if len(key.Secret) != SecretSize {
+		t.Fatalf("expected %d byte secret, got %d", SecretSize, len(key.Secret))
+	}
+
+	parsed, err := Parse(key.URI())
+	if err != nil {
+		t.Fatalf("Parse(%q) failed: %v", key.URI(), err)
+	}
+	if !bytes.Equal(parsed.Secret, key.Secret) || parsed.Issuer != key.Issuer || parsed.Account != key.Account ||
+		parsed.Period != key.Period || parsed.Digits != key.Digits || parsed.Algorithm != key.Algorithm {
+		t.Errorf("round trip changed the key: %+v != %+v", parsed, key)
+	}
+}
+
+func TestKeyVerify(t *testing.T) {
+	key := &Key{
+		Type:      TypeTOTP,
+		Secret:    []byte("12345678901234567890"),
+		Algorithm: AlgorithmSHA1,
+		Digits:    8,
+		Period:    DefaultPeriod,
+	}
+	at := time.Unix(59, 0)
+
+	// RFC 6238 vector for T=59 is 94287082, time step 1
+	step, ok := key.Verify("94287082", at, 1)
+	if !ok || step != 1 {
+		t.Fatalf("Verify = %d, %v; want 1, true", step, ok)
+	}
+
+	// One period later the code is still accepted, two periods later not
+	if _, ok := key.Verify("94287082", at.Add(DefaultPeriod), 1); !ok {
+		t.Error("expected code from the previous period to be accepted")
+	}
+	if _, ok := key.Verify("94287082", at.Add(2*DefaultPeriod), 1); ok {
+		t.Error("expected code from two periods ago to be rejected")
+	}
+
+	for _, code := range []string{"", "9428708", "94287083", "abcdefgh"} {
+		if _, ok := key.Verify(code, at, 1); ok {
+			t.Errorf("Verify(%q) unexpectedly succeeded", code)
+		}
+	}
+}
@@ -0,0 +1,479 @@
+package strength
+
+import (
+	_ "embed"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// pattern names the kind of weakness a match represents
+type pattern int
+
+const (
+	patternBruteforce pattern = iota
+	patternDictionary
+	patternSequence
+	patternRepeat
+	patternKeyboard
+	patternYear
+	patternDate
+)
+
+// match is a substring password[i:j+1] that fits a guessable pattern
+type match struct {
+	pattern pattern
+	i, j    int
+	token   string
+	guesses float64
+
+	// Dictionary details, used for feedback
+	dictionary string
+	rank       int
+	reversed   bool
+	l33t       bool
+}
+
+// Dictionaries, ordered by how common their entries are
+var (
+	//go:embed passwords.txt
+	passwordsData string
+	//go:embed english.txt
+	englishData string
+
+	dictionaries = []struct {
+		name  string
+		ranks map[string]int
+	}{
+		{"passwords", rankedDictionary(passwordsData)},
+		{"english", rankedDictionary(englishData)},
+	}
+)
+
+// rankedDictionary maps each word to its 1-based position in the list
+func rankedDictionary(data string) map[string]int {
+	ranks := make(map[string]int)
+	for i, word := range strings.Fields(data) {
+		if _, ok := ranks[word]; !ok {
+			ranks[word] = i + 1
+		}
+	}
+	return ranks
+}
+
+// keyboardRows are runs of adjacent keys on a QWERTY keyboard
+var keyboardRows = []string{
+	"`1234567890-=",
+	"qwertyuiop[]\\",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+	"1qaz2wsx3edc4rfv5tgb6yhn7ujm8ik,9ol.0p;/",
+	"789456123",
+	"147258369",
+}
+
+// keyboardStarts approximates the number of keys a keyboard pattern can start on
+const keyboardStarts = 94
+
+// Minimum guesses for a match, so patterns never look stronger than a
+// few characters of brute force
+const (
+	minSingleCharGuesses = 10
+	minMultiCharGuesses  = 50
+)
+
+// findMatches returns every pattern match in password
+func findMatches(password string) []match {
+	var matches []match
+	matches = append(matches, dictionaryMatches(password)...)
+	matches = append(matches, sequenceMatches(password)...)
+	matches = append(matches, repeatMatches(password)...)
+	matches = append(matches, keyboardMatches(password)...)
+	matches = append(matches, dateMatches(password)...)
+	return matches
+}
+
+// dictionaryMatches finds words from the built-in dictionaries
+func dictionaryMatches(password string) []match {
+	var matches []match
+	for _, dictionary := range dictionaries {
+		matches = append(matches, wordMatches(password, dictionary.name, dictionary.ranks)...)
+	}
+	return matches
+}
+
+// wordMatches finds words from a ranked dictionary, including reversed
+// words and words written with l33t substitutions
+func wordMatches(password, dictionary string, ranks map[string]int) []match {
+	runes := []rune(password)
+	lower := []rune(strings.ToLower(password))
+
+	var matches []match
+	for i := 0; i < len(lower); i++ {
+		for j := i + 2; j < len(lower); j++ {
+			token := string(runes[i : j+1])
+			word := string(lower[i : j+1])
+
+			for _, candidate := range []struct {
+				word     string
+				reversed bool
+				l33t     bool
+			}{
+				{word, false, false},
+				{reverse(word), true, false},
+				{unl33t(word), false, true},
+			} {
+				if candidate.word == word && (candidate.reversed || candidate.l33t) {
+					continue
+				}
+				rank, ok := ranks[candidate.word]
+				if !ok {
+					continue
+				}
+				guesses := float64(rank) * uppercaseVariations(token)
+				if candidate.reversed {
+					guesses *= 2
+				}
+				if candidate.l33t {
+					guesses *= l33tVariations(word)
+				}
+				matches = append(matches, match{
+					pattern:    patternDictionary,
+					i:          i,
+					j:          j,
+					token:      token,
+					guesses:    guesses,
+					dictionary: dictionary,
+					rank:       rank,
+					reversed:   candidate.reversed,
+					l33t:       candidate.l33t,
+				})
+			}
+		}
+	}
+	return matches
+}
+
+// unl33tTable maps each substitute back to the letter it most often
+// stands for
+var unl33tTable = map[rune]rune{
+	'4': 'a', '@': 'a', '8': 'b', '(': 'c', '{': 'c', '[': 'c', '<': 'c',
+	'3': 'e', '6': 'g', '9': 'g', '1': 'i', '!': 'i', '|': 'i', '0': 'o',
+	'$': 's', '5': 's', '+': 't', '7': 't', '%': 'x', '2': 'z',
+}
+
+// unl33t undoes common substitutions
+func unl33t(word string) string {
+	return strings.Map(func(r rune) rune {
+		if letter, ok := unl33tTable[r]; ok {
+			return letter
+		}
+		return r
+	}, word)
+}
+
+// l33tVariations estimates how many substitution choices an attacker tries
+func l33tVariations(word string) float64 {
+	variations := 1.0
+	for _, r := range word {
+		if _, ok := unl33tTable[r]; ok {
+			variations *= 2
+		}
+	}
+	return variations
+}
+
+// uppercaseVariations estimates how many capitalizations an attacker tries
+func uppercaseVariations(token string) float64 {
+	upper, lower := 0, 0
+	for _, r := range token {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+	if upper == 0 {
+		return 1
+	}
+	if lower == 0 {
+		return 2
+	}
+
+	runes := []rune(token)
+	first, last := unicode.IsUpper(runes[0]), unicode.IsUpper(runes[len(runes)-1])
+	if upper == 1 && (first || last) {
+		return 2
+	}
+
+	variations := 0.0
+	for k := 1; k <= min(upper, lower); k++ {
+		variations += binomial(upper+lower, k)
+	}
+	return max(variations, 1)
+}
+
+// sequenceMatches finds runs such as "abcd", "7654" or "acegi" with a
+// constant step between characters
+func sequenceMatches(password string) []match {
+	runes := []rune(password)
+	var matches []match
+
+	for i := 0; i+2 < len(runes); {
+		step := runes[i+1] - runes[i]
+		j := i + 1
+		for j+1 < len(runes) && runes[j+1]-runes[j] == step {
+			j++
+		}
+
+		if j-i >= 2 && step != 0 && abs(int(step)) <= 5 && sameClass(runes[i:j+1]) {
+			token := string(runes[i : j+1])
+			matches = append(matches, match{
+				pattern: patternSequence,
+				i:       i,
+				j:       j,
+				token:   token,
+				guesses: sequenceGuesses(runes[i], j-i+1, step < 0),
+			})
+		}
+
+		if j == i+1 {
+			i++
+		} else {
+			i = j
+		}
+	}
+	return matches
+}
+
+// sequenceGuesses follows zxcvbn: obvious starting points are tried first
+func sequenceGuesses(start rune, length int, descending bool) float64 {
+	var base float64
+	switch {
+	case strings.ContainsRune("aAzZ019", start):
+		base = 4
+	case unicode.IsDigit(start):
+		base = 10
+	default:
+		base = 26
+	}
+	if descending {
+		base *= 2
+	}
+	return base * float64(length)
+}
+
+// sameClass reports whether runes are all digits, all lowercase or all uppercase
+func sameClass(runes []rune) bool {
+	for _, class := range []func(rune) bool{unicode.IsDigit, unicode.IsLower, unicode.IsUpper} {
+		all := true
+		for _, r := range runes {
+			if !class(r) {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true
+		}
+	}
+	return false
+}
+
+// repeatMatches finds a substring repeated back to back, such as "aaaa"
+// or "abcabcabc"
+func repeatMatches(password string) []match {
+	runes := []rune(password)
+	var matches []match
+
+	for i := 0; i < len(runes); {
+		best := match{}
+		for unit := 1; i+2*unit <= len(runes); unit++ {
+			base := runes[i : i+unit]
+			count := 1
+			for i+(count+1)*unit <= len(runes) && string(runes[i+count*unit:i+(count+1)*unit]) == string(base) {
+				count++
+			}
+			if count < 2 || count*unit <= best.j-best.i+1 {
+				continue
+			}
+
+			baseGuesses := Estimate(string(base)).Guesses
+			best = match{
+				pattern: patternRepeat,
+				i:       i,
+				j:       i + count*unit - 1,
+				token:   string(runes[i : i+count*unit]),
+				guesses: baseGuesses * float64(count),
+			}
+		}
+
+		if best.token != "" && len([]rune(best.token)) >= 3 {
+			matches = append(matches, best)
+			i = best.j + 1
+		} else {
+			i++
+		}
+	}
+	return matches
+}
+
+// keyboardMatches finds four or more adjacent keys typed in a row, in
+// either direction
+func keyboardMatches(password string) []match {
+	lower := []rune(strings.ToLower(password))
+	var matches []match
+
+	for _, row := range keyboardRows {
+		for _, layout := range []string{row, reverse(row)} {
+			for i := 0; i < len(lower); i++ {
+				start := strings.IndexRune(layout, lower[i])
+				if start < 0 {
+					continue
+				}
+				j := i
+				for j+1 < len(lower) && start+(j+1-i) < len(layout) && rune(layout[start+j+1-i]) == lower[j+1] {
+					j++
+				}
+				if j-i >= 3 {
+					length := j - i + 1
+					matches = append(matches, match{
+						pattern: patternKeyboard,
+						i:       i,
+						j:       j,
+						token:   string([]rune(password)[i : j+1]),
+						guesses: keyboardStarts * float64(length) * 2,
+					})
+					i = j
+				}
+			}
+		}
+	}
+	return matches
+}
+
+// Year and date patterns. Years from 1900 to 2099 are recognised.
+var (
+	yearPattern = regexp.MustCompile(`19\d\d|20\d\d`)
+	datePattern = regexp.MustCompile(`\d{1,4}[-/. _]?\d{1,2}[-/. _]?\d{2,4}`)
+)
+
+// minYearSpace is the smallest range of years an attacker is assumed to try
+const minYearSpace = 20
+
+// dateMatches finds years and dates such as 1987, 13-04-1990 or 19900413
+func dateMatches(password string) []match {
+	var matches []match
+
+	for _, loc := range yearPattern.FindAllStringIndex(password, -1) {
+		year, _ := strconv.Atoi(password[loc[0]:loc[1]])
+		matches = append(matches, match{
+			pattern: patternYear,
+			i:       runeIndex(password, loc[0]),
+			j:       runeIndex(password, loc[1]) - 1,
+			token:   password[loc[0]:loc[1]],
+			guesses: yearSpace(year),
+		})
+	}
+
+	for _, loc := range datePattern.FindAllStringIndex(password, -1) {
+		token := password[loc[0]:loc[1]]
+		year, ok := parseDate(token)
+		if !ok {
+			continue
+		}
+		matches = append(matches, match{
+			pattern: patternDate,
+			i:       runeIndex(password, loc[0]),
+			j:       runeIndex(password, loc[1]) - 1,
+			token:   token,
+			guesses: 365 * yearSpace(year),
+		})
+	}
+	return matches
+}
+
+// parseDate reports whether token reads as a day, month and year in any
+// common order and returns the year
+func parseDate(token string) (int, bool) {
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, token)
+	if len(digits) < 4 || len(digits) > 8 {
+		return 0, false
+	}
+
+	// Try every split into three numbers
+	for a := 1; a < len(digits)-1; a++ {
+		for b := a + 1; b < len(digits); b++ {
+			parts := []string{digits[:a], digits[a:b], digits[b:]}
+			for _, order := range [][3]int{{0, 1, 2}, {1, 0, 2}, {2, 1, 0}, {2, 0, 1}} {
+				day, _ := strconv.Atoi(parts[order[0]])
+				month, _ := strconv.Atoi(parts[order[1]])
+				yearPart := parts[order[2]]
+				year, _ := strconv.Atoi(yearPart)
+				if len(yearPart) == 2 {
+					year += 1900
+					if year < 1950 {
+						year += 100
+					}
+				} else if len(yearPart) != 4 {
+					continue
+				}
+				if day >= 1 && day <= 31 && month >= 1 && month <= 12 && year >= 1900 && year <= 2099 {
+					return year, true
+				}
+			}
+		}
+	}
+	return 0, false
+}
+
+// yearSpace is the number of years an attacker tries to reach year
+func yearSpace(year int) float64 {
+	return math.Max(math.Abs(float64(year-time.Now().Year())), minYearSpace)
+}
+
+// bruteforceGuesses is the cost of guessing a token character by character
+func bruteforceGuesses(length int) float64 {
+	guesses := math.Pow(10, float64(length))
+	if length == 1 {
+		return max(guesses+1, minSingleCharGuesses+1)
+	}
+	return max(guesses, minMultiCharGuesses+1)
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+func binomial(n, k int) float64 {
+	result := 1.0
+	for i := 1; i <= k; i++ {
+		result = result * float64(n-k+i) / float64(i)
+	}
+	return result
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// runeIndex converts a byte offset in s to a rune offset
+func runeIndex(s string, byteOffset int) int {
+	return len([]rune(s[:byteOffset]))
+}
@@ -0,0 +1,254 @@
+// Package strength estimates how hard a password is to guess.
+//
+// The estimator follows the approach of Dropbox's zxcvbn: the password is
+// split into the cheapest sequence of guessable patterns (common passwords,
+// dictionary words, sequences, repeats, keyboard rows and dates), with any
+// remaining characters charged as brute force. The result is an estimated
+// number of guesses rather than a character-class checklist, so "P@ssw0rd1"
+// is rated weak while a long random passphrase is rated strong.
+package strength
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// scoreThresholds are the guess counts, from zxcvbn, that a password must
+// exceed for each score above 0. A score of 3 or more resists online
+// attacks and most offline attacks against a slow hash.
+var scoreThresholds = []float64{1e3, 1e6, 1e8, 1e10}
+
+const (
+	// scoreDelta keeps passwords right at a threshold in the lower score
+	scoreDelta = 5
+	// maxPasswordLength bounds the work done on very long inputs
+	maxPasswordLength = 100
+	// minGuessesBeforeGrow penalises splitting a password into more matches
+	minGuessesBeforeGrow = 10000
+)
+
+// MaxScore is the best possible score
+const MaxScore = 4
+
+// Result is the outcome of estimating a password's strength
+type Result struct {
+	// Guesses is the estimated number of attempts needed to find the password
+	Guesses float64
+	// Entropy is log2(Guesses), in bits
+	Entropy float64
+	// Score runs from 0 (too guessable) to 4 (very unguessable)
+	Score int
+	// Warning explains the main weakness, if any
+	Warning string
+}
+
+// Label describes the score in words
+func (r Result) Label() string {
+	return ScoreLabel(r.Score)
+}
+
+// ScoreLabel describes a score in words
+func ScoreLabel(score int) string {
+	switch score {
+	case 0:
+		return "very weak"
+	case 1:
+		return "weak"
+	case 2:
+		return "fair"
+	case 3:
+		return "strong"
+	default:
+		return "very strong"
+	}
+}
+
+// Estimate rates password. User inputs such as the record name or username
+// are treated as a dictionary, since a password built from them is weak.
+// Only the first 100 characters are considered.
+func Estimate(password string, userInputs ...string) Result {
+	runes := []rune(password)
+	if len(runes) > maxPasswordLength {
+		runes = runes[:maxPasswordLength]
+		password = string(runes)
+	}
+	if len(runes) == 0 {
+		return Result{Guesses: 1, Warning: "Password is empty"}
+	}
+
+	matches := findMatches(password)
+	matches = append(matches, userInputMatches(password, userInputs)...)
+	guesses, sequence := cheapestSequence(len(runes), matches)
+
+	result := Result{
+		Guesses: guesses,
+		Entropy: math.Log2(guesses),
+		Score:   score(guesses),
+	}
+	if result.Score <= 2 {
+		result.Warning = warning(sequence, len(sequence) == 1)
+	}
+	return result
+}
+
+func score(guesses float64) int {
+	for score, threshold := range scoreThresholds {
+		if guesses < threshold+scoreDelta {
+			return score
+		}
+	}
+	return MaxScore
+}
+
+// userInputMatches treats the user inputs as a small ranked dictionary
+func userInputMatches(password string, userInputs []string) []match {
+	if len(userInputs) == 0 {
+		return nil
+	}
+
+	ranks := make(map[string]int)
+	for _, input := range userInputs {
+		for _, word := range strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+			return strings.ContainsRune(" @._-/:", r)
+		}) {
+			if _, ok := ranks[word]; !ok && len(word) >= 3 {
+				ranks[word] = len(ranks) + 1
+			}
+		}
+	}
+	return wordMatches(password, "user_inputs", ranks)
+}
+
+// cheapestSequence finds the sequence of non-overlapping matches covering
+// the password with the fewest total guesses. As in zxcvbn, a sequence of
+// l matches costs l! * product(guesses) + 10000^(l-1), which favours fewer,
+// longer matches over many short ones.
+func cheapestSequence(n int, matches []match) (float64, []match) {
+	byEnd := make([][]match, n)
+	for _, m := range matches {
+		byEnd[m.j] = append(byEnd[m.j], m)
+	}
+
+	// best[k][l] is the cheapest sequence of l matches covering password[0:k+1]
+	type state struct {
+		product float64
+		total   float64
+		last    match
+	}
+	best := make([]map[int]state, n)
+	for k := range best {
+		best[k] = make(map[int]state)
+	}
+
+	update := func(m match, length int, product float64) {
+		total := factorial(length)*product + math.Pow(minGuessesBeforeGrow, float64(length-1))
+		for l, other := range best[m.j] {
+			if l <= length && other.total <= total {
+				return
+			}
+		}
+		best[m.j][length] = state{product: product, total: total, last: m}
+	}
+
+	extend := func(m match) {
+		if m.i == 0 {
+			update(m, 1, m.guesses)
+			return
+		}
+		for l, prev := range best[m.i-1] {
+			// Adjacent brute force segments are always better merged
+			if m.pattern == patternBruteforce && prev.last.pattern == patternBruteforce {
+				continue
+			}
+			update(m, l+1, prev.product*m.guesses)
+		}
+	}
+
+	for k := 0; k < n; k++ {
+		for _, m := range byEnd[k] {
+			extend(m)
+		}
+		for i := 0; i <= k; i++ {
+			extend(match{pattern: patternBruteforce, i: i, j: k, guesses: bruteforceGuesses(k - i + 1)})
+		}
+	}
+
+	length, cheapest := 0, math.Inf(1)
+	for l, s := range best[n-1] {
+		if s.total < cheapest || s.total == cheapest && l < length {
+			length, cheapest = l, s.total
+		}
+	}
+
+	sequence := make([]match, length)
+	for k, l := n-1, length; l > 0; l-- {
+		m := best[k][l].last
+		sequence[l-1] = m
+		k = m.i - 1
+	}
+	return cheapest, sequence
+}
+
+// warning explains the weakest part of the password, taken from its
+// longest match
+func warning(sequence []match, sole bool) string {
+	var longest match
+	for _, m := range sequence {
+		if m.j-m.i > longest.j-longest.i || longest.token == "" && m.pattern != patternBruteforce {
+			longest = m
+		}
+	}
+
+	switch longest.pattern {
+	case patternDictionary:
+		return dictionaryWarning(longest, sole)
+	case patternRepeat:
+		if strings.Trim(longest.token, longest.token[:1]) == "" {
+			return `Repeats like "aaa" are easy to guess`
+		}
+		return `Repeats like "abcabcabc" are only slightly harder to guess than "abc"`
+	case patternSequence:
+		return "Sequences like abc or 6543 are easy to guess"
+	case patternKeyboard:
+		return "Straight rows of keys are easy to guess"
+	case patternYear:
+		return "Recent years are easy to guess"
+	case patternDate:
+		return "Dates are often easy to guess"
+	}
+	if sole {
+		return ""
+	}
+	return "Add another word or two. Uncommon words are better"
+}
+
+func dictionaryWarning(m match, sole bool) string {
+	switch m.dictionary {
+	case "passwords":
+		switch {
+		case !sole || m.l33t || m.reversed:
+			return "This is similar to a commonly used password"
+		case m.rank <= 10:
+			return "This is a top-10 common password"
+		case m.rank <= 100:
+			return "This is a top-100 common password"
+		default:
+			return "This is a very common password"
+		}
+	case "user_inputs":
+		return "Avoid using names or usernames from the record"
+	}
+	if sole {
+		return "A word by itself is easy to guess"
+	}
+	return fmt.Sprintf("Common words like %q are easy to guess", strings.ToLower(m.token))
+}
+
+func factorial(n int) float64 {
+	result := 1.0
+	for i := 2; i <= n; i++ {
+		result *= float64(i)
+	}
+	return result
+}
@@ -0,0 +1,95 @@
+package strength
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		maxScore int
+		minScore int
+		warning  string
+	}{
+		{"empty", "", 0, 0, "Password is empty"},
+		{"top common password", "password", 0, 0, "This is a top-10 common password"},
+		{"l33t common password", "P@ssw0rd", 1, 0, "This is similar to a commonly used password"},
+		{"reversed common password", "drowssap", 1, 0, "This is similar to a commonly used password"},
+		{"sequence", "abcdefgh", 0, 0, "Sequences like abc or 6543 are easy to guess"},
+		{"repeated character", "aaaaaaaaaa", 0, 0, `Repeats like "aaa" are easy to guess`},
+		{"repeated block", "xyzxyzxyz", 1, 0, `Repeats like "abcabcabc" are only slightly harder to guess than "abc"`},
+		{"keyboard row", "asdfghjk", 1, 0, "Straight rows of keys are easy to guess"},
+		{"date", "13-04-1990", 1, 0, "Dates are often easy to guess"},
+		{"single word", "dragon", 0, 0, "This is a top-100 common password"},
+		{"random", "xK9#mQ2$vL7@nP4!", MaxScore, MaxScore, ""},
+		{"long passphrase", "maple-orbit-candle-quiet-harbor", MaxScore, 3, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Estimate(tt.password)
+			if result.Score < tt.minScore || result.Score > tt.maxScore {
+				t.Errorf("Estimate(%q).Score = %d, want %d..%d", tt.password, result.Score, tt.minScore, tt.maxScore)
+			}
+			if result.Warning != tt.warning {
+				t.Errorf("Estimate(%q).Warning = %q, want %q", tt.password, result.Warning, tt.warning)
+			}
+		})
+	}
+}
+
+func TestEstimateOrdering(t *testing.T) {
+	// Each password should take more guesses than the one before it
+	passwords := []string{"123456", "monkey", "Monkey", "monkey1987", "m0nkeyBus!ness", "hJ4&kq9!Xz2@Lw"}
+
+	previous := 0.0
+	for _, password := range passwords {
+		result := Estimate(password)
+		if result.Guesses <= previous {
+			t.Errorf("Estimate(%q).Guesses = %g, want more than %g", password, result.Guesses, previous)
+		}
+		previous = result.Guesses
+	}
+}
+
+func TestEstimateUserInputs(t *testing.T) {
+	without := Estimate("orlandoGitHub")
+	with := Estimate("orlandoGitHub", "github", "orlando@example.com")
+
+	if with.Guesses >= without.Guesses {
+		t.Errorf("expected user inputs to lower the estimate, got %g with and %g without", with.Guesses, without.Guesses)
+	}
+	if with.Warning != "Avoid using names or usernames from the record" {
+		t.Errorf("unexpected warning %q", with.Warning)
+	}
+}
+
+func TestEstimateEntropy(t *testing.T) {
+	result := Estimate("correct-horse-battery")
+	if result.Entropy <= 0 {
+		t.Fatalf("expected positive entropy, got %g", result.Entropy)
+	}
+	if math.Abs(math.Exp2(result.Entropy)-result.Guesses) > 1e-6*result.Guesses {
+		t.Errorf("entropy %g bits does not match %g guesses", result.Entropy, result.Guesses)
+	}
+}
+
+func TestEstimateLongInput(t *testing.T) {
+	// Input is truncated, so very long passwords are still handled quickly
+	result := Estimate(strings.Repeat("abcd1234", 1000))
+	if result.Guesses <= 0 {
+		t.Fatalf("expected a positive guess count, got %g", result.Guesses)
+	}
+}
+
+func TestScoreLabel(t *testing.T) {
+	labels := []string{"very weak", "weak", "fair", "strong", "very strong"}
+	for score, label := range labels {
+		if got := ScoreLabel(score); got != label {
+			t.Errorf("ScoreLabel(%d) = %q, want %q", score, got, label)
+		}
+	}
+}
@@ -2,13 +2,18 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"strconv"
 	"strings"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/orlan/go-password-manager/internal/application"
+	"github.com/orlan/go-password-manager/internal/domain"
+	"github.com/orlan/go-password-manager/internal/generator"
 )
 
 // Bot represents the Telegram bot service
@@ -24,14 +29,14 @@ type Bot struct {
 
 // Config holds bot configuration
 type Config struct {
-	BotToken              string
-	SessionTTL            time.Duration
-	EphemeralMessageTTL   time.Duration
-	RateLimitRequests     int
-	RateLimitWindow       time.Duration
-	PasswordRetrievalMax  int
-	PasswordRetrievalWin  time.Duration
-	AllowedUserIDs        []int64
+	BotToken             string
+	SessionTTL           time.Duration
+	EphemeralMessageTTL  time.Duration
+	RateLimitRequests    int
+	RateLimitWindow      time.Duration
+	PasswordRetrievalMax int
+	PasswordRetrievalWin time.Duration
+	AllowedUserIDs       []int64
 }
 
 // NewBot creates a new Telegram bot instance
@@ -54,6 +59,16 @@ func NewBot(config *Config, vaultService *application.VaultService) (*Bot, error
 		config:            config,
 	}
 
+	// Lock the vault session in the backend when a bot session expires
+	bot.sessionManager.SetExpiryHandler(func(session *UserSession) {
+		if session.SessionToken != "" {
+			bot.vaultService.LockVault(context.Background(), session.SessionToken)
+		}
+	})
+
+	// Tell users when the backend locked their vault on its own
+	vaultService.OnSessionLocked(bot.handleSessionLocked)
+
 	return bot, nil
 }
 
@@ -123,12 +138,27 @@ func (b *Bot) handleUpdate(update tgbotapi.Update) {
 		return
 	}
 
-	if state == StateAwaitingMasterPassword {
+	if state.IsPasswordInput() {
 		// Delete the user's password message immediately
 		deleteMsg := tgbotapi.NewDeleteMessage(chatID, update.Message.MessageID)
 		b.api.Request(deleteMsg)
 
-		b.handleMasterPasswordInput(userID, chatID, pendingVault, update.Message.Text)
+		switch state {
+		case StateAwaitingMasterPassword:
+			b.handleMasterPasswordInput(userID, chatID, pendingVault, update.Message.Text)
+		case StateAwaitingTOTP:
+			b.handleTOTPInput(userID, chatID, pendingVault, update.Message.Text)
+		case StateAwaitingImportPassword:
+			b.handleImportPasswordInput(userID, chatID, update.Message.Text)
+		default:
+			b.handleChangePasswordInput(userID, chatID, state, pendingVault, update.Message.Text)
+		}
+		return
+	}
+
+	// Files are only accepted for /import
+	if update.Message.Document != nil {
+		b.handleDocument(userID, chatID, update.Message)
 		return
 	}
 
@@ -160,8 +190,22 @@ func (b *Bot) handleCommand(userID, chatID int64, message *tgbotapi.Message) {
 		b.handleGet(userID, chatID, args)
 	case "add":
 		b.handleAdd(userID, chatID, args)
+	case "history":
+		b.handleHistory(userID, chatID, args)
+	case "otp":
+		b.handleOTP(userID, chatID, args)
+	case "gen":
+		b.handleGen(chatID, args)
+	case "audit":
+		b.handleAudit(userID, chatID)
+	case "log":
+		b.handleLog(userID, chatID)
 	case "vaults":
 		b.handleVaults(chatID)
+	case "passwd":
+		b.handlePasswd(userID, chatID)
+	case "import":
+		b.handleImportCommand(userID, chatID)
 	default:
 		b.sendMessage(chatID, "Unknown command. Use /help to see available commands.")
 	}
@@ -209,11 +253,19 @@ func (b *Bot) handleHelp(chatID int64) {
 *Authentication:*
 /login - Sign into your vault
 /logout - Sign out of your vault
+/passwd - Change the vault master password
 
 *Password Management:*
 /get <name> - Retrieve a password (auto-deletes)
+/history <name> - Show previous passwords (auto-deletes)
+/otp <name> - Get a two-factor code (auto-deletes)
 /list - List all password records
-/add <name> <username> <password> - Add new password
+/add <name> <username> [password] - Add new password (generated if omitted)
+/gen [length] - Generate a password
+/gen phrase [words] - Generate a passphrase
+/audit - Find weak, reused and old passwords
+/log - Show recent activity in the vault
+/import - Import a file from another password manager
 
 *Other:*
 /vaults - List available vaults
@@ -248,17 +300,38 @@ func (b *Bot) handleLogin(userID, chatID int64) {
 	}
 
 	b.sessionManager.SetLoginState(userID, StateAwaitingVaultName, "")
-	b.sendMessage(chatID, "🔑 Please enter the vault name:")
+	b.sessionManager.SetMember(userID, "")
+	b.sendMessage(chatID, "🔑 Please enter the vault name:\n\nFor a shared vault, add your member name: `team-vault alice`")
 }
 
-// handleVaultNameInput processes vault name during login
-func (b *Bot) handleVaultNameInput(userID, chatID int64, vaultName string) {
-	vaultName = strings.TrimSpace(vaultName)
+// handleVaultNameInput processes vault name during login, optionally
+// followed by the member name for a shared vault
+func (b *Bot) handleVaultNameInput(userID, chatID int64, input string) {
+	fields := strings.Fields(input)
 
-	if vaultName == "" {
+	if len(fields) == 0 {
 		b.sendMessage(chatID, "❌ Vault name cannot be empty. Please try again:")
 		return
 	}
+	if len(fields) > 2 {
+		b.sendMessage(chatID, "❌ Please enter the vault name, optionally followed by your member name:")
+		return
+	}
+
+	vaultName := fields[0]
+	if err := domain.ValidateVaultName(vaultName); err != nil {
+		b.sendMessage(chatID, "❌ Invalid vault name. Use up to 64 letters, digits, `-`, `_` or `.`. Please try again:")
+		return
+	}
+
+	member := ""
+	if len(fields) == 2 {
+		member = fields[1]
+		if err := domain.ValidateMemberName(member); err != nil {
+			b.sendMessage(chatID, "❌ Invalid member name. Use up to 64 letters, digits, `-`, `_`, `.`, `@` or `+`. Please try again:")
+			return
+		}
+	}
 
 	// Check if vault exists
 	ctx := context.Background()
@@ -284,36 +357,64 @@ func (b *Bot) handleVaultNameInput(userID, chatID int64, vaultName string) {
 	}
 
 	b.sessionManager.SetLoginState(userID, StateAwaitingMasterPassword, vaultName)
-
-	// Send password prompt and store its message ID for later deletion
-	msg := tgbotapi.NewMessage(chatID, "🔐 Please enter your master password:")
-	msg.ParseMode = "Markdown"
-	sent, err := b.api.Send(msg)
-	if err == nil {
-		b.sessionManager.SetPasswordPromptMsgID(userID, sent.MessageID)
-	}
+	b.sessionManager.SetMember(userID, member)
+	b.sendPasswordPrompt(userID, chatID, "🔐 Please enter your master password:")
 }
 
 // handleMasterPasswordInput processes master password during login
 func (b *Bot) handleMasterPasswordInput(userID, chatID int64, vaultName, masterPassword string) {
-	// Delete the password prompt message
-	promptMsgID := b.sessionManager.GetAndClearPasswordPromptMsgID(userID)
-	if promptMsgID != 0 {
-		deletePrompt := tgbotapi.NewDeleteMessage(chatID, promptMsgID)
-		b.api.Request(deletePrompt)
-	}
+	b.deletePasswordPrompt(userID, chatID)
 
+	// Vaults with two-factor unlock need the code before anything is tried
 	ctx := context.Background()
-	err := b.vaultService.UnlockVault(ctx, vaultName, masterPassword)
+	if enabled, err := b.vaultService.TwoFactorEnabled(ctx, vaultName); err == nil && enabled {
+		b.sessionManager.TakePendingSecrets(userID)
+		b.sessionManager.AddPendingSecret(userID, masterPassword)
+		b.sessionManager.SetLoginState(userID, StateAwaitingTOTP, vaultName)
+		b.sendPasswordPrompt(userID, chatID, "🔢 Please enter the code from your authenticator app:")
+		return
+	}
+
+	b.unlockVault(userID, chatID, vaultName, masterPassword, "")
+}
+
+// handleTOTPInput completes the login to a vault with two-factor unlock
+func (b *Bot) handleTOTPInput(userID, chatID int64, vaultName, code string) {
+	b.deletePasswordPrompt(userID, chatID)
+
+	secrets := b.sessionManager.TakePendingSecrets(userID)
+	if len(secrets) != 1 {
+		b.sendMessage(chatID, "❌ Login failed. Please try /login again.")
+		b.sessionManager.SetLoginState(userID, StateIdle, "")
+		return
+	}
+
+	b.unlockVault(userID, chatID, vaultName, secrets[0], strings.TrimSpace(code))
+}
+
+// unlockVault opens the vault and starts the user's session
+func (b *Bot) unlockVault(userID, chatID int64, vaultName, masterPassword, code string) {
+	ctx := application.WithClient(context.Background(), telegramClient(userID))
+	member := b.sessionManager.Member(userID)
+	token, err := b.vaultService.UnlockVaultAsMember(ctx, vaultName, member, masterPassword, code)
 
 	if err != nil {
-		b.sendMessage(chatID, "❌ Invalid master password or vault error. Please try /login again.")
+		var attemptsErr *application.AttemptsError
+		if errors.As(err, &attemptsErr) {
+			b.sendMessage(chatID, fmt.Sprintf("⏳ Too many failed attempts. Please wait %s before trying /login again.", formatWait(attemptsErr.RetryAfter)))
+		} else if err == domain.ErrInvalidCredentials {
+			b.sendMessage(chatID, "❌ Invalid master password or one-time code. Please try /login again.")
+		} else if err == domain.ErrUnsupportedVaultVersion {
+			b.sendMessage(chatID, "❌ This vault was saved by a newer version of the password manager. Please upgrade the bot.")
+		} else {
+			b.sendMessage(chatID, "❌ Invalid master password or vault error. Please try /login again.")
+		}
 		b.sessionManager.SetLoginState(userID, StateIdle, "")
 		return
 	}
 
 	// Create session
-	b.sessionManager.CreateSession(userID, vaultName)
+	b.sessionManager.CreateSession(userID, chatID, vaultName, member, token)
 	b.sessionManager.SetLoginState(userID, StateIdle, "")
 
 	// Send success message with action buttons
@@ -335,6 +436,40 @@ func (b *Bot) handleMasterPasswordInput(userID, chatID int64, vaultName, masterP
 	b.api.Send(msg)
 }
 
+// handleSessionLocked logs out the user whose vault session was locked by
+// the vault service and lets them know why
+func (b *Bot) handleSessionLocked(event application.SessionEvent) {
+	if event.Reason == application.LockReasonManual {
+		return
+	}
+
+	session := b.sessionManager.TakeSessionByToken(event.Token)
+	if session == nil {
+		return
+	}
+
+	reason := "for security"
+	switch event.Reason {
+	case application.LockReasonIdle:
+		reason = "after a period of inactivity"
+	case application.LockReasonLifetime:
+		reason = "because the session reached its maximum lifetime"
+	case application.LockReasonShutdown:
+		reason = "because the service is shutting down"
+	case application.LockReasonAccessRevoked:
+		reason = "because you were removed from the vault"
+	}
+
+	msg := tgbotapi.NewMessage(session.ChatID, fmt.Sprintf("🔒 Vault *%s* was locked %s. Use /login to sign in again.", session.VaultName, reason))
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🔑 Login", "cmd_login"),
+		),
+	)
+	b.api.Send(msg)
+}
+
 // handleLogout signs out the user
 func (b *Bot) handleLogout(userID, chatID int64) {
 	if !b.sessionManager.IsAuthenticated(userID) {
@@ -343,11 +478,10 @@ func (b *Bot) handleLogout(userID, chatID int64) {
 	}
 
 	session, _ := b.sessionManager.GetSession(userID)
-	vaultName := session.VaultName
 
 	// Lock vault in backend
 	ctx := context.Background()
-	b.vaultService.LockVault(ctx, vaultName)
+	b.vaultService.LockVault(ctx, session.SessionToken)
 
 	// Delete session
 	b.sessionManager.DeleteSession(userID)
@@ -372,6 +506,84 @@ func (b *Bot) handleLogout(userID, chatID int64) {
 	b.api.Send(msg)
 }
 
+// handlePasswd starts the master password change flow
+func (b *Bot) handlePasswd(userID, chatID int64) {
+	if !b.sessionManager.IsAuthenticated(userID) {
+		b.sendMessage(chatID, "🔒 Please /login first.")
+		return
+	}
+
+	session, _ := b.sessionManager.GetSession(userID)
+	b.sessionManager.TakePendingSecrets(userID)
+	b.sessionManager.SetLoginState(userID, StateAwaitingCurrentPassword, session.VaultName)
+	b.sendPasswordPrompt(userID, chatID, "🔐 Please enter your current master password:")
+}
+
+// handleChangePasswordInput processes each password entered during /passwd
+func (b *Bot) handleChangePasswordInput(userID, chatID int64, state LoginState, vaultName, password string) {
+	b.deletePasswordPrompt(userID, chatID)
+	b.sessionManager.AddPendingSecret(userID, password)
+
+	switch state {
+	case StateAwaitingCurrentPassword:
+		b.sessionManager.SetLoginState(userID, StateAwaitingNewPassword, vaultName)
+		b.sendPasswordPrompt(userID, chatID, "🔑 Please enter the new master password:")
+		return
+	case StateAwaitingNewPassword:
+		b.sessionManager.SetLoginState(userID, StateAwaitingNewPasswordConfirm, vaultName)
+		b.sendPasswordPrompt(userID, chatID, "🔑 Please repeat the new master password:")
+		return
+	case StateAwaitingNewPasswordConfirm:
+		if enabled, err := b.vaultService.TwoFactorEnabled(context.Background(), vaultName); err == nil && enabled {
+			b.sessionManager.SetLoginState(userID, StateAwaitingChangeTOTP, vaultName)
+			b.sendPasswordPrompt(userID, chatID, "🔢 Please enter the code from your authenticator app:")
+			return
+		}
+	}
+
+	// All three passwords collected, followed by the code if the vault needs one
+	secrets := b.sessionManager.TakePendingSecrets(userID)
+	b.sessionManager.SetLoginState(userID, StateIdle, "")
+
+	code := ""
+	if state == StateAwaitingChangeTOTP && len(secrets) == 4 {
+		code = strings.TrimSpace(secrets[3])
+		secrets = secrets[:3]
+	}
+	if len(secrets) != 3 {
+		b.sendMessage(chatID, "❌ Password change failed. Please try /passwd again.")
+		return
+	}
+	if secrets[1] != secrets[2] {
+		b.sendMessage(chatID, "❌ New passwords do not match. Please try /passwd again.")
+		return
+	}
+
+	ctx := application.WithClient(context.Background(), telegramClient(userID))
+	err := b.vaultService.ChangeMemberPassword(ctx, vaultName, b.sessionManager.Member(userID), secrets[0], secrets[1], "", code)
+	if err != nil {
+		var attemptsErr *application.AttemptsError
+		if errors.As(err, &attemptsErr) {
+			b.sendMessage(chatID, fmt.Sprintf("⏳ Too many failed attempts. Please wait %s before trying /passwd again.", formatWait(attemptsErr.RetryAfter)))
+			return
+		}
+		if err == domain.ErrInvalidMasterPassword {
+			b.sendMessage(chatID, "❌ Current master password is incorrect. Please try /passwd again.")
+			return
+		}
+		if err == domain.ErrInvalidCredentials {
+			b.sendMessage(chatID, "❌ Current master password or one-time code is incorrect. Please try /passwd again.")
+			return
+		}
+		log.Printf("Failed to change master password: %v", err)
+		b.sendMessage(chatID, "❌ Password change failed. Please try /passwd again.")
+		return
+	}
+
+	b.sendMessage(chatID, fmt.Sprintf("✅ Master password for vault *%s* changed successfully.", vaultName))
+	b.sendActionMenu(chatID, "What would you like to do next?")
+}
+
 // handleList lists all password records
 func (b *Bot) handleList(userID, chatID int64) {
 	if !b.sessionManager.IsAuthenticated(userID) {
@@ -395,7 +607,7 @@ func (b *Bot) handleList(userID, chatID int64) {
 	b.sessionManager.UpdateActivity(userID)
 
 	ctx := context.Background()
-	records, err := b.vaultService.ListPasswordRecords(ctx, session.VaultName)
+	records, err := b.vaultService.ListPasswordRecords(ctx, session.SessionToken, session.VaultName)
 
 	if err != nil {
 		b.sendMessage(chatID, "❌ Error retrieving records.")
@@ -409,7 +621,7 @@ func (b *Bot) handleList(userID, chatID int64) {
 
 	message := "📋 *Password Records:*\n\n"
 	for i, record := range records {
-		message += fmt.Sprintf("%d. *%s*\n   └ Username: `%s`\n", i+1, record.Name, record.Username)
+		message += fmt.Sprintf("%d. %s *%s*\n   └ %s\n", i+1, itemIcons[record.Kind()], escapeMarkdown(record.Name), recordSummary(record))
 	}
 	message += "\n💡 Click a button below or use `/get <name>`"
 
@@ -420,7 +632,7 @@ func (b *Bot) handleList(userID, chatID int64) {
 	var rows [][]tgbotapi.InlineKeyboardButton
 	for _, record := range records {
 		button := tgbotapi.NewInlineKeyboardButtonData(
-			"🔑 "+record.Name,
+			itemIcons[record.Kind()]+" "+record.Name,
 			"get_"+record.Name,
 		)
 		rows = append(rows, tgbotapi.NewInlineKeyboardRow(button))
@@ -471,7 +683,7 @@ func (b *Bot) handleGet(userID, chatID int64, args string) {
 	b.sessionManager.UpdateActivity(userID)
 
 	ctx := context.Background()
-	record, err := b.vaultService.GetPasswordRecord(ctx, session.VaultName, recordName)
+	record, err := b.vaultService.GetPasswordRecord(ctx, session.SessionToken, session.VaultName, recordName)
 
 	if err != nil {
 		// Send error with helpful action button
@@ -490,11 +702,7 @@ func (b *Bot) handleGet(userID, chatID int64, args string) {
 	}
 
 	// Send ephemeral password message
-	message := fmt.Sprintf("🔑 *Password for: %s*\n\n"+
-		"Username: `%s`\n"+
-		"Password: `%s`\n\n"+
-		"⚠️ This message will be deleted in 60 seconds.",
-		record.Name, record.Username, record.Password)
+	message := formatRecord(record) + "\n⚠️ This message will be deleted in 60 seconds."
 
 	msg := tgbotapi.NewMessage(chatID, message)
 	msg.ParseMode = "Markdown"
@@ -512,6 +720,430 @@ func (b *Bot) handleGet(userID, chatID int64, args string) {
 	b.sendActionMenu(chatID, "What would you like to do next?")
 }
 
+// itemIcons and itemTitles label records by item type
+var (
+	itemIcons = map[domain.ItemType]string{
+		domain.ItemTypeLogin:         "🔑",
+		domain.ItemTypeSecureNote:    "📝",
+		domain.ItemTypeCard:          "💳",
+		domain.ItemTypeSSHKey:        "🗝️",
+		domain.ItemTypeAPICredential: "🔌",
+	}
+	itemTitles = map[domain.ItemType]string{
+		domain.ItemTypeLogin:         "Password for",
+		domain.ItemTypeSecureNote:    "Secure note",
+		domain.ItemTypeCard:          "Card",
+		domain.ItemTypeSSHKey:        "SSH key",
+		domain.ItemTypeAPICredential: "API credential",
+	}
+)
+
+// escapeMarkdown escapes user text for messages sent with Markdown parsing
+func escapeMarkdown(text string) string {
+	return tgbotapi.EscapeText(tgbotapi.ModeMarkdown, text)
+}
+
+// recordSummary is the one-line description of a record shown by /list
+func recordSummary(record domain.PasswordRecord) string {
+	switch record.Kind() {
+	case domain.ItemTypeCard:
+		number := record.Card.Number
+		return "Card: `•••• " + number[max(len(number)-4, 0):] + "`"
+	case domain.ItemTypeSSHKey:
+		if record.SSHKey.Fingerprint != "" {
+			return "Fingerprint: `" + record.SSHKey.Fingerprint + "`"
+		}
+		return "SSH key"
+	case domain.ItemTypeAPICredential:
+		if record.APICredential.KeyID != "" {
+			return "Key ID: `" + record.APICredential.KeyID + "`"
+		}
+		return "API credential"
+	case domain.ItemTypeSecureNote:
+		return "Secure note"
+	default:
+		return "Username: `" + record.Username + "`"
+	}
+}
+
+// formatRecord renders a record as a Markdown message. Secrets are shown
+// in code spans like the password; TOTP seeds are never displayed.
+func formatRecord(record *domain.PasswordRecord) string {
+	escape := escapeMarkdown
+
+	var sb strings.Builder
+	kind := record.Kind()
+	fmt.Fprintf(&sb, "%s *%s: %s*\n\n", itemIcons[kind], itemTitles[kind], escape(record.Name))
+
+	switch kind {
+	case domain.ItemTypeLogin:
+		fmt.Fprintf(&sb, "Username: `%s`\n", record.Username)
+		fmt.Fprintf(&sb, "Password: `%s`\n", record.Password)
+		if record.TOTP != "" {
+			fmt.Fprintf(&sb, "2FA: _use /otp %s for a code_\n", escape(record.Name))
+		}
+	case domain.ItemTypeCard:
+		card := record.Card
+		if card.Cardholder != "" {
+			fmt.Fprintf(&sb, "Cardholder: %s\n", escape(card.Cardholder))
+		}
+		if card.Brand != "" {
+			fmt.Fprintf(&sb, "Brand: %s\n", escape(card.Brand))
+		}
+		fmt.Fprintf(&sb, "Number: `%s`\n", card.Number)
+		if card.ExpMonth != 0 || card.ExpYear != 0 {
+			fmt.Fprintf(&sb, "Expires: %02d/%d\n", card.ExpMonth, card.ExpYear)
+		}
+		if card.CVV != "" {
+			fmt.Fprintf(&sb, "CVV: `%s`\n", card.CVV)
+		}
+	case domain.ItemTypeSSHKey:
+		key := record.SSHKey
+		if record.Username != "" {
+			fmt.Fprintf(&sb, "Username: `%s`\n", record.Username)
+		}
+		if key.Fingerprint != "" {
+			fmt.Fprintf(&sb, "Fingerprint: `%s`\n", key.Fingerprint)
+		}
+		if key.PublicKey != "" {
+			fmt.Fprintf(&sb, "Public key:\n```\n%s\n```\n", key.PublicKey)
+		}
+		fmt.Fprintf(&sb, "Private key:\n```\n%s\n```\n", strings.TrimSpace(key.PrivateKey))
+		if key.Passphrase != "" {
+			fmt.Fprintf(&sb, "Passphrase: `%s`\n", key.Passphrase)
+		}
+	case domain.ItemTypeAPICredential:
+		credential := record.APICredential
+		if credential.KeyID != "" {
+			fmt.Fprintf(&sb, "Key ID: `%s`\n", credential.KeyID)
+		}
+		fmt.Fprintf(&sb, "Secret: `%s`\n", credential.Secret)
+		if credential.Endpoint != "" {
+			fmt.Fprintf(&sb, "Endpoint: %s\n", escape(credential.Endpoint))
+		}
+	}
+
+	for _, url := range record.URLs {
+		fmt.Fprintf(&sb, "URL: %s\n", escape(url))
+	}
+	if record.Folder != "" {
+		fmt.Fprintf(&sb, "Folder: %s\n", escape(record.Folder))
+	}
+	if len(record.Tags) > 0 {
+		fmt.Fprintf(&sb, "Tags: %s\n", escape(strings.Join(record.Tags, ", ")))
+	}
+	for _, field := range record.CustomFields {
+		switch field.Type {
+		case domain.CustomFieldTOTP:
+			fmt.Fprintf(&sb, "%s: _TOTP secret stored, use /otp for a code_\n", escape(field.Name))
+		case domain.CustomFieldHidden:
+			fmt.Fprintf(&sb, "%s: `%s`\n", escape(field.Name), field.Value)
+		default:
+			fmt.Fprintf(&sb, "%s: %s\n", escape(field.Name), escape(field.Value))
+		}
+	}
+	if record.Notes != "" {
+		fmt.Fprintf(&sb, "\nNotes:\n%s\n", escape(record.Notes))
+	}
+
+	return sb.String()
+}
+
+// handleHistory sends the previous passwords of a record as an ephemeral message
+func (b *Bot) handleHistory(userID, chatID int64, args string) {
+	if !b.sessionManager.IsAuthenticated(userID) {
+		b.sendMessage(chatID, "🔒 Please /login first.")
+		return
+	}
+
+	// History reveals passwords, so it counts as a retrieval
+	if !b.passwordRetrieval.Allow(userID) {
+		b.sendMessage(chatID, "⏱️ Too many password retrievals. Please wait before trying again.")
+		return
+	}
+
+	recordName := strings.TrimSpace(args)
+	if recordName == "" {
+		b.sendMessage(chatID, "❌ Usage: /history <record_name>")
+		return
+	}
+
+	session, _ := b.sessionManager.GetSession(userID)
+	b.sessionManager.UpdateActivity(userID)
+
+	ctx := context.Background()
+	history, err := b.vaultService.GetRecordHistory(ctx, session.SessionToken, session.VaultName, recordName)
+	if err != nil {
+		b.sendMessage(chatID, fmt.Sprintf("❌ Password record '%s' not found.", recordName))
+		return
+	}
+
+	if len(history) == 0 {
+		b.sendMessage(chatID, fmt.Sprintf("📭 No previous passwords for '%s'.", recordName))
+		return
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🕘 *Password history for: %s*\n\n", escapeMarkdown(recordName))
+	for _, entry := range history {
+		fmt.Fprintf(&sb, "v%d · replaced %s\n`%s`\n\n", entry.Version, entry.ChangedAt.Format("2006-01-02 15:04"), entry.Password)
+	}
+	sb.WriteString("⚠️ This message will be deleted in 60 seconds.")
+
+	b.sendEphemeral(chatID, sb.String())
+}
+
+// handleOTP sends the current two-factor code of a record as an ephemeral message
+func (b *Bot) handleOTP(userID, chatID int64, args string) {
+	if !b.sessionManager.IsAuthenticated(userID) {
+		b.sendMessage(chatID, "🔒 Please /login first.")
+		return
+	}
+
+	// A code grants access like a password, so it counts as a retrieval
+	if !b.passwordRetrieval.Allow(userID) {
+		b.sendMessage(chatID, "⏱️ Too many password retrievals. Please wait before trying again.")
+		return
+	}
+
+	recordName := strings.TrimSpace(args)
+	if recordName == "" {
+		b.sendMessage(chatID, "❌ Usage: /otp <record_name>")
+		return
+	}
+
+	session, _ := b.sessionManager.GetSession(userID)
+	b.sessionManager.UpdateActivity(userID)
+
+	ctx := context.Background()
+	code, err := b.vaultService.GetTOTPCode(ctx, session.SessionToken, session.VaultName, recordName)
+	if err != nil {
+		switch err {
+		case domain.ErrRecordNotFound:
+			b.sendMessage(chatID, fmt.Sprintf("❌ Password record '%s' not found.", escapeMarkdown(recordName)))
+		case domain.ErrNoTOTP:
+			b.sendMessage(chatID, fmt.Sprintf("❌ '%s' has no two-factor secret.", escapeMarkdown(recordName)))
+		default:
+			b.sendMessage(chatID, "❌ Error generating code.")
+		}
+		return
+	}
+
+	remaining := int((code.Remaining + time.Second - 1) / time.Second)
+	b.sendEphemeral(chatID, fmt.Sprintf("🔢 *Code for: %s*\n\n`%s`\n\nValid for %d more seconds.\n\n"+
+		"⚠️ This message will be deleted in 60 seconds.", escapeMarkdown(recordName), code.Code, remaining))
+}
+
+// handleAudit reports weak, reused and old passwords by record name.
+// Passwords themselves are never shown.
+func (b *Bot) handleAudit(userID, chatID int64) {
+	if !b.sessionManager.IsAuthenticated(userID) {
+		b.sendMessage(chatID, "🔒 Please /login first.")
+		return
+	}
+
+	session, _ := b.sessionManager.GetSession(userID)
+	b.sessionManager.UpdateActivity(userID)
+
+	ctx := context.Background()
+	report, err := b.vaultService.AuditVault(ctx, session.SessionToken, session.VaultName)
+	if err != nil {
+		b.sendMessage(chatID, "❌ Error auditing vault.")
+		return
+	}
+
+	if report.Checked == 0 {
+		b.sendMessage(chatID, "📭 No passwords to audit in this vault.")
+		return
+	}
+
+	var weak, reused, old, breached []string
+	for _, record := range report.Records {
+		name := escapeMarkdown(record.Name)
+		for _, issue := range record.Issues {
+			switch issue {
+			case application.AuditIssueWeak:
+				weak = append(weak, fmt.Sprintf("• %s (%s)", name, record.Strength))
+			case application.AuditIssueReused:
+				reused = append(reused, fmt.Sprintf("• %s — same as %s", name, escapeMarkdown(strings.Join(record.ReusedWith, ", "))))
+			case application.AuditIssueOld:
+				old = append(old, fmt.Sprintf("• %s — last changed %s", name, record.UpdatedAt.Format("2006-01-02")))
+			case application.AuditIssueBreached:
+				breached = append(breached, fmt.Sprintf("• %s — seen %d times", name, record.BreachCount))
+			}
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🩺 *Vault audit: %s*\n\n", escapeMarkdown(report.VaultName))
+	fmt.Fprintf(&sb, "Checked %d passwords: %d weak, %d reused, %d old", report.Checked, report.Weak, report.Reused, report.Old)
+	if report.BreachCheck {
+		fmt.Fprintf(&sb, ", %d breached", report.Breached)
+	}
+	sb.WriteString("\n")
+
+	for _, section := range []struct {
+		title string
+		lines []string
+	}{
+		{"🚨 *Breached*", breached},
+		{"⚠️ *Weak*", weak},
+		{"🔁 *Reused*", reused},
+		{"⏳ *Old*", old},
+	} {
+		if len(section.lines) > 0 {
+			fmt.Fprintf(&sb, "\n%s\n%s\n", section.title, strings.Join(section.lines, "\n"))
+		}
+	}
+
+	if len(weak)+len(reused)+len(old)+len(breached) == 0 {
+		sb.WriteString("\n✅ No problems found.")
+	}
+
+	b.sendMessage(chatID, sb.String())
+}
+
+// auditLogLength is how many of the latest audit log entries /log shows
+const auditLogLength = 20
+
+// handleLog shows the latest entries of the vault's audit log
+func (b *Bot) handleLog(userID, chatID int64) {
+	if !b.sessionManager.IsAuthenticated(userID) {
+		b.sendMessage(chatID, "🔒 Please /login first.")
+		return
+	}
+
+	session, _ := b.sessionManager.GetSession(userID)
+	b.sessionManager.UpdateActivity(userID)
+
+	ctx := context.Background()
+	entries, err := b.vaultService.AuditLog(ctx, session.SessionToken, session.VaultName)
+	if err != nil {
+		switch err {
+		case domain.ErrPermissionDenied:
+			b.sendMessage(chatID, "🚫 Only owners of this vault can read its audit log.")
+		case domain.ErrAuditLogTampered:
+			b.sendMessage(chatID, "🚨 The audit log of this vault has been tampered with.")
+		default:
+			b.sendMessage(chatID, "❌ Error reading audit log.")
+		}
+		return
+	}
+
+	if len(entries) == 0 {
+		b.sendMessage(chatID, "📭 The audit log of this vault is empty.")
+		return
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "📜 *Audit log: %s*\n", escapeMarkdown(session.VaultName))
+	if len(entries) > auditLogLength {
+		fmt.Fprintf(&sb, "Latest %d of %d entries\n", auditLogLength, len(entries))
+		entries = entries[len(entries)-auditLogLength:]
+	}
+	sb.WriteString("\n")
+
+	unverified := false
+	for _, entry := range entries {
+		fmt.Fprintf(&sb, "`%s` %s", entry.Time.Local().Format("2006-01-02 15:04"), escapeMarkdown(string(entry.Event)))
+		if entry.Record != "" {
+			fmt.Fprintf(&sb, " *%s*", escapeMarkdown(entry.Record))
+		}
+		if entry.Member != "" {
+			fmt.Fprintf(&sb, " as %s", escapeMarkdown(entry.Member))
+		}
+		if entry.Actor != "" {
+			fmt.Fprintf(&sb, " by %s", escapeMarkdown(entry.Actor))
+		}
+		if entry.Detail != "" {
+			fmt.Fprintf(&sb, " (%s)", escapeMarkdown(entry.Detail))
+		}
+		if !entry.Verified {
+			sb.WriteString(" ⚠️")
+			unverified = true
+		}
+		sb.WriteString("\n")
+	}
+	if unverified {
+		sb.WriteString("\n⚠️ Not yet confirmed by the vault key, such as failed unlocks.")
+	}
+
+	b.sendMessage(chatID, sb.String())
+}
+
+// handleGen generates a password, or a passphrase with "/gen phrase".
+// An optional number sets the length in characters or words.
+func (b *Bot) handleGen(chatID int64, args string) {
+	usage := "❌ Usage: /gen [length] or /gen phrase [words]"
+
+	parts := strings.Fields(args)
+	passphrase := len(parts) > 0 && (parts[0] == "phrase" || parts[0] == "passphrase")
+	if passphrase {
+		parts = parts[1:]
+	}
+	if len(parts) > 1 {
+		b.sendMessage(chatID, usage)
+		return
+	}
+
+	size := 0
+	if len(parts) == 1 {
+		n, err := strconv.Atoi(parts[0])
+		if err != nil {
+			b.sendMessage(chatID, usage)
+			return
+		}
+		size = n
+	}
+
+	var (
+		value   string
+		entropy float64
+		err     error
+	)
+	if passphrase {
+		policy := generator.DefaultPassphrasePolicy()
+		if size != 0 {
+			policy.Words = size
+		}
+		value, err = generator.Passphrase(policy)
+		entropy = policy.Entropy()
+	} else {
+		policy := generator.DefaultPasswordPolicy()
+		if size != 0 {
+			policy.Length = size
+		}
+		value, err = generator.Password(policy)
+		entropy = policy.Entropy()
+	}
+	if err != nil {
+		if errors.Is(err, generator.ErrInvalidPolicy) {
+			b.sendMessage(chatID, "❌ "+err.Error())
+			return
+		}
+		log.Printf("Failed to generate secret: %v", err)
+		b.sendMessage(chatID, "❌ Failed to generate a secret.")
+		return
+	}
+
+	b.sendEphemeral(chatID, fmt.Sprintf("🎲 *Generated:*\n\n`%s`\n\nStrength: about %.0f bits\n\n"+
+		"⚠️ This message will be deleted in 60 seconds.", value, entropy))
+}
+
+// sendEphemeral sends a Markdown message that is deleted after the ephemeral TTL
+func (b *Bot) sendEphemeral(chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+
+	sent, err := b.api.Send(msg)
+	if err != nil {
+		log.Printf("Failed to send ephemeral message: %v", err)
+		return
+	}
+
+	b.ephemeralManager.ScheduleDelete(chatID, sent.MessageID)
+}
+
 // handleAdd adds a new password record
 func (b *Bot) handleAdd(userID, chatID int64, args string) {
 	if !b.sessionManager.IsAuthenticated(userID) {
@@ -520,27 +1152,43 @@ func (b *Bot) handleAdd(userID, chatID int64, args string) {
 	}
 
 	parts := strings.Fields(args)
-	if len(parts) < 3 {
-		b.sendMessage(chatID, "❌ Usage: /add <name> <username> <password>")
+	if len(parts) < 2 {
+		b.sendMessage(chatID, "❌ Usage: /add <name> <username> [password]\nLeave out the password to generate one.")
 		return
 	}
 
-	name := parts[0]
-	username := parts[1]
-	password := parts[2]
+	input := application.RecordInput{
+		Name:     parts[0],
+		Username: parts[1],
+	}
+	if len(parts) > 2 {
+		input.Password = parts[2]
+	} else {
+		policy := generator.DefaultPasswordPolicy()
+		input.Generate = &policy
+	}
 
 	session, _ := b.sessionManager.GetSession(userID)
 	b.sessionManager.UpdateActivity(userID)
 
 	ctx := context.Background()
-	err := b.vaultService.AddPasswordRecord(ctx, session.VaultName, name, username, password)
+	result, err := b.vaultService.AddPasswordRecord(ctx, session.SessionToken, session.VaultName, input)
 
 	if err != nil {
 		b.sendMessage(chatID, fmt.Sprintf("❌ Failed to add password: %s", err.Error()))
 		return
 	}
 
-	b.sendMessage(chatID, fmt.Sprintf("✅ Password record '%s' added successfully!", name))
+	if result.GeneratedPassword != "" {
+		b.sendEphemeral(chatID, fmt.Sprintf("✅ Password record '%s' added with a generated password:\n\n`%s`\n\n"+
+			"⚠️ This message will be deleted in 60 seconds.", escapeMarkdown(input.Name), result.GeneratedPassword))
+	} else {
+		b.sendMessage(chatID, fmt.Sprintf("✅ Password record '%s' added successfully!", input.Name))
+	}
+
+	for _, warning := range result.Warnings {
+		b.sendMessage(chatID, "⚠️ "+escapeMarkdown(warning))
+	}
 
 	// Send action menu
 	b.sendActionMenu(chatID, "What would you like to do next?")
@@ -640,6 +1288,40 @@ func (b *Bot) sendMessage(chatID int64, text string) {
 	b.api.Send(msg)
 }
 
+// telegramClient identifies a Telegram user for unlock attempt limits and
+// the audit log
+func telegramClient(userID int64) string {
+	return fmt.Sprintf("telegram:%d", userID)
+}
+
+// formatWait describes a wait in whole seconds or minutes
+func formatWait(d time.Duration) string {
+	if d <= time.Minute {
+		return fmt.Sprintf("%d seconds", max(int(math.Ceil(d.Seconds())), 1))
+	}
+	return fmt.Sprintf("%d minutes", int(math.Ceil(d.Minutes())))
+}
+
+// sendPasswordPrompt asks for a password and remembers the prompt so it can
+// be deleted once the password has been entered
+func (b *Bot) sendPasswordPrompt(userID, chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	sent, err := b.api.Send(msg)
+	if err == nil {
+		b.sessionManager.SetPasswordPromptMsgID(userID, sent.MessageID)
+	}
+}
+
+// deletePasswordPrompt removes the last password prompt from the chat
+func (b *Bot) deletePasswordPrompt(userID, chatID int64) {
+	promptMsgID := b.sessionManager.GetAndClearPasswordPromptMsgID(userID)
+	if promptMsgID != 0 {
+		deletePrompt := tgbotapi.NewDeleteMessage(chatID, promptMsgID)
+		b.api.Request(deletePrompt)
+	}
+}
+
 // sendActionMenu sends a menu with common action buttons
 func (b *Bot) sendActionMenu(chatID int64, promptText string) {
 	msg := tgbotapi.NewMessage(chatID, promptText)
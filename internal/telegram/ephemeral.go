@@ -1,6 +1,7 @@
 package telegram
 
 import (
+	"fmt"
 	"log"
 	"sync"
 	"time"
@@ -93,7 +94,7 @@ func (emm *EphemeralMessageManager) deleteExpiredMessages() {
 
 // getKey generates a unique key for a message
 func (emm *EphemeralMessageManager) getKey(chatID int64, messageID int) string {
-	return string(chatID) + ":" + string(messageID)
+	return fmt.Sprintf("%d:%d", chatID, messageID)
 }
 
 // Stop stops the cleanup loop
@@ -0,0 +1,200 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/orlan/go-password-manager/internal/application"
+	"github.com/orlan/go-password-manager/internal/crypto"
+	"github.com/orlan/go-password-manager/internal/domain"
+	"github.com/orlan/go-password-manager/internal/transfer"
+)
+
+const importUsage = "📥 To import, send the exported file as a document with the caption:\n\n" +
+	"`/import <format> [skip|replace|rename]`\n\n" +
+	"Formats: `encrypted`, `csv`, `bitwarden`, `1password`, `keepass`\n" +
+	"Duplicate names are skipped unless you choose replace or rename."
+
+// maxProblemsShown bounds the problems listed after an import
+const maxProblemsShown = 10
+
+// handleImportCommand explains how to import, since the file has to be
+// sent as a document
+func (b *Bot) handleImportCommand(userID, chatID int64) {
+	if !b.sessionManager.IsAuthenticated(userID) {
+		b.sendMessage(chatID, "🔒 Please /login first.")
+		return
+	}
+	b.sendMessage(chatID, importUsage)
+}
+
+// handleDocument imports a file sent with an /import caption. The upload
+// holds every password of the source, so it is removed from the chat first.
+func (b *Bot) handleDocument(userID, chatID int64, message *tgbotapi.Message) {
+	caption := strings.Fields(message.Caption)
+	if len(caption) == 0 || caption[0] != "/import" {
+		b.sendMessage(chatID, "Please use /help to see available commands.")
+		return
+	}
+
+	b.api.Request(tgbotapi.NewDeleteMessage(chatID, message.MessageID))
+
+	if !b.sessionManager.IsAuthenticated(userID) {
+		b.sendMessage(chatID, "🔒 Please /login first, then send the file again.")
+		return
+	}
+	if len(caption) < 2 || len(caption) > 3 {
+		b.sendMessage(chatID, importUsage)
+		return
+	}
+
+	format, err := transfer.ParseFormat(caption[1])
+	if err != nil {
+		b.sendMessage(chatID, "❌ Unknown format.\n\n"+importUsage)
+		return
+	}
+	var duplicates application.DuplicatePolicy
+	if len(caption) == 3 {
+		if duplicates, err = application.ParseDuplicatePolicy(caption[2]); err != nil {
+			b.sendMessage(chatID, "❌ "+err.Error())
+			return
+		}
+	}
+
+	if message.Document.FileSize > transfer.MaxFileSize {
+		b.sendMessage(chatID, fmt.Sprintf("❌ File is too large. The limit is %d MB.", transfer.MaxFileSize>>20))
+		return
+	}
+	data, err := b.downloadFile(message.Document.FileID)
+	if err != nil {
+		log.Printf("Failed to download import file: %v", err)
+		b.sendMessage(chatID, "❌ Could not download the file. Please try again.")
+		return
+	}
+
+	b.sessionManager.UpdateActivity(userID)
+
+	if format == transfer.FormatEncrypted {
+		session, _ := b.sessionManager.GetSession(userID)
+		b.sessionManager.SetPendingImport(userID, data, duplicates)
+		b.sessionManager.SetLoginState(userID, StateAwaitingImportPassword, session.VaultName)
+		b.sendPasswordPrompt(userID, chatID, "🔐 Please enter the password of the export:")
+		return
+	}
+
+	b.importRecords(userID, chatID, data, application.ImportOptions{Format: format, Duplicates: duplicates})
+}
+
+// handleImportPasswordInput imports the pending encrypted export
+func (b *Bot) handleImportPasswordInput(userID, chatID int64, password string) {
+	b.deletePasswordPrompt(userID, chatID)
+	b.sessionManager.SetLoginState(userID, StateIdle, "")
+
+	pending := b.sessionManager.TakePendingImport(userID)
+	if pending == nil {
+		b.sendMessage(chatID, "❌ Import failed. Please send the file again.")
+		return
+	}
+
+	b.importRecords(userID, chatID, pending.data, application.ImportOptions{
+		Format:     transfer.FormatEncrypted,
+		Password:   password,
+		Duplicates: pending.duplicates,
+	})
+}
+
+// importRecords adds the records of data to the user's vault and reports
+// the outcome
+func (b *Bot) importRecords(userID, chatID int64, data []byte, opts application.ImportOptions) {
+	defer crypto.Wipe(data)
+
+	session, err := b.sessionManager.GetSession(userID)
+	if err != nil {
+		b.sendMessage(chatID, "🔒 Please /login first, then send the file again.")
+		return
+	}
+
+	result, err := b.vaultService.ImportRecords(context.Background(), session.SessionToken, session.VaultName, data, opts)
+	if err != nil {
+		switch {
+		case err == transfer.ErrWrongPassword:
+			b.sendMessage(chatID, "❌ Wrong export password. Please send the file again.")
+		case errors.Is(err, transfer.ErrMalformed):
+			b.sendMessage(chatID, "❌ "+escapeMarkdown(err.Error()))
+		case err == domain.ErrInvalidSession:
+			b.sendMessage(chatID, "🔒 Your session has expired. Please /login again.")
+		case err == domain.ErrPermissionDenied:
+			b.sendMessage(chatID, "❌ Your role in this vault does not allow importing records.")
+		default:
+			log.Printf("Failed to import records: %v", err)
+			b.sendMessage(chatID, "❌ Import failed. Please try again.")
+		}
+		return
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "✅ Imported %d records into vault *%s*.", result.Added+result.Replaced, escapeMarkdown(session.VaultName))
+	if result.Replaced > 0 {
+		fmt.Fprintf(&text, "\n♻️ %d existing records replaced.", result.Replaced)
+	}
+	if len(result.Renamed) > 0 {
+		fmt.Fprintf(&text, "\n✏️ %d duplicates renamed.", len(result.Renamed))
+	}
+	if len(result.Skipped) > 0 {
+		fmt.Fprintf(&text, "\n⏭️ %d duplicates skipped.", len(result.Skipped))
+	}
+	if len(result.Problems) > 0 {
+		fmt.Fprintf(&text, "\n\n⚠️ %d entries could not be imported:", len(result.Problems))
+		for _, problem := range result.Problems[:min(len(result.Problems), maxProblemsShown)] {
+			text.WriteString("\n• " + escapeMarkdown(problem))
+		}
+		if len(result.Problems) > maxProblemsShown {
+			fmt.Fprintf(&text, "\n• and %d more", len(result.Problems)-maxProblemsShown)
+		}
+	}
+
+	b.sendMessage(chatID, text.String())
+	b.sendActionMenu(chatID, "What would you like to do next?")
+}
+
+// downloadFile fetches an uploaded file from Telegram
+func (b *Bot) downloadFile(fileID string) ([]byte, error) {
+	fileURL, err := b.api.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.api.Client.Do(req)
+	if err != nil {
+		// The URL contains the bot token; keep it out of the logs
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return nil, urlErr.Err
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, transfer.MaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > transfer.MaxFileSize {
+		return nil, fmt.Errorf("file exceeds %d bytes", transfer.MaxFileSize)
+	}
+	return data, nil
+}
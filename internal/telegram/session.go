@@ -4,17 +4,34 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/orlan/go-password-manager/internal/application"
 )
 
 // UserSession represents a Telegram user's vault session
 type UserSession struct {
-	TelegramUserID       int64
-	VaultName            string
-	SessionToken         string
-	LastActivity         time.Time
-	LoginState           LoginState
-	PendingVault         string
-	PasswordPromptMsgID  int // Message ID of password prompt to delete
+	TelegramUserID      int64
+	ChatID              int64
+	VaultName           string
+	Member              string // Member of a shared vault the user logged in as
+	SessionToken        string
+	LastActivity        time.Time
+	LoginState          LoginState
+	PendingVault        string
+	PasswordPromptMsgID int // Message ID of password prompt to delete
+
+	// Passwords collected during a multi-step flow such as /passwd.
+	// Cleared as soon as the flow completes.
+	pendingSecrets []string
+
+	// File uploaded with /import, kept until its password is entered
+	pendingImport *pendingImport
+}
+
+// pendingImport is an encrypted export waiting for its password
+type pendingImport struct {
+	data       []byte
+	duplicates application.DuplicatePolicy
 }
 
 // LoginState tracks the user's login flow state
@@ -24,8 +41,31 @@ const (
 	StateIdle LoginState = iota
 	StateAwaitingVaultName
 	StateAwaitingMasterPassword
+	StateAwaitingCurrentPassword
+	StateAwaitingNewPassword
+	StateAwaitingNewPasswordConfirm
+	// StateAwaitingTOTP follows the master password for vaults with
+	// two-factor unlock
+	StateAwaitingTOTP
+	// StateAwaitingChangeTOTP follows the new master password in /passwd
+	// for vaults with two-factor unlock
+	StateAwaitingChangeTOTP
+	// StateAwaitingImportPassword follows the upload of an encrypted export
+	StateAwaitingImportPassword
 )
 
+// IsPasswordInput reports whether messages received in this state carry a
+// password and must be deleted from the chat
+func (s LoginState) IsPasswordInput() bool {
+	switch s {
+	case StateAwaitingMasterPassword, StateAwaitingCurrentPassword,
+		StateAwaitingNewPassword, StateAwaitingNewPasswordConfirm,
+		StateAwaitingTOTP, StateAwaitingChangeTOTP, StateAwaitingImportPassword:
+		return true
+	}
+	return false
+}
+
 // SessionManager manages user sessions with auto-expiry
 type SessionManager struct {
 	sessions      map[int64]*UserSession
@@ -33,6 +73,7 @@ type SessionManager struct {
 	sessionTTL    time.Duration
 	cleanupTicker *time.Ticker
 	done          chan bool
+	onExpire      func(*UserSession)
 }
 
 // NewSessionManager creates a new session manager
@@ -48,14 +89,26 @@ func NewSessionManager(sessionTTL time.Duration) *SessionManager {
 	return sm
 }
 
+// SetExpiryHandler registers a function called for each session removed
+// by the expiry cleanup
+func (sm *SessionManager) SetExpiryHandler(fn func(*UserSession)) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.onExpire = fn
+}
+
 // CreateSession creates or updates a session for a user
-func (sm *SessionManager) CreateSession(userID int64, vaultName string) {
+func (sm *SessionManager) CreateSession(userID, chatID int64, vaultName, member, sessionToken string) {
 	sm.mu.Lock()
 	defer sm.mu.Unlock()
 
 	sm.sessions[userID] = &UserSession{
 		TelegramUserID: userID,
+		ChatID:         chatID,
 		VaultName:      vaultName,
+		Member:         member,
+		SessionToken:   sessionToken,
 		LastActivity:   time.Now(),
 		LoginState:     StateIdle,
 	}
@@ -137,6 +190,74 @@ func (sm *SessionManager) GetAndClearPasswordPromptMsgID(userID int64) int {
 	return 0
 }
 
+// AddPendingSecret stores a password entered during a multi-step flow
+func (sm *SessionManager) AddPendingSecret(userID int64, secret string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if session, exists := sm.sessions[userID]; exists {
+		session.pendingSecrets = append(session.pendingSecrets, secret)
+	}
+}
+
+// TakePendingSecrets returns and clears the passwords collected so far
+func (sm *SessionManager) TakePendingSecrets(userID int64) []string {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if session, exists := sm.sessions[userID]; exists {
+		secrets := session.pendingSecrets
+		session.pendingSecrets = nil
+		return secrets
+	}
+	return nil
+}
+
+// SetPendingImport keeps an uploaded file until its password is entered
+func (sm *SessionManager) SetPendingImport(userID int64, data []byte, duplicates application.DuplicatePolicy) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if session, exists := sm.sessions[userID]; exists {
+		session.pendingImport = &pendingImport{data: data, duplicates: duplicates}
+	}
+}
+
+// TakePendingImport returns and clears the uploaded file, or nil
+func (sm *SessionManager) TakePendingImport(userID int64) *pendingImport {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if session, exists := sm.sessions[userID]; exists {
+		pending := session.pendingImport
+		session.pendingImport = nil
+		return pending
+	}
+	return nil
+}
+
+// SetMember remembers the member name entered with the vault name during
+// login
+func (sm *SessionManager) SetMember(userID int64, member string) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	if session, exists := sm.sessions[userID]; exists {
+		session.Member = member
+	}
+}
+
+// Member returns the member name entered during login
+func (sm *SessionManager) Member(userID int64) string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+
+	if session, exists := sm.sessions[userID]; exists {
+		return session.Member
+	}
+	return ""
+}
+
 // IsAuthenticated checks if a user has an active vault session
 func (sm *SessionManager) IsAuthenticated(userID int64) bool {
 	sm.mu.RLock()
@@ -158,6 +279,21 @@ func (sm *SessionManager) DeleteSession(userID int64) {
 	delete(sm.sessions, userID)
 }
 
+// TakeSessionByToken removes and returns the session holding the given
+// vault session token, or nil if no user has it
+func (sm *SessionManager) TakeSessionByToken(sessionToken string) *UserSession {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for userID, session := range sm.sessions {
+		if session.SessionToken == sessionToken {
+			delete(sm.sessions, userID)
+			return session
+		}
+	}
+	return nil
+}
+
 // cleanupExpiredSessions periodically removes expired sessions
 func (sm *SessionManager) cleanupExpiredSessions() {
 	for {
@@ -165,12 +301,22 @@ func (sm *SessionManager) cleanupExpiredSessions() {
 		case <-sm.cleanupTicker.C:
 			sm.mu.Lock()
 			now := time.Now()
+			var expired []*UserSession
 			for userID, session := range sm.sessions {
 				if now.Sub(session.LastActivity) > sm.sessionTTL {
+					expired = append(expired, session)
 					delete(sm.sessions, userID)
 				}
 			}
+			onExpire := sm.onExpire
 			sm.mu.Unlock()
+
+			// Notify outside the lock
+			if onExpire != nil {
+				for _, session := range expired {
+					onExpire(session)
+				}
+			}
 		case <-sm.done:
 			return
 		}
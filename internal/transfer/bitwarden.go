@@ -0,0 +1,178 @@
+package transfer
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// Bitwarden item types
+const (
+	bitwardenLogin      = 1
+	bitwardenSecureNote = 2
+	bitwardenCard       = 3
+	bitwardenIdentity   = 4
+	bitwardenSSHKey     = 5
+)
+
+// Bitwarden custom field types
+const (
+	bitwardenFieldText    = 0
+	bitwardenFieldHidden  = 1
+	bitwardenFieldBoolean = 2
+)
+
+// bitwardenExport is the part of a Bitwarden JSON export that is imported
+type bitwardenExport struct {
+	Encrypted bool `json:"encrypted"`
+	Folders   []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"folders"`
+	Items []bitwardenItem `json:"items"`
+}
+
+type bitwardenItem struct {
+	FolderID string `json:"folderId"`
+	Type     int    `json:"type"`
+	Name     string `json:"name"`
+	Notes    string `json:"notes"`
+	Fields   []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+		Type  int    `json:"type"`
+	} `json:"fields"`
+	Login *struct {
+		URIs []struct {
+			URI string `json:"uri"`
+		} `json:"uris"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+		TOTP     string `json:"totp"`
+	} `json:"login"`
+	Card *struct {
+		CardholderName string `json:"cardholderName"`
+		Brand          string `json:"brand"`
+		Number         string `json:"number"`
+		ExpMonth       string `json:"expMonth"`
+		ExpYear        string `json:"expYear"`
+		Code           string `json:"code"`
+	} `json:"card"`
+	SSHKey *struct {
+		PrivateKey     string `json:"privateKey"`
+		PublicKey      string `json:"publicKey"`
+		KeyFingerprint string `json:"keyFingerprint"`
+	} `json:"sshKey"`
+	PasswordHistory []bitwardenHistoryEntry `json:"passwordHistory"`
+	CreationDate    *time.Time              `json:"creationDate"`
+	DeletedDate     *time.Time              `json:"deletedDate"`
+}
+
+type bitwardenHistoryEntry struct {
+	LastUsedDate time.Time `json:"lastUsedDate"`
+	Password     string    `json:"password"`
+}
+
+// ImportBitwarden reads an unencrypted Bitwarden JSON export. Logins, secure
+// notes, cards and SSH keys are imported with their folder, custom fields
+// and password history; identities have no matching item type and are
+// skipped.
+func ImportBitwarden(data []byte) (*Import, error) {
+	var export bitwardenExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, malformed("invalid Bitwarden JSON: %v", err)
+	}
+	if export.Encrypted {
+		return nil, malformed("encrypted Bitwarden exports are not supported, export as unencrypted JSON")
+	}
+
+	folders := make(map[string]string, len(export.Folders))
+	for _, folder := range export.Folders {
+		folders[folder.ID] = folder.Name
+	}
+
+	imp := &Import{}
+	for _, item := range export.Items {
+		if item.DeletedDate != nil {
+			continue
+		}
+
+		record := domain.PasswordRecord{
+			Name:   item.Name,
+			Notes:  item.Notes,
+			Folder: folders[item.FolderID],
+		}
+		if item.CreationDate != nil {
+			record.CreatedAt = *item.CreationDate
+		}
+		for _, field := range item.Fields {
+			switch field.Type {
+			case bitwardenFieldText, bitwardenFieldBoolean:
+				record.CustomFields = append(record.CustomFields, domain.CustomField{Name: field.Name, Value: field.Value, Type: domain.CustomFieldText})
+			case bitwardenFieldHidden:
+				record.CustomFields = append(record.CustomFields, domain.CustomField{Name: field.Name, Value: field.Value, Type: domain.CustomFieldHidden})
+			}
+		}
+
+		switch {
+		case item.Type == bitwardenLogin && item.Login != nil:
+			record.Type = domain.ItemTypeLogin
+			record.Username = item.Login.Username
+			record.Password = item.Login.Password
+			record.TOTP = item.Login.TOTP
+			for _, uri := range item.Login.URIs {
+				if uri.URI != "" {
+					record.URLs = append(record.URLs, uri.URI)
+				}
+			}
+
+			history := item.PasswordHistory
+			slices.SortStableFunc(history, func(a, b bitwardenHistoryEntry) int {
+				return b.LastUsedDate.Compare(a.LastUsedDate)
+			})
+			for i, entry := range history {
+				record.History = append(record.History, domain.PasswordHistoryEntry{
+					Version:   len(history) - i,
+					Password:  entry.Password,
+					ChangedAt: entry.LastUsedDate,
+				})
+			}
+		case item.Type == bitwardenSecureNote:
+			record.Type = domain.ItemTypeSecureNote
+		case item.Type == bitwardenCard && item.Card != nil:
+			record.Type = domain.ItemTypeCard
+			record.Card = &domain.CardData{
+				Cardholder: item.Card.CardholderName,
+				Brand:      item.Card.Brand,
+				Number:     item.Card.Number,
+				CVV:        item.Card.Code,
+			}
+			record.Card.ExpMonth, _ = strconv.Atoi(strings.TrimSpace(item.Card.ExpMonth))
+			record.Card.ExpYear, _ = strconv.Atoi(strings.TrimSpace(item.Card.ExpYear))
+			if record.Card.ExpYear > 0 && record.Card.ExpYear < 100 {
+				record.Card.ExpYear += 2000
+			}
+		case item.Type == bitwardenSSHKey && item.SSHKey != nil:
+			record.Type = domain.ItemTypeSSHKey
+			record.SSHKey = &domain.SSHKeyData{
+				PrivateKey:  item.SSHKey.PrivateKey,
+				PublicKey:   item.SSHKey.PublicKey,
+				Fingerprint: item.SSHKey.KeyFingerprint,
+			}
+		case item.Type == bitwardenIdentity:
+			imp.skip(item.Name, "identities are not supported")
+			continue
+		default:
+			imp.skip(item.Name, fmt.Sprintf("unsupported item type %d", item.Type))
+			continue
+		}
+
+		imp.Records = append(imp.Records, record)
+	}
+	return imp, nil
+}
@@ -0,0 +1,188 @@
+package transfer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// csvHeader lists the columns written by ExportCSV
+var csvHeader = []string{"type", "name", "username", "password", "totp", "urls", "notes", "folder", "tags"}
+
+// csvColumns maps the lower-cased header names used by this program,
+// Bitwarden and 1Password CSV exports to the column they hold
+var csvColumns = map[string]string{
+	"type":           "type",
+	"name":           "name",
+	"title":          "name",
+	"username":       "username",
+	"login_username": "username",
+	"password":       "password",
+	"login_password": "password",
+	"totp":           "totp",
+	"otpauth":        "totp",
+	"login_totp":     "totp",
+	"urls":           "urls",
+	"url":            "urls",
+	"website":        "urls",
+	"login_uri":      "urls",
+	"notes":          "notes",
+	"folder":         "folder",
+	"tags":           "tags",
+	"archived":       "archived",
+}
+
+// csvFormulaStart holds the characters that make spreadsheets read a cell
+// as a formula
+const csvFormulaStart = "=+-@\t\r"
+
+// ExportCSV writes logins and secure notes as plaintext CSV. Other item
+// types do not fit the columns; their names are returned so the caller can
+// point to the encrypted export instead. Cells that a spreadsheet would run
+// as a formula are prefixed with a quote, which ImportCSV removes again.
+func ExportCSV(records []domain.PasswordRecord) ([]byte, []string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(csvHeader); err != nil {
+		return nil, nil, err
+	}
+
+	var skipped []string
+	for _, record := range records {
+		kind := record.Kind()
+		if kind != domain.ItemTypeLogin && kind != domain.ItemTypeSecureNote {
+			skipped = append(skipped, record.Name)
+			continue
+		}
+		row := []string{
+			string(kind),
+			record.Name,
+			record.Username,
+			record.Password,
+			record.TOTP,
+			strings.Join(record.URLs, "\n"),
+			record.Notes,
+			record.Folder,
+			strings.Join(record.Tags, ","),
+		}
+		for i := range row {
+			row[i] = escapeCSVCell(row[i])
+		}
+		if err := w.Write(row); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), skipped, nil
+}
+
+// ImportCSV reads a CSV file with a header row. Columns are matched by name,
+// so files written by ExportCSV, Bitwarden and 1Password are all accepted;
+// unknown columns are ignored.
+func ImportCSV(data []byte) (*Import, error) {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, malformed("missing header row")
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		if column, ok := csvColumns[strings.ToLower(strings.TrimSpace(name))]; ok {
+			if _, seen := columns[column]; !seen {
+				columns[column] = i
+			}
+		}
+	}
+	if _, ok := columns["name"]; !ok {
+		if _, ok := columns["password"]; !ok {
+			return nil, malformed("header has neither a name nor a password column")
+		}
+	}
+
+	// Only files written by ExportCSV have their cells escaped
+	escaped := slices.Equal(header, csvHeader)
+
+	imp := &Import{}
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, malformed("%v", err)
+		}
+		line, _ := r.FieldPos(0)
+
+		cell := func(column string) string {
+			if i, ok := columns[column]; ok && i < len(row) {
+				if escaped {
+					return unescapeCSVCell(row[i])
+				}
+				return row[i]
+			}
+			return ""
+		}
+
+		entry := fmt.Sprintf("line %d", line)
+		if strings.EqualFold(cell("archived"), "true") {
+			imp.skip(entry, "archived")
+			continue
+		}
+
+		record := domain.PasswordRecord{
+			Name:     strings.TrimSpace(cell("name")),
+			Username: cell("username"),
+			Password: cell("password"),
+			TOTP:     strings.TrimSpace(cell("totp")),
+			URLs:     splitList(cell("urls")),
+			Notes:    cell("notes"),
+			Folder:   strings.TrimSpace(cell("folder")),
+			Tags:     splitList(cell("tags")),
+		}
+		if record.Name == "" && record.Username == "" && record.Password == "" && len(record.URLs) == 0 && record.Notes == "" {
+			continue
+		}
+
+		switch kind := strings.ToLower(strings.TrimSpace(cell("type"))); kind {
+		case "", "login":
+			record.Type = domain.ItemTypeLogin
+		case "note", "securenote", "secure_note":
+			record.Type = domain.ItemTypeSecureNote
+		default:
+			imp.skip(entry, fmt.Sprintf("unsupported type %q", kind))
+			continue
+		}
+
+		imp.Records = append(imp.Records, record)
+	}
+	return imp, nil
+}
+
+// escapeCSVCell prefixes value with a quote if it starts like a formula.
+// Values that start with a quote get another one, so that unescapeCSVCell
+// can tell them apart.
+func escapeCSVCell(value string) string {
+	if value != "" && strings.ContainsRune(csvFormulaStart+"'", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// unescapeCSVCell removes the quote added by escapeCSVCell
+func unescapeCSVCell(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(csvFormulaStart+"'", rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
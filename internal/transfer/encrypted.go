@@ -0,0 +1,119 @@
+package transfer
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/crypto"
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+const (
+	// encryptedFormatName marks files written by ExportEncrypted
+	encryptedFormatName = "go-password-manager-export"
+	encryptedVersion    = 1
+
+	// MinExportPasswordLength is the shortest password accepted for an
+	// encrypted export
+	MinExportPasswordLength = 8
+)
+
+// envelope is the file written by ExportEncrypted. The records are
+// encrypted with a key derived from the export password, independent of
+// the master password of the vault they came from.
+type envelope struct {
+	Format  string           `json:"format"`
+	Version int              `json:"version"`
+	KDF     domain.KDFParams `json:"kdf"`
+	Salt    []byte           `json:"salt"`
+	Nonce   []byte           `json:"nonce"`
+	Data    []byte           `json:"data"`
+}
+
+// payload is the encrypted content of an envelope
+type payload struct {
+	ExportedAt time.Time               `json:"exported_at"`
+	Records    []domain.PasswordRecord `json:"records"`
+}
+
+// ExportEncrypted writes records, including their password history, to a
+// JSON file encrypted with password
+func ExportEncrypted(records []domain.PasswordRecord, password string, c domain.CryptoService, now time.Time) ([]byte, error) {
+	if len(password) < MinExportPasswordLength {
+		return nil, ErrWeakPassword
+	}
+
+	kdf, err := c.KDFProfile("")
+	if err != nil {
+		return nil, err
+	}
+	salt, err := c.GenerateSalt()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key, err := c.DeriveKey(password, salt, kdf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive key: %w", err)
+	}
+	defer crypto.Wipe(key)
+
+	plaintext, err := json.Marshal(payload{ExportedAt: now.UTC(), Records: records})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal records: %w", err)
+	}
+	defer crypto.Wipe(plaintext)
+
+	nonce, ciphertext, err := c.Encrypt(plaintext, key, nil)
+	if err != nil {
+		return nil, domain.ErrEncryptionFailed
+	}
+
+	return json.MarshalIndent(envelope{
+		Format:  encryptedFormatName,
+		Version: encryptedVersion,
+		KDF:     kdf,
+		Salt:    salt,
+		Nonce:   nonce,
+		Data:    ciphertext,
+	}, "", "  ")
+}
+
+// ImportEncrypted reads a file written by ExportEncrypted
+func ImportEncrypted(data []byte, password string, c domain.CryptoService) (*Import, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil || env.Format != encryptedFormatName {
+		return nil, malformed("not an encrypted export")
+	}
+	if env.Version != encryptedVersion {
+		return nil, malformed("unsupported export version %d", env.Version)
+	}
+
+	if password == "" {
+		return nil, ErrWrongPassword
+	}
+
+	// Anyone can hand in an export, so its parameters cannot be trusted
+	// like those of a vault file
+	if err := crypto.ValidateKDFCost(env.KDF); err != nil {
+		return nil, malformed("invalid key derivation parameters: %v", err)
+	}
+
+	key, err := c.DeriveKey(password, env.Salt, env.KDF)
+	if err != nil {
+		return nil, malformed("invalid key derivation parameters: %v", err)
+	}
+	defer crypto.Wipe(key)
+
+	plaintext, err := c.Decrypt(env.Nonce, env.Data, key, nil)
+	if err != nil {
+		return nil, ErrWrongPassword
+	}
+	defer crypto.Wipe(plaintext)
+
+	var content payload
+	if err := json.Unmarshal(plaintext, &content); err != nil {
+		return nil, malformed("invalid records: %v", err)
+	}
+	return &Import{Records: content.Records}, nil
+}
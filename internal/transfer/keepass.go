@@ -0,0 +1,164 @@
+package transfer
+
+import (
+	"encoding/xml"
+	"strings"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// keePassFile is the part of a KeePass 2 XML export that is imported
+type keePassFile struct {
+	Meta struct {
+		RecycleBinUUID string `xml:"RecycleBinUUID"`
+	} `xml:"Meta"`
+	Root struct {
+		Groups []keePassGroup `xml:"Group"`
+	} `xml:"Root"`
+}
+
+type keePassGroup struct {
+	UUID    string         `xml:"UUID"`
+	Name    string         `xml:"Name"`
+	Entries []keePassEntry `xml:"Entry"`
+	Groups  []keePassGroup `xml:"Group"`
+}
+
+type keePassEntry struct {
+	Strings []keePassString `xml:"String"`
+	Tags    string          `xml:"Tags"`
+	Times   keePassTimes    `xml:"Times"`
+	History struct {
+		Entries []keePassEntry `xml:"Entry"`
+	} `xml:"History"`
+}
+
+type keePassString struct {
+	Key   string `xml:"Key"`
+	Value struct {
+		Text            string `xml:",chardata"`
+		Protected       bool   `xml:"Protected,attr"`
+		ProtectInMemory bool   `xml:"ProtectInMemory,attr"`
+	} `xml:"Value"`
+}
+
+type keePassTimes struct {
+	CreationTime         string `xml:"CreationTime"`
+	LastModificationTime string `xml:"LastModificationTime"`
+}
+
+// ImportKeePass reads a KeePass 2 XML export. The path of groups below the
+// root group becomes the folder, and entries in the recycle bin are left
+// out. Strings other than the standard fields and TOTP settings become
+// custom fields.
+func ImportKeePass(data []byte) (*Import, error) {
+	var file keePassFile
+	if err := xml.Unmarshal(data, &file); err != nil {
+		return nil, malformed("invalid KeePass XML: %v", err)
+	}
+	if len(file.Root.Groups) == 0 {
+		return nil, malformed("no root group")
+	}
+
+	imp := &Import{}
+	for _, root := range file.Root.Groups {
+		if err := imp.keePassGroup(root, "", file.Meta.RecycleBinUUID); err != nil {
+			return nil, err
+		}
+	}
+	return imp, nil
+}
+
+// keePassGroup imports the entries of group and its subgroups into folder
+func (imp *Import) keePassGroup(group keePassGroup, folder, recycleBin string) error {
+	for _, entry := range group.Entries {
+		record, err := keePassRecord(entry)
+		if err != nil {
+			return err
+		}
+		record.Folder = folder
+		imp.Records = append(imp.Records, record)
+	}
+
+	for _, sub := range group.Groups {
+		if recycleBin != "" && sub.UUID == recycleBin {
+			continue
+		}
+		path := sub.Name
+		if folder != "" {
+			path = folder + "/" + sub.Name
+		}
+		if err := imp.keePassGroup(sub, path, recycleBin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keePassRecord converts an entry to a login
+func keePassRecord(entry keePassEntry) (domain.PasswordRecord, error) {
+	record := domain.PasswordRecord{
+		Type: domain.ItemTypeLogin,
+		Tags: splitList(entry.Tags),
+	}
+	if created, err := time.Parse(time.RFC3339, entry.Times.CreationTime); err == nil {
+		record.CreatedAt = created
+	}
+
+	for _, s := range entry.Strings {
+		if s.Value.Protected {
+			return record, malformed("protected values are only found in database files, export as KeePass XML (2.x) instead")
+		}
+		value := s.Value.Text
+		switch s.Key {
+		case "Title":
+			record.Name = value
+		case "UserName":
+			record.Username = value
+		case "Password":
+			record.Password = value
+		case "URL":
+			if value = strings.TrimSpace(value); value != "" {
+				record.URLs = []string{value}
+			}
+		case "Notes":
+			record.Notes = value
+		case "otp", "TimeOtp-Secret-Base32":
+			record.TOTP = strings.TrimSpace(value)
+		default:
+			// The remaining TimeOtp settings describe the secret imported above
+			if strings.HasPrefix(s.Key, "TimeOtp-") || value == "" {
+				continue
+			}
+			field := domain.CustomField{Name: s.Key, Value: value, Type: domain.CustomFieldText}
+			if s.Value.ProtectInMemory {
+				field.Type = domain.CustomFieldHidden
+			}
+			record.CustomFields = append(record.CustomFields, field)
+		}
+	}
+
+	// KeePass keeps whole old versions of an entry, oldest first; only the
+	// passwords that differ from the next version are worth keeping
+	next := record.Password
+	for i := len(entry.History.Entries) - 1; i >= 0; i-- {
+		old := entry.History.Entries[i]
+		for _, s := range old.Strings {
+			if s.Key != "Password" || s.Value.Text == "" || s.Value.Text == next {
+				continue
+			}
+			changedAt, _ := time.Parse(time.RFC3339, old.Times.LastModificationTime)
+			record.History = append(record.History, domain.PasswordHistoryEntry{
+				Password:  s.Value.Text,
+				ChangedAt: changedAt,
+			})
+			next = s.Value.Text
+		}
+	}
+	for i := range record.History {
+		record.History[i].Version = len(record.History) - i
+	}
+
+	return record, nil
+}
@@ -0,0 +1,100 @@
+// Package transfer moves vault records in and out of files: a
+// password-protected JSON export for backups and moving between vaults, a
+// plaintext CSV export, and imports from CSV, Bitwarden JSON, 1Password CSV
+// and KeePass 2 XML exports.
+package transfer
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// MaxFileSize is the largest file accepted for import
+const MaxFileSize = 10 << 20
+
+// Format names a file format
+type Format string
+
+const (
+	// FormatEncrypted is this program's password-protected JSON export
+	FormatEncrypted Format = "encrypted"
+	// FormatCSV is a plaintext CSV file with a header row
+	FormatCSV Format = "csv"
+	// FormatBitwarden is an unencrypted Bitwarden JSON export
+	FormatBitwarden Format = "bitwarden"
+	// Format1Password is a 1Password CSV export
+	Format1Password Format = "1password"
+	// FormatKeePass is a KeePass 2 XML export
+	FormatKeePass Format = "keepass"
+)
+
+var (
+	// ErrUnknownFormat is returned for a format name that is not supported
+	ErrUnknownFormat = errors.New("transfer: unknown format")
+	// ErrMalformed is returned for a file that does not match its format
+	ErrMalformed = errors.New("transfer: malformed file")
+	// ErrWrongPassword is returned when an encrypted export cannot be
+	// decrypted with the given password
+	ErrWrongPassword = errors.New("transfer: wrong export password")
+	// ErrWeakPassword is returned for an export password shorter than
+	// MinExportPasswordLength
+	ErrWeakPassword = errors.New("transfer: export password is too short")
+)
+
+// ParseFormat converts a format name to a Format
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(strings.TrimSpace(name))); format {
+	case FormatEncrypted, FormatCSV, FormatBitwarden, Format1Password, FormatKeePass:
+		return format, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrUnknownFormat, name)
+}
+
+// CanExport reports whether records can be written in the format
+func (f Format) CanExport() bool {
+	return f == FormatEncrypted || f == FormatCSV
+}
+
+// Read parses an import file. password is only used by FormatEncrypted.
+func Read(format Format, data []byte, password string, c domain.CryptoService) (*Import, error) {
+	switch format {
+	case FormatEncrypted:
+		return ImportEncrypted(data, password, c)
+	case FormatCSV, Format1Password:
+		return ImportCSV(data)
+	case FormatBitwarden:
+		return ImportBitwarden(data)
+	case FormatKeePass:
+		return ImportKeePass(data)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+// Import holds the records read from a file. Entries that could not be
+// converted are described in Skipped instead of failing the whole import.
+// The records are not validated; IDs are left empty.
+type Import struct {
+	Records []domain.PasswordRecord
+	Skipped []string
+}
+
+// skip records why an entry was left out
+func (imp *Import) skip(entry, reason string) {
+	imp.Skipped = append(imp.Skipped, fmt.Sprintf("%s: %s", entry, reason))
+}
+
+// malformed wraps a parse error in ErrMalformed
+func malformed(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrMalformed, fmt.Sprintf(format, args...))
+}
+
+// splitList splits a cell holding several values separated by newlines,
+// commas or semicolons
+func splitList(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == '\n' || r == '\r' || r == ',' || r == ';'
+	})
+}
@@ -0,0 +1,316 @@
+package transfer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/crypto"
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// The fixtures are hand-written in the layout of real exports and only
+// contain made-up accounts.
+
+func readImport(t *testing.T, format Format, path string) *Import {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	imp, err := Read(format, data, "", nil)
+	if err != nil {
+		t.Fatalf("Read(%s) failed: %v", format, err)
+	}
+	return imp
+}
+
+func findRecord(t *testing.T, imp *Import, name string) domain.PasswordRecord {
+	t.Helper()
+
+	for _, record := range imp.Records {
+		if record.Name == name {
+			return record
+		}
+	}
+	t.Fatalf("record %q not imported", name)
+	return domain.PasswordRecord{}
+}
+
+func TestParseFormat(t *testing.T) {
+	if format, err := ParseFormat(" KeePass "); err != nil || format != FormatKeePass {
+		t.Errorf("ParseFormat() = %q, %v", format, err)
+	}
+	if _, err := ParseFormat("lastpass"); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("expected ErrUnknownFormat, got %v", err)
+	}
+	if FormatBitwarden.CanExport() || !FormatCSV.CanExport() {
+		t.Error("only the encrypted and CSV formats can be exported")
+	}
+}
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	c := crypto.NewService()
+	records := []domain.PasswordRecord{{
+		ID:       "1",
+		Name:     "GitHub",
+		Type:     domain.ItemTypeLogin,
+		Username: "octocat",
+		Password: "new-password",
+		History:  []domain.PasswordHistoryEntry{{Version: 1, Password: "old-password", ChangedAt: time.Unix(1700000000, 0).UTC()}},
+	}}
+
+	if _, err := ExportEncrypted(records, "short", c, time.Now()); err != ErrWeakPassword {
+		t.Errorf("expected ErrWeakPassword, got %v", err)
+	}
+
+	data, err := ExportEncrypted(records, "export-password", c, time.Now())
+	if err != nil {
+		t.Fatalf("ExportEncrypted() failed: %v", err)
+	}
+	if strings.Contains(string(data), "new-password") || strings.Contains(string(data), "octocat") {
+		t.Error("export contains plaintext")
+	}
+
+	if _, err := ImportEncrypted(data, "wrong-password", c); err != ErrWrongPassword {
+		t.Errorf("expected ErrWrongPassword, got %v", err)
+	}
+	if _, err := ImportEncrypted([]byte(`{"items": []}`), "export-password", c); !errors.Is(err, ErrMalformed) {
+		t.Errorf("expected ErrMalformed, got %v", err)
+	}
+
+	// A file cannot ask for more work than the strongest profile
+	var env envelope
+	json.Unmarshal(data, &env)
+	env.KDF.Memory = crypto.MaxArgon2Memory
+	costly, _ := json.Marshal(env)
+	if _, err := ImportEncrypted(costly, "export-password", c); !errors.Is(err, ErrMalformed) {
+		t.Errorf("expected ErrMalformed for costly parameters, got %v", err)
+	}
+
+	imp, err := ImportEncrypted(data, "export-password", c)
+	if err != nil {
+		t.Fatalf("ImportEncrypted() failed: %v", err)
+	}
+	if !reflect.DeepEqual(imp.Records, records) {
+		t.Errorf("round trip changed records:\ngot  %+v\nwant %+v", imp.Records, records)
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	records := []domain.PasswordRecord{
+		{
+			Name:     "Mail",
+			Type:     domain.ItemTypeLogin,
+			Username: "jane",
+			Password: `pa,ss"word`,
+			URLs:     []string{"https://mail.example.com", "https://webmail.example.com"},
+			Notes:    "line one\nline two",
+			Folder:   "Personal",
+			Tags:     []string{"email", "important"},
+		},
+		{Name: "Wi-Fi", Type: domain.ItemTypeSecureNote, Notes: "Key: hunter22"},
+		{Name: "Visa", Type: domain.ItemTypeCard, Card: &domain.CardData{Number: "4111111111111111"}},
+	}
+
+	data, skipped, err := ExportCSV(records)
+	if err != nil {
+		t.Fatalf("ExportCSV() failed: %v", err)
+	}
+	if !reflect.DeepEqual(skipped, []string{"Visa"}) {
+		t.Errorf("expected the card to be skipped, got %v", skipped)
+	}
+
+	imp, err := ImportCSV(data)
+	if err != nil {
+		t.Fatalf("ImportCSV() failed: %v", err)
+	}
+	if len(imp.Records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(imp.Records))
+	}
+	if !reflect.DeepEqual(imp.Records[0], records[0]) {
+		t.Errorf("login changed:\ngot  %+v\nwant %+v", imp.Records[0], records[0])
+	}
+	if imp.Records[1].Type != domain.ItemTypeSecureNote || imp.Records[1].Notes != "Key: hunter22" {
+		t.Errorf("unexpected note: %+v", imp.Records[1])
+	}
+}
+
+func TestExportCSVEscapesFormulas(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		want     string
+	}{
+		{"equals sign", "=HYPERLINK(\"http://evil.example\")", "'=HYPERLINK(\"http://evil.example\")"},
+		{"plus sign", "+1+2", "'+1+2"},
+		{"minus sign", "-2+3", "'-2+3"},
+		{"at sign", "@SUM(A1)", "'@SUM(A1)"},
+		{"tab", "\t=1", "'\t=1"},
+		{"quote", "'=1", "''=1"},
+		{"plain", "pass=word", "pass=word"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records := []domain.PasswordRecord{{Name: "Site", Type: domain.ItemTypeLogin, Password: tt.password}}
+			data, _, err := ExportCSV(records)
+			if err != nil {
+				t.Fatalf("ExportCSV() failed: %v", err)
+			}
+
+			rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+			if err != nil {
+				t.Fatalf("failed to read the export: %v", err)
+			}
+			if got := rows[1][3]; got != tt.want {
+				t.Errorf("expected password cell %q, got %q", tt.want, got)
+			}
+
+			// Importing the export gives back the password
+			imp, err := ImportCSV(data)
+			if err != nil {
+				t.Fatalf("ImportCSV() failed: %v", err)
+			}
+			if got := imp.Records[0].Password; got != tt.password {
+				t.Errorf("expected imported password %q, got %q", tt.password, got)
+			}
+		})
+	}
+
+	// Other programs' files are taken as they are
+	imp, err := ImportCSV([]byte("name,password\nSite,'=1\n"))
+	if err != nil {
+		t.Fatalf("ImportCSV() failed: %v", err)
+	}
+	if got := imp.Records[0].Password; got != "'=1" {
+		t.Errorf("expected password %q, got %q", "'=1", got)
+	}
+}
+
+func TestImportCSVErrors(t *testing.T) {
+	if _, err := ImportCSV(nil); !errors.Is(err, ErrMalformed) {
+		t.Errorf("expected ErrMalformed for an empty file, got %v", err)
+	}
+	if _, err := ImportCSV([]byte("foo,bar\n1,2\n")); !errors.Is(err, ErrMalformed) {
+		t.Errorf("expected ErrMalformed without known columns, got %v", err)
+	}
+
+	imp, err := ImportCSV([]byte("type,name,password\nidentity,Passport,\nlogin,Site,secret\n"))
+	if err != nil {
+		t.Fatalf("ImportCSV() failed: %v", err)
+	}
+	if len(imp.Records) != 1 || len(imp.Skipped) != 1 || !strings.HasPrefix(imp.Skipped[0], "line 2") {
+		t.Errorf("expected the identity on line 2 to be skipped, got %+v", imp)
+	}
+}
+
+func TestImport1Password(t *testing.T) {
+	imp := readImport(t, Format1Password, "testdata/1password.csv")
+
+	if len(imp.Records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(imp.Records))
+	}
+	if len(imp.Skipped) != 1 || !strings.Contains(imp.Skipped[0], "archived") {
+		t.Errorf("expected the archived item to be skipped, got %v", imp.Skipped)
+	}
+
+	bank := findRecord(t, imp, "Bank")
+	if bank.Username != "jane" || bank.Password != "bank-password" || bank.Notes != "Branch 12\nAccount 3456" ||
+		!reflect.DeepEqual(bank.URLs, []string{"https://bank.example.com"}) || !reflect.DeepEqual(bank.Tags, []string{"finance"}) {
+		t.Errorf("unexpected record: %+v", bank)
+	}
+	if forum := findRecord(t, imp, "Forum"); !strings.HasPrefix(forum.TOTP, "otpauth://") {
+		t.Errorf("expected the TOTP URI, got %q", forum.TOTP)
+	}
+}
+
+func TestImportBitwarden(t *testing.T) {
+	imp := readImport(t, FormatBitwarden, "testdata/bitwarden.json")
+
+	if len(imp.Records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(imp.Records))
+	}
+	if len(imp.Skipped) != 1 || !strings.HasPrefix(imp.Skipped[0], "Passport") {
+		t.Errorf("expected the identity to be skipped, got %v", imp.Skipped)
+	}
+
+	login := findRecord(t, imp, "GitHub")
+	if login.Type != domain.ItemTypeLogin || login.Folder != "Work" || login.Username != "octocat" ||
+		login.Password != "correct-horse-battery" || login.TOTP != "JBSWY3DPEHPK3PXP" || len(login.URLs) != 2 {
+		t.Errorf("unexpected login: %+v", login)
+	}
+	wantFields := []domain.CustomField{
+		{Name: "Recovery code", Value: "a1b2-c3d4", Type: domain.CustomFieldHidden},
+		{Name: "Plan", Value: "free", Type: domain.CustomFieldText},
+	}
+	if !reflect.DeepEqual(login.CustomFields, wantFields) {
+		t.Errorf("unexpected custom fields: %+v", login.CustomFields)
+	}
+	if len(login.History) != 2 || login.History[0].Password != "older-password" || login.History[0].Version != 2 {
+		t.Errorf("expected the history newest first, got %+v", login.History)
+	}
+	if !login.CreatedAt.Equal(time.Date(2022, 6, 1, 9, 15, 0, 0, time.UTC)) {
+		t.Errorf("unexpected creation date %v", login.CreatedAt)
+	}
+
+	if note := findRecord(t, imp, "Wi-Fi"); note.Type != domain.ItemTypeSecureNote || note.Notes == "" {
+		t.Errorf("unexpected note: %+v", note)
+	}
+	card := findRecord(t, imp, "Visa")
+	want := domain.CardData{Cardholder: "Jane Doe", Brand: "Visa", Number: "4111 1111 1111 1111", ExpMonth: 7, ExpYear: 2030, CVV: "123"}
+	if card.Type != domain.ItemTypeCard || card.Card == nil || *card.Card != want {
+		t.Errorf("unexpected card: %+v", card.Card)
+	}
+
+	if _, err := ImportBitwarden([]byte(`{"encrypted": true, "data": "..."}`)); !errors.Is(err, ErrMalformed) {
+		t.Errorf("expected ErrMalformed for an encrypted export, got %v", err)
+	}
+}
+
+func TestImportKeePass(t *testing.T) {
+	imp := readImport(t, FormatKeePass, "testdata/keepass.xml")
+
+	if len(imp.Records) != 2 {
+		t.Fatalf("expected 2 records outside the recycle bin, got %d", len(imp.Records))
+	}
+
+	mail := findRecord(t, imp, "Mail")
+	if mail.Folder != "" || mail.Username != "jane@example.com" || mail.Password != "mail-password-3" ||
+		mail.TOTP != "JBSWY3DPEHPK3PXP" || mail.Notes != "Main mailbox" ||
+		!reflect.DeepEqual(mail.Tags, []string{"email", "personal"}) {
+		t.Errorf("unexpected record: %+v", mail)
+	}
+	wantFields := []domain.CustomField{
+		{Name: "PIN", Value: "4321", Type: domain.CustomFieldHidden},
+		{Name: "Recovery email", Value: "jane.backup@example.com", Type: domain.CustomFieldText},
+	}
+	if !reflect.DeepEqual(mail.CustomFields, wantFields) {
+		t.Errorf("unexpected custom fields: %+v", mail.CustomFields)
+	}
+	wantHistory := []domain.PasswordHistoryEntry{
+		{Version: 2, Password: "mail-password-2", ChangedAt: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{Version: 1, Password: "mail-password-1", ChangedAt: time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	if !reflect.DeepEqual(mail.History, wantHistory) {
+		t.Errorf("unexpected history: %+v", mail.History)
+	}
+
+	if shop := findRecord(t, imp, "Shop"); shop.Folder != "Internet/Shopping" || !strings.HasPrefix(shop.TOTP, "otpauth://") {
+		t.Errorf("unexpected record: %+v", shop)
+	}
+
+	protected := `<KeePassFile><Root><Group><Name>Root</Name><Entry>
+		<String><Key>Password</Key><Value Protected="True">c2VjcmV0</Value></String>
+	</Entry></Group></Root></KeePassFile>`
+	if _, err := ImportKeePass([]byte(protected)); !errors.Is(err, ErrMalformed) {
+		t.Errorf("expected ErrMalformed for protected values, got %v", err)
+	}
+}
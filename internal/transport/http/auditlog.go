@@ -0,0 +1,55 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/orlan/go-password-manager/internal/application"
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// AuditLogResponse lists the audit log of a vault, oldest entry first
+type AuditLogResponse struct {
+	VaultName string                      `json:"vault_name"`
+	Entries   []application.AuditLogEntry `json:"entries"`
+}
+
+// handleAuditLog returns the audit log of an unlocked vault
+func (h *Handler) handleAuditLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	vaultName := r.URL.Query().Get("vault_name")
+	if vaultName == "" {
+		h.sendError(w, "vault_name query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.validVaultName(w, vaultName) {
+		return
+	}
+
+	entries, err := h.service.AuditLog(r.Context(), sessionToken(r), vaultName)
+	if err != nil {
+		if err == domain.ErrInvalidSession {
+			h.sendSessionError(w, r, err)
+			return
+		}
+		if err == domain.ErrPermissionDenied {
+			h.sendError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err == domain.ErrVaultModified {
+			h.sendError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		h.sendError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if entries == nil {
+		entries = []application.AuditLogEntry{}
+	}
+	h.sendJSON(w, AuditLogResponse{VaultName: vaultName, Entries: entries})
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/orlan/go-password-manager/internal/application"
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+func TestHandleAuditLog(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.service.CreateVault(nil, "test-vault", "my-password", "interactive")
+	token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+	handler.service.AddPasswordRecord(nil, token, "test-vault", application.RecordInput{Name: "github", Username: "user", Password: "secret"})
+
+	get := func(path string, handle http.HandlerFunc, token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.RemoteAddr = "198.51.100.7:4321"
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		handle(w, r)
+		return w
+	}
+
+	if w := get("/api/records/get?vault_name=test-vault&name=github", handler.handleGetRecord, token); w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	w := get("/api/vaults/audit-log?vault_name=test-vault", handler.handleAuditLog, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var response AuditLogResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	if response.VaultName != "test-vault" || len(response.Entries) != 3 {
+		t.Fatalf("unexpected response %+v", response)
+	}
+	last := response.Entries[2]
+	if last.Event != domain.AuditLogGet || last.Actor != "198.51.100.7" || last.Record != "github" || !last.Verified {
+		t.Errorf("unexpected last entry %+v", last)
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		token      string
+		wantStatus int
+	}{
+		{"missing vault name", "/api/vaults/audit-log", token, http.StatusBadRequest},
+		{"invalid vault name", "/api/vaults/audit-log?vault_name=../escape", token, http.StatusBadRequest},
+		{"no session", "/api/vaults/audit-log?vault_name=test-vault", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := get(tt.path, handler.handleAuditLog, tt.token); w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("viewer", func(t *testing.T) {
+		handler.service.AddMember(nil, token, "test-vault", application.MemberInput{Name: "victor", Password: "victor-password", KDFProfile: "interactive"})
+		viewerToken, err := handler.service.UnlockVaultAsMember(nil, "test-vault", "victor", "victor-password", "")
+		if err != nil {
+			t.Fatalf("UnlockVaultAsMember() failed: %v", err)
+		}
+		if w := get("/api/vaults/audit-log?vault_name=test-vault", handler.handleAuditLog, viewerToken); w.Code != http.StatusForbidden {
+			t.Errorf("expected status %d, got %d: %s", http.StatusForbidden, w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("client of other reads", func(t *testing.T) {
+		get("/api/records/history?vault_name=test-vault&name=github", handler.handleRecordHistory, token)
+		get("/api/vaults/audit?vault_name=test-vault", handler.handleAuditVault, token)
+
+		entries, err := handler.service.AuditLog(nil, token, "test-vault")
+		if err != nil {
+			t.Fatalf("AuditLog() failed: %v", err)
+		}
+		for _, entry := range entries[len(entries)-2:] {
+			if entry.Actor != "198.51.100.7" {
+				t.Errorf("expected the client address as actor, got %+v", entry.AuditLogEntry)
+			}
+		}
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/api/vaults/audit-log?vault_name=test-vault", nil)
+		w := httptest.NewRecorder()
+		handler.handleAuditLog(w, r)
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+		}
+	})
+}
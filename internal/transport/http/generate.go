@@ -0,0 +1,127 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/orlan/go-password-manager/internal/generator"
+)
+
+// Kinds of secrets /api/generate can create
+const (
+	generateKindPassword   = "password"
+	generateKindPassphrase = "passphrase"
+)
+
+// PasswordPolicyRequest describes a password to generate. Omitted fields
+// use the defaults: 20 characters drawn from every character class.
+type PasswordPolicyRequest struct {
+	Length           int   `json:"length,omitempty"`
+	Lowercase        *bool `json:"lowercase,omitempty"`
+	Uppercase        *bool `json:"uppercase,omitempty"`
+	Digits           *bool `json:"digits,omitempty"`
+	Symbols          *bool `json:"symbols,omitempty"`
+	ExcludeAmbiguous bool  `json:"exclude_ambiguous,omitempty"`
+	MinLowercase     int   `json:"min_lowercase,omitempty"`
+	MinUppercase     int   `json:"min_uppercase,omitempty"`
+	MinDigits        int   `json:"min_digits,omitempty"`
+	MinSymbols       int   `json:"min_symbols,omitempty"`
+}
+
+// policy converts the request into a generator policy
+func (req PasswordPolicyRequest) policy() generator.PasswordPolicy {
+	policy := generator.DefaultPasswordPolicy()
+	if req.Length != 0 {
+		policy.Length = req.Length
+	}
+	for _, class := range []struct {
+		value   *bool
+		enabled *bool
+	}{
+		{req.Lowercase, &policy.Lowercase},
+		{req.Uppercase, &policy.Uppercase},
+		{req.Digits, &policy.Digits},
+		{req.Symbols, &policy.Symbols},
+	} {
+		if class.value != nil {
+			*class.enabled = *class.value
+		}
+	}
+	policy.ExcludeAmbiguous = req.ExcludeAmbiguous
+	policy.MinLowercase = req.MinLowercase
+	policy.MinUppercase = req.MinUppercase
+	policy.MinDigits = req.MinDigits
+	policy.MinSymbols = req.MinSymbols
+	return policy
+}
+
+// GenerateRequest represents a request to generate a password or, with
+// kind "passphrase", a passphrase of random words
+type GenerateRequest struct {
+	Kind string `json:"kind,omitempty"`
+	PasswordPolicyRequest
+
+	Words         int     `json:"words,omitempty"`
+	Separator     *string `json:"separator,omitempty"`
+	Capitalize    bool    `json:"capitalize,omitempty"`
+	IncludeNumber bool    `json:"include_number,omitempty"`
+}
+
+// GenerateResponse represents a generated secret
+type GenerateResponse struct {
+	Value       string  `json:"value"`
+	EntropyBits float64 `json:"entropy_bits"`
+}
+
+// handleGenerate generates a password or passphrase. It needs no session,
+// as nothing is read from or written to a vault.
+func (h *Handler) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req GenerateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var (
+		response GenerateResponse
+		err      error
+	)
+	switch req.Kind {
+	case "", generateKindPassword:
+		policy := req.policy()
+		response.Value, err = generator.Password(policy)
+		response.EntropyBits = policy.Entropy()
+	case generateKindPassphrase:
+		policy := generator.DefaultPassphrasePolicy()
+		if req.Words != 0 {
+			policy.Words = req.Words
+		}
+		if req.Separator != nil {
+			policy.Separator = *req.Separator
+		}
+		policy.Capitalize = req.Capitalize
+		policy.IncludeNumber = req.IncludeNumber
+		response.Value, err = generator.Passphrase(policy)
+		response.EntropyBits = policy.Entropy()
+	default:
+		h.sendError(w, "kind must be password or passphrase", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		if errors.Is(err, generator.ErrInvalidPolicy) {
+			h.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.sendError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.sendJSON(w, response)
+}
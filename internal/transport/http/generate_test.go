@@ -0,0 +1,118 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleGenerate(t *testing.T) {
+	generate := func(t *testing.T, handler *Handler, body string) (*httptest.ResponseRecorder, GenerateResponse) {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPost, "/api/generate", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		handler.handleGenerate(w, req)
+
+		var response GenerateResponse
+		json.NewDecoder(bytes.NewReader(w.Body.Bytes())).Decode(&response)
+		return w, response
+	}
+
+	t.Run("generates default password", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		w, response := generate(t, handler, `{}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if len(response.Value) != 20 || response.EntropyBits <= 0 {
+			t.Errorf("unexpected response %+v", response)
+		}
+	})
+
+	t.Run("applies password policy", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		w, response := generate(t, handler, `{"length":12,"symbols":false,"uppercase":false,"lowercase":false}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if len(response.Value) != 12 || strings.Trim(response.Value, "0123456789") != "" {
+			t.Errorf("expected 12 digits, got %q", response.Value)
+		}
+	})
+
+	t.Run("generates passphrase", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		w, response := generate(t, handler, `{"kind":"passphrase","words":4,"separator":"."}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if len(strings.Split(response.Value, ".")) != 4 {
+			t.Errorf("expected 4 words, got %q", response.Value)
+		}
+	})
+
+	t.Run("returns error for invalid policy", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		for _, body := range []string{
+			`{"length":1000}`,
+			`{"lowercase":false,"uppercase":false,"digits":false,"symbols":false}`,
+			`{"kind":"passphrase","words":1}`,
+			`{"kind":"pin"}`,
+		} {
+			w, _ := generate(t, handler, body)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status %d, got %d", body, http.StatusBadRequest, w.Code)
+			}
+		}
+	})
+
+	t.Run("returns error for wrong method", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/generate", nil)
+		w := httptest.NewRecorder()
+
+		handler.handleGenerate(w, req)
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+		}
+	})
+}
+
+func TestHandleAddRecordGenerate(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	handler.service.CreateVault(nil, "test-vault", "my-password", "")
+	token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+
+	body := []byte(`{"vault_name":"test-vault","name":"github","username":"user","generate":{"length":24}}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/records/add", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	handler.handleAddRecord(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var response AddRecordResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	if len(response.GeneratedPassword) != 24 {
+		t.Fatalf("expected a 24 character password, got %q", response.GeneratedPassword)
+	}
+
+	record, _ := handler.service.GetPasswordRecord(nil, token, "test-vault", "github")
+	if record.Password != response.GeneratedPassword {
+		t.Error("stored password should be the generated one")
+	}
+}
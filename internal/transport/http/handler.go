@@ -2,24 +2,39 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/orlan/go-password-manager/internal/application"
 	"github.com/orlan/go-password-manager/internal/domain"
+	"github.com/orlan/go-password-manager/internal/generator"
 )
 
+// SessionCookieName is the cookie carrying the vault session token
+const SessionCookieName = "vault_session"
+
 // Handler handles HTTP requests for the password manager API
 type Handler struct {
 	service     *application.VaultService
 	csrfManager *CSRFManager
+	lockedLog   *lockedSessionLog
 }
 
 // NewHandler creates a new HTTP handler
 func NewHandler(service *application.VaultService) *Handler {
-	return &Handler{
+	h := &Handler{
 		service:     service,
 		csrfManager: NewCSRFManager(),
+		lockedLog:   newLockedSessionLog(),
 	}
+
+	// Remember automatic locks so clients can be told why their token stopped working
+	service.OnSessionLocked(h.lockedLog.record)
+
+	return h
 }
 
 // RegisterRoutes sets up the HTTP routes
@@ -31,11 +46,27 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/api/vaults/create", h.handleCreateVault)
 	mux.HandleFunc("/api/vaults/unlock", h.handleUnlockVault)
 	mux.HandleFunc("/api/vaults/lock", h.handleLockVault)
+	mux.HandleFunc("/api/vaults/change-password", h.handleChangePassword)
+	mux.HandleFunc("/api/vaults/audit", h.handleAuditVault)
+	mux.HandleFunc("/api/vaults/audit-log", h.handleAuditLog)
+	mux.HandleFunc("/api/vaults/export", h.handleExportVault)
+	mux.HandleFunc("/api/vaults/import", h.handleImportVault)
+	mux.HandleFunc("/api/vaults/members", h.handleMembers)
+	mux.HandleFunc("/api/vaults/members/add", h.handleAddMember)
+	mux.HandleFunc("/api/vaults/members/remove", h.handleRemoveMember)
+	mux.HandleFunc("/api/vaults/members/role", h.handleSetMemberRole)
+	mux.HandleFunc("/api/vaults/two-factor/setup", h.handleTwoFactorSetup)
+	mux.HandleFunc("/api/vaults/two-factor/enable", h.handleEnableTwoFactor)
+	mux.HandleFunc("/api/vaults/two-factor/disable", h.handleDisableTwoFactor)
 	mux.HandleFunc("/api/records", h.handleRecords)
 	mux.HandleFunc("/api/records/add", h.handleAddRecord)
 	mux.HandleFunc("/api/records/get", h.handleGetRecord)
 	mux.HandleFunc("/api/records/update", h.handleUpdateRecord)
 	mux.HandleFunc("/api/records/delete", h.handleDeleteRecord)
+	mux.HandleFunc("/api/records/history", h.handleRecordHistory)
+	mux.HandleFunc("/api/records/restore", h.handleRestoreRecord)
+	mux.HandleFunc("/api/records/totp", h.handleTOTP)
+	mux.HandleFunc("/api/generate", h.handleGenerate)
 	mux.HandleFunc("/health", h.handleHealth)
 }
 
@@ -48,12 +79,35 @@ func (h *Handler) GetCSRFMiddleware() func(http.Handler) http.Handler {
 type CreateVaultRequest struct {
 	Name           string `json:"name"`
 	MasterPassword string `json:"master_password"`
+	KDFProfile     string `json:"kdf_profile,omitempty"`
+	// TwoFactor requires a one-time code to unlock the new vault
+	TwoFactor bool `json:"two_factor,omitempty"`
+}
+
+// CreateVaultResponse is returned after a vault has been created
+type CreateVaultResponse struct {
+	Message string `json:"message"`
+	// TwoFactor is the key to add to an authenticator app, shown only once
+	TwoFactor *TwoFactorSetupResponse `json:"two_factor,omitempty"`
 }
 
 // UnlockVaultRequest represents a request to unlock a vault
 type UnlockVaultRequest struct {
 	Name           string `json:"name"`
 	MasterPassword string `json:"master_password"`
+	// TOTPCode is the one-time code for vaults with two-factor unlock
+	TOTPCode string `json:"totp_code,omitempty"`
+	// Member unlocks a shared vault with that member's password; empty
+	// selects the first member
+	Member string `json:"member,omitempty"`
+}
+
+// UnlockVaultResponse is returned after a vault has been unlocked.
+// The session token is also set as an HttpOnly cookie for browser clients;
+// API clients should send it back as an "Authorization: Bearer" header.
+type UnlockVaultResponse struct {
+	Message      string `json:"message"`
+	SessionToken string `json:"session_token"`
 }
 
 // LockVaultRequest represents a request to lock a vault
@@ -61,12 +115,52 @@ type LockVaultRequest struct {
 	Name string `json:"name"`
 }
 
-// AddRecordRequest represents a request to add a password record
+// ChangePasswordRequest represents a request to change a vault's master password
+type ChangePasswordRequest struct {
+	Name        string `json:"name"`
+	OldPassword string `json:"old_password"`
+	NewPassword string `json:"new_password"`
+	KDFProfile  string `json:"kdf_profile,omitempty"`
+	// Member changes the password of a member of a shared vault
+	Member string `json:"member,omitempty"`
+	// TOTPCode is required for vaults with two-factor unlock
+	TOTPCode string `json:"totp_code,omitempty"`
+}
+
+// AddRecordRequest represents a request to add a vault item. Type defaults
+// to login; other types carry their data in the matching field.
 type AddRecordRequest struct {
-	VaultName string `json:"vault_name"`
-	Name      string `json:"name"`
-	Username  string `json:"username"`
-	Password  string `json:"password"`
+	VaultName     string                    `json:"vault_name"`
+	Type          domain.ItemType           `json:"type,omitempty"`
+	Name          string                    `json:"name"`
+	Username      string                    `json:"username"`
+	Password      string                    `json:"password"`
+	TOTP          string                    `json:"totp,omitempty"`
+	URLs          []string                  `json:"urls,omitempty"`
+	Notes         string                    `json:"notes,omitempty"`
+	Folder        string                    `json:"folder,omitempty"`
+	Tags          []string                  `json:"tags,omitempty"`
+	CustomFields  []domain.CustomField      `json:"custom_fields,omitempty"`
+	Card          *domain.CardData          `json:"card,omitempty"`
+	SSHKey        *domain.SSHKeyData        `json:"ssh_key,omitempty"`
+	APICredential *domain.APICredentialData `json:"api_credential,omitempty"`
+	// Generate creates the password when none is given
+	Generate *PasswordPolicyRequest `json:"generate,omitempty"`
+}
+
+// AddRecordResponse represents the result of adding a record
+type AddRecordResponse struct {
+	Message string `json:"message"`
+	// GeneratedPassword is returned once when the password was generated
+	GeneratedPassword string `json:"generated_password,omitempty"`
+	// Warnings describe problems that did not stop the record from being saved
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// UpdateRecordResponse represents the result of updating a record
+type UpdateRecordResponse struct {
+	Message  string   `json:"message"`
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 // GetRecordRequest represents a request to retrieve a password record
@@ -75,12 +169,45 @@ type GetRecordRequest struct {
 	Name      string `json:"name"`
 }
 
-// UpdateRecordRequest represents a request to update a password record
+// UpdateRecordRequest represents a request to update a password record.
+// Empty username and password are left unchanged; the other fields are
+// left unchanged when omitted and cleared when sent empty.
 type UpdateRecordRequest struct {
-	VaultName string `json:"vault_name"`
-	Name      string `json:"name"`
-	Username  string `json:"username,omitempty"`
-	Password  string `json:"password,omitempty"`
+	VaultName     string                    `json:"vault_name"`
+	Name          string                    `json:"name"`
+	Username      string                    `json:"username,omitempty"`
+	Password      string                    `json:"password,omitempty"`
+	TOTP          *string                   `json:"totp,omitempty"`
+	URLs          *[]string                 `json:"urls,omitempty"`
+	Notes         *string                   `json:"notes,omitempty"`
+	Folder        *string                   `json:"folder,omitempty"`
+	Tags          *[]string                 `json:"tags,omitempty"`
+	CustomFields  *[]domain.CustomField     `json:"custom_fields,omitempty"`
+	Card          *domain.CardData          `json:"card,omitempty"`
+	SSHKey        *domain.SSHKeyData        `json:"ssh_key,omitempty"`
+	APICredential *domain.APICredentialData `json:"api_credential,omitempty"`
+}
+
+// recordUpdate converts the request into a service update
+func (req UpdateRecordRequest) recordUpdate() application.RecordUpdate {
+	update := application.RecordUpdate{
+		TOTP:          req.TOTP,
+		URLs:          req.URLs,
+		Notes:         req.Notes,
+		Folder:        req.Folder,
+		Tags:          req.Tags,
+		CustomFields:  req.CustomFields,
+		Card:          req.Card,
+		SSHKey:        req.SSHKey,
+		APICredential: req.APICredential,
+	}
+	if req.Username != "" {
+		update.Username = &req.Username
+	}
+	if req.Password != "" {
+		update.Password = &req.Password
+	}
+	return update
 }
 
 // DeleteRecordRequest represents a request to delete a password record
@@ -89,9 +216,20 @@ type DeleteRecordRequest struct {
 	Name      string `json:"name"`
 }
 
+// RestoreRecordRequest represents a request to restore a previous password
+type RestoreRecordRequest struct {
+	VaultName string `json:"vault_name"`
+	Name      string `json:"name"`
+	Version   int    `json:"version"`
+}
+
 // ErrorResponse represents an error response
 type ErrorResponse struct {
 	Error string `json:"error"`
+	// Reason is set when a session was locked automatically, e.g.
+	// "idle_timeout", or to ReasonTwoFactorRequired when an unlock needs a
+	// one-time code
+	Reason string `json:"reason,omitempty"`
 }
 
 // SuccessResponse represents a success response
@@ -167,16 +305,33 @@ func (h *Handler) handleCreateVault(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.service.CreateVault(r.Context(), req.Name, req.MasterPassword); err != nil {
+	if !h.validVaultName(w, req.Name) {
+		return
+	}
+
+	var setup *TwoFactorSetupResponse
+	var err error
+	if req.TwoFactor {
+		var key application.TwoFactorSetup
+		key, err = h.service.CreateVaultWithTwoFactor(r.Context(), req.Name, req.MasterPassword, req.KDFProfile)
+		setup = &TwoFactorSetupResponse{Secret: key.Secret, URI: key.URI}
+	} else {
+		err = h.service.CreateVault(r.Context(), req.Name, req.MasterPassword, req.KDFProfile)
+	}
+	if err != nil {
 		if err == domain.ErrVaultAlreadyExists {
 			h.sendError(w, err.Error(), http.StatusConflict)
 			return
 		}
+		if err == domain.ErrUnknownKDFProfile {
+			h.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		h.sendError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	h.sendJSON(w, SuccessResponse{Message: "vault created successfully"})
+	h.sendJSON(w, CreateVaultResponse{Message: "vault created successfully", TwoFactor: setup})
 }
 
 // handleUnlockVault unlocks a vault
@@ -197,12 +352,27 @@ func (h *Handler) handleUnlockVault(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.service.UnlockVault(r.Context(), req.Name, req.MasterPassword); err != nil {
+	if !h.validVaultName(w, req.Name) {
+		return
+	}
+
+	ctx := application.WithClient(r.Context(), clientAddress(r))
+	token, err := h.service.UnlockVaultAsMember(ctx, req.Name, req.Member, req.MasterPassword, req.TOTPCode)
+	if err != nil {
+		if h.sendTooManyAttempts(w, err) {
+			return
+		}
 		if err == domain.ErrVaultNotFound {
 			h.sendError(w, err.Error(), http.StatusNotFound)
 			return
 		}
-		if err == domain.ErrInvalidMasterPassword {
+		if err == domain.ErrTwoFactorRequired {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error(), Reason: ReasonTwoFactorRequired})
+			return
+		}
+		if err == domain.ErrInvalidMasterPassword || err == domain.ErrInvalidCredentials {
 			h.sendError(w, err.Error(), http.StatusUnauthorized)
 			return
 		}
@@ -210,7 +380,19 @@ func (h *Handler) handleUnlockVault(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.sendJSON(w, SuccessResponse{Message: "vault unlocked successfully"})
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    token,
+		Path:     "/api",
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	h.sendJSON(w, UnlockVaultResponse{
+		Message:      "vault unlocked successfully",
+		SessionToken: token,
+	})
 }
 
 // handleLockVault locks a vault
@@ -231,19 +413,124 @@ func (h *Handler) handleLockVault(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.service.LockVault(r.Context(), req.Name); err != nil {
+	if !h.validVaultName(w, req.Name) {
+		return
+	}
+
+	token := sessionToken(r)
+	if err := h.service.ValidateSession(r.Context(), token, req.Name); err != nil {
+		h.sendSessionError(w, r, err)
+		return
+	}
+
+	if err := h.service.LockVault(r.Context(), token); err != nil {
+		if err == domain.ErrInvalidSession {
+			h.sendSessionError(w, r, err)
+			return
+		}
+		h.sendError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Expire the session cookie
+	clearSessionCookie(w, r)
+
+	h.sendJSON(w, SuccessResponse{Message: "vault locked successfully"})
+}
+
+// handleChangePassword re-encrypts a vault under a new master password
+func (h *Handler) handleChangePassword(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == "" || req.OldPassword == "" || req.NewPassword == "" {
+		h.sendError(w, "name, old_password and new_password are required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.validVaultName(w, req.Name) {
+		return
+	}
+
+	ctx := application.WithClient(r.Context(), clientAddress(r))
+	err := h.service.ChangeMemberPassword(ctx, req.Name, req.Member, req.OldPassword, req.NewPassword, req.KDFProfile, req.TOTPCode)
+	if err != nil {
+		if h.sendTooManyAttempts(w, err) {
+			return
+		}
 		if err == domain.ErrVaultNotFound {
 			h.sendError(w, err.Error(), http.StatusNotFound)
 			return
 		}
+		if err == domain.ErrTwoFactorRequired {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(ErrorResponse{Error: err.Error(), Reason: ReasonTwoFactorRequired})
+			return
+		}
+		if err == domain.ErrInvalidMasterPassword || err == domain.ErrInvalidCredentials {
+			h.sendError(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		if err == domain.ErrUnknownKDFProfile {
+			h.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err == domain.ErrVaultModified {
+			h.sendError(w, err.Error(), http.StatusConflict)
+			return
+		}
 		h.sendError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	h.sendJSON(w, SuccessResponse{Message: "vault locked successfully"})
+	h.sendJSON(w, SuccessResponse{Message: "master password changed successfully"})
+}
+
+// handleAuditVault reports weak, reused and old passwords in a vault
+func (h *Handler) handleAuditVault(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	vaultName := r.URL.Query().Get("vault_name")
+	if vaultName == "" {
+		h.sendError(w, "vault_name query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.validVaultName(w, vaultName) {
+		return
+	}
+
+	ctx := application.WithClient(r.Context(), clientAddress(r))
+	report, err := h.service.AuditVault(ctx, sessionToken(r), vaultName)
+	if err != nil {
+		if err == domain.ErrInvalidSession {
+			h.sendSessionError(w, r, err)
+			return
+		}
+		if err == domain.ErrVaultModified {
+			h.sendError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		h.sendError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.sendJSON(w, report)
 }
 
-// handleRecords lists all records in a vault
+// handleRecords lists the records in a vault, optionally only those of one type
 func (h *Handler) handleRecords(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -256,10 +543,31 @@ func (h *Handler) handleRecords(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	records, err := h.service.ListPasswordRecords(r.Context(), vaultName)
+	if !h.validVaultName(w, vaultName) {
+		return
+	}
+
+	var (
+		records []domain.PasswordRecord
+		err     error
+	)
+	ctx := application.WithClient(r.Context(), clientAddress(r))
+	if itemType := r.URL.Query().Get("type"); itemType != "" {
+		records, err = h.service.ListPasswordRecordsByType(ctx, sessionToken(r), vaultName, domain.ItemType(itemType))
+	} else {
+		records, err = h.service.ListPasswordRecords(ctx, sessionToken(r), vaultName)
+	}
 	if err != nil {
-		if err == domain.ErrVaultNotFound {
-			h.sendError(w, "vault not found or not unlocked", http.StatusNotFound)
+		if err == domain.ErrUnknownItemType {
+			h.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err == domain.ErrInvalidSession {
+			h.sendSessionError(w, r, err)
+			return
+		}
+		if err == domain.ErrVaultModified {
+			h.sendError(w, err.Error(), http.StatusConflict)
 			return
 		}
 		h.sendError(w, err.Error(), http.StatusInternalServerError)
@@ -282,14 +590,63 @@ func (h *Handler) handleAddRecord(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.VaultName == "" || req.Name == "" || req.Username == "" || req.Password == "" {
+	if req.VaultName == "" || req.Name == "" {
+		h.sendError(w, "vault_name and name are required", http.StatusBadRequest)
+		return
+	}
+
+	// Logins keep requiring credentials; other types are checked by the service
+	isLogin := req.Type == "" || req.Type == domain.ItemTypeLogin
+	if isLogin && (req.Username == "" || (req.Password == "" && req.Generate == nil)) {
 		h.sendError(w, "vault_name, name, username, and password are required", http.StatusBadRequest)
 		return
 	}
 
-	if err := h.service.AddPasswordRecord(r.Context(), req.VaultName, req.Name, req.Username, req.Password); err != nil {
-		if err == domain.ErrVaultNotFound {
-			h.sendError(w, "vault not found or not unlocked", http.StatusNotFound)
+	if !h.validVaultName(w, req.VaultName) {
+		return
+	}
+
+	input := application.RecordInput{
+		Type:          req.Type,
+		Name:          req.Name,
+		Username:      req.Username,
+		Password:      req.Password,
+		TOTP:          req.TOTP,
+		URLs:          req.URLs,
+		Notes:         req.Notes,
+		Folder:        req.Folder,
+		Tags:          req.Tags,
+		CustomFields:  req.CustomFields,
+		Card:          req.Card,
+		SSHKey:        req.SSHKey,
+		APICredential: req.APICredential,
+	}
+	if req.Generate != nil {
+		policy := req.Generate.policy()
+		input.Generate = &policy
+	}
+
+	ctx := application.WithClient(r.Context(), clientAddress(r))
+	result, err := h.service.AddPasswordRecord(ctx, sessionToken(r), req.VaultName, input)
+	if err != nil {
+		if err == domain.ErrInvalidCustomField || err == domain.ErrInvalidItem || err == domain.ErrUnknownItemType || err == domain.ErrInvalidTOTP {
+			h.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, generator.ErrInvalidPolicy) {
+			h.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err == domain.ErrInvalidSession {
+			h.sendSessionError(w, r, err)
+			return
+		}
+		if err == domain.ErrPermissionDenied {
+			h.sendError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err == domain.ErrVaultModified {
+			h.sendError(w, err.Error(), http.StatusConflict)
 			return
 		}
 		if err == domain.ErrRecordAlreadyExists {
@@ -300,7 +657,11 @@ func (h *Handler) handleAddRecord(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.sendJSON(w, SuccessResponse{Message: "password record added successfully"})
+	h.sendJSON(w, AddRecordResponse{
+		Message:           "password record added successfully",
+		GeneratedPassword: result.GeneratedPassword,
+		Warnings:          result.Warnings,
+	})
 }
 
 // handleGetRecord retrieves a password record
@@ -318,10 +679,19 @@ func (h *Handler) handleGetRecord(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	record, err := h.service.GetPasswordRecord(r.Context(), vaultName, recordName)
+	if !h.validVaultName(w, vaultName) {
+		return
+	}
+
+	ctx := application.WithClient(r.Context(), clientAddress(r))
+	record, err := h.service.GetPasswordRecord(ctx, sessionToken(r), vaultName, recordName)
 	if err != nil {
-		if err == domain.ErrVaultNotFound {
-			h.sendError(w, "vault not found or not unlocked", http.StatusNotFound)
+		if err == domain.ErrInvalidSession {
+			h.sendSessionError(w, r, err)
+			return
+		}
+		if err == domain.ErrVaultModified {
+			h.sendError(w, err.Error(), http.StatusConflict)
 			return
 		}
 		if err == domain.ErrRecordNotFound {
@@ -353,14 +723,33 @@ func (h *Handler) handleUpdateRecord(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.Username == "" && req.Password == "" {
-		h.sendError(w, "at least username or password must be provided", http.StatusBadRequest)
+	if !h.validVaultName(w, req.VaultName) {
 		return
 	}
 
-	if err := h.service.UpdatePasswordRecord(r.Context(), req.VaultName, req.Name, req.Username, req.Password); err != nil {
-		if err == domain.ErrVaultNotFound {
-			h.sendError(w, "vault not found or not unlocked", http.StatusNotFound)
+	update := req.recordUpdate()
+	if update.IsEmpty() {
+		h.sendError(w, "at least one field to update must be provided", http.StatusBadRequest)
+		return
+	}
+
+	ctx := application.WithClient(r.Context(), clientAddress(r))
+	result, err := h.service.UpdatePasswordRecord(ctx, sessionToken(r), req.VaultName, req.Name, update)
+	if err != nil {
+		if err == domain.ErrInvalidCustomField || err == domain.ErrInvalidItem || err == domain.ErrUnknownItemType || err == domain.ErrInvalidTOTP {
+			h.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err == domain.ErrInvalidSession {
+			h.sendSessionError(w, r, err)
+			return
+		}
+		if err == domain.ErrPermissionDenied {
+			h.sendError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err == domain.ErrVaultModified {
+			h.sendError(w, err.Error(), http.StatusConflict)
 			return
 		}
 		if err == domain.ErrRecordNotFound {
@@ -371,7 +760,10 @@ func (h *Handler) handleUpdateRecord(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	h.sendJSON(w, SuccessResponse{Message: "password record updated successfully"})
+	h.sendJSON(w, UpdateRecordResponse{
+		Message:  "password record updated successfully",
+		Warnings: result.Warnings,
+	})
 }
 
 // handleDeleteRecord deletes a password record
@@ -392,9 +784,22 @@ func (h *Handler) handleDeleteRecord(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.service.DeletePasswordRecord(r.Context(), req.VaultName, req.Name); err != nil {
-		if err == domain.ErrVaultNotFound {
-			h.sendError(w, "vault not found or not unlocked", http.StatusNotFound)
+	if !h.validVaultName(w, req.VaultName) {
+		return
+	}
+
+	ctx := application.WithClient(r.Context(), clientAddress(r))
+	if err := h.service.DeletePasswordRecord(ctx, sessionToken(r), req.VaultName, req.Name); err != nil {
+		if err == domain.ErrInvalidSession {
+			h.sendSessionError(w, r, err)
+			return
+		}
+		if err == domain.ErrPermissionDenied {
+			h.sendError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err == domain.ErrVaultModified {
+			h.sendError(w, err.Error(), http.StatusConflict)
 			return
 		}
 		if err == domain.ErrRecordNotFound {
@@ -408,6 +813,145 @@ func (h *Handler) handleDeleteRecord(w http.ResponseWriter, r *http.Request) {
 	h.sendJSON(w, SuccessResponse{Message: "password record deleted successfully"})
 }
 
+// handleRecordHistory lists the previous passwords of a record
+func (h *Handler) handleRecordHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	vaultName := r.URL.Query().Get("vault_name")
+	recordName := r.URL.Query().Get("name")
+
+	if vaultName == "" || recordName == "" {
+		h.sendError(w, "vault_name and name query parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.validVaultName(w, vaultName) {
+		return
+	}
+
+	ctx := application.WithClient(r.Context(), clientAddress(r))
+	history, err := h.service.GetRecordHistory(ctx, sessionToken(r), vaultName, recordName)
+	if err != nil {
+		if err == domain.ErrInvalidSession {
+			h.sendSessionError(w, r, err)
+			return
+		}
+		if err == domain.ErrVaultModified {
+			h.sendError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err == domain.ErrRecordNotFound {
+			h.sendError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		h.sendError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if history == nil {
+		history = []domain.PasswordHistoryEntry{}
+	}
+	h.sendJSON(w, map[string]interface{}{"history": history})
+}
+
+// handleRestoreRecord makes a previous password of a record current again
+func (h *Handler) handleRestoreRecord(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RestoreRecordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.VaultName == "" || req.Name == "" || req.Version <= 0 {
+		h.sendError(w, "vault_name, name and a positive version are required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.validVaultName(w, req.VaultName) {
+		return
+	}
+
+	ctx := application.WithClient(r.Context(), clientAddress(r))
+	if err := h.service.RestoreRecordVersion(ctx, sessionToken(r), req.VaultName, req.Name, req.Version); err != nil {
+		if err == domain.ErrInvalidSession {
+			h.sendSessionError(w, r, err)
+			return
+		}
+		if err == domain.ErrPermissionDenied {
+			h.sendError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err == domain.ErrVaultModified {
+			h.sendError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err == domain.ErrRecordNotFound || err == domain.ErrHistoryVersionNotFound {
+			h.sendError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		h.sendError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.sendJSON(w, SuccessResponse{Message: "password restored successfully"})
+}
+
+// validVaultName rejects names that are unsafe to use as vault file names
+func (h *Handler) validVaultName(w http.ResponseWriter, name string) bool {
+	if err := domain.ValidateVaultName(name); err != nil {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// sessionToken extracts the vault session token from the Authorization
+// header, falling back to the session cookie set by the unlock endpoint
+func sessionToken(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+
+	if cookie, err := r.Cookie(SessionCookieName); err == nil {
+		return cookie.Value
+	}
+
+	return ""
+}
+
+// clientAddress identifies the client of a request for unlock attempt
+// limits: the remote IP address without the port
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// sendTooManyAttempts answers 429 with a Retry-After header if err says
+// that unlocking has to wait after failed attempts
+func (h *Handler) sendTooManyAttempts(w http.ResponseWriter, err error) bool {
+	var attemptsErr *application.AttemptsError
+	if !errors.As(err, &attemptsErr) {
+		return false
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(attemptsErr.RetrySeconds()))
+	h.sendError(w, err.Error(), http.StatusTooManyRequests)
+	return true
+}
+
 // sendJSON sends a JSON response
 func (h *Handler) sendJSON(w http.ResponseWriter, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
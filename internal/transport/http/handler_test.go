@@ -6,8 +6,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/orlan/go-password-manager/internal/application"
+	"github.com/orlan/go-password-manager/internal/breach"
 	"github.com/orlan/go-password-manager/internal/crypto"
 	"github.com/orlan/go-password-manager/internal/domain"
 	"github.com/orlan/go-password-manager/internal/vault"
@@ -22,6 +24,7 @@ func setupTestHandler(t *testing.T) *Handler {
 	}
 	cryptoSvc := crypto.NewService()
 	service := application.NewVaultService(repo, cryptoSvc)
+	t.Cleanup(service.Stop)
 	return NewHandler(service)
 }
 
@@ -46,11 +49,27 @@ func TestRegisterRoutes(t *testing.T) {
 		"/api/vaults/create",
 		"/api/vaults/unlock",
 		"/api/vaults/lock",
+		"/api/vaults/change-password",
+		"/api/vaults/audit",
+		"/api/vaults/audit-log",
+		"/api/vaults/export",
+		"/api/vaults/import",
+		"/api/vaults/members",
+		"/api/vaults/members/add",
+		"/api/vaults/members/remove",
+		"/api/vaults/members/role",
+		"/api/vaults/two-factor/setup",
+		"/api/vaults/two-factor/enable",
+		"/api/vaults/two-factor/disable",
 		"/api/records",
 		"/api/records/add",
 		"/api/records/get",
 		"/api/records/update",
 		"/api/records/delete",
+		"/api/records/history",
+		"/api/records/restore",
+		"/api/records/totp",
+		"/api/generate",
 		"/health",
 	}
 
@@ -182,6 +201,45 @@ func TestHandleCreateVault(t *testing.T) {
 		}
 	})
 
+	t.Run("returns error for invalid vault name", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		reqBody := CreateVaultRequest{
+			Name:           "../../etc/evil",
+			MasterPassword: "my-password",
+		}
+		body, _ := json.Marshal(reqBody)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/vaults/create", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+
+		handler.handleCreateVault(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("returns error for unknown KDF profile", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		reqBody := CreateVaultRequest{
+			Name:           "test-vault",
+			MasterPassword: "my-password",
+			KDFProfile:     "unknown",
+		}
+		body, _ := json.Marshal(reqBody)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/vaults/create", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+
+		handler.handleCreateVault(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
 	t.Run("returns error for invalid JSON", func(t *testing.T) {
 		handler := setupTestHandler(t)
 
@@ -214,7 +272,7 @@ func TestHandleUnlockVault(t *testing.T) {
 		handler := setupTestHandler(t)
 
 		// Create vault first
-		handler.service.CreateVault(nil, "test-vault", "my-password")
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
 
 		reqBody := UnlockVaultRequest{
 			Name:           "test-vault",
@@ -230,12 +288,31 @@ func TestHandleUnlockVault(t *testing.T) {
 		if w.Code != http.StatusOK {
 			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
 		}
+
+		var response UnlockVaultResponse
+		json.NewDecoder(w.Body).Decode(&response)
+		if response.SessionToken == "" {
+			t.Error("expected a session token in the response")
+		}
+
+		var cookie *http.Cookie
+		for _, c := range w.Result().Cookies() {
+			if c.Name == SessionCookieName {
+				cookie = c
+			}
+		}
+		if cookie == nil || cookie.Value != response.SessionToken {
+			t.Fatal("expected session cookie matching the session token")
+		}
+		if !cookie.HttpOnly {
+			t.Error("session cookie should be HttpOnly")
+		}
 	})
 
 	t.Run("returns error for wrong password", func(t *testing.T) {
 		handler := setupTestHandler(t)
 
-		handler.service.CreateVault(nil, "test-vault", "correct-password")
+		handler.service.CreateVault(nil, "test-vault", "correct-password", "")
 
 		reqBody := UnlockVaultRequest{
 			Name:           "test-vault",
@@ -303,19 +380,62 @@ func TestHandleUnlockVault(t *testing.T) {
 			t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
 		}
 	})
+
+	t.Run("returns 429 after repeated failures", func(t *testing.T) {
+		handler := setupTestHandler(t)
+		handler.service.SetUnlockPolicy(application.UnlockPolicy{FreeAttempts: 2, Delay: time.Minute})
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+
+		unlock := func(remoteAddr, password string) *httptest.ResponseRecorder {
+			body, _ := json.Marshal(UnlockVaultRequest{Name: "test-vault", MasterPassword: password})
+			req := httptest.NewRequest(http.MethodPost, "/api/vaults/unlock", bytes.NewBuffer(body))
+			req.RemoteAddr = remoteAddr
+			w := httptest.NewRecorder()
+			handler.handleUnlockVault(w, req)
+			return w
+		}
+
+		for range 2 {
+			if w := unlock("203.0.113.7:4000", "wrong"); w.Code != http.StatusUnauthorized {
+				t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+			}
+		}
+
+		w := unlock("203.0.113.7:4001", "my-password")
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+		}
+		if retry := w.Header().Get("Retry-After"); retry != "60" {
+			t.Errorf("expected Retry-After 60, got %q", retry)
+		}
+	})
+}
+
+func TestHandleRecordsInvalidVaultName(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/records/get?vault_name=..%2Fsecret&name=github", nil)
+	w := httptest.NewRecorder()
+
+	handler.handleGetRecord(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
 }
 
 func TestHandleLockVault(t *testing.T) {
 	t.Run("locks vault successfully", func(t *testing.T) {
 		handler := setupTestHandler(t)
 
-		handler.service.CreateVault(nil, "test-vault", "my-password")
-		handler.service.UnlockVault(nil, "test-vault", "my-password")
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
 
 		reqBody := LockVaultRequest{Name: "test-vault"}
 		body, _ := json.Marshal(reqBody)
 
 		req := httptest.NewRequest(http.MethodPost, "/api/vaults/lock", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+token)
 		w := httptest.NewRecorder()
 
 		handler.handleLockVault(w, req)
@@ -325,7 +445,7 @@ func TestHandleLockVault(t *testing.T) {
 		}
 	})
 
-	t.Run("returns error for non-existent vault", func(t *testing.T) {
+	t.Run("returns error without session", func(t *testing.T) {
 		handler := setupTestHandler(t)
 
 		reqBody := LockVaultRequest{Name: "non-existent"}
@@ -336,8 +456,8 @@ func TestHandleLockVault(t *testing.T) {
 
 		handler.handleLockVault(w, req)
 
-		if w.Code != http.StatusNotFound {
-			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
 		}
 	})
 
@@ -371,12 +491,111 @@ func TestHandleLockVault(t *testing.T) {
 	})
 }
 
+func TestHandleChangePassword(t *testing.T) {
+	t.Run("changes password successfully", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		handler.service.CreateVault(nil, "test-vault", "old-password", "")
+
+		reqBody := ChangePasswordRequest{
+			Name:        "test-vault",
+			OldPassword: "old-password",
+			NewPassword: "new-password",
+		}
+		body, _ := json.Marshal(reqBody)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/vaults/change-password", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+
+		handler.handleChangePassword(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		if _, err := handler.service.UnlockVault(nil, "test-vault", "new-password"); err != nil {
+			t.Errorf("UnlockVault() with new password failed: %v", err)
+		}
+	})
+
+	t.Run("returns error for wrong old password", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		handler.service.CreateVault(nil, "test-vault", "old-password", "")
+
+		reqBody := ChangePasswordRequest{
+			Name:        "test-vault",
+			OldPassword: "wrong-password",
+			NewPassword: "new-password",
+		}
+		body, _ := json.Marshal(reqBody)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/vaults/change-password", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+
+		handler.handleChangePassword(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+		}
+	})
+
+	t.Run("returns error for non-existent vault", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		reqBody := ChangePasswordRequest{
+			Name:        "non-existent",
+			OldPassword: "old-password",
+			NewPassword: "new-password",
+		}
+		body, _ := json.Marshal(reqBody)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/vaults/change-password", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+
+		handler.handleChangePassword(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("returns error for missing fields", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		reqBody := ChangePasswordRequest{Name: "test-vault", OldPassword: "old-password"}
+		body, _ := json.Marshal(reqBody)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/vaults/change-password", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+
+		handler.handleChangePassword(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("returns error for wrong method", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/vaults/change-password", nil)
+		w := httptest.NewRecorder()
+
+		handler.handleChangePassword(w, req)
+
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+		}
+	})
+}
+
 func TestHandleVaults(t *testing.T) {
 	t.Run("lists vaults successfully", func(t *testing.T) {
 		handler := setupTestHandler(t)
 
-		handler.service.CreateVault(nil, "vault1", "password")
-		handler.service.CreateVault(nil, "vault2", "password")
+		handler.service.CreateVault(nil, "vault1", "password", "")
+		handler.service.CreateVault(nil, "vault2", "password", "")
 
 		req := httptest.NewRequest(http.MethodGet, "/api/vaults", nil)
 		w := httptest.NewRecorder()
@@ -413,8 +632,8 @@ func TestHandleAddRecord(t *testing.T) {
 	t.Run("adds record successfully", func(t *testing.T) {
 		handler := setupTestHandler(t)
 
-		handler.service.CreateVault(nil, "test-vault", "my-password")
-		handler.service.UnlockVault(nil, "test-vault", "my-password")
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
 
 		reqBody := AddRecordRequest{
 			VaultName: "test-vault",
@@ -425,6 +644,7 @@ func TestHandleAddRecord(t *testing.T) {
 		body, _ := json.Marshal(reqBody)
 
 		req := httptest.NewRequest(http.MethodPost, "/api/records/add", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+token)
 		w := httptest.NewRecorder()
 
 		handler.handleAddRecord(w, req)
@@ -437,7 +657,7 @@ func TestHandleAddRecord(t *testing.T) {
 	t.Run("returns error for locked vault", func(t *testing.T) {
 		handler := setupTestHandler(t)
 
-		handler.service.CreateVault(nil, "test-vault", "my-password")
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
 
 		reqBody := AddRecordRequest{
 			VaultName: "test-vault",
@@ -452,17 +672,17 @@ func TestHandleAddRecord(t *testing.T) {
 
 		handler.handleAddRecord(w, req)
 
-		if w.Code != http.StatusNotFound {
-			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
 		}
 	})
 
 	t.Run("returns error for duplicate record", func(t *testing.T) {
 		handler := setupTestHandler(t)
 
-		handler.service.CreateVault(nil, "test-vault", "my-password")
-		handler.service.UnlockVault(nil, "test-vault", "my-password")
-		handler.service.AddPasswordRecord(nil, "test-vault", "gmail", "user@gmail.com", "pass")
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+		handler.service.AddPasswordRecord(nil, token, "test-vault", application.RecordInput{Name: "gmail", Username: "user@gmail.com", Password: "pass"})
 
 		reqBody := AddRecordRequest{
 			VaultName: "test-vault",
@@ -473,6 +693,7 @@ func TestHandleAddRecord(t *testing.T) {
 		body, _ := json.Marshal(reqBody)
 
 		req := httptest.NewRequest(http.MethodPost, "/api/records/add", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+token)
 		w := httptest.NewRecorder()
 
 		handler.handleAddRecord(w, req)
@@ -503,6 +724,115 @@ func TestHandleAddRecord(t *testing.T) {
 		}
 	})
 
+	t.Run("stores optional fields", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+
+		body := []byte(`{"vault_name":"test-vault","name":"github","username":"user","password":"pass",` +
+			`"urls":["https://github.com"],"notes":"2FA enabled","folder":"Work","tags":["dev"],` +
+			`"custom_fields":[{"name":"PIN","value":"1234","type":"hidden"}]}`)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/records/add", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+
+		handler.handleAddRecord(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		req = httptest.NewRequest(http.MethodGet, "/api/records/get?vault_name=test-vault&name=github", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w = httptest.NewRecorder()
+
+		handler.handleGetRecord(w, req)
+
+		var record domain.PasswordRecord
+		json.NewDecoder(w.Body).Decode(&record)
+		if record.Notes != "2FA enabled" || record.Folder != "Work" || len(record.URLs) != 1 || len(record.Tags) != 1 {
+			t.Errorf("optional fields not returned: %+v", record)
+		}
+		if len(record.CustomFields) != 1 || record.CustomFields[0].Type != domain.CustomFieldHidden {
+			t.Errorf("custom fields not returned: %+v", record.CustomFields)
+		}
+	})
+
+	t.Run("adds typed item without username and password", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+
+		body := []byte(`{"vault_name":"test-vault","type":"card","name":"visa",` +
+			`"card":{"cardholder":"Jane Doe","number":"4111 1111 1111 1111","exp_month":12,"exp_year":2030}}`)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/records/add", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+
+		handler.handleAddRecord(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		record, _ := handler.service.GetPasswordRecord(nil, token, "test-vault", "visa")
+		if record == nil || record.Type != domain.ItemTypeCard || record.Card.Cardholder != "Jane Doe" {
+			t.Errorf("card not stored: %+v", record)
+		}
+	})
+
+	t.Run("returns error for invalid typed item", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+
+		for _, body := range []string{
+			`{"vault_name":"test-vault","type":"card","name":"visa","card":{"number":"1234"}}`,
+			`{"vault_name":"test-vault","type":"wallet","name":"x"}`,
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/api/records/add", bytes.NewBufferString(body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+
+			handler.handleAddRecord(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status %d, got %d", body, http.StatusBadRequest, w.Code)
+			}
+		}
+	})
+
+	t.Run("returns error for invalid custom field", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+
+		reqBody := AddRecordRequest{
+			VaultName:    "test-vault",
+			Name:         "github",
+			Username:     "user",
+			Password:     "pass",
+			CustomFields: []domain.CustomField{{Name: "PIN", Value: "1234", Type: "secret"}},
+		}
+		body, _ := json.Marshal(reqBody)
+
+		req := httptest.NewRequest(http.MethodPost, "/api/records/add", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+
+		handler.handleAddRecord(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
 	t.Run("returns error for wrong method", func(t *testing.T) {
 		handler := setupTestHandler(t)
 
@@ -521,11 +851,12 @@ func TestHandleGetRecord(t *testing.T) {
 	t.Run("gets record successfully", func(t *testing.T) {
 		handler := setupTestHandler(t)
 
-		handler.service.CreateVault(nil, "test-vault", "my-password")
-		handler.service.UnlockVault(nil, "test-vault", "my-password")
-		handler.service.AddPasswordRecord(nil, "test-vault", "gmail", "user@gmail.com", "secret123")
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+		handler.service.AddPasswordRecord(nil, token, "test-vault", application.RecordInput{Name: "gmail", Username: "user@gmail.com", Password: "secret123"})
 
 		req := httptest.NewRequest(http.MethodGet, "/api/records/get?vault_name=test-vault&name=gmail", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
 		w := httptest.NewRecorder()
 
 		handler.handleGetRecord(w, req)
@@ -548,10 +879,11 @@ func TestHandleGetRecord(t *testing.T) {
 	t.Run("returns error for non-existent record", func(t *testing.T) {
 		handler := setupTestHandler(t)
 
-		handler.service.CreateVault(nil, "test-vault", "my-password")
-		handler.service.UnlockVault(nil, "test-vault", "my-password")
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
 
 		req := httptest.NewRequest(http.MethodGet, "/api/records/get?vault_name=test-vault&name=non-existent", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
 		w := httptest.NewRecorder()
 
 		handler.handleGetRecord(w, req)
@@ -564,15 +896,15 @@ func TestHandleGetRecord(t *testing.T) {
 	t.Run("returns error for locked vault", func(t *testing.T) {
 		handler := setupTestHandler(t)
 
-		handler.service.CreateVault(nil, "test-vault", "my-password")
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
 
 		req := httptest.NewRequest(http.MethodGet, "/api/records/get?vault_name=test-vault&name=gmail", nil)
 		w := httptest.NewRecorder()
 
 		handler.handleGetRecord(w, req)
 
-		if w.Code != http.StatusNotFound {
-			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
 		}
 	})
 
@@ -607,12 +939,13 @@ func TestHandleRecords(t *testing.T) {
 	t.Run("lists records successfully", func(t *testing.T) {
 		handler := setupTestHandler(t)
 
-		handler.service.CreateVault(nil, "test-vault", "my-password")
-		handler.service.UnlockVault(nil, "test-vault", "my-password")
-		handler.service.AddPasswordRecord(nil, "test-vault", "gmail", "user@gmail.com", "pass1")
-		handler.service.AddPasswordRecord(nil, "test-vault", "github", "user@github.com", "pass2")
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+		handler.service.AddPasswordRecord(nil, token, "test-vault", application.RecordInput{Name: "gmail", Username: "user@gmail.com", Password: "pass1"})
+		handler.service.AddPasswordRecord(nil, token, "test-vault", application.RecordInput{Name: "github", Username: "user@github.com", Password: "pass2"})
 
 		req := httptest.NewRequest(http.MethodGet, "/api/records?vault_name=test-vault", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
 		w := httptest.NewRecorder()
 
 		handler.handleRecords(w, req)
@@ -629,6 +962,49 @@ func TestHandleRecords(t *testing.T) {
 		}
 	})
 
+	t.Run("filters records by type", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+		handler.service.AddPasswordRecord(nil, token, "test-vault", application.RecordInput{Name: "gmail", Username: "user@gmail.com", Password: "pass1"})
+		handler.service.AddPasswordRecord(nil, token, "test-vault", application.RecordInput{Type: domain.ItemTypeSecureNote, Name: "wifi", Notes: "on the router"})
+
+		req := httptest.NewRequest(http.MethodGet, "/api/records?vault_name=test-vault&type=secure_note", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+
+		handler.handleRecords(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		var response map[string][]domain.PasswordRecord
+		json.NewDecoder(w.Body).Decode(&response)
+
+		if len(response["records"]) != 1 || response["records"][0].Name != "wifi" {
+			t.Errorf("expected only the note, got %+v", response["records"])
+		}
+	})
+
+	t.Run("returns error for unknown type", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+
+		req := httptest.NewRequest(http.MethodGet, "/api/records?vault_name=test-vault&type=wallet", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+
+		handler.handleRecords(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
 	t.Run("returns error for missing vault_name", func(t *testing.T) {
 		handler := setupTestHandler(t)
 
@@ -660,9 +1036,9 @@ func TestHandleUpdateRecord(t *testing.T) {
 	t.Run("updates record successfully", func(t *testing.T) {
 		handler := setupTestHandler(t)
 
-		handler.service.CreateVault(nil, "test-vault", "my-password")
-		handler.service.UnlockVault(nil, "test-vault", "my-password")
-		handler.service.AddPasswordRecord(nil, "test-vault", "gmail", "old@gmail.com", "oldpass")
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+		handler.service.AddPasswordRecord(nil, token, "test-vault", application.RecordInput{Name: "gmail", Username: "old@gmail.com", Password: "oldpass"})
 
 		reqBody := UpdateRecordRequest{
 			VaultName: "test-vault",
@@ -673,6 +1049,7 @@ func TestHandleUpdateRecord(t *testing.T) {
 		body, _ := json.Marshal(reqBody)
 
 		req := httptest.NewRequest(http.MethodPut, "/api/records/update", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+token)
 		w := httptest.NewRecorder()
 
 		handler.handleUpdateRecord(w, req)
@@ -685,8 +1062,8 @@ func TestHandleUpdateRecord(t *testing.T) {
 	t.Run("returns error for non-existent record", func(t *testing.T) {
 		handler := setupTestHandler(t)
 
-		handler.service.CreateVault(nil, "test-vault", "my-password")
-		handler.service.UnlockVault(nil, "test-vault", "my-password")
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
 
 		reqBody := UpdateRecordRequest{
 			VaultName: "test-vault",
@@ -696,6 +1073,7 @@ func TestHandleUpdateRecord(t *testing.T) {
 		body, _ := json.Marshal(reqBody)
 
 		req := httptest.NewRequest(http.MethodPut, "/api/records/update", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+token)
 		w := httptest.NewRecorder()
 
 		handler.handleUpdateRecord(w, req)
@@ -705,7 +1083,7 @@ func TestHandleUpdateRecord(t *testing.T) {
 		}
 	})
 
-	t.Run("returns error for missing both username and password", func(t *testing.T) {
+	t.Run("returns error when no field is set", func(t *testing.T) {
 		handler := setupTestHandler(t)
 
 		reqBody := UpdateRecordRequest{
@@ -724,6 +1102,34 @@ func TestHandleUpdateRecord(t *testing.T) {
 		}
 	})
 
+	t.Run("updates optional fields only", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+		handler.service.AddPasswordRecord(nil, token, "test-vault", application.RecordInput{Name: "gmail", Username: "user", Password: "pass", Folder: "Mail"})
+
+		body := []byte(`{"vault_name":"test-vault","name":"gmail","notes":"new notes","folder":""}`)
+
+		req := httptest.NewRequest(http.MethodPut, "/api/records/update", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+
+		handler.handleUpdateRecord(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		record, _ := handler.service.GetPasswordRecord(nil, token, "test-vault", "gmail")
+		if record.Notes != "new notes" || record.Folder != "" {
+			t.Errorf("optional fields not updated: %+v", record)
+		}
+		if record.Username != "user" || record.Password != "pass" {
+			t.Errorf("credentials should be unchanged: %+v", record)
+		}
+	})
+
 	t.Run("returns error for wrong method", func(t *testing.T) {
 		handler := setupTestHandler(t)
 
@@ -742,9 +1148,9 @@ func TestHandleDeleteRecord(t *testing.T) {
 	t.Run("deletes record successfully", func(t *testing.T) {
 		handler := setupTestHandler(t)
 
-		handler.service.CreateVault(nil, "test-vault", "my-password")
-		handler.service.UnlockVault(nil, "test-vault", "my-password")
-		handler.service.AddPasswordRecord(nil, "test-vault", "gmail", "user@gmail.com", "pass")
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+		handler.service.AddPasswordRecord(nil, token, "test-vault", application.RecordInput{Name: "gmail", Username: "user@gmail.com", Password: "pass"})
 
 		reqBody := DeleteRecordRequest{
 			VaultName: "test-vault",
@@ -753,6 +1159,7 @@ func TestHandleDeleteRecord(t *testing.T) {
 		body, _ := json.Marshal(reqBody)
 
 		req := httptest.NewRequest(http.MethodDelete, "/api/records/delete", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+token)
 		w := httptest.NewRecorder()
 
 		handler.handleDeleteRecord(w, req)
@@ -765,8 +1172,8 @@ func TestHandleDeleteRecord(t *testing.T) {
 	t.Run("returns error for non-existent record", func(t *testing.T) {
 		handler := setupTestHandler(t)
 
-		handler.service.CreateVault(nil, "test-vault", "my-password")
-		handler.service.UnlockVault(nil, "test-vault", "my-password")
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
 
 		reqBody := DeleteRecordRequest{
 			VaultName: "test-vault",
@@ -775,6 +1182,7 @@ func TestHandleDeleteRecord(t *testing.T) {
 		body, _ := json.Marshal(reqBody)
 
 		req := httptest.NewRequest(http.MethodDelete, "/api/records/delete", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+token)
 		w := httptest.NewRecorder()
 
 		handler.handleDeleteRecord(w, req)
@@ -816,3 +1224,278 @@ func TestHandleDeleteRecord(t *testing.T) {
 		}
 	})
 }
+
+func TestSessionAuthentication(t *testing.T) {
+	t.Run("accepts session cookie", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+
+		req := httptest.NewRequest(http.MethodGet, "/api/records?vault_name=test-vault", nil)
+		req.AddCookie(&http.Cookie{Name: SessionCookieName, Value: token})
+		w := httptest.NewRecorder()
+
+		handler.handleRecords(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+	})
+
+	t.Run("rejects requests without a session token", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		handler.service.UnlockVault(nil, "test-vault", "my-password")
+
+		req := httptest.NewRequest(http.MethodGet, "/api/records?vault_name=test-vault", nil)
+		w := httptest.NewRecorder()
+
+		handler.handleRecords(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+		}
+	})
+
+	t.Run("rejects token after lock", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+		otherToken, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+
+		body, _ := json.Marshal(LockVaultRequest{Name: "test-vault"})
+		lockReq := httptest.NewRequest(http.MethodPost, "/api/vaults/lock", bytes.NewBuffer(body))
+		lockReq.Header.Set("Authorization", "Bearer "+token)
+		handler.handleLockVault(httptest.NewRecorder(), lockReq)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/records?vault_name=test-vault", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.handleRecords(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d for locked session, got %d", http.StatusUnauthorized, w.Code)
+		}
+
+		req = httptest.NewRequest(http.MethodGet, "/api/records?vault_name=test-vault", nil)
+		req.Header.Set("Authorization", "Bearer "+otherToken)
+		w = httptest.NewRecorder()
+		handler.handleRecords(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status %d for other session, got %d", http.StatusOK, w.Code)
+		}
+	})
+}
+
+func TestHandleRecordHistory(t *testing.T) {
+	t.Run("returns history and restores a version", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+		handler.service.AddPasswordRecord(nil, token, "test-vault", application.RecordInput{Name: "gmail", Username: "user", Password: "old"})
+		handler.service.UpdatePasswordRecord(nil, token, "test-vault", "gmail", application.RecordUpdate{Password: stringPtr("new")})
+
+		req := httptest.NewRequest(http.MethodGet, "/api/records/history?vault_name=test-vault&name=gmail", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+
+		handler.handleRecordHistory(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		var response map[string][]domain.PasswordHistoryEntry
+		json.NewDecoder(w.Body).Decode(&response)
+		if len(response["history"]) != 1 || response["history"][0].Password != "old" {
+			t.Fatalf("unexpected history %+v", response["history"])
+		}
+
+		body, _ := json.Marshal(RestoreRecordRequest{VaultName: "test-vault", Name: "gmail", Version: response["history"][0].Version})
+		req = httptest.NewRequest(http.MethodPost, "/api/records/restore", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w = httptest.NewRecorder()
+
+		handler.handleRestoreRecord(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+
+		record, _ := handler.service.GetPasswordRecord(nil, token, "test-vault", "gmail")
+		if record.Password != "old" {
+			t.Errorf("expected restored password, got %q", record.Password)
+		}
+	})
+
+	t.Run("returns error for unknown version", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+		handler.service.AddPasswordRecord(nil, token, "test-vault", application.RecordInput{Name: "gmail", Username: "user", Password: "pass"})
+
+		body, _ := json.Marshal(RestoreRecordRequest{VaultName: "test-vault", Name: "gmail", Version: 3})
+		req := httptest.NewRequest(http.MethodPost, "/api/records/restore", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+
+		handler.handleRestoreRecord(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("returns error for missing fields", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/records/history?vault_name=test-vault", nil)
+		w := httptest.NewRecorder()
+		handler.handleRecordHistory(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("history: expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+
+		body, _ := json.Marshal(RestoreRecordRequest{VaultName: "test-vault", Name: "gmail"})
+		req = httptest.NewRequest(http.MethodPost, "/api/records/restore", bytes.NewBuffer(body))
+		w = httptest.NewRecorder()
+		handler.handleRestoreRecord(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("restore: expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("returns error without session", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+
+		req := httptest.NewRequest(http.MethodGet, "/api/records/history?vault_name=test-vault&name=gmail", nil)
+		w := httptest.NewRecorder()
+
+		handler.handleRecordHistory(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+		}
+	})
+}
+
+func TestHandleAuditVault(t *testing.T) {
+	t.Run("returns the report without passwords", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+		handler.service.AddPasswordRecord(nil, token, "test-vault", application.RecordInput{Name: "gmail", Username: "user", Password: "password1"})
+		handler.service.AddPasswordRecord(nil, token, "test-vault", application.RecordInput{Name: "bank", Username: "user", Password: "password1"})
+
+		req := httptest.NewRequest(http.MethodGet, "/api/vaults/audit?vault_name=test-vault", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+
+		handler.handleAuditVault(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		if bytes.Contains(w.Body.Bytes(), []byte("password1")) {
+			t.Fatal("audit response contains a password")
+		}
+
+		var report application.AuditReport
+		json.NewDecoder(w.Body).Decode(&report)
+		if report.Checked != 2 || report.Weak != 2 || report.Reused != 2 {
+			t.Errorf("unexpected report %+v", report)
+		}
+	})
+
+	t.Run("returns error for missing vault name", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/vaults/audit", nil)
+		w := httptest.NewRecorder()
+
+		handler.handleAuditVault(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("returns error without session", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+
+		req := httptest.NewRequest(http.MethodGet, "/api/vaults/audit?vault_name=test-vault", nil)
+		w := httptest.NewRecorder()
+
+		handler.handleAuditVault(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+		}
+	})
+}
+
+func TestBreachWarnings(t *testing.T) {
+	handler := setupTestHandler(t)
+	store, err := breach.Open("../../breach/testdata/pwned-passwords-sample.txt")
+	if err != nil {
+		t.Fatalf("breach.Open() failed: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	handler.service.SetBreachChecker(store)
+
+	handler.service.CreateVault(nil, "test-vault", "my-password", "")
+	token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+
+	body, _ := json.Marshal(AddRecordRequest{VaultName: "test-vault", Name: "gmail", Username: "user", Password: "password"})
+	req := httptest.NewRequest(http.MethodPost, "/api/records/add", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+
+	handler.handleAddRecord(w, req)
+
+	var added AddRecordResponse
+	json.NewDecoder(w.Body).Decode(&added)
+	if w.Code != http.StatusOK || len(added.Warnings) != 1 {
+		t.Fatalf("add: expected status %d with a warning, got %d %+v", http.StatusOK, w.Code, added)
+	}
+
+	body, _ = json.Marshal(UpdateRecordRequest{VaultName: "test-vault", Name: "gmail", Password: "123456"})
+	req = httptest.NewRequest(http.MethodPut, "/api/records/update", bytes.NewBuffer(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+
+	handler.handleUpdateRecord(w, req)
+
+	var updated UpdateRecordResponse
+	json.NewDecoder(w.Body).Decode(&updated)
+	if w.Code != http.StatusOK || len(updated.Warnings) != 1 {
+		t.Fatalf("update: expected status %d with a warning, got %d %+v", http.StatusOK, w.Code, updated)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/vaults/audit?vault_name=test-vault", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+
+	handler.handleAuditVault(w, req)
+
+	var report application.AuditReport
+	json.NewDecoder(w.Body).Decode(&report)
+	if !report.BreachCheck || report.Breached != 1 || report.Records[0].BreachCount == 0 {
+		t.Errorf("unexpected audit report %+v", report)
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
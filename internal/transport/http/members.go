@@ -0,0 +1,189 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/orlan/go-password-manager/internal/application"
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// MembersResponse lists the members of a vault. Single-user vaults are not
+// shared and have no members.
+type MembersResponse struct {
+	VaultName string                   `json:"vault_name"`
+	Shared    bool                     `json:"shared"`
+	Members   []application.MemberInfo `json:"members"`
+}
+
+// AddMemberRequest gives another person access to a vault with their own
+// password. Role defaults to viewer.
+type AddMemberRequest struct {
+	VaultName  string `json:"vault_name"`
+	Member     string `json:"member"`
+	Password   string `json:"password"`
+	KDFProfile string `json:"kdf_profile,omitempty"`
+	Role       string `json:"role,omitempty"`
+}
+
+// RemoveMemberRequest takes away a member's access to a shared vault
+type RemoveMemberRequest struct {
+	VaultName string `json:"vault_name"`
+	Member    string `json:"member"`
+}
+
+// SetMemberRoleRequest changes the role of a member of a shared vault
+type SetMemberRoleRequest struct {
+	VaultName string `json:"vault_name"`
+	Member    string `json:"member"`
+	Role      string `json:"role"`
+}
+
+// handleMembers lists the members of an unlocked vault
+func (h *Handler) handleMembers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	vaultName := r.URL.Query().Get("vault_name")
+	if vaultName == "" {
+		h.sendError(w, "vault_name query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.validVaultName(w, vaultName) {
+		return
+	}
+
+	members, err := h.service.ListMembers(r.Context(), sessionToken(r), vaultName)
+	if err != nil {
+		h.sendMemberError(w, r, err)
+		return
+	}
+
+	h.sendJSON(w, MembersResponse{VaultName: vaultName, Shared: len(members) > 0, Members: members})
+}
+
+// handleAddMember adds a member to a vault, sharing it if it is not yet
+func (h *Handler) handleAddMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req AddMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.VaultName == "" || req.Member == "" || req.Password == "" {
+		h.sendError(w, "vault_name, member and password are required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.validVaultName(w, req.VaultName) {
+		return
+	}
+
+	err := h.service.AddMember(r.Context(), sessionToken(r), req.VaultName, application.MemberInput{
+		Name:       req.Member,
+		Password:   req.Password,
+		KDFProfile: req.KDFProfile,
+		Role:       domain.MemberRole(req.Role),
+	})
+	if err != nil {
+		h.sendMemberError(w, r, err)
+		return
+	}
+
+	h.sendJSON(w, SuccessResponse{Message: "member added successfully"})
+}
+
+// handleRemoveMember removes a member from a shared vault and rotates its
+// data key
+func (h *Handler) handleRemoveMember(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req RemoveMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.VaultName == "" || req.Member == "" {
+		h.sendError(w, "vault_name and member are required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.validVaultName(w, req.VaultName) {
+		return
+	}
+
+	if err := h.service.RemoveMember(r.Context(), sessionToken(r), req.VaultName, req.Member); err != nil {
+		h.sendMemberError(w, r, err)
+		return
+	}
+
+	h.sendJSON(w, SuccessResponse{Message: "member removed successfully"})
+}
+
+// handleSetMemberRole changes what a member of a shared vault may do
+func (h *Handler) handleSetMemberRole(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req SetMemberRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.VaultName == "" || req.Member == "" || req.Role == "" {
+		h.sendError(w, "vault_name, member and role are required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.validVaultName(w, req.VaultName) {
+		return
+	}
+
+	err := h.service.SetMemberRole(r.Context(), sessionToken(r), req.VaultName, req.Member, domain.MemberRole(req.Role))
+	if err != nil {
+		h.sendMemberError(w, r, err)
+		return
+	}
+
+	h.sendJSON(w, SuccessResponse{Message: "member role changed successfully"})
+}
+
+// sendMemberError maps the errors of the member operations to responses
+func (h *Handler) sendMemberError(w http.ResponseWriter, r *http.Request, err error) {
+	if err == domain.ErrInvalidSession {
+		h.sendSessionError(w, r, err)
+		return
+	}
+	if err == domain.ErrInvalidMemberName || err == domain.ErrUnknownKDFProfile || err == domain.ErrUnknownRole {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err == domain.ErrPermissionDenied {
+		h.sendError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err == domain.ErrMemberNotFound {
+		h.sendError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err == domain.ErrMemberAlreadyExists || err == domain.ErrLastMember || err == domain.ErrLastOwner || err == domain.ErrVaultModified {
+		h.sendError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	h.sendError(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,151 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/orlan/go-password-manager/internal/application"
+)
+
+func TestHandleMembers(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.service.CreateVault(nil, "test-vault", "my-password", "interactive")
+	token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+
+	post := func(path string, handle http.HandlerFunc, req any) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		r := httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handle(w, r)
+		return w
+	}
+	list := func() MembersResponse {
+		t.Helper()
+		r := httptest.NewRequest(http.MethodGet, "/api/vaults/members?vault_name=test-vault", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.handleMembers(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		var response MembersResponse
+		json.NewDecoder(w.Body).Decode(&response)
+		return response
+	}
+
+	if response := list(); response.Shared || len(response.Members) != 0 {
+		t.Fatalf("expected a single-user vault, got %+v", response)
+	}
+
+	addTests := []struct {
+		name       string
+		req        AddMemberRequest
+		wantStatus int
+	}{
+		{"missing password", AddMemberRequest{VaultName: "test-vault", Member: "alice"}, http.StatusBadRequest},
+		{"invalid name", AddMemberRequest{VaultName: "test-vault", Member: "alice smith", Password: "alice-password"}, http.StatusBadRequest},
+		{"unknown kdf profile", AddMemberRequest{VaultName: "test-vault", Member: "alice", Password: "alice-password", KDFProfile: "extreme"}, http.StatusBadRequest},
+		{"locked vault", AddMemberRequest{VaultName: "other-vault", Member: "alice", Password: "alice-password"}, http.StatusUnauthorized},
+		{"success", AddMemberRequest{VaultName: "test-vault", Member: "alice", Password: "alice-password", KDFProfile: "interactive"}, http.StatusOK},
+		{"duplicate", AddMemberRequest{VaultName: "test-vault", Member: "alice", Password: "alice-password", KDFProfile: "interactive"}, http.StatusConflict},
+	}
+	for _, tt := range addTests {
+		t.Run("add "+tt.name, func(t *testing.T) {
+			if w := post("/api/vaults/members/add", handler.handleAddMember, tt.req); w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	response := list()
+	if !response.Shared || len(response.Members) != 2 || response.Members[0].Name != application.OwnerMemberName || response.Members[1].Name != "alice" {
+		t.Fatalf("unexpected members: %+v", response)
+	}
+
+	t.Run("member unlock", func(t *testing.T) {
+		w := post("/api/vaults/unlock", handler.handleUnlockVault, UnlockVaultRequest{Name: "test-vault", Member: "alice", MasterPassword: "alice-password"})
+		if w.Code != http.StatusOK {
+			t.Errorf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		w = post("/api/vaults/unlock", handler.handleUnlockVault, UnlockVaultRequest{Name: "test-vault", Member: "alice", MasterPassword: "my-password"})
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d: %s", http.StatusUnauthorized, w.Code, w.Body.String())
+		}
+	})
+
+	removeTests := []struct {
+		name       string
+		req        RemoveMemberRequest
+		wantStatus int
+	}{
+		{"missing member", RemoveMemberRequest{VaultName: "test-vault"}, http.StatusBadRequest},
+		{"unknown member", RemoveMemberRequest{VaultName: "test-vault", Member: "bob"}, http.StatusNotFound},
+		{"success", RemoveMemberRequest{VaultName: "test-vault", Member: "alice"}, http.StatusOK},
+		{"last member", RemoveMemberRequest{VaultName: "test-vault", Member: application.OwnerMemberName}, http.StatusConflict},
+	}
+	for _, tt := range removeTests {
+		t.Run("remove "+tt.name, func(t *testing.T) {
+			if w := post("/api/vaults/members/remove", handler.handleRemoveMember, tt.req); w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if response := list(); len(response.Members) != 1 || !response.Members[0].Current {
+		t.Errorf("expected only the current member to remain, got %+v", response)
+	}
+}
+
+func TestHandleMemberRoles(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.service.CreateVault(nil, "test-vault", "my-password", "interactive")
+	ownerToken, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+	handler.service.AddMember(nil, ownerToken, "test-vault", application.MemberInput{Name: "alice", Password: "alice-password", KDFProfile: "interactive"})
+	aliceToken, _ := handler.service.UnlockVaultAsMember(nil, "test-vault", "alice", "alice-password", "")
+
+	post := func(token, path string, handle http.HandlerFunc, req any) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		r := httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handle(w, r)
+		return w
+	}
+	addRecord := AddRecordRequest{VaultName: "test-vault", Name: "github", Username: "alice", Password: "secret"}
+
+	if w := post(aliceToken, "/api/records/add", handler.handleAddRecord, addRecord); w.Code != http.StatusForbidden {
+		t.Errorf("viewer adding a record: expected status %d, got %d: %s", http.StatusForbidden, w.Code, w.Body.String())
+	}
+
+	roleTests := []struct {
+		name       string
+		token      string
+		req        SetMemberRoleRequest
+		wantStatus int
+	}{
+		{"missing role", ownerToken, SetMemberRoleRequest{VaultName: "test-vault", Member: "alice"}, http.StatusBadRequest},
+		{"unknown role", ownerToken, SetMemberRoleRequest{VaultName: "test-vault", Member: "alice", Role: "admin"}, http.StatusBadRequest},
+		{"unknown member", ownerToken, SetMemberRoleRequest{VaultName: "test-vault", Member: "bob", Role: "editor"}, http.StatusNotFound},
+		{"not an owner", aliceToken, SetMemberRoleRequest{VaultName: "test-vault", Member: "alice", Role: "owner"}, http.StatusForbidden},
+		{"last owner", ownerToken, SetMemberRoleRequest{VaultName: "test-vault", Member: application.OwnerMemberName, Role: "viewer"}, http.StatusConflict},
+		{"success", ownerToken, SetMemberRoleRequest{VaultName: "test-vault", Member: "alice", Role: "editor"}, http.StatusOK},
+	}
+	for _, tt := range roleTests {
+		t.Run("role "+tt.name, func(t *testing.T) {
+			if w := post(tt.token, "/api/vaults/members/role", handler.handleSetMemberRole, tt.req); w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if w := post(aliceToken, "/api/records/add", handler.handleAddRecord, addRecord); w.Code != http.StatusOK {
+		t.Errorf("editor adding a record: expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if w := post(aliceToken, "/api/vaults/members/add", handler.handleAddMember, AddMemberRequest{VaultName: "test-vault", Member: "bob", Password: "bob-password"}); w.Code != http.StatusForbidden {
+		t.Errorf("editor adding a member: expected status %d, got %d: %s", http.StatusForbidden, w.Code, w.Body.String())
+	}
+}
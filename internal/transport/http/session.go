@@ -0,0 +1,95 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/application"
+)
+
+// lockedSessionRetention is how long the reason for an automatic lock is
+// remembered for clients still presenting the old token
+const lockedSessionRetention = 1 * time.Hour
+
+// lockedSessionLog remembers sessions that the service locked on its own
+type lockedSessionLog struct {
+	mu     sync.Mutex
+	events map[string]application.SessionEvent
+}
+
+// newLockedSessionLog creates an empty log
+func newLockedSessionLog() *lockedSessionLog {
+	return &lockedSessionLog{
+		events: make(map[string]application.SessionEvent),
+	}
+}
+
+// record stores automatic lock events; manual locks need no explanation
+func (l *lockedSessionLog) record(event application.SessionEvent) {
+	if event.Reason == application.LockReasonManual {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Drop old entries so the log cannot grow without bound
+	for token, old := range l.events {
+		if time.Since(old.LockedAt) > lockedSessionRetention {
+			delete(l.events, token)
+		}
+	}
+	l.events[event.Token] = event
+}
+
+// take returns and forgets the lock event for token
+func (l *lockedSessionLog) take(token string) (application.SessionEvent, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	event, ok := l.events[token]
+	if ok {
+		delete(l.events, token)
+	}
+	return event, ok
+}
+
+// sendSessionError responds 401 for an invalid session. If the session was
+// locked automatically the response says why and the stale cookie is cleared.
+func (h *Handler) sendSessionError(w http.ResponseWriter, r *http.Request, err error) {
+	event, ok := h.lockedLog.take(sessionToken(r))
+	if !ok {
+		h.sendError(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	message := "vault was locked automatically, please unlock it again"
+	switch event.Reason {
+	case application.LockReasonIdle:
+		message = "vault was locked after a period of inactivity, please unlock it again"
+	case application.LockReasonLifetime:
+		message = "session reached its maximum lifetime, please unlock the vault again"
+	case application.LockReasonAccessRevoked:
+		message = "you were removed from this vault"
+	}
+
+	clearSessionCookie(w, r)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: message, Reason: string(event.Reason)})
+}
+
+// clearSessionCookie expires the session cookie
+func clearSessionCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    "",
+		Path:     "/api",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
@@ -0,0 +1,65 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/application"
+)
+
+func TestAutomaticLock(t *testing.T) {
+	t.Run("reports reason for idle lock", func(t *testing.T) {
+		handler := setupTestHandler(t)
+		handler.service.SetSessionPolicy(application.SessionPolicy{IdleTimeout: time.Millisecond})
+
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+
+		time.Sleep(10 * time.Millisecond)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/records?vault_name=test-vault", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+
+		handler.handleRecords(w, req)
+
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+		}
+
+		var resp ErrorResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		if resp.Reason != string(application.LockReasonIdle) {
+			t.Errorf("expected reason %q, got %q", application.LockReasonIdle, resp.Reason)
+		}
+
+		cleared := false
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == SessionCookieName && cookie.MaxAge < 0 {
+				cleared = true
+			}
+		}
+		if !cleared {
+			t.Error("expected session cookie to be cleared")
+		}
+	})
+
+	t.Run("omits reason for unknown token", func(t *testing.T) {
+		handler := setupTestHandler(t)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/records?vault_name=test-vault", nil)
+		req.Header.Set("Authorization", "Bearer unknown")
+		w := httptest.NewRecorder()
+
+		handler.handleRecords(w, req)
+
+		var resp ErrorResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		if w.Code != http.StatusUnauthorized || resp.Reason != "" {
+			t.Errorf("expected plain 401, got %d with reason %q", w.Code, resp.Reason)
+		}
+	})
+}
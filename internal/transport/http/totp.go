@@ -0,0 +1,65 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// TOTPResponse is the current two-factor code of a record
+type TOTPResponse struct {
+	Code             string    `json:"code"`
+	Period           int       `json:"period"`
+	RemainingSeconds int       `json:"remaining_seconds"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+// handleTOTP returns the current TOTP code of a record
+func (h *Handler) handleTOTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	vaultName := r.URL.Query().Get("vault_name")
+	recordName := r.URL.Query().Get("name")
+
+	if vaultName == "" || recordName == "" {
+		h.sendError(w, "vault_name and name query parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.validVaultName(w, vaultName) {
+		return
+	}
+
+	code, err := h.service.GetTOTPCode(r.Context(), sessionToken(r), vaultName, recordName)
+	if err != nil {
+		if err == domain.ErrInvalidSession {
+			h.sendSessionError(w, r, err)
+			return
+		}
+		if err == domain.ErrVaultModified {
+			h.sendError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if err == domain.ErrRecordNotFound || err == domain.ErrNoTOTP {
+			h.sendError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err == domain.ErrInvalidTOTP {
+			h.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.sendError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.sendJSON(w, TOTPResponse{
+		Code:             code.Code,
+		Period:           int(code.Period / time.Second),
+		RemainingSeconds: int((code.Remaining + time.Second - 1) / time.Second),
+		ExpiresAt:        code.ExpiresAt,
+	})
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/orlan/go-password-manager/internal/application"
+)
+
+func TestHandleTOTP(t *testing.T) {
+	setup := func(t *testing.T) (*Handler, string) {
+		handler := setupTestHandler(t)
+		handler.service.CreateVault(nil, "test-vault", "my-password", "")
+		token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+		return handler, token
+	}
+	get := func(handler *Handler, token, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/records/totp?"+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.handleTOTP(w, req)
+		return w
+	}
+
+	t.Run("returns the current code", func(t *testing.T) {
+		handler, token := setup(t)
+
+		body := []byte(`{"vault_name":"test-vault","name":"github","username":"user","password":"pass","totp":"otpauth://totp/GitHub:user?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"}`)
+		req := httptest.NewRequest(http.MethodPost, "/api/records/add", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.handleAddRecord(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("add: expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		w = get(handler, token, "vault_name=test-vault&name=github")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+
+		var response TOTPResponse
+		json.NewDecoder(w.Body).Decode(&response)
+		if len(response.Code) != 6 || response.Period != 30 {
+			t.Errorf("unexpected response %+v", response)
+		}
+		if response.RemainingSeconds < 1 || response.RemainingSeconds > 30 {
+			t.Errorf("unexpected remaining seconds %d", response.RemainingSeconds)
+		}
+	})
+
+	t.Run("rejects an invalid seed", func(t *testing.T) {
+		handler, token := setup(t)
+
+		body, _ := json.Marshal(AddRecordRequest{VaultName: "test-vault", Name: "github", Username: "user", Password: "pass", TOTP: "not a secret!"})
+		req := httptest.NewRequest(http.MethodPost, "/api/records/add", bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.handleAddRecord(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("returns not found without a seed", func(t *testing.T) {
+		handler, token := setup(t)
+		handler.service.AddPasswordRecord(nil, token, "test-vault", application.RecordInput{Name: "github", Username: "user", Password: "pass"})
+
+		if w := get(handler, token, "vault_name=test-vault&name=github"); w.Code != http.StatusNotFound {
+			t.Errorf("no seed: expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+		if w := get(handler, token, "vault_name=test-vault&name=missing"); w.Code != http.StatusNotFound {
+			t.Errorf("missing record: expected status %d, got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("returns error for missing fields", func(t *testing.T) {
+		handler, token := setup(t)
+
+		if w := get(handler, token, "vault_name=test-vault"); w.Code != http.StatusBadRequest {
+			t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("returns error without session", func(t *testing.T) {
+		handler, _ := setup(t)
+
+		if w := get(handler, "", "vault_name=test-vault&name=github"); w.Code != http.StatusUnauthorized {
+			t.Errorf("expected status %d, got %d", http.StatusUnauthorized, w.Code)
+		}
+	})
+}
@@ -0,0 +1,203 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/orlan/go-password-manager/internal/application"
+	"github.com/orlan/go-password-manager/internal/domain"
+	"github.com/orlan/go-password-manager/internal/transfer"
+)
+
+// ExportSkippedHeader carries the number of items left out of a CSV export
+// because the format cannot hold them
+const ExportSkippedHeader = "X-Export-Skipped"
+
+// ExportVaultRequest asks for a download of every item in a vault
+type ExportVaultRequest struct {
+	VaultName string `json:"vault_name"`
+	// Format is "encrypted" (the default) or "csv"
+	Format string `json:"format,omitempty"`
+	// Password encrypts the export; required for the encrypted format
+	Password string `json:"password,omitempty"`
+	// ConfirmPlaintext must be true for a CSV export, which contains every
+	// password unencrypted
+	ConfirmPlaintext bool `json:"confirm_plaintext,omitempty"`
+}
+
+// ImportResponse describes the outcome of an import
+type ImportResponse struct {
+	Message  string   `json:"message"`
+	Added    int      `json:"added"`
+	Replaced int      `json:"replaced"`
+	Renamed  []string `json:"renamed,omitempty"`
+	Skipped  []string `json:"skipped,omitempty"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+// handleExportVault sends the items of an unlocked vault as a file download
+func (h *Handler) handleExportVault(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req ExportVaultRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.VaultName == "" {
+		h.sendError(w, "vault_name is required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.validVaultName(w, req.VaultName) {
+		return
+	}
+
+	format := transfer.FormatEncrypted
+	if req.Format != "" {
+		var err error
+		if format, err = transfer.ParseFormat(req.Format); err != nil {
+			h.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx := application.WithClient(r.Context(), clientAddress(r))
+	export, err := h.service.ExportVault(ctx, sessionToken(r), req.VaultName, application.ExportOptions{
+		Format:           format,
+		Password:         req.Password,
+		ConfirmPlaintext: req.ConfirmPlaintext,
+	})
+	if err != nil {
+		if err == domain.ErrInvalidSession {
+			h.sendSessionError(w, r, err)
+			return
+		}
+		if err == domain.ErrPermissionDenied {
+			h.sendError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err == domain.ErrPlaintextExportNotConfirmed || err == transfer.ErrWeakPassword || errors.Is(err, transfer.ErrUnknownFormat) {
+			h.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		h.sendError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	contentType, extension := "application/json", "json"
+	if format == transfer.FormatCSV {
+		contentType, extension = "text/csv; charset=utf-8", "csv"
+		w.Header().Set(ExportSkippedHeader, strconv.Itoa(len(export.Skipped)))
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-export.%s"`, req.VaultName, extension))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(export.Data)
+}
+
+// handleImportVault adds the items of an uploaded file to an unlocked vault.
+// The request is a multipart form with the fields vault_name, format,
+// password (for encrypted exports) and duplicates, and the file as "file".
+func (h *Handler) handleImportVault(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Keep the whole upload in memory rather than in temporary files
+	const formOverhead = 1 << 20
+	r.Body = http.MaxBytesReader(w, r.Body, transfer.MaxFileSize+formOverhead)
+	if err := r.ParseMultipartForm(transfer.MaxFileSize + formOverhead); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.sendError(w, fmt.Sprintf("file is too large: the limit is %d MB", transfer.MaxFileSize>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		h.sendError(w, "invalid multipart form", http.StatusBadRequest)
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	vaultName := r.FormValue("vault_name")
+	if vaultName == "" || r.FormValue("format") == "" {
+		h.sendError(w, "vault_name, format and file are required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.validVaultName(w, vaultName) {
+		return
+	}
+
+	format, err := transfer.ParseFormat(r.FormValue("format"))
+	if err != nil {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	duplicates, err := application.ParseDuplicatePolicy(r.FormValue("duplicates"))
+	if err != nil {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		h.sendError(w, "vault_name, format and file are required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, transfer.MaxFileSize+1))
+	if err != nil {
+		h.sendError(w, "failed to read file", http.StatusBadRequest)
+		return
+	}
+	if len(data) > transfer.MaxFileSize {
+		h.sendError(w, fmt.Sprintf("file is too large: the limit is %d MB", transfer.MaxFileSize>>20), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	ctx := application.WithClient(r.Context(), clientAddress(r))
+	result, err := h.service.ImportRecords(ctx, sessionToken(r), vaultName, data, application.ImportOptions{
+		Format:     format,
+		Password:   r.FormValue("password"),
+		Duplicates: duplicates,
+	})
+	if err != nil {
+		if err == domain.ErrInvalidSession {
+			h.sendSessionError(w, r, err)
+			return
+		}
+		if err == domain.ErrPermissionDenied {
+			h.sendError(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err == transfer.ErrWrongPassword || errors.Is(err, transfer.ErrMalformed) || errors.Is(err, transfer.ErrUnknownFormat) {
+			h.sendError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err == domain.ErrVaultModified {
+			h.sendError(w, err.Error(), http.StatusConflict)
+			return
+		}
+		h.sendError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.sendJSON(w, ImportResponse{
+		Message:  fmt.Sprintf("imported %d records", result.Added+result.Replaced),
+		Added:    result.Added,
+		Replaced: result.Replaced,
+		Renamed:  result.Renamed,
+		Skipped:  result.Skipped,
+		Problems: result.Problems,
+	})
+}
@@ -0,0 +1,155 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/orlan/go-password-manager/internal/application"
+)
+
+// importRequest builds a multipart upload for the import endpoint
+func importRequest(t *testing.T, token string, fields map[string]string, file []byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for name, value := range fields {
+		form.WriteField(name, value)
+	}
+	if file != nil {
+		part, err := form.CreateFormFile("file", "export")
+		if err != nil {
+			t.Fatalf("CreateFormFile() failed: %v", err)
+		}
+		part.Write(file)
+	}
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/vaults/import", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestHandleExportVault(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.service.CreateVault(nil, "test-vault", "my-password", "")
+	token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+	handler.service.AddPasswordRecord(nil, token, "test-vault", application.RecordInput{Name: "github", Username: "user", Password: "secret-password"})
+
+	export := func(req ExportVaultRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		r := httptest.NewRequest(http.MethodPost, "/api/vaults/export", bytes.NewBuffer(body))
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.handleExportVault(w, r)
+		return w
+	}
+
+	tests := []struct {
+		name       string
+		req        ExportVaultRequest
+		wantStatus int
+	}{
+		{"missing vault name", ExportVaultRequest{Password: "export-password"}, http.StatusBadRequest},
+		{"unknown format", ExportVaultRequest{VaultName: "test-vault", Format: "keepass", ConfirmPlaintext: true}, http.StatusBadRequest},
+		{"short password", ExportVaultRequest{VaultName: "test-vault", Password: "short"}, http.StatusBadRequest},
+		{"unconfirmed plaintext", ExportVaultRequest{VaultName: "test-vault", Format: "csv"}, http.StatusBadRequest},
+		{"locked vault", ExportVaultRequest{VaultName: "other-vault", Password: "export-password"}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := export(tt.req); w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		w := export(ExportVaultRequest{VaultName: "test-vault", Format: "csv", ConfirmPlaintext: true})
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Header().Get("Content-Disposition"), `filename="test-vault-export.csv"`) {
+			t.Errorf("unexpected Content-Disposition %q", w.Header().Get("Content-Disposition"))
+		}
+		if !strings.Contains(w.Body.String(), "secret-password") || w.Header().Get(ExportSkippedHeader) != "0" {
+			t.Errorf("unexpected export: %q", w.Body.String())
+		}
+	})
+
+	t.Run("encrypted round trip", func(t *testing.T) {
+		w := export(ExportVaultRequest{VaultName: "test-vault", Password: "export-password"})
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		if strings.Contains(w.Body.String(), "secret-password") {
+			t.Fatal("encrypted export contains the password")
+		}
+
+		fields := map[string]string{"vault_name": "test-vault", "format": "encrypted", "password": "export-password", "duplicates": "rename"}
+		rec := httptest.NewRecorder()
+		handler.handleImportVault(rec, importRequest(t, token, fields, w.Body.Bytes()))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("import: expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+		}
+
+		var response ImportResponse
+		json.NewDecoder(rec.Body).Decode(&response)
+		if response.Added != 1 || len(response.Renamed) != 1 || response.Renamed[0] != "github -> github (2)" {
+			t.Errorf("unexpected response: %+v", response)
+		}
+	})
+}
+
+func TestHandleImportVault(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.service.CreateVault(nil, "test-vault", "my-password", "")
+	token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+
+	csvFile := []byte("name,username,password\ngithub,user,secret\n")
+
+	tests := []struct {
+		name       string
+		token      string
+		fields     map[string]string
+		file       []byte
+		wantStatus int
+	}{
+		{"missing file", token, map[string]string{"vault_name": "test-vault", "format": "csv"}, nil, http.StatusBadRequest},
+		{"missing format", token, map[string]string{"vault_name": "test-vault"}, csvFile, http.StatusBadRequest},
+		{"unknown format", token, map[string]string{"vault_name": "test-vault", "format": "lastpass"}, csvFile, http.StatusBadRequest},
+		{"unknown duplicate policy", token, map[string]string{"vault_name": "test-vault", "format": "csv", "duplicates": "merge"}, csvFile, http.StatusBadRequest},
+		{"malformed file", token, map[string]string{"vault_name": "test-vault", "format": "bitwarden"}, []byte("not json"), http.StatusBadRequest},
+		{"invalid session", "bad-token", map[string]string{"vault_name": "test-vault", "format": "csv"}, csvFile, http.StatusUnauthorized},
+		{"csv", token, map[string]string{"vault_name": "test-vault", "format": "csv"}, csvFile, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler.handleImportVault(w, importRequest(t, tt.token, tt.fields, tt.file))
+			if w.Code != tt.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	record, err := handler.service.GetPasswordRecord(nil, token, "test-vault", "github")
+	if err != nil || record.Password != "secret" {
+		t.Errorf("expected the imported record, got %+v, %v", record, err)
+	}
+
+	t.Run("file too large", func(t *testing.T) {
+		req := importRequest(t, token, map[string]string{"vault_name": "test-vault", "format": "csv"}, make([]byte, 12<<20))
+		w := httptest.NewRecorder()
+		handler.handleImportVault(w, req)
+		if w.Code != http.StatusRequestEntityTooLarge {
+			t.Errorf("expected status %d, got %d", http.StatusRequestEntityTooLarge, w.Code)
+		}
+	})
+}
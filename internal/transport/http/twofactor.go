@@ -0,0 +1,146 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/orlan/go-password-manager/internal/application"
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// ReasonTwoFactorRequired is the error reason of an unlock that needs a
+// one-time code; the client should ask for one and retry
+const ReasonTwoFactorRequired = "two_factor_required"
+
+// TwoFactorSetupResponse is a new TOTP key for two-factor unlock
+type TwoFactorSetupResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// EnableTwoFactorRequest turns on two-factor unlock with the key from the
+// setup endpoint and a code from the authenticator app
+type EnableTwoFactorRequest struct {
+	VaultName string `json:"vault_name"`
+	Secret    string `json:"secret"`
+	Code      string `json:"code"`
+}
+
+// DisableTwoFactorRequest turns off two-factor unlock
+type DisableTwoFactorRequest struct {
+	VaultName string `json:"vault_name"`
+	Code      string `json:"code"`
+}
+
+// handleTwoFactorSetup generates a TOTP key for an unlocked vault. Nothing
+// is stored until the key is confirmed through the enable endpoint.
+func (h *Handler) handleTwoFactorSetup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	vaultName := r.URL.Query().Get("vault_name")
+	if vaultName == "" {
+		h.sendError(w, "vault_name query parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.validVaultName(w, vaultName) {
+		return
+	}
+
+	if err := h.service.ValidateSession(r.Context(), sessionToken(r), vaultName); err != nil {
+		h.sendSessionError(w, r, err)
+		return
+	}
+
+	setup, err := application.NewTwoFactorSetup(vaultName)
+	if err != nil {
+		h.sendError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	h.sendJSON(w, TwoFactorSetupResponse{Secret: setup.Secret, URI: setup.URI})
+}
+
+// handleEnableTwoFactor turns on two-factor unlock for a vault
+func (h *Handler) handleEnableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req EnableTwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.VaultName == "" || req.Secret == "" || req.Code == "" {
+		h.sendError(w, "vault_name, secret and code are required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.validVaultName(w, req.VaultName) {
+		return
+	}
+
+	if err := h.service.EnableTwoFactor(r.Context(), sessionToken(r), req.VaultName, req.Secret, req.Code); err != nil {
+		h.sendTwoFactorError(w, r, err)
+		return
+	}
+
+	h.sendJSON(w, SuccessResponse{Message: "two-factor authentication enabled"})
+}
+
+// handleDisableTwoFactor turns off two-factor unlock for a vault
+func (h *Handler) handleDisableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		h.sendError(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req DisableTwoFactorRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.sendError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.VaultName == "" || req.Code == "" {
+		h.sendError(w, "vault_name and code are required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.validVaultName(w, req.VaultName) {
+		return
+	}
+
+	if err := h.service.DisableTwoFactor(r.Context(), sessionToken(r), req.VaultName, req.Code); err != nil {
+		h.sendTwoFactorError(w, r, err)
+		return
+	}
+
+	h.sendJSON(w, SuccessResponse{Message: "two-factor authentication disabled"})
+}
+
+// sendTwoFactorError maps errors of the enable and disable endpoints
+func (h *Handler) sendTwoFactorError(w http.ResponseWriter, r *http.Request, err error) {
+	if err == domain.ErrInvalidSession {
+		h.sendSessionError(w, r, err)
+		return
+	}
+	if err == domain.ErrPermissionDenied {
+		h.sendError(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err == domain.ErrInvalidTOTP || err == domain.ErrInvalidTwoFactorCode {
+		h.sendError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err == domain.ErrTwoFactorEnabled || err == domain.ErrTwoFactorNotEnabled || err == domain.ErrVaultModified {
+		h.sendError(w, err.Error(), http.StatusConflict)
+		return
+	}
+	h.sendError(w, err.Error(), http.StatusInternalServerError)
+}
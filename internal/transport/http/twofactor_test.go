@@ -0,0 +1,156 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/otp"
+)
+
+// currentCode returns the code of the otpauth:// key at the given offset
+// from now
+func currentCode(t *testing.T, uri string, offset time.Duration) string {
+	t.Helper()
+
+	key, err := otp.Parse(uri)
+	if err != nil {
+		t.Fatalf("otp.Parse(%q) failed: %v", uri, err)
+	}
+	code, err := key.Code(time.Now().Add(offset))
+	if err != nil {
+		t.Fatalf("Code() failed: %v", err)
+	}
+	return code
+}
+
+func unlockRequest(handler *Handler, req UnlockVaultRequest) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(req)
+	w := httptest.NewRecorder()
+	handler.handleUnlockVault(w, httptest.NewRequest(http.MethodPost, "/api/vaults/unlock", bytes.NewBuffer(body)))
+	return w
+}
+
+func TestCreateVaultWithTwoFactor(t *testing.T) {
+	handler := setupTestHandler(t)
+
+	body, _ := json.Marshal(CreateVaultRequest{Name: "guarded", MasterPassword: "my-password", TwoFactor: true})
+	w := httptest.NewRecorder()
+	handler.handleCreateVault(w, httptest.NewRequest(http.MethodPost, "/api/vaults/create", bytes.NewBuffer(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("create: expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var created CreateVaultResponse
+	json.NewDecoder(w.Body).Decode(&created)
+	if created.TwoFactor == nil || created.TwoFactor.URI == "" {
+		t.Fatalf("expected a two-factor key, got %+v", created)
+	}
+
+	w = unlockRequest(handler, UnlockVaultRequest{Name: "guarded", MasterPassword: "my-password"})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d without code, got %d", http.StatusUnauthorized, w.Code)
+	}
+	var response ErrorResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	if response.Reason != ReasonTwoFactorRequired {
+		t.Errorf("expected reason %q, got %q", ReasonTwoFactorRequired, response.Reason)
+	}
+
+	// A wrong code and a wrong password cannot be told apart
+	wrongCode := unlockRequest(handler, UnlockVaultRequest{Name: "guarded", MasterPassword: "my-password", TOTPCode: "12345"})
+	wrongPassword := unlockRequest(handler, UnlockVaultRequest{Name: "guarded", MasterPassword: "wrong-password", TOTPCode: currentCode(t, created.TwoFactor.URI, 0)})
+	if wrongCode.Code != http.StatusUnauthorized || wrongPassword.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d for a wrong code and password, got %d and %d", http.StatusUnauthorized, wrongCode.Code, wrongPassword.Code)
+	}
+	if wrongCode.Body.String() != wrongPassword.Body.String() {
+		t.Errorf("responses differ: %s and %s", wrongCode.Body.String(), wrongPassword.Body.String())
+	}
+
+	w = unlockRequest(handler, UnlockVaultRequest{Name: "guarded", MasterPassword: "my-password", TOTPCode: currentCode(t, created.TwoFactor.URI, 0)})
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	// The password alone cannot change the password either
+	changePassword := func(req ChangePasswordRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		w := httptest.NewRecorder()
+		handler.handleChangePassword(w, httptest.NewRequest(http.MethodPost, "/api/vaults/change-password", bytes.NewBuffer(body)))
+		return w
+	}
+	w = changePassword(ChangePasswordRequest{Name: "guarded", OldPassword: "my-password", NewPassword: "stolen-password"})
+	response = ErrorResponse{}
+	json.NewDecoder(w.Body).Decode(&response)
+	if w.Code != http.StatusUnauthorized || response.Reason != ReasonTwoFactorRequired {
+		t.Errorf("expected status %d with reason %q, got %d: %+v", http.StatusUnauthorized, ReasonTwoFactorRequired, w.Code, response)
+	}
+	w = changePassword(ChangePasswordRequest{Name: "guarded", OldPassword: "my-password", NewPassword: "new-password", TOTPCode: currentCode(t, created.TwoFactor.URI, otp.DefaultPeriod)})
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+}
+
+func TestEnableAndDisableTwoFactor(t *testing.T) {
+	handler := setupTestHandler(t)
+	handler.service.CreateVault(nil, "test-vault", "my-password", "")
+	token, _ := handler.service.UnlockVault(nil, "test-vault", "my-password")
+
+	post := func(handle http.HandlerFunc, path string, payload any) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(payload)
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handle(w, req)
+		return w
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/vaults/two-factor/setup?vault_name=test-vault", nil)
+	w := httptest.NewRecorder()
+	handler.handleTwoFactorSetup(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("setup without session: expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	w = httptest.NewRecorder()
+	handler.handleTwoFactorSetup(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("setup: expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var setup TwoFactorSetupResponse
+	json.NewDecoder(w.Body).Decode(&setup)
+
+	w = post(handler.handleEnableTwoFactor, "/api/vaults/two-factor/enable", EnableTwoFactorRequest{VaultName: "test-vault", Secret: setup.URI, Code: "000000x"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("enable with wrong code: expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+
+	w = post(handler.handleEnableTwoFactor, "/api/vaults/two-factor/enable", EnableTwoFactorRequest{VaultName: "test-vault", Secret: setup.URI, Code: currentCode(t, setup.URI, 0)})
+	if w.Code != http.StatusOK {
+		t.Fatalf("enable: expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	w = post(handler.handleEnableTwoFactor, "/api/vaults/two-factor/enable", EnableTwoFactorRequest{VaultName: "test-vault", Secret: setup.URI, Code: currentCode(t, setup.URI, otp.DefaultPeriod)})
+	if w.Code != http.StatusConflict {
+		t.Errorf("enable twice: expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+
+	w = unlockRequest(handler, UnlockVaultRequest{Name: "test-vault", MasterPassword: "my-password"})
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unlock without code: expected status %d, got %d", http.StatusUnauthorized, w.Code)
+	}
+
+	w = post(handler.handleDisableTwoFactor, "/api/vaults/two-factor/disable", DisableTwoFactorRequest{VaultName: "test-vault", Code: currentCode(t, setup.URI, otp.DefaultPeriod)})
+	if w.Code != http.StatusOK {
+		t.Fatalf("disable: expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	w = unlockRequest(handler, UnlockVaultRequest{Name: "test-vault", MasterPassword: "my-password"})
+	if w.Code != http.StatusOK {
+		t.Errorf("unlock after disable: expected status %d, got %d", http.StatusOK, w.Code)
+	}
+}
@@ -0,0 +1,83 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// AttemptsFile holds the failed unlock counters of all vaults in the
+// directory. Deleting it lifts every lockout.
+const AttemptsFile = "unlock-attempts.json"
+
+// TwoFactorStepsFile holds the time step of the last one-time code used
+// for each vault in the directory
+const TwoFactorStepsFile = "two-factor-steps.json"
+
+// UpdateAttempts implements domain.AttemptStore. The counters are kept in
+// AttemptsFile, so they survive restarts and are shared with every process
+// using the vault directory.
+func (r *FileRepository) UpdateAttempts(ctx context.Context, fn func(attempts map[string]domain.UnlockAttempts) error) error {
+	return updateMapFile(r, AttemptsFile, "unlock attempts", fn)
+}
+
+// UpdateTwoFactorSteps implements domain.TwoFactorStepStore. The steps are
+// kept in TwoFactorStepsFile, so a code used with one process is refused by
+// the others.
+func (r *FileRepository) UpdateTwoFactorSteps(ctx context.Context, fn func(steps map[string]uint64) error) error {
+	return updateMapFile(r, TwoFactorStepsFile, "two-factor steps", fn)
+}
+
+// updateMapFile passes the map stored as JSON in the directory's file name
+// to fn and writes back what fn leaves behind, holding the file's advisory
+// lock throughout. An empty map removes the file. what names the contents
+// in errors.
+func updateMapFile[V any](r *FileRepository, name, what string, fn func(m map[string]V) error) error {
+	path := filepath.Join(r.vaultDir, name)
+
+	unlock, err := lockFile(filepath.Join(r.vaultDir, "."+name+LockExtension))
+	if err != nil {
+		return fmt.Errorf("failed to lock %s: %w", what, err)
+	}
+	defer unlock()
+
+	m := make(map[string]V)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", what, err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &m); err != nil {
+			return fmt.Errorf("failed to unmarshal %s: %w", what, err)
+		}
+	}
+
+	if err := fn(m); err != nil {
+		return err
+	}
+
+	if len(m) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", what, err)
+		}
+		return nil
+	}
+
+	if data, err = json.Marshal(m); err != nil {
+		return fmt.Errorf("failed to marshal %s: %w", what, err)
+	}
+	tmpPath, err := r.writeTemp(name, data)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", what, err)
+	}
+	defer os.Remove(tmpPath)
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to replace %s: %w", what, err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+func TestUpdateAttempts(t *testing.T) {
+	t.Run("persists counters across repositories", func(t *testing.T) {
+		dir := t.TempDir()
+		repo, _ := NewFileRepository(dir)
+		ctx := context.Background()
+		at := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+
+		err := repo.UpdateAttempts(ctx, func(attempts map[string]domain.UnlockAttempts) error {
+			attempts["vault:personal"] = domain.UnlockAttempts{Failures: 3, LastFailure: at}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("UpdateAttempts() failed: %v", err)
+		}
+
+		reopened, _ := NewFileRepository(dir)
+		var got domain.UnlockAttempts
+		reopened.UpdateAttempts(ctx, func(attempts map[string]domain.UnlockAttempts) error {
+			got = attempts["vault:personal"]
+			return nil
+		})
+		if got.Failures != 3 || !got.LastFailure.Equal(at) {
+			t.Errorf("unexpected counters %+v", got)
+		}
+
+		// The attempts file is not mistaken for a vault
+		vaults, _ := reopened.List(ctx)
+		if len(vaults) != 0 {
+			t.Errorf("expected no vaults, got %v", vaults)
+		}
+	})
+
+	t.Run("keeps counters when fn fails", func(t *testing.T) {
+		repo, _ := NewFileRepository(t.TempDir())
+		ctx := context.Background()
+
+		repo.UpdateAttempts(ctx, func(attempts map[string]domain.UnlockAttempts) error {
+			attempts["vault:personal"] = domain.UnlockAttempts{Failures: 1}
+			return nil
+		})
+
+		errStop := errors.New("stop")
+		err := repo.UpdateAttempts(ctx, func(attempts map[string]domain.UnlockAttempts) error {
+			delete(attempts, "vault:personal")
+			return errStop
+		})
+		if err != errStop {
+			t.Fatalf("expected fn error, got %v", err)
+		}
+
+		repo.UpdateAttempts(ctx, func(attempts map[string]domain.UnlockAttempts) error {
+			if attempts["vault:personal"].Failures != 1 {
+				t.Errorf("expected counters to be kept, got %+v", attempts)
+			}
+			return nil
+		})
+	})
+
+	t.Run("removes the file when no counters are left", func(t *testing.T) {
+		dir := t.TempDir()
+		repo, _ := NewFileRepository(dir)
+		ctx := context.Background()
+
+		repo.UpdateAttempts(ctx, func(attempts map[string]domain.UnlockAttempts) error {
+			attempts["vault:personal"] = domain.UnlockAttempts{Failures: 1}
+			return nil
+		})
+		repo.UpdateAttempts(ctx, func(attempts map[string]domain.UnlockAttempts) error {
+			delete(attempts, "vault:personal")
+			return nil
+		})
+
+		if _, err := os.Stat(filepath.Join(dir, AttemptsFile)); !os.IsNotExist(err) {
+			t.Errorf("expected attempts file to be removed, got %v", err)
+		}
+	})
+
+	t.Run("serializes concurrent updates", func(t *testing.T) {
+		dir := t.TempDir()
+		ctx := context.Background()
+
+		var wg sync.WaitGroup
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// Separate repositories behave like separate processes
+				repo, _ := NewFileRepository(dir)
+				repo.UpdateAttempts(ctx, func(attempts map[string]domain.UnlockAttempts) error {
+					counter := attempts["vault:personal"]
+					counter.Failures++
+					attempts["vault:personal"] = counter
+					return nil
+				})
+			}()
+		}
+		wg.Wait()
+
+		repo, _ := NewFileRepository(dir)
+		repo.UpdateAttempts(ctx, func(attempts map[string]domain.UnlockAttempts) error {
+			if attempts["vault:personal"].Failures != 20 {
+				t.Errorf("expected 20 failures, got %d", attempts["vault:personal"].Failures)
+			}
+			return nil
+		})
+	})
+}
+
+func TestUpdateTwoFactorSteps(t *testing.T) {
+	dir := t.TempDir()
+	repo, _ := NewFileRepository(dir)
+	ctx := context.Background()
+
+	err := repo.UpdateTwoFactorSteps(ctx, func(steps map[string]uint64) error {
+		steps["personal"] = 56666666
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateTwoFactorSteps() failed: %v", err)
+	}
+
+	// Another process sees the step
+	reopened, _ := NewFileRepository(dir)
+	reopened.UpdateTwoFactorSteps(ctx, func(steps map[string]uint64) error {
+		if steps["personal"] != 56666666 {
+			t.Errorf("unexpected steps %v", steps)
+		}
+		delete(steps, "personal")
+		return nil
+	})
+	if _, err := os.Stat(filepath.Join(dir, TwoFactorStepsFile)); !os.IsNotExist(err) {
+		t.Errorf("expected the steps file to be removed, got %v", err)
+	}
+}
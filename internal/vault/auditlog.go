@@ -0,0 +1,201 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// AuditLogExtension is the extension of the file holding a vault's audit
+// log, one JSON record per line
+const AuditLogExtension = ".auditlog"
+
+// AuditLogHeadExtension is appended to the name of a vault's audit log for
+// the file holding its head, the last record with a MAC
+const AuditLogHeadExtension = ".head"
+
+// auditLogBlockSize is how much of the end of a log is read at a time when
+// looking for its last record
+const auditLogBlockSize = 4096
+
+// AppendAuditLog implements domain.AuditLogStore. Records are appended to
+// <name>.auditlog next to the vault under an advisory lock shared with
+// other processes, and synced before the lock is released. A record with a
+// MAC replaces <name>.auditlog.head under the same lock. Only the last
+// record is read, so appending does not slow down as the log grows.
+func (r *FileRepository) AppendAuditLog(ctx context.Context, vaultName string, fn func(last *domain.AuditLogRecord) (domain.AuditLogRecord, error)) error {
+	if err := domain.ValidateVaultName(vaultName); err != nil {
+		return err
+	}
+
+	unlock, err := lockFile(filepath.Join(r.vaultDir, "."+vaultName+AuditLogExtension+LockExtension))
+	if err != nil {
+		return fmt.Errorf("failed to lock audit log: %w", err)
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(r.getAuditLogPath(vaultName), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	last, err := lastAuditLogRecord(f, info.Size())
+	if err != nil {
+		return err
+	}
+
+	record, err := fn(last)
+	if err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit log record: %w", err)
+	}
+	if err := r.writeFile(f, append(line, '\n')); err != nil {
+		// Drop a partly written record so the log stays readable
+		f.Truncate(info.Size())
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync audit log: %w", err)
+	}
+
+	if record.MAC != nil {
+		if err := r.writeAuditLogHead(vaultName, line); err != nil {
+			return fmt.Errorf("failed to write audit log head: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeAuditLogHead atomically replaces the head of a vault's log with the
+// encoded record. A head left behind by a failed write only misses the
+// records after it.
+func (r *FileRepository) writeAuditLogHead(vaultName string, record []byte) error {
+	path := r.getAuditLogPath(vaultName) + AuditLogHeadExtension
+	tmpPath, err := r.writeTemp(filepath.Base(path), record)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return syncDir(r.vaultDir)
+}
+
+// LoadAuditLogHead implements domain.AuditLogStore. A vault without a
+// head has none.
+func (r *FileRepository) LoadAuditLogHead(ctx context.Context, vaultName string) (*domain.AuditLogRecord, error) {
+	if err := domain.ValidateVaultName(vaultName); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(r.getAuditLogPath(vaultName) + AuditLogHeadExtension)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read audit log head: %w", err)
+	}
+
+	var head domain.AuditLogRecord
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal audit log head: %w", err)
+	}
+	return &head, nil
+}
+
+// LoadAuditLog implements domain.AuditLogStore. A vault without a log has
+// no records.
+func (r *FileRepository) LoadAuditLog(ctx context.Context, vaultName string) ([]domain.AuditLogRecord, error) {
+	if err := domain.ValidateVaultName(vaultName); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(r.getAuditLogPath(vaultName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	defer f.Close()
+
+	return readAuditLog(f)
+}
+
+// readAuditLog parses every record in r. A last line without a newline was
+// cut short by a failed write and makes the log unreadable, as appending
+// to it would corrupt the next record too.
+func readAuditLog(r io.Reader) ([]domain.AuditLogRecord, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		return nil, errors.New("failed to read audit log: last record is incomplete")
+	}
+
+	var records []domain.AuditLogRecord
+	for line := range bytes.Lines(data) {
+		var record domain.AuditLogRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal audit log record: %w", err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// lastAuditLogRecord reads the last record of a log of size bytes, or nil
+// if it is empty. The log is read backwards from its end up to the line
+// before the last one. Like readAuditLog it fails on an incomplete record.
+func lastAuditLogRecord(f *os.File, size int64) (*domain.AuditLogRecord, error) {
+	if size == 0 {
+		return nil, nil
+	}
+
+	var tail []byte
+	for offset := size; offset > 0; {
+		block := make([]byte, min(auditLogBlockSize, offset))
+		offset -= int64(len(block))
+		if _, err := f.ReadAt(block, offset); err != nil {
+			return nil, fmt.Errorf("failed to read audit log: %w", err)
+		}
+		tail = append(block, tail...)
+
+		if tail[len(tail)-1] != '\n' {
+			return nil, errors.New("failed to read audit log: last record is incomplete")
+		}
+		if i := bytes.LastIndexByte(tail[:len(tail)-1], '\n'); i >= 0 {
+			tail = tail[i+1:]
+			break
+		}
+	}
+
+	var record domain.AuditLogRecord
+	if err := json.Unmarshal(tail, &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal audit log record: %w", err)
+	}
+	return &record, nil
+}
+
+// getAuditLogPath constructs the path of a vault's audit log
+func (r *FileRepository) getAuditLogPath(name string) string {
+	return filepath.Join(r.vaultDir, name+AuditLogExtension)
+}
@@ -0,0 +1,151 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/orlan/go-password-manager/internal/domain"
+)
+
+// appendRecord appends a record whose entry is text and whose sequence
+// follows the last record
+func appendRecord(repo *FileRepository, text string) error {
+	return repo.AppendAuditLog(context.Background(), "test-vault", func(last *domain.AuditLogRecord) (domain.AuditLogRecord, error) {
+		record := domain.AuditLogRecord{Sequence: 1, Entry: []byte(text)}
+		if last != nil {
+			record.Sequence = last.Sequence + 1
+		}
+		return record, nil
+	})
+}
+
+func TestAuditLog(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("appends across repositories", func(t *testing.T) {
+		dir := t.TempDir()
+		repo, _ := NewFileRepository(dir)
+		for i := range 3 {
+			if err := appendRecord(repo, fmt.Sprintf("entry %d", i+1)); err != nil {
+				t.Fatalf("AppendAuditLog() failed: %v", err)
+			}
+		}
+
+		reopened, _ := NewFileRepository(dir)
+		appendRecord(reopened, "entry 4")
+		records, err := reopened.LoadAuditLog(ctx, "test-vault")
+		if err != nil {
+			t.Fatalf("LoadAuditLog() failed: %v", err)
+		}
+		if len(records) != 4 {
+			t.Fatalf("expected 4 records, got %d", len(records))
+		}
+		for i, record := range records {
+			if record.Sequence != uint64(i+1) || string(record.Entry) != fmt.Sprintf("entry %d", i+1) {
+				t.Errorf("unexpected record %d: %+v", i, record)
+			}
+		}
+
+		// The log is not mistaken for a vault
+		if vaults, _ := reopened.List(ctx); len(vaults) != 0 {
+			t.Errorf("expected no vaults, got %v", vaults)
+		}
+	})
+
+	t.Run("appends after records longer than a block", func(t *testing.T) {
+		repo, _ := NewFileRepository(t.TempDir())
+		long := strings.Repeat("x", 3*auditLogBlockSize)
+		appendRecord(repo, long)
+		appendRecord(repo, long)
+		if err := appendRecord(repo, "entry 3"); err != nil {
+			t.Fatalf("AppendAuditLog() failed: %v", err)
+		}
+
+		records, err := repo.LoadAuditLog(ctx, "test-vault")
+		if err != nil || len(records) != 3 || records[2].Sequence != 3 || string(records[1].Entry) != long {
+			t.Errorf("unexpected records %v, %v", len(records), err)
+		}
+	})
+
+	t.Run("records with a MAC become the head", func(t *testing.T) {
+		repo, _ := NewFileRepository(t.TempDir())
+		if head, err := repo.LoadAuditLogHead(ctx, "test-vault"); head != nil || err != nil {
+			t.Fatalf("expected no head, got %+v, %v", head, err)
+		}
+
+		for i, mac := range [][]byte{[]byte("mac"), nil} {
+			repo.AppendAuditLog(ctx, "test-vault", func(last *domain.AuditLogRecord) (domain.AuditLogRecord, error) {
+				return domain.AuditLogRecord{Sequence: uint64(i + 1), MAC: mac}, nil
+			})
+		}
+
+		head, err := repo.LoadAuditLogHead(ctx, "test-vault")
+		if err != nil || head == nil || head.Sequence != 1 || string(head.MAC) != "mac" {
+			t.Errorf("expected the first record as head, got %+v, %v", head, err)
+		}
+	})
+
+	t.Run("missing log is empty", func(t *testing.T) {
+		repo, _ := NewFileRepository(t.TempDir())
+		records, err := repo.LoadAuditLog(ctx, "test-vault")
+		if err != nil || len(records) != 0 {
+			t.Errorf("expected no records, got %v, %v", records, err)
+		}
+	})
+
+	t.Run("failed append leaves the log unchanged", func(t *testing.T) {
+		repo, _ := NewFileRepository(t.TempDir())
+		appendRecord(repo, "entry 1")
+
+		fnErr := errors.New("rejected")
+		err := repo.AppendAuditLog(ctx, "test-vault", func(last *domain.AuditLogRecord) (domain.AuditLogRecord, error) {
+			return domain.AuditLogRecord{}, fnErr
+		})
+		if err != fnErr {
+			t.Errorf("expected the error of fn, got %v", err)
+		}
+
+		// Simulate the disk filling up halfway through the write
+		repo.writeFile = func(f *os.File, data []byte) error {
+			f.Write(data[:len(data)/2])
+			return errors.New("no space left on device")
+		}
+		if err := appendRecord(repo, "entry 2"); err == nil {
+			t.Fatal("AppendAuditLog() should fail when the write fails")
+		}
+
+		records, err := repo.LoadAuditLog(ctx, "test-vault")
+		if err != nil || len(records) != 1 {
+			t.Errorf("expected the first record only, got %v, %v", records, err)
+		}
+	})
+
+	t.Run("incomplete record", func(t *testing.T) {
+		dir := t.TempDir()
+		repo, _ := NewFileRepository(dir)
+		appendRecord(repo, "entry 1")
+
+		f, _ := os.OpenFile(filepath.Join(dir, "test-vault"+AuditLogExtension), os.O_WRONLY|os.O_APPEND, 0600)
+		f.WriteString(`{"seq":2,"ent`)
+		f.Close()
+
+		if _, err := repo.LoadAuditLog(ctx, "test-vault"); err == nil {
+			t.Error("expected LoadAuditLog() to fail on an incomplete record")
+		}
+		if err := appendRecord(repo, "entry 2"); err == nil {
+			t.Error("expected AppendAuditLog() to fail on an incomplete record")
+		}
+	})
+
+	t.Run("invalid vault name", func(t *testing.T) {
+		repo, _ := NewFileRepository(t.TempDir())
+		if _, err := repo.LoadAuditLog(ctx, "../escape"); err == nil {
+			t.Error("expected LoadAuditLog() to reject an invalid vault name")
+		}
+	})
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package vault
+
+// lockFile is a no-op on platforms without flock. Revision checks in
+// SaveIfUnchanged still detect most concurrent writers.
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 
 	"github.com/orlan/go-password-manager/internal/domain"
@@ -13,12 +14,20 @@ import (
 
 const (
 	VaultExtension = ".vault"
+	// BackupExtension is appended to the vault file name for the copy of the
+	// previous version. Saves that replace a key remove it.
+	BackupExtension = ".bak"
+	LockExtension   = ".lock"
 	DefaultVaultDir = "./vaults"
 )
 
 // FileRepository implements VaultRepository using the filesystem
 type FileRepository struct {
 	vaultDir string
+
+	// writeFile writes data to a freshly created temp file.
+	// Tests replace it to simulate failures partway through a write.
+	writeFile func(f *os.File, data []byte) error
 }
 
 // NewFileRepository creates a new file-based vault repository
@@ -33,12 +42,124 @@ func NewFileRepository(vaultDir string) (*FileRepository, error) {
 	}
 
 	return &FileRepository{
-		vaultDir: vaultDir,
+		vaultDir:  vaultDir,
+		writeFile: writeAll,
 	}, nil
 }
 
-// Save persists vault metadata to disk
+// Save persists vault metadata to disk.
+// The new contents are written to a temp file in the vault directory,
+// synced and renamed over the vault file, so a crash or full disk never
+// leaves a truncated vault behind. The previous version is kept as
+// <name>.vault.bak; it still opens with the keys it was saved with until
+// RemoveBackup deletes it.
 func (r *FileRepository) Save(ctx context.Context, name string, metadata *domain.VaultMetadata) error {
+	if err := domain.ValidateVaultName(name); err != nil {
+		return err
+	}
+
+	unlock, err := lockFile(r.getLockPath(name))
+	if err != nil {
+		return fmt.Errorf("failed to lock vault: %w", err)
+	}
+	defer unlock()
+
+	return r.write(name, metadata)
+}
+
+// Create writes the vault file of a new vault. The file is linked into
+// place, which fails if it exists, so two processes creating the same vault
+// cannot overwrite each other: the second gets domain.ErrVaultAlreadyExists.
+func (r *FileRepository) Create(ctx context.Context, name string, metadata *domain.VaultMetadata) error {
+	if err := domain.ValidateVaultName(name); err != nil {
+		return err
+	}
+
+	unlock, err := lockFile(r.getLockPath(name))
+	if err != nil {
+		return fmt.Errorf("failed to lock vault: %w", err)
+	}
+	defer unlock()
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal vault metadata: %w", err)
+	}
+
+	tmpPath, err := r.writeTemp(name+VaultExtension, data)
+	if err != nil {
+		return fmt.Errorf("failed to write vault file: %w", err)
+	}
+	defer os.Remove(tmpPath)
+
+	if err := os.Link(tmpPath, r.getVaultPath(name)); err != nil {
+		if os.IsExist(err) {
+			return domain.ErrVaultAlreadyExists
+		}
+		return fmt.Errorf("failed to create vault file: %w", err)
+	}
+
+	if err := syncDir(r.vaultDir); err != nil {
+		return fmt.Errorf("failed to sync vault directory: %w", err)
+	}
+	return nil
+}
+
+// SaveIfUnchanged persists vault metadata only if the revision on disk still
+// equals expectedRevision, returning domain.ErrVaultModified otherwise.
+// On success metadata.Revision is set to the new revision. The check and the
+// write happen under an advisory lock shared with other processes.
+func (r *FileRepository) SaveIfUnchanged(ctx context.Context, name string, metadata *domain.VaultMetadata, expectedRevision uint64) error {
+	if err := domain.ValidateVaultName(name); err != nil {
+		return err
+	}
+
+	unlock, err := lockFile(r.getLockPath(name))
+	if err != nil {
+		return fmt.Errorf("failed to lock vault: %w", err)
+	}
+	defer unlock()
+
+	current, err := r.Load(ctx, name)
+	if err != nil {
+		return err
+	}
+	if current.Revision != expectedRevision {
+		return domain.ErrVaultModified
+	}
+
+	metadata.Revision = expectedRevision + 1
+	if err := r.write(name, metadata); err != nil {
+		metadata.Revision = expectedRevision
+		return err
+	}
+
+	return nil
+}
+
+// RemoveBackup deletes <name>.vault.bak. It is not an error if there is none.
+func (r *FileRepository) RemoveBackup(ctx context.Context, name string) error {
+	if err := domain.ValidateVaultName(name); err != nil {
+		return err
+	}
+
+	unlock, err := lockFile(r.getLockPath(name))
+	if err != nil {
+		return fmt.Errorf("failed to lock vault: %w", err)
+	}
+	defer unlock()
+
+	if err := os.Remove(r.getVaultPath(name) + BackupExtension); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove vault backup: %w", err)
+	}
+	if err := syncDir(r.vaultDir); err != nil {
+		return fmt.Errorf("failed to sync vault directory: %w", err)
+	}
+	return nil
+}
+
+// write atomically replaces the vault file. Callers must hold the vault lock.
+func (r *FileRepository) write(name string, metadata *domain.VaultMetadata) error {
 	filePath := r.getVaultPath(name)
 
 	data, err := json.Marshal(metadata)
@@ -46,15 +167,94 @@ func (r *FileRepository) Save(ctx context.Context, name string, metadata *domain
 		return fmt.Errorf("failed to marshal vault metadata: %w", err)
 	}
 
-	if err := os.WriteFile(filePath, data, 0600); err != nil {
+	tmpPath, err := r.writeTemp(name+VaultExtension, data)
+	if err != nil {
 		return fmt.Errorf("failed to write vault file: %w", err)
 	}
+	defer os.Remove(tmpPath)
+
+	// Keep the previous version before replacing it
+	previous, err := os.ReadFile(filePath)
+	if err == nil {
+		bakTmpPath, err := r.writeTemp(name+VaultExtension, previous)
+		if err != nil {
+			return fmt.Errorf("failed to write vault backup: %w", err)
+		}
+		if err := os.Rename(bakTmpPath, filePath+BackupExtension); err != nil {
+			os.Remove(bakTmpPath)
+			return fmt.Errorf("failed to write vault backup: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read vault file: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, filePath); err != nil {
+		return fmt.Errorf("failed to replace vault file: %w", err)
+	}
+
+	// Make the rename itself durable
+	if err := syncDir(r.vaultDir); err != nil {
+		return fmt.Errorf("failed to sync vault directory: %w", err)
+	}
 
 	return nil
 }
 
+// writeTemp writes data to a new synced temp file next to the file named
+// base and returns its path. The temp file is removed if anything goes wrong.
+func (r *FileRepository) writeTemp(base string, data []byte) (string, error) {
+	f, err := os.CreateTemp(r.vaultDir, "."+base+".tmp-*")
+	if err != nil {
+		return "", err
+	}
+	tmpPath := f.Name()
+
+	if err := r.writeFile(f, data); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+
+	return tmpPath, nil
+}
+
+// writeAll writes data to f, failing on short writes
+func writeAll(f *os.File, data []byte) error {
+	_, err := f.Write(data)
+	return err
+}
+
+// syncDir flushes directory entries so renames survive a crash
+func syncDir(dir string) error {
+	// Directories cannot be opened for syncing on Windows
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
 // Load retrieves vault metadata from disk
 func (r *FileRepository) Load(ctx context.Context, name string) (*domain.VaultMetadata, error) {
+	if err := domain.ValidateVaultName(name); err != nil {
+		return nil, err
+	}
+
 	filePath := r.getVaultPath(name)
 
 	data, err := os.ReadFile(filePath)
@@ -75,6 +275,10 @@ func (r *FileRepository) Load(ctx context.Context, name string) (*domain.VaultMe
 
 // Exists checks if a vault exists
 func (r *FileRepository) Exists(ctx context.Context, name string) (bool, error) {
+	if err := domain.ValidateVaultName(name); err != nil {
+		return false, err
+	}
+
 	filePath := r.getVaultPath(name)
 	_, err := os.Stat(filePath)
 	if err != nil {
@@ -97,6 +301,10 @@ func (r *FileRepository) List(ctx context.Context) ([]string, error) {
 	for _, entry := range entries {
 		if !entry.IsDir() && strings.HasSuffix(entry.Name(), VaultExtension) {
 			name := strings.TrimSuffix(entry.Name(), VaultExtension)
+			// Skip files that could not have been created through Save
+			if domain.ValidateVaultName(name) != nil {
+				continue
+			}
 			vaults = append(vaults, name)
 		}
 	}
@@ -104,7 +312,13 @@ func (r *FileRepository) List(ctx context.Context) ([]string, error) {
 	return vaults, nil
 }
 
-// getVaultPath constructs the full path to a vault file
+// getVaultPath constructs the full path to a vault file.
+// name must already have passed domain.ValidateVaultName.
 func (r *FileRepository) getVaultPath(name string) string {
 	return filepath.Join(r.vaultDir, name+VaultExtension)
 }
+
+// getLockPath constructs the path of the lock file guarding a vault
+func (r *FileRepository) getLockPath(name string) string {
+	return filepath.Join(r.vaultDir, "."+name+VaultExtension+LockExtension)
+}
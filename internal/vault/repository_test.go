@@ -1,10 +1,15 @@
 package vault
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/orlan/go-password-manager/internal/domain"
@@ -229,6 +234,97 @@ func TestSave(t *testing.T) {
 		}
 	})
 
+	t.Run("keeps previous version as backup", func(t *testing.T) {
+		tempDir := t.TempDir()
+		repo, _ := NewFileRepository(tempDir)
+		ctx := context.Background()
+
+		metadata1 := &domain.VaultMetadata{Version: "1.0", Encrypted: []byte{1}}
+		metadata2 := &domain.VaultMetadata{Version: "2.0", Encrypted: []byte{2}}
+
+		if err := repo.Save(ctx, "test-vault", metadata1); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+
+		backupPath := filepath.Join(tempDir, "test-vault"+VaultExtension+BackupExtension)
+		if _, err := os.Stat(backupPath); !os.IsNotExist(err) {
+			t.Error("backup should not exist after first save")
+		}
+
+		if err := repo.Save(ctx, "test-vault", metadata2); err != nil {
+			t.Fatalf("Save() failed on overwrite: %v", err)
+		}
+
+		data, err := os.ReadFile(backupPath)
+		if err != nil {
+			t.Fatalf("backup file was not created: %v", err)
+		}
+		var backup domain.VaultMetadata
+		if err := json.Unmarshal(data, &backup); err != nil {
+			t.Fatalf("backup is not valid JSON: %v", err)
+		}
+		if backup.Version != "1.0" {
+			t.Errorf("expected backup version %q, got %q", "1.0", backup.Version)
+		}
+	})
+
+	t.Run("leaves existing vault intact when write fails", func(t *testing.T) {
+		tempDir := t.TempDir()
+		repo, _ := NewFileRepository(tempDir)
+		ctx := context.Background()
+
+		original := &domain.VaultMetadata{
+			Version:   "1.0",
+			Salt:      []byte{1, 2, 3, 4},
+			Nonce:     []byte{5, 6, 7, 8},
+			Encrypted: []byte{9, 10, 11, 12},
+		}
+		if err := repo.Save(ctx, "test-vault", original); err != nil {
+			t.Fatalf("Save() failed: %v", err)
+		}
+		before, _ := os.ReadFile(filepath.Join(tempDir, "test-vault"+VaultExtension))
+
+		// Simulate the disk filling up halfway through the write
+		repo.writeFile = func(f *os.File, data []byte) error {
+			f.Write(data[:len(data)/2])
+			return errors.New("no space left on device")
+		}
+
+		updated := &domain.VaultMetadata{
+			Version:   "2.0",
+			Salt:      []byte{13, 14, 15, 16},
+			Nonce:     []byte{17, 18, 19, 20},
+			Encrypted: []byte{21, 22, 23, 24},
+		}
+		if err := repo.Save(ctx, "test-vault", updated); err == nil {
+			t.Fatal("Save() should fail when the write fails")
+		}
+
+		after, err := os.ReadFile(filepath.Join(tempDir, "test-vault"+VaultExtension))
+		if err != nil {
+			t.Fatalf("vault file is missing after failed write: %v", err)
+		}
+		if !bytes.Equal(before, after) {
+			t.Error("vault file was modified by failed write")
+		}
+
+		// No temp files should be left behind
+		entries, _ := os.ReadDir(tempDir)
+		for _, entry := range entries {
+			if strings.Contains(entry.Name(), ".tmp-") {
+				t.Errorf("temp file left in vault directory: %s", entry.Name())
+			}
+		}
+
+		loaded, err := repo.Load(ctx, "test-vault")
+		if err != nil {
+			t.Fatalf("Load() failed: %v", err)
+		}
+		if loaded.Version != "1.0" {
+			t.Errorf("expected version %q, got %q", "1.0", loaded.Version)
+		}
+	})
+
 	t.Run("saves vault with special characters in name", func(t *testing.T) {
 		tempDir := t.TempDir()
 		repo, _ := NewFileRepository(tempDir)
@@ -255,6 +351,200 @@ func TestSave(t *testing.T) {
 	})
 }
 
+func TestCreate(t *testing.T) {
+	t.Run("creates a new vault", func(t *testing.T) {
+		tempDir := t.TempDir()
+		repo, _ := NewFileRepository(tempDir)
+		ctx := context.Background()
+
+		if err := repo.Create(ctx, "test-vault", &domain.VaultMetadata{Version: "1.0", Encrypted: []byte{1}}); err != nil {
+			t.Fatalf("Create() failed: %v", err)
+		}
+		loaded, err := repo.Load(ctx, "test-vault")
+		if err != nil || !bytes.Equal(loaded.Encrypted, []byte{1}) {
+			t.Errorf("unexpected vault %+v, %v", loaded, err)
+		}
+
+		entries, _ := os.ReadDir(tempDir)
+		for _, entry := range entries {
+			if strings.Contains(entry.Name(), ".tmp-") {
+				t.Errorf("temp file %s was left behind", entry.Name())
+			}
+		}
+	})
+
+	t.Run("leaves an existing vault alone", func(t *testing.T) {
+		tempDir := t.TempDir()
+		repo, _ := NewFileRepository(tempDir)
+		ctx := context.Background()
+
+		repo.Save(ctx, "test-vault", &domain.VaultMetadata{Version: "1.0", Encrypted: []byte{1}})
+		if err := repo.Create(ctx, "test-vault", &domain.VaultMetadata{Version: "1.0", Encrypted: []byte{2}}); err != domain.ErrVaultAlreadyExists {
+			t.Errorf("expected ErrVaultAlreadyExists, got %v", err)
+		}
+		if loaded, _ := repo.Load(ctx, "test-vault"); !bytes.Equal(loaded.Encrypted, []byte{1}) {
+			t.Error("the existing vault was overwritten")
+		}
+	})
+
+	t.Run("only one concurrent create succeeds", func(t *testing.T) {
+		tempDir := t.TempDir()
+		ctx := context.Background()
+
+		var wg sync.WaitGroup
+		errs := make([]error, 10)
+		for i := range errs {
+			// Separate repositories, as in separate processes
+			repo, _ := NewFileRepository(tempDir)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = repo.Create(ctx, "test-vault", &domain.VaultMetadata{Version: "1.0", Encrypted: []byte{byte(i)}})
+			}()
+		}
+		wg.Wait()
+
+		created := 0
+		for _, err := range errs {
+			switch err {
+			case nil:
+				created++
+			case domain.ErrVaultAlreadyExists:
+			default:
+				t.Errorf("Create() failed: %v", err)
+			}
+		}
+		if created != 1 {
+			t.Errorf("expected exactly one create to succeed, got %d", created)
+		}
+	})
+}
+
+func TestSaveIfUnchanged(t *testing.T) {
+	t.Run("saves and increments revision", func(t *testing.T) {
+		tempDir := t.TempDir()
+		repo, _ := NewFileRepository(tempDir)
+		ctx := context.Background()
+
+		repo.Save(ctx, "test-vault", &domain.VaultMetadata{Version: "1.0"})
+
+		metadata := &domain.VaultMetadata{Version: "1.0", Encrypted: []byte{1}}
+		if err := repo.SaveIfUnchanged(ctx, "test-vault", metadata, 0); err != nil {
+			t.Fatalf("SaveIfUnchanged() failed: %v", err)
+		}
+		if metadata.Revision != 1 {
+			t.Errorf("expected revision 1, got %d", metadata.Revision)
+		}
+
+		loaded, _ := repo.Load(ctx, "test-vault")
+		if loaded.Revision != 1 {
+			t.Errorf("expected stored revision 1, got %d", loaded.Revision)
+		}
+	})
+
+	t.Run("returns ErrVaultModified for stale revision", func(t *testing.T) {
+		tempDir := t.TempDir()
+		repo, _ := NewFileRepository(tempDir)
+		ctx := context.Background()
+
+		repo.Save(ctx, "test-vault", &domain.VaultMetadata{Version: "1.0"})
+		repo.SaveIfUnchanged(ctx, "test-vault", &domain.VaultMetadata{Version: "1.0", Encrypted: []byte{1}}, 0)
+
+		// A second writer still holding revision 0
+		stale := &domain.VaultMetadata{Version: "1.0", Encrypted: []byte{2}}
+		err := repo.SaveIfUnchanged(ctx, "test-vault", stale, 0)
+		if err != domain.ErrVaultModified {
+			t.Errorf("expected ErrVaultModified, got %v", err)
+		}
+
+		loaded, _ := repo.Load(ctx, "test-vault")
+		if !bytes.Equal(loaded.Encrypted, []byte{1}) {
+			t.Error("stale save overwrote newer vault contents")
+		}
+	})
+
+	t.Run("returns ErrVaultNotFound for missing vault", func(t *testing.T) {
+		tempDir := t.TempDir()
+		repo, _ := NewFileRepository(tempDir)
+
+		err := repo.SaveIfUnchanged(context.Background(), "missing", &domain.VaultMetadata{}, 0)
+		if err != domain.ErrVaultNotFound {
+			t.Errorf("expected ErrVaultNotFound, got %v", err)
+		}
+	})
+
+	t.Run("serializes writers sharing a directory", func(t *testing.T) {
+		tempDir := t.TempDir()
+		ctx := context.Background()
+
+		// Separate repositories stand in for separate processes
+		repo1, _ := NewFileRepository(tempDir)
+		repo2, _ := NewFileRepository(tempDir)
+		repo1.Save(ctx, "test-vault", &domain.VaultMetadata{Version: "1.0"})
+
+		var wg sync.WaitGroup
+		for _, repo := range []*FileRepository{repo1, repo2} {
+			wg.Add(1)
+			go func(repo *FileRepository) {
+				defer wg.Done()
+				for i := 0; i < 20; {
+					current, err := repo.Load(ctx, "test-vault")
+					if err != nil {
+						t.Errorf("Load() failed: %v", err)
+						return
+					}
+					err = repo.SaveIfUnchanged(ctx, "test-vault", current, current.Revision)
+					if err == domain.ErrVaultModified {
+						continue
+					}
+					if err != nil {
+						t.Errorf("SaveIfUnchanged() failed: %v", err)
+						return
+					}
+					i++
+				}
+			}(repo)
+		}
+		wg.Wait()
+
+		loaded, _ := repo1.Load(ctx, "test-vault")
+		if loaded.Revision != 40 {
+			t.Errorf("expected revision 40 after 40 saves, got %d", loaded.Revision)
+		}
+	})
+}
+
+func TestRemoveBackup(t *testing.T) {
+	tempDir := t.TempDir()
+	repo, _ := NewFileRepository(tempDir)
+	ctx := context.Background()
+
+	if err := repo.RemoveBackup(ctx, "test-vault"); err != nil {
+		t.Errorf("RemoveBackup() without a backup failed: %v", err)
+	}
+
+	repo.Save(ctx, "test-vault", &domain.VaultMetadata{Version: "1.0", Encrypted: []byte{1}})
+	repo.Save(ctx, "test-vault", &domain.VaultMetadata{Version: "2.0", Encrypted: []byte{2}})
+	backupPath := filepath.Join(tempDir, "test-vault"+VaultExtension+BackupExtension)
+	if _, err := os.Stat(backupPath); err != nil {
+		t.Fatalf("expected a backup: %v", err)
+	}
+
+	if err := repo.RemoveBackup(ctx, "test-vault"); err != nil {
+		t.Fatalf("RemoveBackup() failed: %v", err)
+	}
+	if _, err := os.Stat(backupPath); !os.IsNotExist(err) {
+		t.Errorf("expected the backup to be removed, got %v", err)
+	}
+	if loaded, err := repo.Load(ctx, "test-vault"); err != nil || loaded.Version != "2.0" {
+		t.Errorf("the vault itself changed: %+v, %v", loaded, err)
+	}
+
+	if err := repo.RemoveBackup(ctx, "../escape"); err == nil {
+		t.Error("expected an error for an invalid vault name")
+	}
+}
+
 func TestLoad(t *testing.T) {
 	t.Run("loads vault metadata successfully", func(t *testing.T) {
 		tempDir := t.TempDir()
@@ -578,6 +868,63 @@ func TestList(t *testing.T) {
 	})
 }
 
+func TestVaultNameValidation(t *testing.T) {
+	invalidNames := []string{
+		"",
+		"../escape",
+		"../../etc/passwd",
+		"nested/vault",
+		`nested\vault`,
+		".hidden",
+		"..",
+		"vault.",
+		"my..vault",
+		"-vault",
+		"vault name",
+		"CON",
+		"nul.txt",
+		"lpt1",
+		strings.Repeat("a", 65),
+	}
+
+	for _, name := range invalidNames {
+		t.Run(fmt.Sprintf("rejects %q", name), func(t *testing.T) {
+			parent := t.TempDir()
+			vaultDir := filepath.Join(parent, "vaults")
+			repo, _ := NewFileRepository(vaultDir)
+			ctx := context.Background()
+
+			err := repo.Save(ctx, name, &domain.VaultMetadata{Version: "1.0"})
+			if err != domain.ErrInvalidVaultName {
+				t.Errorf("Save() expected ErrInvalidVaultName, got %v", err)
+			}
+			if _, err := repo.Load(ctx, name); err != domain.ErrInvalidVaultName {
+				t.Errorf("Load() expected ErrInvalidVaultName, got %v", err)
+			}
+			if _, err := repo.Exists(ctx, name); err != domain.ErrInvalidVaultName {
+				t.Errorf("Exists() expected ErrInvalidVaultName, got %v", err)
+			}
+
+			// Nothing may be written outside the vault directory
+			entries, _ := os.ReadDir(parent)
+			if len(entries) != 1 {
+				t.Errorf("unexpected files created next to vault directory: %v", entries)
+			}
+		})
+	}
+
+	t.Run("accepts valid names", func(t *testing.T) {
+		repo, _ := NewFileRepository(t.TempDir())
+		ctx := context.Background()
+
+		for _, name := range []string{"personal", "my-vault_2024", "work.v2", "A", strings.Repeat("a", 64)} {
+			if err := repo.Save(ctx, name, &domain.VaultMetadata{Version: "1.0"}); err != nil {
+				t.Errorf("Save(%q) failed: %v", name, err)
+			}
+		}
+	})
+}
+
 func TestGetVaultPath(t *testing.T) {
 	t.Run("constructs correct path", func(t *testing.T) {
 		tempDir := t.TempDir()